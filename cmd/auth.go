@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"terafetch/downloader"
+	"terafetch/internal"
+)
+
+// keyEnvVar holds the passphrase of the session store, for scripts and
+// services that cannot answer a prompt
+const keyEnvVar = "TERAFETCH_KEY"
+
+// sessionPassphrase is the passphrase the stored session was unlocked with,
+// so it is asked for at most once per run
+var sessionPassphrase string
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the stored Terabox session",
+	Long: `Manage the Terabox session stored in the encrypted session store.
+
+'terafetch auth login' imports cookies once, from a cookie file, a browser
+or a Cookie header, and encrypts them under $XDG_CONFIG_HOME/terafetch with a
+key derived from a passphrase. Later runs use the stored session whenever no
+cookies are given, asking for the passphrase or reading it from TERAFETCH_KEY.`,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Import cookies into the encrypted session store",
+	Long: `Import cookies into the encrypted session store, replacing the session
+stored there. The cookies must hold a valid session, which is checked with
+Terabox's account API when it can be reached.
+
+Examples:
+  terafetch auth login -c cookies.txt
+  terafetch auth login --cookies-from-browser firefox
+  TERAFETCH_KEY=... terafetch auth login --cookie "BDUSS=...; STOKEN=..."`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cookies := currentCookies()
+		if !cookies.set() {
+			return fmt.Errorf("no cookies to import, give them with --cookies, --cookies-from-browser or --cookie")
+		}
+		store, err := downloader.DefaultSessionStore()
+		if err != nil {
+			return err
+		}
+
+		authManager := downloader.NewCookieAuthManager()
+		defer authManager.Cleanup()
+		authContext, err := loadCookies(authManager, cookies, quiet)
+		if err != nil {
+			return err
+		}
+		if err := authManager.ValidateSession(authContext); err != nil {
+			return fmt.Errorf("the cookies from %s do not hold a valid session: %w", cookies, err)
+		}
+		if err := checkSessionOnline(context.Background(), newHTTPClient(proxyURL), authManager, authContext); errors.Is(err, downloader.ErrSessionRevoked) {
+			return fmt.Errorf("the cookies from %s do not hold a valid session: %w", cookies, err)
+		} else if err != nil {
+			internal.LogWarn("Could not check the session online: %v", err)
+			fmt.Printf("⚠️  Could not check the session online: %v\n", err)
+		}
+
+		passphrase, err := newPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := authManager.StoreSession(store, passphrase); err != nil {
+			return err
+		}
+
+		internal.LogInfo("Session stored in %s", store.Path())
+		fmt.Printf("🔐 Session stored in %s\n", store.Path())
+		if account := describeAccount(authContext); account != "" {
+			fmt.Printf("   Account: %s\n", account)
+		}
+		printSessionExpiry(authContext)
+		return nil
+	},
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the stored session, its account and whether it is live",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := downloader.DefaultSessionStore()
+		if err != nil {
+			return err
+		}
+		savedAt, err := store.SavedAt()
+		if errors.Is(err, downloader.ErrNoStoredSession) {
+			return fmt.Errorf("no stored session, import one with 'terafetch auth login'")
+		}
+		if err != nil {
+			return err
+		}
+
+		passphrase, err := storedSessionPassphrase()
+		if err != nil {
+			return err
+		}
+		authManager := downloader.NewCookieAuthManager()
+		defer authManager.Cleanup()
+		authContext, err := authManager.LoadStoredSession(store, passphrase)
+		if err != nil {
+			return err
+		}
+
+		// Only a session that is still valid locally is worth checking online
+		validErr := authManager.ValidateSession(authContext)
+		var onlineErr error
+		if validErr == nil {
+			onlineErr = checkSessionOnline(context.Background(), newHTTPClient(proxyURL), authManager, authContext)
+		}
+
+		names := make([]string, 0, len(authContext.Cookies))
+		for name := range authContext.Cookies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Printf("🔐 Session store: %s\n", store.Path())
+		fmt.Printf("   Stored:  %s\n", savedAt.Format(time.RFC3339))
+		if account := describeAccount(authContext); account != "" {
+			fmt.Printf("   Account: %s\n", account)
+		}
+		fmt.Printf("   Session: BDUSS %s\n", maskSecret(authContext.BDUSS))
+		fmt.Printf("   Cookies: %s\n", strings.Join(names, ", "))
+		printSessionExpiry(authContext)
+		switch {
+		case validErr != nil:
+			fmt.Printf("⚠️  Session is not valid: %v\n", validErr)
+		case errors.Is(onlineErr, downloader.ErrSessionRevoked):
+			fmt.Printf("⚠️  Session is not valid: %v\n", onlineErr)
+		case onlineErr != nil:
+			internal.LogWarn("Could not check the session online: %v", onlineErr)
+			fmt.Printf("✅ Session is valid, but could not be checked online: %v\n", onlineErr)
+		default:
+			fmt.Printf("✅ Session is live\n")
+		}
+		return nil
+	},
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Wipe the stored session",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := downloader.DefaultSessionStore()
+		if err != nil {
+			return err
+		}
+		if err := store.Wipe(); errors.Is(err, downloader.ErrNoStoredSession) {
+			fmt.Printf("No stored session to remove\n")
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		internal.LogInfo("Stored session wiped from %s", store.Path())
+		fmt.Printf("🗑️  Stored session removed\n")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd, authStatusCmd, authLogoutCmd)
+
+	authCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress the disclaimer")
+	authLoginCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to cookie file: Netscape format, JSON export or Cookie header")
+	authLoginCmd.Flags().StringVar(&cookiesFromBrowser, "cookies-from-browser", "", cookiesFromBrowserUsage)
+	authLoginCmd.Flags().StringVar(&cookieHeader, "cookie", "", cookieHeaderUsage)
+	authLoginCmd.MarkFlagsMutuallyExclusive("cookies", "cookies-from-browser", "cookie")
+}
+
+// loadStoredSession loads the session stored with 'terafetch auth login', if
+// there is one. When no passphrase can be had the run goes on without it.
+func loadStoredSession(authManager *downloader.CookieAuthManager, quiet bool) (*internal.AuthContext, error) {
+	store, err := downloader.DefaultSessionStore()
+	if err != nil || !store.Exists() {
+		return nil, nil
+	}
+
+	passphrase, err := storedSessionPassphrase()
+	if err != nil {
+		internal.LogWarn("Not using the stored session: %v", err)
+		if !quiet {
+			fmt.Printf("⚠️  Not using the stored session: %v\n", err)
+		}
+		return nil, nil
+	}
+
+	internal.LogInfo("Loading authentication from the stored session: %s", store.Path())
+	authContext, err := authManager.LoadStoredSession(store, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the stored session: %w", err)
+	}
+	if !quiet {
+		fmt.Printf("🔐 Using the stored session\n")
+	}
+	return authContext, nil
+}
+
+// storedSessionPassphrase returns the passphrase of the session store from
+// TERAFETCH_KEY, or else asks for it on the terminal
+func storedSessionPassphrase() (string, error) {
+	if sessionPassphrase != "" {
+		return sessionPassphrase, nil
+	}
+	if key := os.Getenv(keyEnvVar); key != "" {
+		return key, nil
+	}
+
+	passphrase, err := readPassphrase("Session passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	sessionPassphrase = passphrase
+	return passphrase, nil
+}
+
+// newPassphrase returns the passphrase to encrypt a new session with, from
+// TERAFETCH_KEY or typed twice on the terminal
+func newPassphrase() (string, error) {
+	if key := os.Getenv(keyEnvVar); key != "" {
+		return key, nil
+	}
+
+	passphrase, err := readPassphrase("New session passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	repeated, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if repeated != passphrase {
+		return "", fmt.Errorf("the passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// readPassphrase prompts for a passphrase on stderr and reads it from the
+// terminal without echoing it
+func readPassphrase(prompt string) (string, error) {
+	restore, err := disableEcho(int(os.Stdin.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the passphrase, set %s", keyEnvVar)
+	}
+	defer restore()
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the passphrase: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// printSessionExpiry prints when the session expires, following its BDUSS cookie
+func printSessionExpiry(authContext *internal.AuthContext) {
+	expires := authContext.ExpiresAt.Format(time.RFC3339)
+	switch left := time.Until(authContext.ExpiresAt); {
+	case left <= 0:
+		fmt.Printf("   Expires: %s (expired)\n", expires)
+	case left < 48*time.Hour:
+		fmt.Printf("   Expires: %s (in %d hours)\n", expires, int(left.Hours()))
+	default:
+		fmt.Printf("   Expires: %s (in %d days)\n", expires, int(left.Hours()/24))
+	}
+}
+
+// maskSecret shows the start and end of a secret, enough to tell sessions apart
+func maskSecret(secret string) string {
+	if len(secret) < 12 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + "…" + secret[len(secret)-4:]
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"terafetch/downloader"
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+// batchResult records the outcome of a single URL in a batch download
+type batchResult struct {
+	URL      string
+	Path     string
+	Size     int64
+	Duration time.Duration
+	Err      error
+}
+
+// batchQueue holds the state shared by every download in a batch: one HTTP
+// client, one rate limiter and one auth context for the whole queue
+type batchQueue struct {
+	client    *utils.HTTPClient
+	resolver  *downloader.TeraboxResolver
+	limiter   internal.RateLimiter
+	auth      *internal.AuthContext
+	outputDir string
+	threads   int
+	proxyURL  string
+	quiet     bool
+
+	// reserved tracks output paths already claimed by earlier queue entries
+	reserved map[string]bool
+	mutex    sync.Mutex
+}
+
+// loadURLList reads the URL list from path, or from stdin when path is "-"
+func loadURLList(path string) ([]string, error) {
+	if path == "-" {
+		return utils.ReadURLList(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	return utils.ReadURLList(file)
+}
+
+// executeBatchWorkflow downloads every URL in urls as a single queue, running up to
+// concurrentFiles downloads at once, and prints a per-URL summary table at the end
+func executeBatchWorkflow(urls []string, outputDir string, threads int, rateLimitBytes int64, cookies cookieSource, proxyURL string, quiet bool, concurrentFiles int) error {
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal handling for graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			internal.LogInfo("Received signal %v, stopping batch download...", sig)
+			if !quiet {
+				fmt.Printf("\n🛑 Received %v signal, stopping batch download...\n", sig)
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Shared components for the whole queue
+	client := newHTTPClient(proxyURL)
+
+	authContext, err := loadAuthContext(ctx, client, downloader.NewCookieAuthManager(), cookies, quiet)
+	if err != nil {
+		return err
+	}
+
+	queue := &batchQueue{
+		client:    client,
+		resolver:  newResolver(client),
+		auth:      authContext,
+		outputDir: outputDir,
+		threads:   threads,
+		proxyURL:  proxyURL,
+		quiet:     quiet,
+		reserved:  make(map[string]bool),
+	}
+	attachEvents(queue.resolver, nil)
+	if limiter := scheduledRateLimiter(ctx, rateLimitBytes); limiter != nil {
+		queue.limiter = limiter
+	} else if rateLimitBytes > 0 {
+		queue.limiter = utils.NewTokenBucketLimiter(rateLimitBytes)
+	}
+
+	if concurrentFiles > len(urls) {
+		concurrentFiles = len(urls)
+	}
+
+	internal.LogInfo("Starting batch download: %d URLs, %d concurrent files", len(urls), concurrentFiles)
+	if !quiet {
+		fmt.Printf("📋 Queued %d URLs (%d at a time)\n\n", len(urls), concurrentFiles)
+	}
+
+	// Process the queue with a fixed number of file workers
+	results := make([]batchResult, len(urls))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrentFiles; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = queue.download(ctx, urls[i], i+1, len(urls), concurrentFiles > 1)
+			}
+		}()
+	}
+
+	for i, url := range urls {
+		if ctx.Err() != nil {
+			results[i] = batchResult{URL: url, Err: fmt.Errorf("cancelled by user")}
+			events.EmitError(url, "", results[i].Err)
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Summarize
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	if events == nil {
+		printBatchSummary(results)
+	}
+
+	if failed > 0 {
+		internal.LogError("Batch download finished with %d of %d URLs failed", failed, len(urls))
+		return reportedError{fmt.Errorf("%d of %d URLs failed to download", failed, len(urls))}
+	}
+
+	internal.LogInfo("Batch download completed: %d URLs", len(urls))
+	return nil
+}
+
+// download resolves and downloads a single queue entry. Progress bars are
+// suppressed when several files download at once so their output doesn't interleave.
+func (q *batchQueue) download(ctx context.Context, url string, position, total int, concurrent bool) (result batchResult) {
+	result.URL = url
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		events.EmitError(url, result.Path, result.Err)
+	}()
+
+	urlInfo, err := newURLValidator().ParseURL(url)
+	if err != nil {
+		result.Err = fmt.Errorf("invalid URL: %w", err)
+		internal.LogError("Skipping invalid URL %s: %v", url, err)
+		return result
+	}
+
+	// A pwd= parameter on the entry itself wins over the --password flag
+	sharePassword := password
+	if urlInfo.Password != "" {
+		sharePassword = urlInfo.Password
+	}
+
+	if !q.quiet {
+		fmt.Printf("🔍 [%d/%d] Resolving %s\n", position, total, url)
+	}
+
+	// Resolution messages would interleave between concurrent files
+	meta, access, err := resolveFileMetadata(ctx, q.resolver, url, q.auth, sharePassword, q.quiet || concurrent)
+	if err != nil {
+		result.Err = err
+		if !q.quiet {
+			fmt.Printf("❌ [%d/%d] %s: %v\n", position, total, url, err)
+		}
+		return result
+	}
+
+	result.Size = meta.Size
+	result.Path = q.reservePath(meta.Filename)
+
+	if !q.quiet {
+		fmt.Printf("🚀 [%d/%d] %s (%s)\n", position, total, result.Path, formatFileSize(meta.Size))
+	}
+
+	engine := downloader.NewMultiThreadEngineWithClient(q.client)
+	attachEvents(nil, engine)
+	engine.SetLinkRefresher(linkRefresher(q.resolver, url, q.auth, sharePassword))
+	if q.limiter != nil {
+		engine.SetRateLimiter(q.limiter)
+	}
+
+	downloadConfig := &internal.DownloadConfig{
+		OutputPath: result.Path,
+		Threads:    q.threads,
+		ProxyURL:   q.proxyURL,
+		Quiet:      q.quiet || concurrent,
+		SkipVerify: skipVerify(),
+		Source:     resumeSource(url, access, result.Path, q.threads),
+	}
+
+	if err := engine.Download(ctx, meta, downloadConfig); err != nil {
+		result.Err = err
+		internal.LogError("Download of %s failed: %v", url, err)
+		if !q.quiet {
+			fmt.Printf("❌ [%d/%d] %s: %v\n", position, total, result.Path, err)
+		}
+		return result
+	}
+
+	internal.LogInfo("Download completed successfully: %s", result.Path)
+	if !q.quiet {
+		fmt.Printf("✅ [%d/%d] %s\n", position, total, result.Path)
+	}
+	return result
+}
+
+// reservePath picks the output path for filename, adding a numeric suffix when an
+// earlier entry in the queue already claimed the same name
+func (q *batchQueue) reservePath(filename string) string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// Remote names must not steer the file outside the output directory
+	filename = filepath.Base(filename)
+	if filename == "." || filename == ".." || filename == string(filepath.Separator) {
+		filename = "download"
+	}
+
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+
+	candidate := filepath.Join(q.outputDir, filename)
+	for n := 1; q.reserved[candidate]; n++ {
+		candidate = filepath.Join(q.outputDir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+	}
+
+	q.reserved[candidate] = true
+	return candidate
+}
+
+// printBatchSummary prints the per-URL success/failure table
+func printBatchSummary(results []batchResult) {
+	succeeded := 0
+	for _, result := range results {
+		if result.Err == nil {
+			succeeded++
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("📊 Batch summary: %d succeeded, %d failed\n", succeeded, len(results)-succeeded)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tURL\tFILE\tSIZE\tTIME\tERROR")
+	for _, result := range results {
+		status, size, errMsg := "OK", formatFileSize(result.Size), "-"
+		if result.Err != nil {
+			status, errMsg = "FAILED", summarizeError(result.Err)
+		}
+		file := result.Path
+		if file == "" {
+			file, size = "-", "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", status, result.URL, file, size,
+			result.Duration.Round(time.Second), errMsg)
+	}
+	w.Flush()
+}
+
+// summarizeError shortens an error to a single table-friendly line; the full
+// error is already in the log
+func summarizeError(err error) string {
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	msg = strings.TrimSuffix(strings.TrimSpace(msg), ":")
+	if runes := []rune(msg); len(runes) > 80 {
+		msg = string(runes[:77]) + "..."
+	}
+	return msg
+}
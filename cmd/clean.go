@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"terafetch/downloader"
+	"terafetch/internal"
+)
+
+var (
+	cleanOlderThan string
+	cleanForce     bool
+	cleanDryRun    bool
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean [DIR]",
+	Short: "Remove stale partial downloads",
+	Long: `Find partial downloads under a directory (the current one by default) that
+can no longer be resumed and report how much space they take up:
+
+  orphaned metadata   a .terafetch.json file whose .part file is gone
+  no metadata         a .part file without its .terafetch.json file, last
+                      written longer ago than --older-than
+  corrupt metadata    a .terafetch.json file that cannot be read
+  expired             a download last checkpointed longer ago than --older-than
+
+Nothing is deleted unless --force is given. Resume files written by a newer
+version of TeraFetch are left alone.
+
+Examples:
+  terafetch clean --dry-run ./downloads
+  terafetch clean --older-than 3d --force ./downloads
+  terafetch clean --older-than 0 --force`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		maxAge, err := parseAge(cleanOlderThan)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than value: %v", err)
+		}
+
+		return executeCleanWorkflow(dir, maxAge, cleanForce && !cleanDryRun)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().StringVar(&cleanOlderThan, "older-than", "7d", "Treat downloads last checkpointed, and .part files without metadata last written, longer ago than this as stale (e.g. 12h, 3d); 0 keeps them at any age")
+	cleanCmd.Flags().BoolVarP(&cleanForce, "force", "f", false, "Delete the stale files instead of only reporting them")
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "Only show what would be deleted")
+	cleanCmd.MarkFlagsMutuallyExclusive("force", "dry-run")
+}
+
+// executeCleanWorkflow reports the stale partial downloads under dir and
+// deletes them when remove is set
+func executeCleanWorkflow(dir string, maxAge time.Duration, remove bool) error {
+	stale, err := downloader.NewDownloadPlanner().FindStaleDownloads(dir, maxAge)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Printf("✨ No stale partial downloads found in %s\n", dir)
+		return nil
+	}
+
+	var reclaimable int64
+	for _, download := range stale {
+		reclaimable += download.Size
+	}
+
+	printStaleDownloads(stale)
+	fmt.Println()
+
+	if !remove {
+		fmt.Printf("🧹 %s reclaimable in %d stale downloads\n", formatFileSize(reclaimable), len(stale))
+		if !cleanDryRun {
+			fmt.Printf("   Run again with --force to delete them.\n")
+		}
+		return nil
+	}
+
+	var freed int64
+	failed := 0
+	for _, download := range stale {
+		if err := download.Remove(); err != nil {
+			failed++
+			internal.LogError("Failed to remove %s: %v", download.OutputPath, err)
+			fmt.Printf("❌ %s: %v\n", download.OutputPath, err)
+			continue
+		}
+		internal.LogInfo("Removed stale download %s (%s)", download.OutputPath, download.Reason)
+		freed += download.Size
+	}
+
+	fmt.Printf("🧹 Removed %d stale downloads, freed %s\n", len(stale)-failed, formatFileSize(freed))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d stale downloads could not be removed", failed, len(stale))
+	}
+	return nil
+}
+
+// printStaleDownloads prints the stale downloads found by 'terafetch clean'
+func printStaleDownloads(stale []*downloader.StaleDownload) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REASON\tFILE\tSIZE\tAGE\tFILES")
+	for _, download := range stale {
+		files := make([]string, len(download.Files))
+		for i, path := range download.Files {
+			files[i] = strings.TrimPrefix(path, download.OutputPath)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", download.Reason, download.OutputPath,
+			formatFileSize(download.Size), formatAge(download.Age), strings.Join(files, ", "))
+	}
+	w.Flush()
+}
+
+// parseAge parses an age such as 90m, 12h or 3d. Go durations are accepted
+// as well as whole days with a d suffix.
+func parseAge(value string) (time.Duration, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("expected a whole number of days, got %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if age < 0 {
+		return 0, fmt.Errorf("age cannot be negative, got %q", value)
+	}
+	return age, nil
+}
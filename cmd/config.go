@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"terafetch/internal"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+	Long: `Inspect the configuration TeraFetch runs with.
+
+Settings are read from $XDG_CONFIG_HOME/terafetch/config.toml (or config.yaml,
+~/.config/terafetch when XDG_CONFIG_HOME is not set), or from the file given
+with --config. Top-level settings apply to every run, and --profile applies a
+named [profiles.NAME] section on top of them. Environment variables override
+the config file, and command line flags override everything.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration and where each value came from",
+	Long: `Print every setting with its effective value and its source: the default,
+the config file, a profile, an environment variable or a flag.
+
+Examples:
+  terafetch config show
+  terafetch config show --profile work
+  terafetch config show --json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if events != nil {
+			return json.NewEncoder(os.Stdout).Encode(struct {
+				File     string             `json:"file,omitempty"`
+				Profile  string             `json:"profile,omitempty"`
+				Settings []internal.Setting `json:"settings"`
+			}{config.File, config.Profile, config.Settings()})
+		}
+		printConfig(os.Stdout, config)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	// The flags that override settings, so their effect can be previewed
+	configShowCmd.Flags().IntVarP(&threads, "threads", "t", internal.DefaultConfig().DefaultThreads, "Number of download threads (1-32)")
+	configShowCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to cookie file: Netscape format, JSON export or Cookie header")
+	configShowCmd.Flags().StringVar(&cookiesFromBrowser, "cookies-from-browser", "", "Browser profile to read cookies from")
+	configShowCmd.MarkFlagsMutuallyExclusive("cookies", "cookies-from-browser")
+	configShowCmd.Flags().StringVarP(&rateLimit, "limit-rate", "r", "", "Bandwidth limit (e.g., 5M for 5MB/s)")
+	configShowCmd.Flags().StringVar(&rateScheduleSpec, "rate-schedule", "", "Time-of-day bandwidth limits")
+	configShowCmd.Flags().StringVar(&proxyURL, "proxy", "", "HTTP/SOCKS proxy URL")
+	configShowCmd.Flags().BoolVar(&bypassAuth, "bypass", false, "Force bypass mode without authentication")
+	configShowCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress the disclaimer")
+	configShowCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	configShowCmd.Flags().StringVar(&logLevel, "log-level", "", "Set log level (debug, info, warn, error)")
+	configShowCmd.Flags().StringVar(&logFile, "log-file", "", "Write logs to file instead of stderr")
+	configShowCmd.Flags().StringVar(&outputFmt, "output-format", "text", "Output format: text, or json for a single JSON object on stdout")
+	configShowCmd.Flags().BoolVar(&jsonOutput, "json", false, "Shorthand for --output-format json")
+	configShowCmd.MarkFlagsMutuallyExclusive("output-format", "json")
+}
+
+// printConfig prints the effective configuration as a table
+func printConfig(w io.Writer, config *internal.Config) {
+	file := config.File
+	if file == "" {
+		file = "none"
+		if dir, err := internal.ConfigDir(); err == nil {
+			file = fmt.Sprintf("none (looked in %s)", dir)
+		}
+	}
+	fmt.Fprintf(w, "Config file: %s\n", file)
+	if config.Profile != "" {
+		fmt.Fprintf(w, "Profile:     %s\n", config.Profile)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, setting := range config.Settings() {
+		value := setting.Value
+		if value == "" {
+			value = "-"
+		}
+		if runes := []rune(value); len(runes) > 72 {
+			value = string(runes[:69]) + "..."
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", setting.Key, value, setting.Source)
+	}
+	tw.Flush()
+}
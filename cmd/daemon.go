@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"terafetch/daemon"
+	"terafetch/downloader"
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+var (
+	stateDir          string
+	daemonWorkers     int
+	rpcEnabled        bool
+	rpcListen         string
+	rpcSecret         string
+	rpcAllowOriginAll bool
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the download daemon that works through the persistent queue",
+	Long: `Run a long-lived download daemon. It keeps a persistent queue of jobs in the
+state directory (--state-dir, $TERAFETCH_STATE_DIR or ~/.terafetch), downloads
+--concurrent-files of them at once and accepts new jobs from 'terafetch add'
+over a Unix socket. Jobs interrupted by a restart continue where they stopped.
+
+With --rpc the daemon also serves an aria2-compatible JSON-RPC endpoint (HTTP
+and WebSocket at /jsonrpc), so aria2 web UIs such as AriaNg can drive it. The
+endpoint listens on localhost unless --rpc-listen says otherwise, and every
+call must carry the secret from --rpc-secret or $TERAFETCH_RPC_SECRET.
+
+Examples:
+  terafetch daemon -o ~/Downloads --concurrent-files 3
+  TERAFETCH_RPC_SECRET=s3cret terafetch daemon --rpc
+  terafetch add https://terabox.com/s/1AbC123
+  terafetch list
+  terafetch remove 2`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if daemonWorkers < 1 || daemonWorkers > 16 {
+			return fmt.Errorf("concurrent files must be between 1 and 16, got %d", daemonWorkers)
+		}
+		if threads < 1 || threads > 32 {
+			return fmt.Errorf("thread count must be between 1 and 32, got %d", threads)
+		}
+
+		dir, err := resolveStateDir()
+		if err != nil {
+			return err
+		}
+
+		if outputPath == "" {
+			outputPath = "."
+		}
+		outputDir, err := filepath.Abs(outputPath)
+		if err != nil {
+			return fmt.Errorf("invalid output directory: %v", err)
+		}
+		if err := validateOutputDir(outputDir); err != nil {
+			return fmt.Errorf("invalid output directory: %v", err)
+		}
+
+		var rateLimitBytes int64
+		if rateLimit != "" {
+			rateLimitBytes, err = utils.ParseRateLimit(rateLimit)
+			if err != nil {
+				return fmt.Errorf("invalid rate limit format: %v", err)
+			}
+		}
+
+		if rpcSecret == "" {
+			rpcSecret = os.Getenv("TERAFETCH_RPC_SECRET")
+		}
+		if rpcEnabled && rpcSecret == "" {
+			return fmt.Errorf("--rpc needs a secret, set --rpc-secret or TERAFETCH_RPC_SECRET")
+		}
+
+		return executeDaemonWorkflow(dir, outputDir, rateLimitBytes)
+	},
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <URL>...",
+	Short: "Queue downloads on the running daemon",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, url := range args {
+			if err := validateArguments(url); err != nil {
+				return fmt.Errorf("%s: %w", url, err)
+			}
+		}
+
+		// The daemon runs in its own working directory
+		var outputDir string
+		if outputPath != "" {
+			var err error
+			if outputDir, err = filepath.Abs(outputPath); err != nil {
+				return fmt.Errorf("invalid output directory: %v", err)
+			}
+		}
+
+		client, err := newDaemonClient()
+		if err != nil {
+			return err
+		}
+
+		for _, url := range args {
+			job, err := client.Add(context.Background(), daemon.AddRequest{URL: url, Password: password, OutputDir: outputDir})
+			if err != nil {
+				return err
+			}
+			if events != nil {
+				json.NewEncoder(os.Stdout).Encode(job)
+			} else if !quiet {
+				fmt.Printf("➕ Queued job %d: %s\n", job.ID, job.URL)
+			}
+		}
+		return nil
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the daemon's download queue",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newDaemonClient()
+		if err != nil {
+			return err
+		}
+
+		jobs, err := client.List(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if events != nil {
+			return json.NewEncoder(os.Stdout).Encode(jobs)
+		}
+		printJobs(os.Stdout, jobs)
+		return nil
+	},
+}
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <ID>...",
+	Short: "Remove jobs from the daemon's queue, stopping them if they are running",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids := make([]int, len(args))
+		for i, arg := range args {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("invalid job ID: %s", arg)
+			}
+			ids[i] = id
+		}
+
+		client, err := newDaemonClient()
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			job, err := client.Remove(context.Background(), id)
+			if err != nil {
+				return err
+			}
+			if events != nil {
+				json.NewEncoder(os.Stdout).Encode(job)
+			} else if !quiet {
+				fmt.Printf("🗑️  Removed job %d: %s\n", job.ID, job.URL)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd, addCmd, listCmd, removeCmd)
+
+	for _, cmd := range []*cobra.Command{daemonCmd, addCmd, listCmd, removeCmd} {
+		cmd.Flags().StringVar(&stateDir, "state-dir", "", "Directory holding the queue and daemon socket (env: TERAFETCH_STATE_DIR) (default ~/.terafetch)")
+	}
+
+	daemonCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Directory downloads are saved to unless a job sets its own (default current directory)")
+	daemonCmd.Flags().IntVar(&daemonWorkers, "concurrent-files", 2, "Number of jobs downloaded at once (1-16)")
+	// init functions run in file order, so the global config isn't set up yet
+	defaultThreads := internal.DefaultConfig().DefaultThreads
+	daemonCmd.Flags().IntVarP(&threads, "threads", "t", defaultThreads, fmt.Sprintf("Number of download threads per job (1-32) (env: TERAFETCH_THREADS) (default %d)", defaultThreads))
+	daemonCmd.Flags().StringVarP(&rateLimit, "limit-rate", "r", "", "Bandwidth limit shared by all jobs (e.g., 5M for 5MB/s) (env: TERAFETCH_RATE_LIMIT)")
+	daemonCmd.Flags().StringVar(&rateScheduleSpec, "rate-schedule", "", rateScheduleUsage)
+	daemonCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to cookie file: Netscape format, JSON export or Cookie header (env: TERAFETCH_COOKIES)")
+	daemonCmd.Flags().StringVar(&cookiesFromBrowser, "cookies-from-browser", "", cookiesFromBrowserUsage)
+	daemonCmd.Flags().StringVar(&cookieHeader, "cookie", "", cookieHeaderUsage)
+	daemonCmd.MarkFlagsMutuallyExclusive("cookies", "cookies-from-browser", "cookie")
+	daemonCmd.Flags().StringVar(&proxyURL, "proxy", "", "HTTP/SOCKS proxy URL (env: TERAFETCH_PROXY)")
+	daemonCmd.Flags().BoolVar(&bypassAuth, "bypass", false, "Force bypass mode without authentication (env: TERAFETCH_BYPASS)")
+	daemonCmd.Flags().BoolVar(&verify, "verify", true, "Verify the MD5 checksum of finished downloads")
+	daemonCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip MD5 checksum verification")
+	daemonCmd.MarkFlagsMutuallyExclusive("verify", "no-verify")
+	daemonCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress status output")
+	daemonCmd.Flags().BoolVar(&rpcEnabled, "rpc", false, "Serve the aria2-compatible JSON-RPC endpoint")
+	daemonCmd.Flags().StringVar(&rpcListen, "rpc-listen", daemon.DefaultRPCListen, "Address of the JSON-RPC endpoint")
+	daemonCmd.Flags().StringVar(&rpcSecret, "rpc-secret", "", "Secret token JSON-RPC clients must send (env: TERAFETCH_RPC_SECRET)")
+	daemonCmd.Flags().BoolVar(&rpcAllowOriginAll, "rpc-allow-origin-all", false, "Let browser pages on any origin call the JSON-RPC endpoint over HTTP")
+
+	addCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Directory to save the downloads to (default the daemon's output directory)")
+	addCmd.Flags().StringVar(&password, "password", "", "Share password (extraction code) for protected shares, also read from a pwd= URL parameter")
+
+	for _, cmd := range []*cobra.Command{addCmd, listCmd, removeCmd} {
+		cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress status output")
+		cmd.Flags().StringVar(&outputFmt, "output-format", "text", "Output format: text, or json")
+		cmd.Flags().BoolVar(&jsonOutput, "json", false, "Shorthand for --output-format json")
+		cmd.MarkFlagsMutuallyExclusive("output-format", "json")
+	}
+}
+
+// resolveStateDir returns --state-dir or the default state directory
+func resolveStateDir() (string, error) {
+	if stateDir != "" {
+		return filepath.Abs(stateDir)
+	}
+	return daemon.DefaultStateDir()
+}
+
+// newDaemonClient connects to the daemon of the configured state directory
+func newDaemonClient() (*daemon.Client, error) {
+	dir, err := resolveStateDir()
+	if err != nil {
+		return nil, err
+	}
+	return daemon.NewClient(daemon.SocketPath(dir)), nil
+}
+
+// executeDaemonWorkflow runs the daemon until it receives SIGINT or SIGTERM
+func executeDaemonWorkflow(dir, outputDir string, rateLimitBytes int64) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			internal.LogInfo("Received signal %v, stopping daemon...", sig)
+			if !quiet {
+				fmt.Printf("\n🛑 Received %v signal, stopping daemon. Unfinished jobs continue on the next start.\n", sig)
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Every job shares one client, rate limit and session, as in batch mode
+	client := newHTTPClient(proxyURL)
+
+	authContext, err := loadAuthContext(ctx, client, downloader.NewCookieAuthManager(), currentCookies(), quiet)
+	if err != nil {
+		return err
+	}
+
+	resolver := newResolver(client)
+	resolver.SetOutput(io.Discard)
+
+	daemonConfig := daemon.Config{
+		StateDir:   dir,
+		OutputDir:  outputDir,
+		Concurrent: daemonWorkers,
+		Threads:    threads,
+		RateLimit:  rateLimitBytes,
+		SkipVerify: skipVerify(),
+		Resolve: func(ctx context.Context, url, sharePassword string) (*internal.FileMetadata, error) {
+			meta, _, err := resolveFileMetadata(ctx, resolver, url, authContext, sharePassword, true)
+			return meta, err
+		},
+		NewEngine: func() *downloader.MultiThreadEngine {
+			engine := downloader.NewMultiThreadEngineWithClient(client)
+			engine.SetOutput(io.Discard)
+			return engine
+		},
+		Refresher: func(url, sharePassword string) downloader.LinkRefresher {
+			return linkRefresher(resolver, url, authContext, sharePassword)
+		},
+	}
+	if rpcEnabled {
+		daemonConfig.RPCListen = rpcListen
+		daemonConfig.RPCSecret = rpcSecret
+		daemonConfig.RPCAllowOriginAll = rpcAllowOriginAll
+	}
+
+	d, err := daemon.New(daemonConfig)
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		fmt.Printf("🛰️  Daemon running, %d jobs at a time\n", daemonWorkers)
+		fmt.Printf("📁 Output directory: %s\n", outputDir)
+		fmt.Printf("🔌 Socket: %s\n", daemon.SocketPath(dir))
+		if rpcEnabled {
+			fmt.Printf("🌐 JSON-RPC: http://%s/jsonrpc\n", rpcListen)
+		}
+	}
+	if rateSchedule != nil {
+		go rateSchedule.Run(ctx, rateLimitBytes, d.SetRateLimit)
+		if !quiet {
+			fmt.Printf("🕒 Rate schedule: %s\n", rateSchedule)
+		}
+	}
+	return d.Run(ctx)
+}
+
+// printJobs prints the queue as a table
+func printJobs(w io.Writer, jobs []daemon.Job) {
+	if len(jobs) == 0 {
+		fmt.Fprintln(w, "The queue is empty")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tPROGRESS\tSIZE\tFILE\tERROR")
+	for _, job := range jobs {
+		progress, size, file, errMsg := "-", "-", job.URL, "-"
+		if job.Metadata != nil {
+			size = formatFileSize(job.Metadata.Size)
+			if job.Metadata.Size > 0 {
+				progress = fmt.Sprintf("%.1f%%", float64(job.Downloaded)/float64(job.Metadata.Size)*100)
+			}
+		}
+		if job.OutputPath != "" {
+			file = job.OutputPath
+		}
+		if job.Error != "" {
+			errMsg = summarizeError(fmt.Errorf("%s", job.Error))
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.Status, progress, size, file, errMsg)
+	}
+	tw.Flush()
+}
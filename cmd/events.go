@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"terafetch/downloader"
+	"terafetch/utils"
+)
+
+var (
+	outputFmt  string
+	jsonOutput bool
+
+	// events receives machine-readable output in JSON mode; nil otherwise
+	events *utils.EventWriter
+)
+
+// reportedError wraps the summary error of a command that already emitted an
+// error event for each of its failures, so Execute does not add one without a
+// URL on top of them
+type reportedError struct {
+	error
+}
+
+func (e reportedError) Unwrap() error {
+	return e.error
+}
+
+// setupOutputFormat validates --output-format and, in JSON mode, switches stdout
+// to a stream of JSON events and silences the human-readable output
+func setupOutputFormat() error {
+	if jsonOutput {
+		outputFmt = "json"
+	}
+
+	switch outputFmt {
+	case "", "text":
+		events = nil
+	case "json":
+		events = utils.NewEventWriter(os.Stdout)
+		quiet = true
+	default:
+		return fmt.Errorf("invalid output format %q, expected text or json", outputFmt)
+	}
+	return nil
+}
+
+// attachEvents routes the resolver's and engine's output to the JSON event
+// stream when it is enabled. Either argument may be nil.
+func attachEvents(resolver *downloader.TeraboxResolver, engine *downloader.MultiThreadEngine) {
+	if events == nil {
+		return
+	}
+	if resolver != nil {
+		resolver.SetOutput(io.Discard)
+	}
+	if engine != nil {
+		engine.SetEvents(events)
+		engine.SetOutput(io.Discard)
+	}
+}
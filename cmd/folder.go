@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"terafetch/downloader"
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+// executeFolderWorkflow resolves a folder share and downloads every file in it,
+// recreating the share's directory layout under outputDir
+func executeFolderWorkflow(url, outputDir string, threads int, rateLimitBytes int64, cookies cookieSource, proxyURL string, quiet bool) error {
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal handling for graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			internal.LogInfo("Received signal %v, stopping folder download...", sig)
+			if !quiet {
+				fmt.Printf("\n🛑 Received %v signal, stopping folder download...\n", sig)
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Initialize components
+	client := newHTTPClient(proxyURL)
+	resolver := newResolver(client)
+	authManager := downloader.NewCookieAuthManager()
+	engine := downloader.NewMultiThreadEngineWithClient(client)
+	attachEvents(resolver, engine)
+
+	authContext, err := loadAuthContext(ctx, client, authManager, cookies, quiet)
+	if err != nil {
+		return err
+	}
+	if bypassAuth {
+		authContext = nil
+	}
+
+	// Unlock password-protected shares before walking them
+	sharePassword := password
+	if sharePassword == "" {
+		if urlInfo, err := newURLValidator().ParseURL(url); err == nil {
+			sharePassword = urlInfo.Password
+		}
+	}
+	if sharePassword != "" {
+		if !quiet {
+			fmt.Printf("🔑 Unlocking password-protected share...\n")
+		}
+		authContext, err = resolver.VerifySharePassword(ctx, url, sharePassword, authContext)
+		if err != nil {
+			internal.LogError("Share password handshake failed: %v", err)
+			return fmt.Errorf("failed to unlock password-protected share: %w", err)
+		}
+	}
+
+	access := accessPublic
+	switch {
+	case bypassAuth:
+		access = accessBypass
+	case sharePassword != "":
+		access = accessPassword
+	case authContext != nil:
+		access = accessCookies
+	}
+
+	// Step 1: Walk the folder share
+	internal.LogInfo("Resolving folder share: %s", url)
+	if !quiet {
+		fmt.Printf("🔍 Resolving folder share...\n")
+	}
+
+	tree, err := resolver.ResolveFolder(ctx, url, authContext)
+	if err != nil {
+		internal.LogError("Folder resolution failed: %v", err)
+		if downloader.IsSharePasswordError(err) {
+			return fmt.Errorf("share is password-protected, provide the extraction code with --password: %w", err)
+		}
+		return fmt.Errorf("failed to resolve folder share: %w", err)
+	}
+
+	// The unlocked session re-walks the share when a file's link expires
+	engine.SetLinkRefresher(resolver.LinkRefresher(url, authContext, ""))
+	if limiter := scheduledRateLimiter(ctx, rateLimitBytes); limiter != nil {
+		engine.SetRateLimiter(limiter)
+	}
+
+	files := tree.Files()
+	for _, file := range files {
+		events.Emit(utils.Event{Type: utils.EventResolved, URL: url, Metadata: file})
+	}
+	if !quiet {
+		fmt.Printf("✅ Folder resolved: %d files, %s\n", len(files), formatFileSize(tree.TotalSize()))
+		fmt.Printf("📁 Output directory: %s\n", outputDir)
+		fmt.Println()
+	}
+
+	// Step 2: Download each file into its place in the tree
+	var failed []string
+	for i, file := range files {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("folder download cancelled by user after %d of %d files", i, len(files))
+		default:
+		}
+
+		target, err := utils.SafeJoin(outputDir, file.Path)
+		if err != nil {
+			internal.LogError("Skipping unsafe path %q: %v", file.Path, err)
+			events.EmitError(url, file.Path, err)
+			failed = append(failed, file.Path)
+			continue
+		}
+
+		if !quiet {
+			fmt.Printf("📄 [%d/%d] %s (%s)\n", i+1, len(files), file.Path, formatFileSize(file.Size))
+		}
+
+		downloadConfig := &internal.DownloadConfig{
+			OutputPath: target,
+			Threads:    threads,
+			RateLimit:  rateLimitBytes,
+			ProxyURL:   proxyURL,
+			Quiet:      quiet,
+			SkipVerify: skipVerify(),
+			Source:     resumeSource(url, access, target, threads),
+		}
+
+		if err := engine.Download(ctx, file, downloadConfig); err != nil {
+			internal.LogError("Download of %s failed: %v", file.Path, err)
+			events.EmitError(url, target, err)
+			if !quiet {
+				fmt.Printf("❌ %s: %v\n", file.Path, err)
+			}
+			failed = append(failed, file.Path)
+		}
+	}
+
+	if len(failed) > 0 {
+		return reportedError{fmt.Errorf("%d of %d files failed to download: %v", len(failed), len(files), failed)}
+	}
+
+	internal.LogInfo("Folder download completed: %d files", len(files))
+	if !quiet {
+		fmt.Printf("✅ Folder download completed successfully!\n")
+		fmt.Printf("📁 Files saved under: %s\n", outputDir)
+	}
+	return nil
+}
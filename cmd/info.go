@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"terafetch/downloader"
+	"terafetch/internal"
+)
+
+// shareInfo is what terafetch info reports about a share
+type shareInfo struct {
+	URL       string                 `json:"url"`
+	ShareID   string                 `json:"share_id"`
+	Access    string                 `json:"access"` // public, cookies, password or bypass
+	Folder    bool                   `json:"folder"`
+	FileCount int                    `json:"file_count"`
+	TotalSize int64                  `json:"total_size"`
+	File      *internal.FileMetadata `json:"file,omitempty"` // Single-file shares
+	Tree      *internal.FileTreeNode `json:"tree,omitempty"` // Folder shares
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info <URL>",
+	Short: "Show what a share contains without downloading it",
+	Long: `Resolve a share and print its filename, size, MD5 and share ID, or the
+file tree of a folder share, along with the access method that worked.
+
+Nothing is written to disk: no .part file and no resume metadata.
+
+Examples:
+  terafetch info https://terabox.com/s/1AbC123
+  terafetch info --password x7k2 https://terabox.com/s/1AbC123
+  terafetch info --json https://terabox.com/s/1AbC123`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url := args[0]
+
+		if err := validateArguments(url); err != nil {
+			return err
+		}
+		if cookiesPath != "" {
+			if err := validateCookiesFile(cookiesPath); err != nil {
+				return fmt.Errorf("invalid cookies file: %v", err)
+			}
+		}
+		if proxyURL != "" {
+			if err := validateProxyURL(proxyURL); err != nil {
+				return fmt.Errorf("invalid proxy URL: %v", err)
+			}
+		}
+
+		info, err := executeInfoWorkflow(url, currentCookies(), proxyURL, quiet)
+		if err != nil {
+			return err
+		}
+
+		if events != nil {
+			return json.NewEncoder(os.Stdout).Encode(info)
+		}
+		printShareInfo(os.Stdout, info)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to cookie file: Netscape format, JSON export or Cookie header (env: TERAFETCH_COOKIES)")
+	infoCmd.Flags().StringVar(&cookiesFromBrowser, "cookies-from-browser", "", cookiesFromBrowserUsage)
+	infoCmd.Flags().StringVar(&cookieHeader, "cookie", "", cookieHeaderUsage)
+	infoCmd.MarkFlagsMutuallyExclusive("cookies", "cookies-from-browser", "cookie")
+	infoCmd.Flags().StringVar(&proxyURL, "proxy", "", "HTTP/SOCKS proxy URL (env: TERAFETCH_PROXY)")
+	infoCmd.Flags().BoolVar(&bypassAuth, "bypass", false, "Force bypass mode without authentication (env: TERAFETCH_BYPASS)")
+	infoCmd.Flags().StringVar(&password, "password", "", "Share password (extraction code) for protected shares, also read from a pwd= URL parameter")
+	infoCmd.Flags().StringVar(&outputFmt, "output-format", "text", "Output format: text, or json for a single JSON object on stdout")
+	infoCmd.Flags().BoolVar(&jsonOutput, "json", false, "Shorthand for --output-format json")
+	infoCmd.MarkFlagsMutuallyExclusive("output-format", "json")
+}
+
+// executeInfoWorkflow resolves a share without downloading it. The share is
+// listed as a folder first; shares that can't be listed, or that hold a single
+// file, go through the same resolution chain as a download.
+func executeInfoWorkflow(url string, cookies cookieSource, proxyURL string, quiet bool) (*shareInfo, error) {
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	client := newHTTPClient(proxyURL)
+	resolver := newResolver(client)
+	// The resolver's attempt-by-attempt messages would clutter the report
+	resolver.SetOutput(io.Discard)
+
+	authContext, err := loadAuthContext(ctx, client, downloader.NewCookieAuthManager(), cookies, quiet)
+	if err != nil {
+		return nil, err
+	}
+	if bypassAuth {
+		authContext = nil
+	}
+
+	urlInfo, err := newURLValidator().ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	info := &shareInfo{URL: url, ShareID: urlInfo.GetIdentifier()}
+
+	internal.LogInfo("Inspecting share: %s", url)
+	if !quiet {
+		fmt.Printf("🔍 Resolving share...\n\n")
+	}
+
+	// Listing needs an unlocked share key for password-protected shares
+	sharePassword := password
+	if sharePassword == "" {
+		sharePassword = urlInfo.Password
+	}
+	listAuth, listAccess := authContext, accessPublic
+	if bypassAuth {
+		listAccess = accessBypass
+	} else if authContext != nil && authContext.BDUSS != "" {
+		listAccess = accessCookies
+	}
+	if sharePassword != "" && !bypassAuth {
+		listAuth, err = resolver.VerifySharePassword(ctx, url, sharePassword, authContext)
+		if err != nil {
+			internal.LogError("Share password handshake failed: %v", err)
+			return nil, fmt.Errorf("failed to unlock password-protected share: %w", err)
+		}
+		listAccess = accessPassword
+	}
+
+	// Listing alone tells what the share holds, so no download link is
+	// requested for its files
+	tree, err := resolver.ListFolder(ctx, url, listAuth)
+	if err == nil {
+		files := tree.Files()
+		normalizeChecksums(files)
+		info.Access = listAccess
+
+		// A share of one file is reported from its listed entry
+		if !isFolderShare(tree) {
+			meta := files[0]
+			meta.Path = ""
+			info.FileCount = 1
+			info.TotalSize = meta.Size
+			info.File = meta
+			return info, nil
+		}
+
+		info.Folder = true
+		info.FileCount = len(files)
+		info.TotalSize = tree.TotalSize()
+		info.Tree = tree
+		return info, nil
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("cancelled by user")
+	}
+	if downloader.IsSharePasswordError(err) {
+		return nil, fmt.Errorf("share is password-protected, provide the extraction code with --password: %w", err)
+	}
+	internal.LogWarn("Folder listing failed: %v, resolving as a single file", err)
+
+	meta, access, err := resolveShare(ctx, resolver, url, authContext, sharePassword, true)
+	if err != nil {
+		return nil, err
+	}
+	normalizeChecksums([]*internal.FileMetadata{meta})
+
+	info.Access = access
+	info.FileCount = 1
+	info.TotalSize = meta.Size
+	info.File = meta
+	if meta.ShareID != "" {
+		info.ShareID = meta.ShareID
+	}
+	return info, nil
+}
+
+// isFolderShare reports whether a listed share is more than a single file
+func isFolderShare(tree *internal.FileTreeNode) bool {
+	if len(tree.Children) != 1 {
+		return true
+	}
+	return tree.Children[0].IsDir
+}
+
+// normalizeChecksums replaces Terabox's obfuscated MD5s with the plain form;
+// checksums in an unrecognized format are left as they are
+func normalizeChecksums(files []*internal.FileMetadata) {
+	for _, file := range files {
+		if md5, err := downloader.NormalizeMD5(file.Checksum); err == nil {
+			file.Checksum = md5
+		}
+	}
+}
+
+// describeAccess explains an access method in the human-readable report
+func describeAccess(access string) string {
+	switch access {
+	case accessCookies:
+		return "authenticated (cookies)"
+	case accessPassword:
+		return "unlocked with share password"
+	case accessBypass:
+		return "bypass (no authentication)"
+	default:
+		return "public"
+	}
+}
+
+// printShareInfo prints the human-readable share report
+func printShareInfo(w io.Writer, info *shareInfo) {
+	if !info.Folder {
+		file := info.File
+		fmt.Fprintf(w, "📄 File:     %s\n", file.Filename)
+		fmt.Fprintf(w, "📏 Size:     %s (%d bytes)\n", formatFileSize(file.Size), file.Size)
+		if file.Checksum != "" {
+			fmt.Fprintf(w, "🔐 MD5:      %s\n", file.Checksum)
+		}
+		fmt.Fprintf(w, "🔗 Share ID: %s\n", info.ShareID)
+		fmt.Fprintf(w, "🔓 Access:   %s\n", describeAccess(info.Access))
+		return
+	}
+
+	fmt.Fprintf(w, "📁 Folder:   %s\n", info.ShareID)
+	fmt.Fprintf(w, "📦 Files:    %d (%s)\n", info.FileCount, formatFileSize(info.TotalSize))
+	fmt.Fprintf(w, "🔓 Access:   %s\n", describeAccess(info.Access))
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%s/\n", info.Tree.Name)
+	printTree(w, info.Tree.Children, "")
+}
+
+// printTree prints the nodes of a folder share as an indented tree
+func printTree(w io.Writer, nodes []*internal.FileTreeNode, indent string) {
+	for i, node := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		if node.IsDir {
+			fmt.Fprintf(w, "%s%s%s/\n", indent, branch, node.Name)
+			printTree(w, node.Children, indent+next)
+			continue
+		}
+
+		details := []string{formatFileSize(node.File.Size)}
+		if node.File.Checksum != "" {
+			details = append(details, "md5 "+node.File.Checksum)
+		}
+		fmt.Fprintf(w, "%s%s%s (%s)\n", indent, branch, node.Name, strings.Join(details, ", "))
+	}
+}
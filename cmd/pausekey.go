@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"terafetch/downloader"
+	"terafetch/internal"
+)
+
+// watchPauseKey lets the user pause and resume the download behind handle by
+// pressing p, when stdin is a terminal and progress is shown. It returns a
+// function that stops reading keys and puts the terminal back, so no later
+// input is swallowed.
+func watchPauseKey(handle *downloader.DownloadHandle, quiet bool) func() {
+	if quiet {
+		return func() {}
+	}
+
+	restore, err := enableKeypresses(int(os.Stdin.Fd()))
+	if err != nil {
+		internal.LogDebug("Pause key disabled: %v", err)
+		return func() {}
+	}
+
+	fmt.Printf("⌨️  Press p to pause or resume\n")
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		key := make([]byte, 1)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-handle.Done():
+				return
+			default:
+			}
+
+			// An empty read means no key was pressed for a moment
+			n, err := os.Stdin.Read(key)
+			if err == io.EOF || n == 0 {
+				continue
+			}
+			if err != nil {
+				return
+			}
+
+			if key[0] == 'p' || key[0] == 'P' {
+				if handle.Toggle() {
+					internal.LogInfo("Download paused")
+				} else {
+					internal.LogInfo("Download resumed")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		restore()
+	}
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"terafetch/downloader"
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+// resumeAll makes 'terafetch resume' take a directory and resume every
+// interrupted download found in it
+var resumeAll bool
+
+// bulkResume holds the state shared by every download of 'terafetch resume
+// --all': one HTTP client, one rate limiter and one resolver
+type bulkResume struct {
+	client   *utils.HTTPClient
+	resolver *downloader.TeraboxResolver
+	limiter  internal.RateLimiter
+	proxyURL string
+	quiet    bool
+}
+
+// bulkResumeEntry is a resumable download together with the settings and
+// session it is resumed with
+type bulkResumeEntry struct {
+	download *downloader.ResumableDownload
+	settings resumeSettings
+	auth     *internal.AuthContext
+	authErr  error
+}
+
+// executeResumeAllWorkflow scans dir for interrupted downloads, prints a summary
+// of each and resumes the resumable ones, running up to concurrentFiles at once
+// under one shared rate limit
+func executeResumeAllWorkflow(cmd *cobra.Command, dir string, rateLimitBytes int64, proxyURL string, quiet bool, concurrentFiles int) error {
+	downloads, err := downloader.NewDownloadPlanner().ScanResumableDownloads(dir)
+	if err != nil {
+		return err
+	}
+	if len(downloads) == 0 {
+		internal.LogInfo("No interrupted downloads found in %s", dir)
+		if !quiet {
+			fmt.Printf("No interrupted downloads found in %s\n", dir)
+		}
+		return nil
+	}
+
+	if events == nil {
+		printResumeScan(downloads)
+	}
+
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal handling for graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case sig := <-sigChan:
+			internal.LogInfo("Received signal %v, stopping bulk resume...", sig)
+			if !quiet {
+				fmt.Printf("\n🛑 Received %v signal, stopping bulk resume...\n", sig)
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Shared components for every download
+	client := newHTTPClient(proxyURL)
+
+	// Restore each download's own settings and load every cookie source once,
+	// sharing the session with later downloads that use the same one
+	var entries []*bulkResumeEntry
+	sessions := make(map[cookieSource]*bulkResumeEntry)
+	for _, download := range downloads {
+		if !download.Resumable() {
+			internal.LogWarn("Skipping %s: %v", download.OutputPath, download.Err)
+			continue
+		}
+
+		entry := &bulkResumeEntry{download: download, settings: restoredSettings(cmd, download.Metadata.Source)}
+		if cookies := entry.settings.cookies; !noRefresh && !entry.settings.bypass {
+			if first, ok := sessions[cookies]; ok {
+				entry.auth, entry.authErr = first.auth, first.authErr
+			} else {
+				entry.auth, entry.authErr = loadAuthContext(ctx, client, downloader.NewCookieAuthManager(), cookies, quiet)
+				sessions[cookies] = entry
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("none of the %d interrupted downloads in %s can be resumed", len(downloads), dir)
+	}
+
+	bulk := &bulkResume{
+		client:   client,
+		resolver: newResolver(client),
+		proxyURL: proxyURL,
+		quiet:    quiet,
+	}
+	attachEvents(bulk.resolver, nil)
+	if limiter := scheduledRateLimiter(ctx, rateLimitBytes); limiter != nil {
+		bulk.limiter = limiter
+	} else if rateLimitBytes > 0 {
+		bulk.limiter = utils.NewTokenBucketLimiter(rateLimitBytes)
+	}
+
+	if concurrentFiles > len(entries) {
+		concurrentFiles = len(entries)
+	}
+
+	internal.LogInfo("Resuming %d of %d interrupted downloads in %s, %d at a time", len(entries), len(downloads), dir, concurrentFiles)
+	if !quiet {
+		fmt.Printf("\n🔄 Resuming %d downloads (%d at a time)\n\n", len(entries), concurrentFiles)
+	}
+
+	// Process the downloads with a fixed number of file workers
+	results := make([]batchResult, len(entries))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrentFiles; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = bulk.resume(ctx, entries[i], i+1, len(entries), concurrentFiles > 1)
+			}
+		}()
+	}
+
+	for i, entry := range entries {
+		if ctx.Err() != nil {
+			results[i] = batchResult{URL: shareURLOf(entry.download), Path: entry.download.OutputPath, Err: fmt.Errorf("cancelled by user")}
+			events.EmitError(results[i].URL, results[i].Path, results[i].Err)
+			continue
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	// Summarize
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	if events == nil {
+		printBatchSummary(results)
+	}
+
+	if failed > 0 {
+		internal.LogError("Bulk resume finished with %d of %d downloads failed", failed, len(entries))
+		return reportedError{fmt.Errorf("%d of %d downloads failed to resume", failed, len(entries))}
+	}
+
+	internal.LogInfo("Bulk resume completed: %d downloads", len(entries))
+	return nil
+}
+
+// resume continues a single interrupted download. Progress bars are suppressed
+// when several files download at once so their output doesn't interleave.
+func (b *bulkResume) resume(ctx context.Context, entry *bulkResumeEntry, position, total int, concurrent bool) (result batchResult) {
+	download := entry.download
+	result.URL = shareURLOf(download)
+	result.Path = download.OutputPath
+	if meta := download.Metadata.FileMetadata; meta != nil {
+		result.Size = meta.Size
+	}
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		events.EmitError(result.URL, result.Path, result.Err)
+	}()
+
+	if entry.authErr != nil {
+		result.Err = entry.authErr
+		if !b.quiet {
+			fmt.Printf("❌ [%d/%d] %s: %v\n", position, total, result.Path, result.Err)
+		}
+		return result
+	}
+
+	if !b.quiet {
+		fmt.Printf("🚀 [%d/%d] %s (%.1f%% done)\n", position, total, result.Path, download.Progress)
+	}
+
+	engine := downloader.NewMultiThreadEngineWithClient(b.client)
+	attachEvents(nil, engine)
+	if b.limiter != nil {
+		engine.SetRateLimiter(b.limiter)
+	}
+
+	// Stored links usually expire before a download is resumed, so a fresh one
+	// is resolved from the share unless --no-refresh is given
+	if !noRefresh && result.URL != "" {
+		auth := entry.auth
+		if entry.settings.bypass {
+			auth = nil
+		}
+		// --password only applies to the shares that were unlocked with one
+		sharePassword := ""
+		if source := download.Metadata.Source; source != nil && source.AuthMode == accessPassword {
+			sharePassword = password
+		}
+		engine.SetLinkRefresher(linkRefresher(b.resolver, result.URL, auth, sharePassword))
+	}
+
+	downloadConfig := &internal.DownloadConfig{
+		OutputPath: download.OutputPath,
+		Threads:    entry.settings.threads,
+		ProxyURL:   b.proxyURL,
+		Quiet:      b.quiet || concurrent,
+		SkipVerify: skipVerify(),
+	}
+
+	if err := engine.Resume(ctx, download.PartialPath(), downloadConfig); err != nil {
+		result.Err = err
+		internal.LogError("Resume of %s failed: %v", download.OutputPath, err)
+		if !b.quiet {
+			fmt.Printf("❌ [%d/%d] %s: %v\n", position, total, result.Path, err)
+		}
+		return result
+	}
+
+	internal.LogInfo("Resume completed successfully: %s", download.OutputPath)
+	if !b.quiet {
+		fmt.Printf("✅ [%d/%d] %s\n", position, total, result.Path)
+	}
+	return result
+}
+
+// shareURLOf returns the share URL a download was started from, or "" when
+// its resume metadata doesn't record one
+func shareURLOf(download *downloader.ResumableDownload) string {
+	if download.Metadata == nil || download.Metadata.Source == nil {
+		return ""
+	}
+	return download.Metadata.Source.ShareURL
+}
+
+// printResumeScan prints the interrupted downloads found by 'terafetch resume --all'
+func printResumeScan(downloads []*downloader.ResumableDownload) {
+	resumable := 0
+	for _, download := range downloads {
+		if download.Resumable() {
+			resumable++
+		}
+	}
+
+	fmt.Printf("📂 Found %d interrupted downloads, %d resumable\n", len(downloads), resumable)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tFILE\tPROGRESS\tSIZE\tAGE\tREASON")
+	for _, download := range downloads {
+		status, progress, size, age, reason := "RESUME", "-", "-", "-", "-"
+		if download.Metadata != nil {
+			progress = fmt.Sprintf("%.1f%%", download.Progress)
+			age = formatAge(download.Age)
+			if meta := download.Metadata.FileMetadata; meta != nil {
+				size = formatFileSize(meta.Size)
+			}
+		}
+		if !download.Resumable() {
+			status, reason = "SKIP", summarizeError(download.Err)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", status, download.OutputPath, progress, size, age, reason)
+	}
+	w.Flush()
+}
+
+// formatAge formats the time since a download's last checkpoint
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -27,11 +28,31 @@ var (
 	logLevel    string
 	logFile     string
 	bypassAuth  bool
+	recursive   bool
+	password    string
+	inputFile   string
+	concurrency int
+	verify      bool
+	noVerify    bool
+	noRefresh   bool
+	configPath  string
+	profile     string
 	config      *internal.Config
+
+	// cookiesFromBrowser is the --cookies-from-browser flag, the browser
+	// profile cookies are read from instead of a cookie file, and
+	// cookieHeader the --cookie flag, a Cookie header given directly
+	cookiesFromBrowser string
+	cookieHeader       string
+
+	// rateScheduleSpec is the --rate-schedule flag and rateSchedule its parsed
+	// form, nil when no schedule is set
+	rateScheduleSpec string
+	rateSchedule     *utils.RateSchedule
 )
 
 var rootCmd = &cobra.Command{
-	Use:     "terafetch [OPTIONS] <URL>",
+	Use:     "terafetch [OPTIONS] <URL>...",
 	Short:   "Download files from Terabox with multi-threaded support",
 	Version: "v1.0.0",
 	Long: `TeraFetch is a production-grade CLI tool for downloading files from Terabox
@@ -42,20 +63,52 @@ Examples:
   terafetch https://terabox.com/s/1AbC123
   terafetch -o /path/to/file.zip -t 16 https://terabox.com/s/1AbC123
   terafetch -c cookies.txt -r 5M --proxy http://proxy:8080 https://terabox.com/s/1AbC123
+  terafetch --cookies-from-browser firefox https://terabox.com/s/1AbC123
+  terafetch --cookie "BDUSS=...; STOKEN=..." https://terabox.com/s/1AbC123
+  terafetch auth login -c cookies.txt
+  terafetch -R -o ./episodes https://terabox.com/s/1AbC123
+  terafetch --password x7k2 https://terabox.com/s/1AbC123
+  terafetch -i urls.txt --concurrent-files 3 -o ./downloads
+  terafetch --no-verify https://terabox.com/s/1AbC123
+  terafetch --json https://terabox.com/s/1AbC123
   terafetch resume /path/to/file.zip.part
+  terafetch resume --all ./downloads
+  terafetch clean --dry-run ./downloads
+  terafetch info https://terabox.com/s/1AbC123
+  terafetch --profile work https://terabox.com/s/1AbC123
 
 Environment Variables:
   TERAFETCH_THREADS     Default number of threads (1-32)
   TERAFETCH_TIMEOUT     HTTP timeout in seconds
+  TERAFETCH_MAX_RETRIES Retries after a failed request, segment or download
+  TERAFETCH_RETRY_DELAY Seconds before the first retry (doubles up to TERAFETCH_MAX_RETRY_DELAY)
   TERAFETCH_COOKIES     Path to cookie file
+  TERAFETCH_COOKIES_FROM_BROWSER  Browser profile to read cookies from (e.g., firefox or chromium:Default)
+  TERAFETCH_KEY         Passphrase of the session stored with 'terafetch auth login'
   TERAFETCH_PROXY       Proxy URL
   TERAFETCH_RATE_LIMIT  Default rate limit (e.g., 5M)
+  TERAFETCH_RATE_SCHEDULE  Time-of-day rate limits (e.g., 08:00-18:00=2M,18:00-08:00=0)
+  TERAFETCH_STATE_DIR   Daemon queue and socket directory (default ~/.terafetch)
+  TERAFETCH_RPC_SECRET  Secret token of the daemon's JSON-RPC endpoint
+  TERAFETCH_CONFIG      Config file (default $XDG_CONFIG_HOME/terafetch/config.toml)
+  TERAFETCH_PROFILE     Config file profile to apply
 
 DISCLAIMER: Respect Terabox's Terms of Service and copyright laws.`,
-	Args: cobra.ExactArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		// URLs may come entirely from --input-file
+		if inputFile != "" {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// JSON output implies quiet, so settle it before the configuration reads it
+		if err := setupOutputFormat(); err != nil {
+			return err
+		}
+
 		// Load and initialize configuration first
-		if err := loadConfiguration(); err != nil {
+		if err := loadConfiguration(cmd); err != nil {
 			return fmt.Errorf("configuration error: %v", err)
 		}
 		
@@ -78,23 +131,51 @@ DISCLAIMER: Respect Terabox's Terms of Service and copyright laws.`,
 		return nil
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		url := args[0]
-		
-		internal.LogInfo("Processing download request for URL: %s", url)
-		
-		// Validate arguments
-		if err := validateArguments(url); err != nil {
-			internal.LogError("Argument validation failed: %v", err)
-			return err
+		// Gather URLs from the command line and the input file
+		urls := args
+		if inputFile != "" {
+			listed, err := loadURLList(inputFile)
+			if err != nil {
+				return err
+			}
+			urls = append(append([]string{}, args...), listed...)
+			if len(urls) == 0 {
+				return fmt.Errorf("no URLs found in input file: %s", inputFile)
+			}
 		}
-		
-		// Validate and parse the URL
-		validator := utils.NewURLValidator()
-		urlInfo, err := validator.ParseURL(url)
-		if err != nil {
-			validationErr := internal.NewInvalidURLError(url, err.Error())
-			internal.LogTeraboxError(validationErr)
-			return fmt.Errorf("invalid URL: %v\n\nSupported URL formats:\n  - https://terabox.com/s/[share_id]\n  - https://www.terabox.com/s/[share_id]\n  - https://pan.baidu.com/s/[share_id]", err)
+		batch := len(urls) > 1 || inputFile != ""
+		url := urls[0]
+
+		internal.LogInfo("Processing download request for %d URL(s), first: %s", len(urls), url)
+
+		if batch && recursive {
+			return fmt.Errorf("--recursive downloads a single folder share and cannot be combined with multiple URLs")
+		}
+		if concurrency < 1 || concurrency > 16 {
+			return fmt.Errorf("concurrent files must be between 1 and 16, got %d", concurrency)
+		}
+
+		// Validate and parse every URL up front so a typo fails before any download starts
+		validator := newURLValidator()
+		var urlInfo *utils.URLInfo
+		for _, u := range urls {
+			if err := validateArguments(u); err != nil {
+				internal.LogError("Argument validation failed: %v", err)
+				if batch {
+					return fmt.Errorf("%s: %w", u, err)
+				}
+				return err
+			}
+
+			info, err := validator.ParseURL(u)
+			if err != nil {
+				validationErr := internal.NewInvalidURLError(u, err.Error())
+				internal.LogTeraboxError(validationErr)
+				return fmt.Errorf("invalid URL: %v\n\nSupported URL formats:\n  - https://terabox.com/s/[share_id]\n  - https://www.terabox.com/s/[share_id]\n  - https://pan.baidu.com/s/[share_id]", err)
+			}
+			if urlInfo == nil {
+				urlInfo = info
+			}
 		}
 		
 		internal.LogDebug("URL parsed successfully: domain=%s, identifier=%s", urlInfo.Domain, urlInfo.GetIdentifier())
@@ -102,6 +183,7 @@ DISCLAIMER: Respect Terabox's Terms of Service and copyright laws.`,
 		// Parse rate limit if provided
 		var rateLimitBytes int64
 		if rateLimit != "" {
+			var err error
 			rateLimitBytes, err = utils.ParseRateLimit(rateLimit)
 			if err != nil {
 				validationErr := internal.NewValidationErrorWithValue("rate_limit", "invalid format", rateLimit).
@@ -115,10 +197,17 @@ DISCLAIMER: Respect Terabox's Terms of Service and copyright laws.`,
 		// Set default output path if not provided
 		if outputPath == "" {
 			outputPath = generateDefaultOutputPath(urlInfo)
+			if batch {
+				outputPath = "."
+			}
 		}
 		
-		// Validate output path
-		if err := validateOutputPath(outputPath); err != nil {
+		// Validate output path (a directory when downloading a folder share or a batch)
+		validate := validateOutputPath
+		if recursive || batch {
+			validate = validateOutputDir
+		}
+		if err := validate(outputPath); err != nil {
 			validationErr := internal.NewValidationErrorWithValue("output_path", err.Error(), outputPath)
 			internal.LogValidationError(validationErr)
 			return fmt.Errorf("invalid output path: %v", err)
@@ -148,14 +237,21 @@ DISCLAIMER: Respect Terabox's Terms of Service and copyright laws.`,
 		}
 		
 		if !quiet {
-			fmt.Printf("📥 Downloading from: %s\n", url)
+			if batch {
+				fmt.Printf("📥 Downloading %d URLs\n", len(urls))
+			} else {
+				fmt.Printf("📥 Downloading from: %s\n", url)
+			}
 			fmt.Printf("📁 Output path: %s\n", outputPath)
 			fmt.Printf("🧵 Threads: %d\n", threads)
 			if rateLimitBytes > 0 {
 				fmt.Printf("🚦 Rate limit: %s (%d bytes/sec)\n", rateLimit, rateLimitBytes)
 			}
-			if cookiesPath != "" {
-				fmt.Printf("🍪 Using cookies from: %s\n", cookiesPath)
+			if rateSchedule != nil {
+				fmt.Printf("🕒 Rate schedule: %s\n", rateSchedule)
+			}
+			if cookies := currentCookies(); cookies.set() {
+				fmt.Printf("🍪 Using cookies from: %s\n", cookies)
 			}
 			if proxyURL != "" {
 				fmt.Printf("🌐 Using proxy: %s\n", proxyURL)
@@ -167,24 +263,59 @@ DISCLAIMER: Respect Terabox's Terms of Service and copyright laws.`,
 		internal.LogDebug("Final config: output=%s, threads=%d, rateLimit=%d, cookies=%s, proxy=%s", 
 			outputPath, threads, rateLimitBytes, cookiesPath, proxyURL)
 		
+		// Several URLs are downloaded as one queue sharing client, limiter and auth
+		if batch {
+			return executeBatchWorkflow(urls, outputPath, threads, rateLimitBytes, currentCookies(), proxyURL, quiet, concurrency)
+		}
+
+		// Folder shares are walked recursively and downloaded file by file
+		if recursive {
+			return executeFolderWorkflow(url, outputPath, threads, rateLimitBytes, currentCookies(), proxyURL, quiet)
+		}
+
 		// Execute the complete download workflow
-		return executeDownloadWorkflow(url, outputPath, threads, rateLimitBytes, cookiesPath, proxyURL, quiet)
+		return executeDownloadWorkflow(url, outputPath, threads, rateLimitBytes, currentCookies(), proxyURL, quiet)
 	},
 }
 
 var resumeCmd = &cobra.Command{
-	Use:   "resume <PARTIAL_FILE_PATH>",
+	Use:   "resume <PARTIAL_FILE_PATH> | --all <DIR>",
 	Short: "Resume an interrupted download",
 	Long: `Resume an interrupted download from a .part file.
 
 The resume command will automatically detect the associated metadata file
-and continue downloading from where it left off.
+and continue downloading from where it left off. Download links expire, so a
+fresh one is resolved from the original share before resuming. The thread
+count, cookie file and bypass mode the download was started with are restored
+unless flags override them.
+
+With --all, the directory is searched for interrupted downloads instead. Each
+one is listed with its progress and age, and every resumable download is
+resumed in turn, or --concurrent-files at a time, under one shared rate limit.
+Downloads whose last checkpoint is more than 7 days old are skipped.
 
 Examples:
   terafetch resume /path/to/file.zip.part
-  terafetch resume -t 16 -r 5M /path/to/file.zip.part`,
+  terafetch resume -t 16 -r 5M /path/to/file.zip.part
+  terafetch resume --password x7k2 /path/to/file.zip.part
+  terafetch resume --all --concurrent-files 3 ./downloads`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if resumeAll {
+			if concurrency < 1 || concurrency > 16 {
+				return fmt.Errorf("concurrent files must be between 1 and 16, got %d", concurrency)
+			}
+			var rateLimitBytes int64
+			if rateLimit != "" {
+				var err error
+				rateLimitBytes, err = utils.ParseRateLimit(rateLimit)
+				if err != nil {
+					return fmt.Errorf("invalid rate limit format: %v", err)
+				}
+			}
+			return executeResumeAllWorkflow(cmd, args[0], rateLimitBytes, proxyURL, quiet, concurrency)
+		}
+
 		partialPath := args[0]
 		
 		internal.LogInfo("Attempting to resume download from: %s", partialPath)
@@ -201,10 +332,20 @@ Examples:
 		
 		// Derive output path from partial path
 		outputPath = strings.TrimSuffix(partialPath, ".part")
+
+		// Restore the share and settings the download was started with
+		resumeData, err := downloader.NewDownloadPlanner().LoadResumeMetadata(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to load resume metadata: %w", err)
+		}
+		restoreResumeSource(cmd, resumeData.Source)
+		var shareURL string
+		if resumeData.Source != nil {
+			shareURL = resumeData.Source.ShareURL
+		}
 		
 		// Parse rate limit if provided
 		var rateLimitBytes int64
-		var err error
 		if rateLimit != "" {
 			rateLimitBytes, err = utils.ParseRateLimit(rateLimit)
 			if err != nil {
@@ -218,8 +359,11 @@ Examples:
 			if rateLimitBytes > 0 {
 				fmt.Printf("🚦 Rate limit: %s (%d bytes/sec)\n", rateLimit, rateLimitBytes)
 			}
-			if cookiesPath != "" {
-				fmt.Printf("🍪 Using cookies from: %s\n", cookiesPath)
+			if rateSchedule != nil {
+				fmt.Printf("🕒 Rate schedule: %s\n", rateSchedule)
+			}
+			if cookies := currentCookies(); cookies.set() {
+				fmt.Printf("🍪 Using cookies from: %s\n", cookies)
 			}
 			if proxyURL != "" {
 				fmt.Printf("🌐 Using proxy: %s\n", proxyURL)
@@ -230,55 +374,126 @@ Examples:
 		internal.LogInfo("Resume configuration complete - ready to resume download")
 		
 		// Execute the resume workflow
-		return executeResumeWorkflow(partialPath, threads, rateLimitBytes, cookiesPath, proxyURL, quiet)
+		return executeResumeWorkflow(partialPath, shareURL, threads, rateLimitBytes, currentCookies(), proxyURL, quiet)
 	},
 }
 
-// loadConfiguration loads configuration from environment variables and merges with CLI flags
-func loadConfiguration() error {
-	config = internal.DefaultConfig()
-	config.LoadFromEnv()
+// loadConfiguration loads the configuration from the defaults, the config file
+// profile and environment variables, and merges it with the CLI flags of cmd
+func loadConfiguration(cmd *cobra.Command) error {
+	if configPath == "" {
+		configPath = os.Getenv("TERAFETCH_CONFIG")
+	}
+	if profile == "" {
+		profile = os.Getenv("TERAFETCH_PROFILE")
+	}
+
+	var err error
+	config, err = internal.LoadConfig(configPath, profile)
+	if err != nil {
+		return err
+	}
 	
-	// Override with environment variables if CLI flags are not set
-	if threads == 8 { // Default value, check if env var should override
-		if envThreads := os.Getenv("TERAFETCH_THREADS"); envThreads != "" {
-			threads = config.DefaultThreads
+	// Flags the user set win over everything else; the rest take the merged value
+	flags := cmd.Flags()
+	changed := func(flag, key string) bool {
+		if !flags.Changed(flag) {
+			return false
 		}
+		config.SetSource(key, "flag --"+flag)
+		return true
 	}
 	
-	if cookiesPath == "" {
-		cookiesPath = os.Getenv("TERAFETCH_COOKIES")
+	if changed("threads", internal.SettingThreads) {
+		config.DefaultThreads = threads
+	} else {
+		threads = config.DefaultThreads
 	}
 	
-	if proxyURL == "" {
-		proxyURL = os.Getenv("TERAFETCH_PROXY")
+	if changed("cookies", internal.SettingCookies) {
+		config.CookiesPath = cookiesPath
+	} else {
+		cookiesPath = config.CookiesPath
+	}
+	
+	if changed("cookies-from-browser", internal.SettingCookiesFromBrowser) {
+		config.CookiesBrowser = cookiesFromBrowser
+	} else {
+		cookiesFromBrowser = config.CookiesBrowser
+	}
+	// A cookie file, a browser and a Cookie header exclude each other, so the
+	// one given as a flag replaces the others from the config file or environment
+	switch {
+	case flags.Changed("cookie"):
+		cookiesPath, config.CookiesPath = "", ""
+		cookiesFromBrowser, config.CookiesBrowser = "", ""
+		config.SetSource(internal.SettingCookies, "flag --cookie")
+		config.SetSource(internal.SettingCookiesFromBrowser, "flag --cookie")
+	case flags.Changed("cookies-from-browser"):
+		cookiesPath, config.CookiesPath = "", ""
+		config.SetSource(internal.SettingCookies, "flag --cookies-from-browser")
+	case flags.Changed("cookies"):
+		cookiesFromBrowser, config.CookiesBrowser = "", ""
+		config.SetSource(internal.SettingCookiesFromBrowser, "flag --cookies")
+	}
+	if cookiesFromBrowser != "" {
+		if _, _, err := downloader.ParseBrowserSpec(cookiesFromBrowser); err != nil {
+			return fmt.Errorf("invalid cookies_from_browser: %w", err)
+		}
+	}
+	
+	if changed("proxy", internal.SettingProxy) {
+		config.ProxyURL = proxyURL
+	} else {
+		proxyURL = config.ProxyURL
 	}
 	
-	if rateLimit == "" {
-		rateLimit = os.Getenv("TERAFETCH_RATE_LIMIT")
+	if changed("limit-rate", internal.SettingRateLimit) {
+		config.RateLimit = rateLimit
+	} else {
+		rateLimit = config.RateLimit
 	}
 	
-	if !bypassAuth {
-		if envBypass := os.Getenv("TERAFETCH_BYPASS"); envBypass != "" {
-			bypassAuth = strings.ToLower(envBypass) == "true" || envBypass == "1"
+	if changed("rate-schedule", internal.SettingRateSchedule) {
+		config.RateSchedule = rateScheduleSpec
+	} else {
+		rateScheduleSpec = config.RateSchedule
+	}
+	rateSchedule = nil
+	if rateScheduleSpec != "" {
+		schedule, err := utils.ParseRateSchedule(rateScheduleSpec)
+		if err != nil {
+			return fmt.Errorf("invalid rate schedule: %w", err)
 		}
+		rateSchedule = schedule
+	}
+	
+	if changed("bypass", internal.SettingBypass) {
+		config.Bypass = bypassAuth
+	} else {
+		bypassAuth = config.Bypass
 	}
 	
 	// Update logging configuration based on CLI flags
-	if debug {
-		config.EnableDebug = true
-		config.LogLevel = "debug"
+	if changed("debug", internal.SettingDebug) {
+		config.EnableDebug = debug
+		if debug {
+			config.LogLevel = "debug"
+			config.SetSource(internal.SettingLogLevel, "flag --debug")
+		}
 	}
 	
-	if quiet {
-		config.QuietMode = true
+	// JSON output has already turned quiet on without the flag
+	if changed("quiet", internal.SettingQuiet) || quiet {
+		config.QuietMode = quiet
 	}
+	quiet = config.QuietMode
 	
-	if logLevel != "" {
+	if changed("log-level", internal.SettingLogLevel) {
 		config.LogLevel = logLevel
 	}
 	
-	if logFile != "" {
+	if changed("log-file", internal.SettingLogFile) {
 		config.LogFile = logFile
 	}
 	
@@ -347,6 +562,23 @@ func validateOutputPath(path string) error {
 	return nil
 }
 
+// validateOutputDir creates the output directory if needed and checks it is writable
+func validateOutputDir(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("output directory cannot be empty")
+	}
+
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return fmt.Errorf("output path is not a directory: %s", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create output directory: %v", err)
+	}
+
+	return validateOutputPath(filepath.Join(dir, ".terafetch_output"))
+}
+
 // validateCookiesFile validates the cookies file path and format
 func validateCookiesFile(path string) error {
 	if path == "" {
@@ -391,21 +623,54 @@ func init() {
 	// Add resume command
 	rootCmd.AddCommand(resumeCmd)
 	
+	// Config file flags apply to every command
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Config file to read instead of $XDG_CONFIG_HOME/terafetch/config.{toml,yaml} (env: TERAFETCH_CONFIG)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config file profile to apply (env: TERAFETCH_PROFILE)")
+	
 	// Define CLI flags with environment variable fallbacks
 	rootCmd.Flags().StringVarP(&outputPath, "output", "o", "", "Custom output file path")
-	rootCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to Netscape-format cookie file (env: TERAFETCH_COOKIES)")
+	rootCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to cookie file: Netscape format, JSON export or Cookie header (env: TERAFETCH_COOKIES)")
+	rootCmd.Flags().StringVar(&cookiesFromBrowser, "cookies-from-browser", "", cookiesFromBrowserUsage)
+	rootCmd.Flags().StringVar(&cookieHeader, "cookie", "", cookieHeaderUsage)
+	rootCmd.MarkFlagsMutuallyExclusive("cookies", "cookies-from-browser", "cookie")
 	rootCmd.Flags().IntVarP(&threads, "threads", "t", config.DefaultThreads, fmt.Sprintf("Number of download threads (1-32) (env: TERAFETCH_THREADS) (default %d)", config.DefaultThreads))
 	rootCmd.Flags().StringVarP(&rateLimit, "limit-rate", "r", "", "Bandwidth limit (e.g., 5M for 5MB/s) (env: TERAFETCH_RATE_LIMIT)")
+	rootCmd.Flags().StringVar(&rateScheduleSpec, "rate-schedule", "", rateScheduleUsage)
 	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bar output")
 	rootCmd.Flags().StringVar(&proxyURL, "proxy", "", "HTTP/SOCKS proxy URL (env: TERAFETCH_PROXY)")
 	rootCmd.Flags().BoolVar(&bypassAuth, "bypass", false, "Force bypass mode without authentication (env: TERAFETCH_BYPASS)")
+	rootCmd.Flags().StringVar(&password, "password", "", "Share password (extraction code) for protected shares, also read from a pwd= URL parameter")
+	rootCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "Download every file of a folder share, keeping its layout under --output")
+	rootCmd.Flags().StringVarP(&inputFile, "input-file", "i", "", "Read share URLs from a file, one per line (- for stdin)")
+	rootCmd.Flags().IntVar(&concurrency, "concurrent-files", 1, "Number of files downloaded at once in batch mode (1-16)")
+	rootCmd.Flags().BoolVar(&verify, "verify", true, "Verify the MD5 checksum of finished downloads")
+	rootCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip MD5 checksum verification")
+	rootCmd.MarkFlagsMutuallyExclusive("verify", "no-verify")
+	rootCmd.Flags().StringVar(&outputFmt, "output-format", "text", "Output format: text, or json for one JSON event per line on stdout")
+	rootCmd.Flags().BoolVar(&jsonOutput, "json", false, "Shorthand for --output-format json")
+	rootCmd.MarkFlagsMutuallyExclusive("output-format", "json")
 	
 	// Add flags to resume command as well
-	resumeCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to Netscape-format cookie file (env: TERAFETCH_COOKIES)")
+	resumeCmd.Flags().StringVarP(&cookiesPath, "cookies", "c", "", "Path to cookie file: Netscape format, JSON export or Cookie header (env: TERAFETCH_COOKIES)")
+	resumeCmd.Flags().StringVar(&cookiesFromBrowser, "cookies-from-browser", "", cookiesFromBrowserUsage)
+	resumeCmd.Flags().StringVar(&cookieHeader, "cookie", "", cookieHeaderUsage)
+	resumeCmd.MarkFlagsMutuallyExclusive("cookies", "cookies-from-browser", "cookie")
 	resumeCmd.Flags().IntVarP(&threads, "threads", "t", config.DefaultThreads, fmt.Sprintf("Number of download threads (1-32) (env: TERAFETCH_THREADS) (default %d)", config.DefaultThreads))
 	resumeCmd.Flags().StringVarP(&rateLimit, "limit-rate", "r", "", "Bandwidth limit (e.g., 5M for 5MB/s) (env: TERAFETCH_RATE_LIMIT)")
+	resumeCmd.Flags().StringVar(&rateScheduleSpec, "rate-schedule", "", rateScheduleUsage)
 	resumeCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress bar output")
 	resumeCmd.Flags().StringVar(&proxyURL, "proxy", "", "HTTP/SOCKS proxy URL (env: TERAFETCH_PROXY)")
+	resumeCmd.Flags().BoolVar(&bypassAuth, "bypass", false, "Re-resolve the download link without authentication (env: TERAFETCH_BYPASS)")
+	resumeCmd.Flags().StringVar(&password, "password", "", "Share password (extraction code) for re-resolving the download link of a protected share")
+	resumeCmd.Flags().BoolVar(&noRefresh, "no-refresh", false, "Reuse the stored download link instead of resolving a fresh one from the share")
+	resumeCmd.Flags().BoolVar(&verify, "verify", true, "Verify the MD5 checksum of finished downloads")
+	resumeCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip MD5 checksum verification")
+	resumeCmd.MarkFlagsMutuallyExclusive("verify", "no-verify")
+	resumeCmd.Flags().StringVar(&outputFmt, "output-format", "text", "Output format: text, or json for one JSON event per line on stdout")
+	resumeCmd.Flags().BoolVar(&jsonOutput, "json", false, "Shorthand for --output-format json")
+	resumeCmd.MarkFlagsMutuallyExclusive("output-format", "json")
+	resumeCmd.Flags().BoolVar(&resumeAll, "all", false, "Resume every interrupted download found in the given directory")
+	resumeCmd.Flags().IntVar(&concurrency, "concurrent-files", 1, "Number of files resumed at once with --all (1-16)")
 	
 	// Logging flags
 	rootCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging with file and line information (env: TERAFETCH_DEBUG)")
@@ -439,12 +704,23 @@ Use "{{.CommandPath}} [command] --help" for more information about a command.{{e
 `)
 }
 
+// skipVerify reports whether checksum verification was turned off with
+// --no-verify or --verify=false
+func skipVerify() bool {
+	return noVerify || !verify
+}
+
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	var reported reportedError
+	if !errors.As(err, &reported) {
+		events.EmitError("", "", err)
+	}
+	return err
 }
 
 // executeDownloadWorkflow implements the complete download workflow
-func executeDownloadWorkflow(url, outputPath string, threads int, rateLimitBytes int64, cookiesPath, proxyURL string, quiet bool) error {
+func executeDownloadWorkflow(url, outputPath string, threads int, rateLimitBytes int64, cookies cookieSource, proxyURL string, quiet bool) error {
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -462,33 +738,16 @@ func executeDownloadWorkflow(url, outputPath string, threads int, rateLimitBytes
 	}()
 
 	// Initialize components
-	resolver := downloader.NewTeraboxResolver()
+	client := newHTTPClient(proxyURL)
+	resolver := newResolver(client)
 	authManager := downloader.NewCookieAuthManager()
-	engine := downloader.NewMultiThreadEngine()
+	engine := downloader.NewMultiThreadEngineWithClient(client)
+	attachEvents(resolver, engine)
 
 	// Load authentication if cookies provided
-	var authContext *internal.AuthContext
-	if cookiesPath != "" {
-		internal.LogInfo("Loading authentication from cookies file: %s", cookiesPath)
-		var err error
-		authContext, err = authManager.LoadCookies(cookiesPath)
-		if err != nil {
-			return fmt.Errorf("failed to load cookies: %w", err)
-		}
-
-		// Validate session
-		if err := authManager.ValidateSession(authContext); err != nil {
-			internal.LogWarn("Session validation failed: %v", err)
-			if !quiet {
-				fmt.Printf("⚠️  Warning: Session validation failed: %v\n", err)
-				fmt.Printf("   Attempting to continue with potentially expired credentials...\n")
-			}
-		} else {
-			internal.LogInfo("Authentication session validated successfully")
-			if !quiet {
-				fmt.Printf("✅ Authentication validated (expires: %s)\n", authContext.ExpiresAt.Format(time.RFC3339))
-			}
-		}
+	authContext, err := loadAuthContext(ctx, client, authManager, cookies, quiet)
+	if err != nil {
+		return err
 	}
 
 	// Step 1: Resolve the URL to get file metadata
@@ -497,50 +756,13 @@ func executeDownloadWorkflow(url, outputPath string, threads int, rateLimitBytes
 		fmt.Printf("🔍 Resolving download link...\n")
 	}
 
-	var fileMetadata *internal.FileMetadata
-	var err error
-
-	// Check if bypass mode is forced
-	if bypassAuth {
-		internal.LogInfo("Bypass mode forced, skipping authentication")
-		if !quiet {
-			fmt.Printf("🔓 Bypass mode enabled - attempting without authentication...\n")
-		}
-		fileMetadata, err = resolver.ResolveWithBypass(url)
-	} else if authContext != nil {
-		// Try private link resolution first
-		fileMetadata, err = resolver.ResolvePrivateLink(url, authContext)
-		if err != nil {
-			internal.LogWarn("Private link resolution failed: %v, trying public resolution", err)
-			// Fallback to public resolution
-			fileMetadata, err = resolver.ResolvePublicLink(url)
-		}
-	} else {
-		// Public link resolution
-		fileMetadata, err = resolver.ResolvePublicLink(url)
-	}
-
-	// If all standard methods failed and bypass wasn't forced, try bypass mode
-	if err != nil && !bypassAuth {
-		internal.LogWarn("Standard resolution failed: %v, attempting bypass mode", err)
-		if !quiet {
-			fmt.Printf("⚠️  Standard resolution failed, trying bypass mode...\n")
-		}
-		
-		fileMetadata, err = resolver.ResolveWithBypass(url)
-		if err != nil {
-			internal.LogError("All resolution methods failed: %v", err)
-			return fmt.Errorf("failed to resolve download URL: %w", err)
-		}
-		
-		if !quiet {
-			fmt.Printf("✅ Bypass mode successful!\n")
-		}
-	}
-
+	fileMetadata, access, err := resolveFileMetadata(ctx, resolver, url, authContext, password, quiet)
 	if err != nil {
-		internal.LogError("URL resolution failed: %v", err)
-		return fmt.Errorf("failed to resolve download URL: %w", err)
+		return err
+	}
+	engine.SetLinkRefresher(linkRefresher(resolver, url, authContext, password))
+	if limiter := scheduledRateLimiter(ctx, rateLimitBytes); limiter != nil {
+		engine.SetRateLimiter(limiter)
 	}
 
 	internal.LogInfo("URL resolved successfully: filename=%s, size=%d bytes", fileMetadata.Filename, fileMetadata.Size)
@@ -561,6 +783,8 @@ func executeDownloadWorkflow(url, outputPath string, threads int, rateLimitBytes
 		RateLimit:  rateLimitBytes,
 		ProxyURL:   proxyURL,
 		Quiet:      quiet,
+		SkipVerify: skipVerify(),
+		Source:     resumeSource(url, access, outputPath, threads),
 	}
 
 	// Step 3: Execute the download
@@ -569,39 +793,35 @@ func executeDownloadWorkflow(url, outputPath string, threads int, rateLimitBytes
 		fmt.Printf("🚀 Starting download...\n")
 	}
 
-	// Monitor context for cancellation during download
-	downloadErr := make(chan error, 1)
-	go func() {
-		downloadErr <- engine.Download(fileMetadata, downloadConfig)
-	}()
-
-	// Wait for download completion or cancellation
-	select {
-	case err := <-downloadErr:
-		if err != nil {
-			internal.LogError("Download failed: %v", err)
-			return fmt.Errorf("download failed: %w", err)
-		}
-		
-		internal.LogInfo("Download completed successfully: %s", outputPath)
-		if !quiet {
-			fmt.Printf("✅ Download completed successfully!\n")
-			fmt.Printf("📁 File saved to: %s\n", outputPath)
+	// The engine stops its workers and flushes resume data when ctx is cancelled
+	handle := engine.Start(ctx, fileMetadata, downloadConfig)
+	restoreTerminal := watchPauseKey(handle, quiet)
+	err = handle.Wait()
+	restoreTerminal()
+	if err != nil {
+		if ctx.Err() != nil {
+			internal.LogInfo("Download cancelled by user")
+			if !quiet {
+				fmt.Printf("⏸️  Download cancelled. Resume data has been saved.\n")
+				fmt.Printf("   Use 'terafetch resume %s.part' to continue later.\n", outputPath)
+			}
+			return fmt.Errorf("download cancelled by user")
 		}
-		return nil
 
-	case <-ctx.Done():
-		internal.LogInfo("Download cancelled by user")
-		if !quiet {
-			fmt.Printf("⏸️  Download cancelled. Resume data has been saved.\n")
-			fmt.Printf("   Use 'terafetch resume %s.part' to continue later.\n", outputPath)
-		}
-		return fmt.Errorf("download cancelled by user")
+		internal.LogError("Download failed: %v", err)
+		return fmt.Errorf("download failed: %w", err)
 	}
+
+	internal.LogInfo("Download completed successfully: %s", outputPath)
+	if !quiet {
+		fmt.Printf("✅ Download completed successfully!\n")
+		fmt.Printf("📁 File saved to: %s\n", outputPath)
+	}
+	return nil
 }
 
 // executeResumeWorkflow implements the resume workflow
-func executeResumeWorkflow(partialPath string, threads int, rateLimitBytes int64, cookiesPath, proxyURL string, quiet bool) error {
+func executeResumeWorkflow(partialPath, shareURL string, threads int, rateLimitBytes int64, cookies cookieSource, proxyURL string, quiet bool) error {
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -619,14 +839,31 @@ func executeResumeWorkflow(partialPath string, threads int, rateLimitBytes int64
 	}()
 
 	// Initialize components
-	engine := downloader.NewMultiThreadEngine()
+	client := newHTTPClient(proxyURL)
+	resolver := newResolver(client)
+	engine := downloader.NewMultiThreadEngineWithClient(client)
+	attachEvents(resolver, engine)
+
+	// Stored links usually expire before a download is resumed, so a fresh one
+	// is resolved from the share unless --no-refresh is given
+	if !noRefresh {
+		authContext, err := loadAuthContext(ctx, client, downloader.NewCookieAuthManager(), cookies, quiet)
+		if err != nil {
+			return err
+		}
+		engine.SetLinkRefresher(linkRefresher(resolver, shareURL, authContext, password))
+	}
+	if limiter := scheduledRateLimiter(ctx, rateLimitBytes); limiter != nil {
+		engine.SetRateLimiter(limiter)
+	}
 
 	// Create download configuration
 	downloadConfig := &internal.DownloadConfig{
-		Threads:   threads,
-		RateLimit: rateLimitBytes,
-		ProxyURL:  proxyURL,
-		Quiet:     quiet,
+		Threads:    threads,
+		RateLimit:  rateLimitBytes,
+		ProxyURL:   proxyURL,
+		Quiet:      quiet,
+		SkipVerify: skipVerify(),
 	}
 
 	// Execute the resume
@@ -635,36 +872,379 @@ func executeResumeWorkflow(partialPath string, threads int, rateLimitBytes int64
 		fmt.Printf("🔄 Resuming download...\n")
 	}
 
-	// Monitor context for cancellation during resume
-	resumeErr := make(chan error, 1)
-	go func() {
-		resumeErr <- engine.Resume(partialPath, downloadConfig)
-	}()
+	// The engine stops its workers and flushes resume data when ctx is cancelled
+	handle := engine.StartResume(ctx, partialPath, downloadConfig)
+	restoreTerminal := watchPauseKey(handle, quiet)
+	err := handle.Wait()
+	restoreTerminal()
+	if err != nil {
+		if ctx.Err() != nil {
+			internal.LogInfo("Resume cancelled by user")
+			if !quiet {
+				fmt.Printf("⏸️  Resume cancelled. Resume data has been saved.\n")
+				fmt.Printf("   Use 'terafetch resume %s' to continue later.\n", partialPath)
+			}
+			return fmt.Errorf("resume cancelled by user")
+		}
+
+		internal.LogError("Resume failed: %v", err)
+		return fmt.Errorf("resume failed: %w", err)
+	}
+
+	outputPath := strings.TrimSuffix(partialPath, ".part")
+	internal.LogInfo("Resume completed successfully: %s", outputPath)
+	if !quiet {
+		fmt.Printf("✅ Resume completed successfully!\n")
+		fmt.Printf("📁 File saved to: %s\n", outputPath)
+	}
+	return nil
+}
+
+// Access methods reported by resolveShare, recorded as the auth mode in resume metadata
+const (
+	accessPublic   = internal.AuthModePublic
+	accessCookies  = internal.AuthModeCookies
+	accessPassword = internal.AuthModePassword
+	accessBypass   = internal.AuthModeBypass
+)
+
+// resolveFileMetadata resolves a share URL, trying password unlock, authenticated,
+// public and bypass resolution in turn, and reports which access method succeeded
+func resolveFileMetadata(ctx context.Context, resolver *downloader.TeraboxResolver, url string, authContext *internal.AuthContext, password string, quiet bool) (*internal.FileMetadata, string, error) {
+	fileMetadata, access, err := resolveShare(ctx, resolver, url, authContext, password, quiet)
+	if err != nil {
+		return nil, "", err
+	}
+
+	events.Emit(utils.Event{Type: utils.EventResolved, URL: url, Metadata: fileMetadata})
+	return fileMetadata, access, nil
+}
+
+// resolveShare works like resolveFileMetadata without emitting a resolved
+// event. The access method is public, cookies, password or bypass.
+func resolveShare(ctx context.Context, resolver *downloader.TeraboxResolver, url string, authContext *internal.AuthContext, password string, quiet bool) (*internal.FileMetadata, string, error) {
+	// Password-protected shares need the share-verify handshake first
+	if password == "" {
+		if urlInfo, err := newURLValidator().ParseURL(url); err == nil {
+			password = urlInfo.Password
+		}
+	}
+	if password != "" && !bypassAuth {
+		internal.LogInfo("Unlocking password-protected share")
+		if !quiet {
+			fmt.Printf("🔑 Unlocking password-protected share...\n")
+		}
+		fileMetadata, _, err := resolver.ResolveProtectedLink(ctx, url, password, authContext)
+		if err != nil {
+			internal.LogError("Share password handshake failed: %v", err)
+			return nil, "", fmt.Errorf("failed to unlock password-protected share: %w", err)
+		}
+		return fileMetadata, accessPassword, nil
+	}
+
+	var fileMetadata *internal.FileMetadata
+	var err error
+	access := accessPublic
+
+	// Check if bypass mode is forced
+	if bypassAuth {
+		internal.LogInfo("Bypass mode forced, skipping authentication")
+		if !quiet {
+			fmt.Printf("🔓 Bypass mode enabled - attempting without authentication...\n")
+		}
+		fileMetadata, err = resolver.ResolveWithBypass(ctx, url)
+		access = accessBypass
+	} else if authContext != nil {
+		// Try private link resolution first
+		fileMetadata, err = resolver.ResolvePrivateLink(ctx, url, authContext)
+		access = accessCookies
+		if err != nil {
+			access = accessPublic
+			internal.LogWarn("Private link resolution failed: %v, trying public resolution", err)
+			// Fallback to public resolution
+			fileMetadata, err = resolver.ResolvePublicLink(ctx, url)
+		}
+	} else {
+		// Public link resolution
+		fileMetadata, err = resolver.ResolvePublicLink(ctx, url)
+	}
+
+	// Bypass cannot get past a share password, so report it instead
+	if err != nil && downloader.IsSharePasswordError(err) {
+		internal.LogError("Share requires a password: %v", err)
+		return nil, "", fmt.Errorf("share is password-protected, provide the extraction code with --password: %w", err)
+	}
 
-	// Wait for resume completion or cancellation
-	select {
-	case err := <-resumeErr:
+	// If all standard methods failed and bypass wasn't forced, try bypass mode
+	if err != nil && !bypassAuth && ctx.Err() == nil {
+		internal.LogWarn("Standard resolution failed: %v, attempting bypass mode", err)
+		if !quiet {
+			fmt.Printf("⚠️  Standard resolution failed, trying bypass mode...\n")
+		}
+		
+		fileMetadata, err = resolver.ResolveWithBypass(ctx, url)
 		if err != nil {
-			internal.LogError("Resume failed: %v", err)
-			return fmt.Errorf("resume failed: %w", err)
+			internal.LogError("All resolution methods failed: %v", err)
+			return nil, "", fmt.Errorf("failed to resolve download URL: %w", err)
 		}
+		access = accessBypass
 		
-		outputPath := strings.TrimSuffix(partialPath, ".part")
-		internal.LogInfo("Resume completed successfully: %s", outputPath)
 		if !quiet {
-			fmt.Printf("✅ Resume completed successfully!\n")
-			fmt.Printf("📁 File saved to: %s\n", outputPath)
+			fmt.Printf("✅ Bypass mode successful!\n")
 		}
-		return nil
+	}
+
+	if err != nil {
+		internal.LogError("URL resolution failed: %v", err)
+		return nil, "", fmt.Errorf("failed to resolve download URL: %w", err)
+	}
+
+	return fileMetadata, access, nil
+}
+
+// cookieSource is where the session cookies come from: a cookie file, a
+// browser profile or a Cookie header, at most one of them set
+type cookieSource struct {
+	path    string
+	browser string
+	header  string
+}
 
-	case <-ctx.Done():
-		internal.LogInfo("Resume cancelled by user")
+// currentCookies returns the cookie source the flags and configuration select
+func currentCookies() cookieSource {
+	return cookieSource{path: cookiesPath, browser: cookiesFromBrowser, header: cookieHeader}
+}
+
+// set reports whether any cookies are configured
+func (c cookieSource) set() bool {
+	return c != cookieSource{}
+}
+
+// String describes the source for status output, leaving out the header's values
+func (c cookieSource) String() string {
+	switch {
+	case c.browser != "":
+		return c.browser + " (browser)"
+	case c.header != "":
+		return "--cookie header"
+	default:
+		return c.path
+	}
+}
+
+// loadAuthContext loads cookies and validates the session when a cookies file,
+// a browser to read cookies from or a Cookie header is configured, or else a
+// session has been stored with 'terafetch auth login'. The session is checked
+// online through client until ctx is done.
+func loadAuthContext(ctx context.Context, client *utils.HTTPClient, authManager *downloader.CookieAuthManager, cookies cookieSource, quiet bool) (*internal.AuthContext, error) {
+	authContext, err := loadCookies(authManager, cookies, quiet)
+	if err != nil || authContext == nil {
+		return nil, err
+	}
+
+	// Validate session
+	if err := authManager.ValidateSession(authContext); err != nil {
+		internal.LogWarn("Session validation failed: %v", err)
 		if !quiet {
-			fmt.Printf("⏸️  Resume cancelled. Resume data has been saved.\n")
-			fmt.Printf("   Use 'terafetch resume %s' to continue later.\n", partialPath)
+			fmt.Printf("⚠️  Warning: Session validation failed: %v\n", err)
+			fmt.Printf("   Attempting to continue with potentially expired credentials...\n")
+		}
+		return authContext, nil
+	}
+
+	// Confirm the session with Terabox, saving any cookies it rotates
+	if err := checkSessionOnline(ctx, client, authManager, authContext); errors.Is(err, downloader.ErrSessionRevoked) {
+		internal.LogWarn("Session validation failed: %v", err)
+		if !quiet {
+			fmt.Printf("⚠️  Warning: Session validation failed: %v\n", err)
+			fmt.Printf("   Attempting to continue with revoked credentials...\n")
+		}
+		return authContext, nil
+	} else if err != nil {
+		internal.LogWarn("Could not check the session online: %v", err)
+	}
+
+	internal.LogInfo("Authentication session validated successfully")
+	if !quiet {
+		if account := describeAccount(authContext); account != "" {
+			fmt.Printf("✅ Authentication validated as %s (expires: %s)\n", account, authContext.ExpiresAt.Format(time.RFC3339))
+		} else {
+			fmt.Printf("✅ Authentication validated (expires: %s)\n", authContext.ExpiresAt.Format(time.RFC3339))
+		}
+	}
+
+	return authContext, nil
+}
+
+// checkSessionOnline asks the account API whether the session is live, which
+// also fills in the account and writes rotated cookies back to their source
+func checkSessionOnline(ctx context.Context, client *utils.HTTPClient, authManager *downloader.CookieAuthManager, authContext *internal.AuthContext) error {
+	authManager.SetHTTPClient(client)
+	return authManager.RefreshSession(ctx, authContext)
+}
+
+// describeAccount names the account a session belongs to, once the account
+// API has been asked; it returns an empty string before that
+func describeAccount(authContext *internal.AuthContext) string {
+	switch {
+	case authContext.Username == "":
+		return ""
+	case authContext.VIP:
+		return authContext.Username + " (VIP)"
+	default:
+		return authContext.Username
+	}
+}
+
+// loadCookies loads the cookies of the given source into authManager. Without
+// one, the stored session is used unless bypass mode is on; it returns nil
+// when there are no cookies at all.
+func loadCookies(authManager *downloader.CookieAuthManager, cookies cookieSource, quiet bool) (*internal.AuthContext, error) {
+	var authContext *internal.AuthContext
+	var err error
+	switch {
+	case cookies.browser != "":
+		internal.LogInfo("Loading authentication from browser cookies: %s", cookies.browser)
+		authContext, err = authManager.LoadBrowserCookies(cookies.browser)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cookies from %s: %w", cookies.browser, err)
 		}
-		return fmt.Errorf("resume cancelled by user")
+	case cookies.header != "":
+		internal.LogInfo("Loading authentication from the --cookie header")
+		authContext, err = authManager.LoadCookieHeader(cookies.header)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cookie header: %w", err)
+		}
+	case cookies.path != "":
+		internal.LogInfo("Loading authentication from cookies file: %s", cookies.path)
+		authContext, err = authManager.LoadCookies(cookies.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cookies: %w", err)
+		}
+	case !bypassAuth:
+		return loadStoredSession(authManager, quiet)
+	}
+	return authContext, nil
+}
+
+// resumeSource records how a download was started, so 'terafetch resume' can
+// re-resolve the share and restore the settings
+func resumeSource(url, access, outputPath string, threads int) *internal.ResumeSource {
+	source := &internal.ResumeSource{
+		ShareURL:   url,
+		AuthMode:   access,
+		Threads:    threads,
+		OutputPath: outputPath,
+	}
+	if absPath, err := filepath.Abs(outputPath); err == nil {
+		source.OutputPath = absPath
+	}
+	if cookiesPath != "" && access != accessBypass {
+		source.CookiesPath = cookiesPath
+		if absPath, err := filepath.Abs(cookiesPath); err == nil {
+			source.CookiesPath = absPath
+		}
+	}
+	if cookiesFromBrowser != "" && access != accessBypass {
+		source.CookiesBrowser = cookiesFromBrowser
+	}
+	// A --cookie header is not recorded, as it would put the session on disk
+	return source
+}
+
+// resumeSettings are the per-download settings a resume runs with
+type resumeSettings struct {
+	threads int
+	cookies cookieSource
+	bypass  bool
+}
+
+// restoredSettings merges the settings recorded when a download was started
+// with the resume command's own. Flags and environment variables the user set
+// take precedence.
+func restoredSettings(cmd *cobra.Command, source *internal.ResumeSource) resumeSettings {
+	settings := resumeSettings{threads: threads, cookies: currentCookies(), bypass: bypassAuth}
+	if source == nil {
+		return settings
+	}
+
+	if source.Threads > 0 && !cmd.Flags().Changed("threads") && os.Getenv("TERAFETCH_THREADS") == "" {
+		settings.threads = source.Threads
+	}
+	if !settings.cookies.set() {
+		settings.cookies = cookieSource{path: source.CookiesPath, browser: source.CookiesBrowser}
+	}
+	if source.AuthMode == accessBypass && !cmd.Flags().Changed("bypass") && os.Getenv("TERAFETCH_BYPASS") == "" {
+		settings.bypass = true
+	}
+	if source.AuthMode == accessPassword && password == "" && !strings.Contains(source.ShareURL, "pwd=") {
+		internal.LogWarn("Share %s is password-protected, pass --password to re-resolve its download link", source.ShareURL)
+	}
+	internal.LogInfo("Restored settings from resume metadata: share=%s, auth=%s, threads=%d", source.ShareURL, source.AuthMode, settings.threads)
+	return settings
+}
+
+// restoreResumeSource applies the settings recorded when a download was
+// started to the resume command
+func restoreResumeSource(cmd *cobra.Command, source *internal.ResumeSource) {
+	settings := restoredSettings(cmd, source)
+	threads, bypassAuth = settings.threads, settings.bypass
+	cookiesPath, cookiesFromBrowser, cookieHeader = settings.cookies.path, settings.cookies.browser, settings.cookies.header
+}
+
+// cookiesFromBrowserUsage is the help text of the --cookies-from-browser flag
+const cookiesFromBrowserUsage = "Read Terabox cookies from a browser profile: firefox, chromium or chrome, optionally followed by :PROFILE (env: TERAFETCH_COOKIES_FROM_BROWSER)"
+
+// cookieHeaderUsage is the help text of the --cookie flag
+const cookieHeaderUsage = `Cookie header to authenticate with instead of a cookie file, e.g. "BDUSS=...; STOKEN=..."`
+
+// rateScheduleUsage is the help text of the --rate-schedule flag
+const rateScheduleUsage = "Time-of-day bandwidth limits, e.g. 08:00-18:00=2M,18:00-08:00=0 (0 = unlimited); --limit-rate applies outside the windows (env: TERAFETCH_RATE_SCHEDULE)"
+
+// newHTTPClient creates an HTTP client with the configured timeout, retry
+// policy and user agents, going through proxyURL if set
+func newHTTPClient(proxyURL string) *utils.HTTPClient {
+	return utils.NewHTTPClientFromConfig(config, proxyURL)
+}
+
+// newURLValidator creates a URL validator accepting the configured domains
+func newURLValidator() *utils.URLValidator {
+	return utils.NewURLValidatorWithDomains(config.AllowedDomains)
+}
+
+// newResolver creates a resolver using client that accepts share URLs from
+// the configured domains
+func newResolver(client *utils.HTTPClient) *downloader.TeraboxResolver {
+	resolver := downloader.NewTeraboxResolverWithClient(client)
+	resolver.SetAllowedDomains(config.AllowedDomains)
+	return resolver
+}
+
+// scheduledRateLimiter returns a limiter that follows the rate schedule until
+// ctx is done, with rateLimitBytes applying outside the scheduled windows. It
+// returns nil when no schedule is set.
+func scheduledRateLimiter(ctx context.Context, rateLimitBytes int64) internal.RateLimiter {
+	if rateSchedule == nil {
+		return nil
+	}
+	limiter := utils.NewTokenBucketLimiter(rateSchedule.RateAt(time.Now(), rateLimitBytes))
+	go rateSchedule.Run(ctx, rateLimitBytes, limiter.SetRate)
+	return limiter
+}
+
+// linkRefresher returns the refresher that re-resolves expired download links
+// of a share the way it was first resolved: with the session cookies unless
+// bypass mode is forced, and with the share password when there is one
+func linkRefresher(resolver *downloader.TeraboxResolver, url string, authContext *internal.AuthContext, password string) downloader.LinkRefresher {
+	if password == "" && url != "" {
+		if urlInfo, err := newURLValidator().ParseURL(url); err == nil {
+			password = urlInfo.Password
+		}
+	}
+	if bypassAuth {
+		authContext = nil
 	}
+	return resolver.LinkRefresher(url, authContext, password)
 }
 
 // formatFileSize formats a file size in bytes to a human-readable string
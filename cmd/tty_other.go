@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package cmd
+
+import "errors"
+
+// enableKeypresses is not supported on this platform, so the pause key is off
+func enableKeypresses(fd int) (func(), error) {
+	return nil, errors.New("single keypresses are not supported on this platform")
+}
+
+// disableEcho is not supported on this platform, so passphrases come from
+// TERAFETCH_KEY
+func disableEcho(fd int) (func(), error) {
+	return nil, errors.New("reading a passphrase is not supported on this platform")
+}
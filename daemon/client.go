@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Client talks to a running daemon over its Unix socket
+type Client struct {
+	socketPath string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the daemon listening on socketPath
+func NewClient(socketPath string) *Client {
+	return &Client{
+		socketPath: socketPath,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Add queues a download
+func (c *Client) Add(ctx context.Context, req AddRequest) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodPost, "/jobs", req, http.StatusCreated, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List returns every job in the queue
+func (c *Client) List(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	if err := c.do(ctx, http.MethodGet, "/jobs", nil, http.StatusOK, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Remove deletes a job from the queue
+func (c *Client) Remove(ctx context.Context, id int) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, http.MethodDelete, "/jobs/"+strconv.Itoa(id), nil, http.StatusOK, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// do sends a request to the daemon and decodes the response into out
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, expected int, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// The host is ignored; every request goes to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://terafetch"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("daemon is not running (no socket at %s), start it with 'terafetch daemon'", c.socketPath)
+		}
+		return fmt.Errorf("failed to reach daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		var errResp errorResponse
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("daemon: %s", errResp.Error)
+		}
+		return fmt.Errorf("daemon returned HTTP %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+	return nil
+}
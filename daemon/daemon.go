@@ -0,0 +1,612 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"terafetch/downloader"
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+const (
+	// QueueFileName is the name of the queue file inside the state directory
+	QueueFileName = "queue.json"
+	// SocketFileName is the name of the daemon's Unix socket inside the state directory
+	SocketFileName = "daemon.sock"
+)
+
+// Config configures a Daemon
+type Config struct {
+	StateDir   string // Holds the queue file and the socket
+	OutputDir  string // Default directory for jobs added without one
+	Concurrent int    // Number of jobs downloaded at once
+	Threads    int    // Threads per download
+	RateLimit  int64  // Bandwidth shared by all jobs in bytes per second, 0 for unlimited
+	SkipVerify bool
+
+	// RPCListen is the TCP address of the aria2-compatible JSON-RPC endpoint;
+	// empty disables it. RPCSecret is the token clients must send, and
+	// RPCAllowOriginAll lets browser UIs on other origins call it over HTTP.
+	RPCListen         string
+	RPCSecret         string
+	RPCAllowOriginAll bool
+
+	// Resolve turns a share URL into file metadata. The daemon re-resolves a job
+	// every time it starts, since download links expire.
+	Resolve func(ctx context.Context, url, password string) (*internal.FileMetadata, error)
+
+	// NewEngine creates the engine a job is downloaded with
+	NewEngine func() *downloader.MultiThreadEngine
+
+	// Refresher returns the refresher that re-resolves a job's expired
+	// download link. Optional; without it a job fails when its link expires.
+	Refresher func(url, password string) downloader.LinkRefresher
+}
+
+// Daemon downloads the jobs in its queue, Concurrent at a time, and serves the
+// queue to clients over a Unix socket
+type Daemon struct {
+	config  Config
+	queue   *Queue
+	planner *downloader.DownloadPlanner
+
+	// limiter is shared by every job, and always set so the rate can be
+	// changed while the daemon runs
+	limiter internal.RateLimiter
+
+	mutex     sync.Mutex
+	running   map[int]*runningJob
+	reserved  map[string]bool // Output paths claimed by jobs still in the queue
+	rateLimit int64
+
+	subscribersMutex sync.Mutex
+	subscribers      []func(event JobEvent, job Job)
+
+	wake chan struct{}
+}
+
+// JobEvent is a change in a job's state reported to subscribers
+type JobEvent string
+
+const (
+	EventJobStarted   JobEvent = "start"
+	EventJobPaused    JobEvent = "pause"
+	EventJobStopped   JobEvent = "stop" // Removed from the queue
+	EventJobCompleted JobEvent = "complete"
+	EventJobFailed    JobEvent = "error"
+)
+
+// runningJob tracks a job that is being downloaded
+type runningJob struct {
+	cancel  context.CancelFunc
+	engine  *downloader.MultiThreadEngine // Set once the job is resolved
+	removed bool
+	paused  bool
+}
+
+// DefaultStateDir returns the state directory used when none is configured:
+// $TERAFETCH_STATE_DIR, or ~/.terafetch
+func DefaultStateDir() (string, error) {
+	if dir := os.Getenv("TERAFETCH_STATE_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".terafetch"), nil
+}
+
+// SocketPath returns the path of the daemon socket in stateDir
+func SocketPath(stateDir string) string {
+	return filepath.Join(stateDir, SocketFileName)
+}
+
+// New creates a daemon and loads its queue from the state directory
+func New(config Config) (*Daemon, error) {
+	if config.Resolve == nil || config.NewEngine == nil {
+		return nil, fmt.Errorf("daemon needs a resolver and an engine")
+	}
+	if config.Concurrent < 1 {
+		config.Concurrent = 1
+	}
+	if config.RPCListen != "" && config.RPCSecret == "" {
+		return nil, fmt.Errorf("the RPC endpoint needs a secret")
+	}
+
+	queue, err := LoadQueue(filepath.Join(config.StateDir, QueueFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Daemon{
+		config:    config,
+		queue:     queue,
+		planner:   downloader.NewDownloadPlanner(),
+		limiter:   utils.NewTokenBucketLimiter(config.RateLimit),
+		running:   make(map[int]*runningJob),
+		reserved:  make(map[string]bool),
+		rateLimit: config.RateLimit,
+		wake:      make(chan struct{}, 1),
+	}
+	for _, job := range queue.Jobs() {
+		if job.OutputPath != "" {
+			d.reserved[job.OutputPath] = true
+		}
+	}
+	return d, nil
+}
+
+// Run serves the socket and downloads queued jobs until ctx is cancelled.
+// Jobs interrupted by the shutdown are queued again for the next run.
+func (d *Daemon) Run(ctx context.Context) error {
+	listener, err := listen(SocketPath(d.config.StateDir))
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: d.handler()}
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	internal.LogInfo("Daemon listening on %s with %d concurrent downloads", SocketPath(d.config.StateDir), d.config.Concurrent)
+
+	var rpc *rpcServer
+	var rpcHTTP *http.Server
+	if d.config.RPCListen != "" {
+		rpcListener, err := net.Listen("tcp", d.config.RPCListen)
+		if err != nil {
+			server.Close()
+			return fmt.Errorf("failed to listen for RPC on %s: %w", d.config.RPCListen, err)
+		}
+
+		rpc = newRPCServer(d, d.config.RPCSecret, d.config.RPCAllowOriginAll)
+		go rpc.run(ctx)
+		rpcHTTP = &http.Server{Handler: rpc.handler()}
+		go func() {
+			serveErr <- rpcHTTP.Serve(rpcListener)
+		}()
+		internal.LogInfo("JSON-RPC listening on %s", rpcListener.Addr())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < d.config.Concurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.worker(ctx)
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	if rpcHTTP != nil {
+		// Shutdown doesn't track hijacked WebSocket connections
+		rpcHTTP.Shutdown(shutdownCtx)
+		rpc.closeSockets()
+	}
+	wg.Wait()
+
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("daemon server failed: %w", err)
+	}
+	internal.LogInfo("Daemon stopped")
+	return nil
+}
+
+// listen opens the daemon socket, replacing a stale socket file left by a
+// daemon that didn't shut down cleanly
+func listen(socketPath string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already running on %s", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// Add queues a new download
+func (d *Daemon) Add(url, password, outputDir string) (Job, error) {
+	if outputDir == "" {
+		outputDir = d.config.OutputDir
+	}
+	job, err := d.queue.Add(Job{URL: url, Password: password, OutputDir: outputDir})
+	if err != nil {
+		return Job{}, err
+	}
+
+	internal.LogInfo("Queued job %d: %s", job.ID, url)
+	d.notify()
+	return job, nil
+}
+
+// Remove deletes a job from the queue. A running download is stopped, and the
+// partial data of an unfinished job is deleted; a completed file is kept.
+func (d *Daemon) Remove(id int) (Job, error) {
+	d.mutex.Lock()
+	job, err := d.queue.Remove(id)
+	if err != nil {
+		d.mutex.Unlock()
+		return Job{}, err
+	}
+	internal.LogInfo("Removed job %d: %s", job.ID, job.URL)
+
+	if running, ok := d.running[id]; ok {
+		// The worker cleans up once the engine has stopped writing
+		running.removed = true
+		running.cancel()
+	} else {
+		if job.Status != StatusCompleted {
+			d.cleanup(job)
+		}
+		delete(d.reserved, job.OutputPath)
+	}
+	d.mutex.Unlock()
+
+	d.publish(EventJobStopped, job)
+	return job, nil
+}
+
+// Pause stops a queued or running job, keeping its progress. A paused job
+// isn't downloaded again until it is unpaused.
+func (d *Daemon) Pause(id int) (Job, error) {
+	d.mutex.Lock()
+	job, ok := d.queue.Get(id)
+	if !ok {
+		d.mutex.Unlock()
+		return Job{}, fmt.Errorf("job %d not found", id)
+	}
+	if job.Status == StatusPaused {
+		d.mutex.Unlock()
+		return job, nil
+	}
+	if job.Status != StatusQueued && job.Status != StatusDownloading {
+		d.mutex.Unlock()
+		return Job{}, fmt.Errorf("job %d is %s and can't be paused", id, job.Status)
+	}
+
+	if running, ok := d.running[id]; ok {
+		// The worker stops once the engine has flushed its resume metadata
+		running.paused = true
+		running.cancel()
+	}
+	d.update(id, func(j *Job) {
+		j.Status = StatusPaused
+	})
+	job.Status = StatusPaused
+	d.mutex.Unlock()
+
+	internal.LogInfo("Paused job %d: %s", job.ID, job.URL)
+	d.publish(EventJobPaused, job)
+	return job, nil
+}
+
+// Unpause queues a paused job again
+func (d *Daemon) Unpause(id int) (Job, error) {
+	d.mutex.Lock()
+	job, ok := d.queue.Get(id)
+	if !ok {
+		d.mutex.Unlock()
+		return Job{}, fmt.Errorf("job %d not found", id)
+	}
+	if job.Status != StatusPaused {
+		d.mutex.Unlock()
+		return Job{}, fmt.Errorf("job %d is not paused", id)
+	}
+	d.update(id, func(j *Job) {
+		j.Status = StatusQueued
+	})
+	job.Status = StatusQueued
+	d.mutex.Unlock()
+
+	internal.LogInfo("Unpaused job %d: %s", job.ID, job.URL)
+	d.notify()
+	return job, nil
+}
+
+// Job returns a job with its progress, as Jobs does
+func (d *Daemon) Job(id int) (Job, bool) {
+	job, ok := d.queue.Get(id)
+	if !ok {
+		return Job{}, false
+	}
+	jobs := []Job{job}
+	d.fillProgress(jobs)
+	return jobs[0], true
+}
+
+// SetRateLimit changes the bandwidth shared by all jobs, including the
+// running ones. 0 removes the limit.
+func (d *Daemon) SetRateLimit(bytesPerSecond int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.rateLimit = bytesPerSecond
+	d.limiter.SetRate(bytesPerSecond)
+	internal.LogInfo("Rate limit set to %d bytes/s", bytesPerSecond)
+}
+
+// RateLimit returns the bandwidth shared by all jobs in bytes per second, 0
+// when unlimited
+func (d *Daemon) RateLimit() int64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.rateLimit
+}
+
+// Subscribe registers fn to be called whenever a job starts, pauses, stops,
+// completes or fails. fn must not block.
+func (d *Daemon) Subscribe(fn func(event JobEvent, job Job)) {
+	d.subscribersMutex.Lock()
+	defer d.subscribersMutex.Unlock()
+	d.subscribers = append(d.subscribers, fn)
+}
+
+// publish reports a job event to every subscriber
+func (d *Daemon) publish(event JobEvent, job Job) {
+	d.subscribersMutex.Lock()
+	subscribers := d.subscribers
+	d.subscribersMutex.Unlock()
+
+	for _, fn := range subscribers {
+		fn(event, job)
+	}
+}
+
+// Jobs returns every job in the queue. The progress of running jobs comes from
+// their engine, that of other unfinished jobs from their resume metadata.
+func (d *Daemon) Jobs() []Job {
+	jobs := d.queue.Jobs()
+	d.fillProgress(jobs)
+	return jobs
+}
+
+// fillProgress sets Downloaded and Speed on jobs
+func (d *Daemon) fillProgress(jobs []Job) {
+	d.mutex.Lock()
+	engines := make(map[int]*downloader.MultiThreadEngine, len(d.running))
+	for id, running := range d.running {
+		if running.engine != nil {
+			engines[id] = running.engine
+		}
+	}
+	d.mutex.Unlock()
+
+	for i := range jobs {
+		job := &jobs[i]
+		if engine, ok := engines[job.ID]; ok {
+			if downloaded, _, speed, ok := engine.Progress(); ok {
+				job.Downloaded = downloaded
+				job.Speed = speed
+				continue
+			}
+		}
+
+		switch {
+		case job.Status == StatusCompleted && job.Metadata != nil:
+			job.Downloaded = job.Metadata.Size
+		case job.OutputPath != "":
+			if resumeData, err := d.planner.LoadResumeMetadata(job.OutputPath); err == nil {
+				job.Downloaded = downloadedBytes(resumeData.Segments)
+			}
+		}
+	}
+}
+
+// downloadedBytes sums the bytes already written across segments
+func downloadedBytes(segments []internal.SegmentInfo) int64 {
+	var total int64
+	for _, segment := range segments {
+		if segment.Completed {
+			total += segment.End - segment.Start + 1
+		} else {
+			total += segment.Downloaded
+		}
+	}
+	return total
+}
+
+// notify wakes an idle worker
+func (d *Daemon) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// worker downloads queued jobs one at a time until ctx is cancelled
+func (d *Daemon) worker(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, ok, err := d.queue.Next()
+		if err != nil {
+			internal.LogError("Failed to save queue: %v", err)
+		}
+		if !ok {
+			select {
+			case <-d.wake:
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		// More jobs may be waiting for another idle worker
+		d.notify()
+		d.run(ctx, job)
+	}
+}
+
+// run downloads a single job and records the outcome in the queue
+func (d *Daemon) run(ctx context.Context, job Job) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	d.mutex.Lock()
+	if current, ok := d.queue.Get(job.ID); !ok || current.Status != StatusDownloading {
+		// Removed or paused between being picked and starting
+		d.mutex.Unlock()
+		return
+	}
+	running := &runningJob{cancel: cancel}
+	d.running[job.ID] = running
+	d.mutex.Unlock()
+
+	internal.LogInfo("Starting job %d: %s", job.ID, job.URL)
+	d.publish(EventJobStarted, job)
+	err := d.download(jobCtx, &job, running)
+
+	d.mutex.Lock()
+	delete(d.running, job.ID)
+	removed, paused := running.removed, running.paused
+	if removed {
+		delete(d.reserved, job.OutputPath)
+	}
+	d.mutex.Unlock()
+
+	switch {
+	case removed:
+		d.cleanup(job)
+		return
+	case err == nil:
+		internal.LogInfo("Job %d completed: %s", job.ID, job.OutputPath)
+		d.update(job.ID, func(j *Job) {
+			j.Status = StatusCompleted
+		})
+		job.Status = StatusCompleted
+		d.publish(EventJobCompleted, job)
+	case paused:
+		// Pause already recorded the status; the resume metadata keeps the progress
+		internal.LogInfo("Job %d stopped for pause", job.ID)
+	case ctx.Err() != nil:
+		// The daemon is shutting down; pick the job up again on the next start
+		internal.LogInfo("Job %d interrupted, it will continue on the next start", job.ID)
+		d.update(job.ID, func(j *Job) {
+			j.Status = StatusQueued
+		})
+	default:
+		internal.LogError("Job %d failed: %v", job.ID, err)
+		d.update(job.ID, func(j *Job) {
+			j.Status = StatusFailed
+			j.Error = err.Error()
+		})
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		d.publish(EventJobFailed, job)
+	}
+}
+
+// download resolves a job and downloads it, choosing its output path the first
+// time it runs
+func (d *Daemon) download(ctx context.Context, job *Job, running *runningJob) error {
+	meta, err := d.config.Resolve(ctx, job.URL, job.Password)
+	if err != nil {
+		return err
+	}
+
+	if job.OutputPath == "" {
+		job.OutputPath = d.reservePath(job.OutputDir, meta.Filename)
+	}
+	d.update(job.ID, func(j *Job) {
+		j.Metadata = meta
+		j.OutputPath = job.OutputPath
+	})
+
+	downloadConfig := &internal.DownloadConfig{
+		OutputPath: job.OutputPath,
+		Threads:    d.config.Threads,
+		Quiet:      true,
+		SkipVerify: d.config.SkipVerify,
+		Source: &internal.ResumeSource{
+			ShareURL:   job.URL,
+			Threads:    d.config.Threads,
+			OutputPath: job.OutputPath,
+		},
+	}
+	engine := d.config.NewEngine()
+	engine.SetRateLimiter(d.limiter)
+	if d.config.Refresher != nil {
+		engine.SetLinkRefresher(d.config.Refresher(job.URL, job.Password))
+	}
+	d.mutex.Lock()
+	running.engine = engine
+	d.mutex.Unlock()
+	return engine.Download(ctx, meta, downloadConfig)
+}
+
+// update applies fn to a job, logging failures to persist the queue
+func (d *Daemon) update(id int, fn func(job *Job)) {
+	if _, err := d.queue.Update(id, fn); err != nil {
+		internal.LogError("Failed to save queue: %v", err)
+	}
+}
+
+// reservePath picks the output path for filename in dir, adding a numeric
+// suffix when a file or another job already has the name
+func (d *Daemon) reservePath(dir, filename string) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// Remote names must not steer the file outside the output directory
+	filename = filepath.Base(filename)
+	if filename == "." || filename == ".." || filename == string(filepath.Separator) {
+		filename = "download"
+	}
+
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+
+	candidate := filepath.Join(dir, filename)
+	for n := 1; d.reserved[candidate] || exists(candidate); n++ {
+		candidate = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+	}
+
+	d.reserved[candidate] = true
+	return candidate
+}
+
+// cleanup deletes the partial data of an unfinished job
+func (d *Daemon) cleanup(job Job) {
+	if job.OutputPath == "" {
+		return
+	}
+	os.Remove(job.OutputPath + ".part")
+	if err := d.planner.CleanupResumeMetadata(job.OutputPath); err != nil {
+		internal.LogWarn("Failed to remove resume metadata of job %d: %v", job.ID, err)
+	}
+}
+
+// exists reports whether anything is at path
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,291 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terafetch/downloader"
+	"terafetch/internal"
+	"terafetch/internal/faketerabox"
+	"terafetch/utils"
+)
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+// startTestDaemon runs a daemon against the fake server until the returned
+// stop function is called
+func startTestDaemon(t *testing.T, server *faketerabox.Server, stateDir, outputDir string) (*Client, func()) {
+	t.Helper()
+	return runTestDaemon(t, newTestDaemon(t, server, stateDir, outputDir), stateDir)
+}
+
+// newTestDaemon creates a daemon that downloads from the fake server
+func newTestDaemon(t *testing.T, server *faketerabox.Server, stateDir, outputDir string) *Daemon {
+	t.Helper()
+
+	client := utils.NewHTTPClientWithConfig(&utils.HTTPClientConfig{
+		Timeout: 10 * time.Second,
+		RetryConfig: &utils.RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			MaxDelay:    50 * time.Millisecond,
+			Multiplier:  2.0,
+		},
+	})
+	resolver := downloader.NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+
+	d, err := New(Config{
+		StateDir:   stateDir,
+		OutputDir:  outputDir,
+		Concurrent: 2,
+		Threads:    2,
+		Resolve: func(ctx context.Context, url, password string) (*internal.FileMetadata, error) {
+			return resolver.ResolvePublicLink(ctx, url)
+		},
+		NewEngine: func() *downloader.MultiThreadEngine {
+			return downloader.NewMultiThreadEngineWithClient(client)
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return d
+}
+
+// runTestDaemon runs d until the returned stop function is called
+func runTestDaemon(t *testing.T, d *Daemon, stateDir string) (*Client, func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Run(ctx)
+	}()
+
+	daemonClient := NewClient(SocketPath(stateDir))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := daemonClient.List(context.Background()); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("Daemon socket did not come up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return daemonClient, func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	}
+}
+
+// waitForJob polls the daemon until the job satisfies cond
+func waitForJob(t *testing.T, client *Client, id int, cond func(Job) bool) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		jobs, err := client.List(context.Background())
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		for _, job := range jobs {
+			if job.ID == id && cond(job) {
+				return job
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for job %d", id)
+	return Job{}
+}
+
+func TestDaemon_Download(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(512 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1DaemonFile",
+		Files: []*faketerabox.File{{Path: "movie.mkv", Content: content}},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_daemon_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client, stop := startTestDaemon(t, server, tempDir, tempDir)
+	defer stop()
+
+	first, err := client.Add(context.Background(), AddRequest{URL: shareURL})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	second, err := client.Add(context.Background(), AddRequest{URL: shareURL})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	done := func(job Job) bool { return job.Status == StatusCompleted || job.Status == StatusFailed }
+	firstJob := waitForJob(t, client, first.ID, done)
+	secondJob := waitForJob(t, client, second.ID, done)
+
+	for _, job := range []Job{firstJob, secondJob} {
+		if job.Status != StatusCompleted {
+			t.Fatalf("Job %d failed: %s", job.ID, job.Error)
+		}
+		if job.Downloaded != int64(len(content)) {
+			t.Errorf("Job %d: expected %d bytes downloaded, got %d", job.ID, len(content), job.Downloaded)
+		}
+		downloaded, err := os.ReadFile(job.OutputPath)
+		if err != nil || !bytes.Equal(downloaded, content) {
+			t.Errorf("Job %d: downloaded file does not match the share (%v)", job.ID, err)
+		}
+	}
+
+	// Two jobs for the same file must not share an output path
+	if firstJob.OutputPath == secondJob.OutputPath {
+		t.Errorf("Expected distinct output paths, both got %s", firstJob.OutputPath)
+	}
+
+	if _, err := client.Add(context.Background(), AddRequest{URL: "not a url"}); err == nil {
+		t.Error("Expected an invalid URL to be rejected")
+	}
+	if _, err := client.Remove(context.Background(), 99); err == nil {
+		t.Error("Expected removing an unknown job to fail")
+	}
+}
+
+func TestDaemon_RemoveRunningJob(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1DaemonSlow",
+		Files: []*faketerabox.File{{Path: "slow.bin", Content: testContent(2 * 1024 * 1024)}},
+	})
+	server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{ChunkDelay: 100 * time.Millisecond})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_daemon_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client, stop := startTestDaemon(t, server, tempDir, tempDir)
+	defer stop()
+
+	added, err := client.Add(context.Background(), AddRequest{URL: shareURL})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	job := waitForJob(t, client, added.ID, func(job Job) bool {
+		return job.Status == StatusDownloading && job.OutputPath != ""
+	})
+
+	if _, err := client.Remove(context.Background(), job.ID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	// The partial data goes once the engine has stopped
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, partErr := os.Stat(job.OutputPath + ".part")
+		_, metaErr := os.Stat(job.OutputPath + downloader.ResumeMetadataExt)
+		if os.IsNotExist(partErr) && os.IsNotExist(metaErr) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Partial data of the removed job was not cleaned up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	jobs, err := client.List(context.Background())
+	if err != nil || len(jobs) != 0 {
+		t.Errorf("Expected an empty queue, got %v (%v)", jobs, err)
+	}
+}
+
+func TestDaemon_ResumesAfterRestart(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(2 * 1024 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1DaemonRestart",
+		Files: []*faketerabox.File{{Path: "restart.bin", Content: content}},
+	})
+	server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{ChunkDelay: 100 * time.Millisecond})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_daemon_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Stop the daemon part-way through the download
+	client, stop := startTestDaemon(t, server, tempDir, tempDir)
+	added, err := client.Add(context.Background(), AddRequest{URL: shareURL})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	waitForJob(t, client, added.ID, func(job Job) bool {
+		return job.Status == StatusDownloading && job.OutputPath != ""
+	})
+	time.Sleep(500 * time.Millisecond)
+	stop()
+
+	queue, err := LoadQueue(filepath.Join(tempDir, QueueFileName))
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+	interrupted, _ := queue.Get(added.ID)
+	if interrupted.Status != StatusQueued || interrupted.OutputPath == "" {
+		t.Fatalf("Expected the interrupted job to be queued with its output path, got %+v", interrupted)
+	}
+	resumeData, err := downloader.NewDownloadPlanner().LoadResumeMetadata(interrupted.OutputPath)
+	if err != nil {
+		t.Fatalf("Expected resume metadata for the interrupted job: %v", err)
+	}
+	checkpointed := downloadedBytes(resumeData.Segments)
+	if checkpointed == 0 || checkpointed >= int64(len(content)) {
+		t.Fatalf("Expected a partial checkpoint, got %d of %d bytes", checkpointed, len(content))
+	}
+
+	// A new daemon picks the job up and finishes it in the same file
+	server.ClearFaults()
+	client, stop = startTestDaemon(t, server, tempDir, tempDir)
+	defer stop()
+
+	job := waitForJob(t, client, added.ID, func(job Job) bool {
+		return job.Status == StatusCompleted || job.Status == StatusFailed
+	})
+	if job.Status != StatusCompleted {
+		t.Fatalf("Job failed after restart: %s", job.Error)
+	}
+	if job.OutputPath != interrupted.OutputPath {
+		t.Errorf("Expected output path %s to be kept, got %s", interrupted.OutputPath, job.OutputPath)
+	}
+	downloaded, err := os.ReadFile(job.OutputPath)
+	if err != nil || !bytes.Equal(downloaded, content) {
+		t.Errorf("Downloaded file does not match the share (%v)", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package daemon runs a long-lived download service around a persistent job
+// queue. Each job's byte-level progress lives in the resume metadata written
+// next to its output file, so unfinished jobs continue where they stopped when
+// the daemon restarts. Clients talk to the daemon over a local Unix socket, or
+// over an optional aria2-compatible JSON-RPC endpoint.
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"terafetch/internal"
+)
+
+// JobStatus is the state of a queued download
+type JobStatus string
+
+const (
+	StatusQueued      JobStatus = "queued"
+	StatusDownloading JobStatus = "downloading"
+	StatusPaused      JobStatus = "paused"
+	StatusCompleted   JobStatus = "completed"
+	StatusFailed      JobStatus = "failed"
+)
+
+// Job is a single download in the daemon's queue
+type Job struct {
+	ID         int                    `json:"id"`
+	URL        string                 `json:"url"`
+	Password   string                 `json:"password,omitempty"`
+	OutputDir  string                 `json:"output_dir"`
+	OutputPath string                 `json:"output_path,omitempty"` // Chosen on first resolution and kept across restarts
+	Status     JobStatus              `json:"status"`
+	Error      string                 `json:"error,omitempty"`
+	Metadata   *internal.FileMetadata `json:"metadata,omitempty"`
+	Downloaded int64                  `json:"downloaded"`      // Filled from the engine or resume metadata when listing
+	Speed      float64                `json:"speed,omitempty"` // Bytes per second, filled for running jobs when listing
+	AddedAt    time.Time              `json:"added_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// queueFile is the on-disk form of the queue
+type queueFile struct {
+	NextID int    `json:"next_id"`
+	Jobs   []*Job `json:"jobs"`
+}
+
+// Queue is the persistent list of jobs. Every change is written to disk
+// before it returns.
+type Queue struct {
+	path   string
+	mutex  sync.Mutex
+	nextID int
+	jobs   []*Job
+}
+
+// LoadQueue reads the queue stored at path, or starts an empty one if the file
+// doesn't exist. Jobs that were downloading when the daemon stopped are queued
+// again.
+func LoadQueue(path string) (*Queue, error) {
+	q := &Queue{path: path, nextID: 1}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	var stored queueFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse queue %s: %w", path, err)
+	}
+
+	q.jobs = stored.Jobs
+	q.nextID = stored.NextID
+	for _, job := range q.jobs {
+		if job.Status == StatusDownloading {
+			job.Status = StatusQueued
+		}
+		if job.ID >= q.nextID {
+			q.nextID = job.ID + 1
+		}
+	}
+	return q, nil
+}
+
+// Add appends a job to the queue and assigns its ID
+func (q *Queue) Add(job Job) (Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	job.ID = q.nextID
+	job.Status = StatusQueued
+	job.AddedAt = now
+	job.UpdatedAt = now
+
+	q.nextID++
+	q.jobs = append(q.jobs, &job)
+	if err := q.save(); err != nil {
+		q.jobs = q.jobs[:len(q.jobs)-1]
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Remove deletes a job from the queue and returns it
+func (q *Queue) Remove(id int) (Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for i, job := range q.jobs {
+		if job.ID == id {
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+			return *job, q.save()
+		}
+	}
+	return Job{}, fmt.Errorf("job %d not found", id)
+}
+
+// Get returns a copy of the job with the given ID
+func (q *Queue) Get(id int) (Job, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
+
+// Jobs returns a copy of every job in queue order
+func (q *Queue) Jobs() []Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	jobs := make([]Job, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// Next marks the first queued job as downloading and returns it
+func (q *Queue) Next() (Job, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs {
+		if job.Status == StatusQueued {
+			job.Status = StatusDownloading
+			job.Error = ""
+			job.UpdatedAt = time.Now()
+			return *job, true, q.save()
+		}
+	}
+	return Job{}, false, nil
+}
+
+// Update applies fn to the job with the given ID and saves the queue. It
+// reports false if the job has been removed.
+func (q *Queue) Update(id int, fn func(job *Job)) (bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs {
+		if job.ID == id {
+			fn(job)
+			job.UpdatedAt = time.Now()
+			return true, q.save()
+		}
+	}
+	return false, nil
+}
+
+// save writes the queue to disk through a temporary file, so a crash never
+// leaves a truncated queue behind; the caller holds the mutex
+func (q *Queue) save() error {
+	data, err := json.MarshalIndent(&queueFile{NextID: q.nextID, Jobs: q.jobs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal queue: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0700); err != nil {
+		return fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write queue: %w", err)
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write queue: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueue_Persistence(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "terafetch_daemon_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, QueueFileName)
+
+	queue, err := LoadQueue(path)
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+
+	first, err := queue.Add(Job{URL: "https://terabox.com/s/1First"})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	second, _ := queue.Add(Job{URL: "https://terabox.com/s/1Second"})
+	third, _ := queue.Add(Job{URL: "https://terabox.com/s/1Third"})
+	if first.ID != 1 || second.ID != 2 || third.ID != 3 || first.Status != StatusQueued {
+		t.Fatalf("Unexpected job IDs or status: %+v %+v %+v", first, second, third)
+	}
+
+	// The first job starts downloading, the last is removed
+	next, ok, err := queue.Next()
+	if err != nil || !ok || next.ID != first.ID || next.Status != StatusDownloading {
+		t.Fatalf("Next returned %+v, %v, %v", next, ok, err)
+	}
+	if _, err := queue.Remove(third.ID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := queue.Remove(third.ID); err == nil {
+		t.Error("Expected an error removing a job twice")
+	}
+
+	// A restart queues interrupted jobs again and keeps IDs unique
+	reloaded, err := LoadQueue(path)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	jobs := reloaded.Jobs()
+	if len(jobs) != 2 {
+		t.Fatalf("Expected 2 jobs after reload, got %d", len(jobs))
+	}
+	if jobs[0].ID != first.ID || jobs[0].Status != StatusQueued {
+		t.Errorf("Expected interrupted job to be queued again, got %+v", jobs[0])
+	}
+
+	added, _ := reloaded.Add(Job{URL: "https://terabox.com/s/1Fourth"})
+	if added.ID != 4 {
+		t.Errorf("Expected ID 4 after reload, got %d", added.ID)
+	}
+}
+
+func TestQueue_Update(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "terafetch_daemon_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	queue, err := LoadQueue(filepath.Join(tempDir, QueueFileName))
+	if err != nil {
+		t.Fatalf("LoadQueue failed: %v", err)
+	}
+
+	job, _ := queue.Add(Job{URL: "https://terabox.com/s/1AbC123"})
+	ok, err := queue.Update(job.ID, func(j *Job) {
+		j.Status = StatusFailed
+		j.Error = "share not found"
+	})
+	if !ok || err != nil {
+		t.Fatalf("Update returned %v, %v", ok, err)
+	}
+
+	got, _ := queue.Get(job.ID)
+	if got.Status != StatusFailed || got.Error != "share not found" {
+		t.Errorf("Update not applied: %+v", got)
+	}
+
+	if ok, _ := queue.Update(99, func(j *Job) {}); ok {
+		t.Error("Expected Update of a missing job to report false")
+	}
+}
+
+func TestLoadQueue_Corrupted(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "terafetch_daemon_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, QueueFileName)
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadQueue(path); err == nil {
+		t.Error("Expected an error for a corrupted queue file")
+	}
+}
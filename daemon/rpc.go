@@ -0,0 +1,865 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+const (
+	// DefaultRPCListen is the address of the JSON-RPC endpoint when none is
+	// configured. It only accepts local connections.
+	DefaultRPCListen = "127.0.0.1:6800"
+
+	// aria2Version is the aria2 release whose RPC interface is mirrored; web UIs
+	// read it to decide which features to offer
+	aria2Version = "1.37.0"
+
+	// maxRPCMessage caps the size of a request body or WebSocket message
+	maxRPCMessage = 1 << 20
+
+	// rpcWriteTimeout bounds how long a slow WebSocket client can hold up
+	// notifications
+	rpcWriteTimeout = 5 * time.Second
+)
+
+// JSON-RPC error codes. aria2 reports every failure of a known method with
+// code 1.
+const (
+	rpcCodeFailed         = 1
+	rpcCodeParseError     = -32700
+	rpcCodeInvalidRequest = -32600
+	rpcCodeNoMethod       = -32601
+	rpcCodeInvalidParams  = -32602
+)
+
+// rpcRequest is a JSON-RPC 2.0 request
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is a JSON-RPC 2.0 notification sent to WebSocket clients
+type rpcNotification struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+func invalidParams(format string, args ...interface{}) error {
+	return &rpcError{Code: rpcCodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// notificationMethods maps job events to aria2's notification methods
+var notificationMethods = map[JobEvent]string{
+	EventJobStarted:   "aria2.onDownloadStart",
+	EventJobPaused:    "aria2.onDownloadPause",
+	EventJobStopped:   "aria2.onDownloadStop",
+	EventJobCompleted: "aria2.onDownloadComplete",
+	EventJobFailed:    "aria2.onDownloadError",
+}
+
+// rpcMethods are the aria2 methods the endpoint implements. Every one of them
+// takes the "token:<secret>" parameter first, which is stripped before the
+// method is called.
+var rpcMethods = map[string]func(s *rpcServer, params []json.RawMessage) (interface{}, error){
+	"aria2.addUri":               (*rpcServer).addURI,
+	"aria2.remove":               (*rpcServer).remove,
+	"aria2.forceRemove":          (*rpcServer).remove,
+	"aria2.pause":                (*rpcServer).pause,
+	"aria2.forcePause":           (*rpcServer).pause,
+	"aria2.pauseAll":             (*rpcServer).pauseAll,
+	"aria2.forcePauseAll":        (*rpcServer).pauseAll,
+	"aria2.unpause":              (*rpcServer).unpause,
+	"aria2.unpauseAll":           (*rpcServer).unpauseAll,
+	"aria2.tellStatus":           (*rpcServer).tellStatus,
+	"aria2.getUris":              (*rpcServer).getURIs,
+	"aria2.getFiles":             (*rpcServer).getFiles,
+	"aria2.tellActive":           (*rpcServer).tellActive,
+	"aria2.tellWaiting":          (*rpcServer).tellWaiting,
+	"aria2.tellStopped":          (*rpcServer).tellStopped,
+	"aria2.getOption":            (*rpcServer).getOption,
+	"aria2.changeOption":         (*rpcServer).changeOption,
+	"aria2.getGlobalOption":      (*rpcServer).getGlobalOption,
+	"aria2.changeGlobalOption":   (*rpcServer).changeGlobalOption,
+	"aria2.getGlobalStat":        (*rpcServer).getGlobalStat,
+	"aria2.removeDownloadResult": (*rpcServer).removeDownloadResult,
+	"aria2.purgeDownloadResult":  (*rpcServer).purgeDownloadResult,
+	"aria2.getVersion":           (*rpcServer).getVersion,
+	"aria2.getSessionInfo":       (*rpcServer).getSessionInfo,
+	"aria2.saveSession":          (*rpcServer).saveSession,
+}
+
+// rpcServer serves the daemon's queue over aria2-compatible JSON-RPC, on HTTP
+// POST and WebSocket at /jsonrpc
+type rpcServer struct {
+	daemon         *Daemon
+	secret         string
+	allowOriginAll bool
+	sessionID      string
+
+	mutex   sync.Mutex
+	sockets map[*rpcSocket]bool
+
+	notifications chan rpcNotification
+}
+
+// rpcSocket is a connected WebSocket client
+type rpcSocket struct {
+	conn  *websocket.Conn
+	mutex sync.Mutex // Serializes writes of responses and notifications
+}
+
+// send writes a JSON message to the client
+func (s *rpcSocket) send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(rpcWriteTimeout))
+	return websocket.Message.Send(s.conn, string(data))
+}
+
+// newRPCServer creates the JSON-RPC endpoint for d and subscribes it to job
+// events
+func newRPCServer(d *Daemon, secret string, allowOriginAll bool) *rpcServer {
+	s := &rpcServer{
+		daemon:         d,
+		secret:         secret,
+		allowOriginAll: allowOriginAll,
+		sessionID:      fmt.Sprintf("%016x", time.Now().UnixNano()),
+		sockets:        make(map[*rpcSocket]bool),
+		notifications:  make(chan rpcNotification, 64),
+	}
+
+	d.Subscribe(func(event JobEvent, job Job) {
+		method, ok := notificationMethods[event]
+		if !ok {
+			return
+		}
+		notification := rpcNotification{
+			JSONRPC: "2.0",
+			Method:  method,
+			Params:  []interface{}{map[string]string{"gid": gid(job.ID)}},
+		}
+		// Subscribers must not block the daemon
+		select {
+		case s.notifications <- notification:
+		default:
+			internal.LogWarn("Dropped RPC notification %s for job %d", method, job.ID)
+		}
+	})
+	return s
+}
+
+// run sends notifications to WebSocket clients until ctx is cancelled
+func (s *rpcServer) run(ctx context.Context) {
+	for {
+		select {
+		case notification := <-s.notifications:
+			s.mutex.Lock()
+			sockets := make([]*rpcSocket, 0, len(s.sockets))
+			for socket := range s.sockets {
+				sockets = append(sockets, socket)
+			}
+			s.mutex.Unlock()
+
+			for _, socket := range sockets {
+				if err := socket.send(notification); err != nil {
+					internal.LogDebug("Failed to notify RPC client: %v", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeSockets disconnects every WebSocket client
+func (s *rpcServer) closeSockets() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for socket := range s.sockets {
+		socket.conn.Close()
+	}
+}
+
+// handler serves JSON-RPC requests at /jsonrpc
+func (s *rpcServer) handler() http.Handler {
+	// websocket.Server doesn't check the Origin header; the secret protects
+	// the endpoint from pages in the user's browser
+	ws := websocket.Server{Handler: s.serveWebSocket}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/jsonrpc", func(w http.ResponseWriter, r *http.Request) {
+		if s.allowOriginAll {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		}
+
+		switch {
+		case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+			ws.ServeHTTP(w, r)
+		case r.Method == http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost:
+			s.serveHTTP(w, r)
+		default:
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		}
+	})
+	return mux
+}
+
+// serveHTTP answers a JSON-RPC request or batch sent with HTTP POST
+func (s *rpcServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCMessage))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.handleMessage(body))
+}
+
+// serveWebSocket answers JSON-RPC requests on a WebSocket connection, which
+// also receives notifications, until the client disconnects
+func (s *rpcServer) serveWebSocket(conn *websocket.Conn) {
+	conn.MaxPayloadBytes = maxRPCMessage
+	socket := &rpcSocket{conn: conn}
+
+	s.mutex.Lock()
+	s.sockets[socket] = true
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.sockets, socket)
+		s.mutex.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		var message []byte
+		if err := websocket.Message.Receive(conn, &message); err != nil {
+			return
+		}
+		if err := socket.send(s.handleMessage(message)); err != nil {
+			return
+		}
+	}
+}
+
+// handleMessage answers a single request or a batch of them
+func (s *rpcServer) handleMessage(message []byte) interface{} {
+	message = bytes.TrimSpace(message)
+	if len(message) > 0 && message[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(message, &batch); err != nil {
+			return rpcFailure(nil, &rpcError{Code: rpcCodeParseError, Message: "Parse error"})
+		}
+		if len(batch) == 0 {
+			return rpcFailure(nil, &rpcError{Code: rpcCodeInvalidRequest, Message: "Invalid Request"})
+		}
+
+		responses := make([]rpcResponse, len(batch))
+		for i, raw := range batch {
+			responses[i] = s.call(raw)
+		}
+		return responses
+	}
+
+	if !json.Valid(message) {
+		return rpcFailure(nil, &rpcError{Code: rpcCodeParseError, Message: "Parse error"})
+	}
+	return s.call(message)
+}
+
+// call answers a single request
+func (s *rpcServer) call(raw json.RawMessage) rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.Method == "" {
+		return rpcFailure(req.ID, &rpcError{Code: rpcCodeInvalidRequest, Message: "Invalid Request"})
+	}
+
+	result, err := s.dispatch(req.Method, req.Params)
+	if err != nil {
+		return rpcFailure(req.ID, err)
+	}
+	return rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// rpcFailure builds the response for a failed request. Errors other than
+// *rpcError are reported with aria2's generic failure code.
+func rpcFailure(id json.RawMessage, err error) rpcResponse {
+	rpcErr, ok := err.(*rpcError)
+	if !ok {
+		rpcErr = &rpcError{Code: rpcCodeFailed, Message: err.Error()}
+	}
+	return rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+}
+
+// dispatch checks the secret token and calls method
+func (s *rpcServer) dispatch(method string, params []json.RawMessage) (interface{}, error) {
+	// Like aria2, the system methods need no token; system.multicall checks
+	// the token of each call it makes
+	switch method {
+	case "system.listMethods":
+		return s.listMethods(), nil
+	case "system.listNotifications":
+		return s.listNotifications(), nil
+	case "system.multicall":
+		return s.multicall(params)
+	}
+
+	fn, ok := rpcMethods[method]
+	if !ok {
+		return nil, &rpcError{Code: rpcCodeNoMethod, Message: "Method not found"}
+	}
+
+	params, err := s.authorize(params)
+	if err != nil {
+		return nil, err
+	}
+	return fn(s, params)
+}
+
+// authorize checks the "token:<secret>" first parameter and returns the rest
+func (s *rpcServer) authorize(params []json.RawMessage) ([]json.RawMessage, error) {
+	unauthorized := &rpcError{Code: rpcCodeFailed, Message: "Unauthorized"}
+	if len(params) == 0 {
+		return nil, unauthorized
+	}
+
+	var token string
+	if err := json.Unmarshal(params[0], &token); err != nil {
+		return nil, unauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte("token:"+s.secret)) != 1 {
+		return nil, unauthorized
+	}
+	return params[1:], nil
+}
+
+func (s *rpcServer) listMethods() []string {
+	methods := []string{"system.listMethods", "system.listNotifications", "system.multicall"}
+	for method := range rpcMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (s *rpcServer) listNotifications() []string {
+	notifications := make([]string, 0, len(notificationMethods))
+	for _, method := range notificationMethods {
+		notifications = append(notifications, method)
+	}
+	sort.Strings(notifications)
+	return notifications
+}
+
+// multicall runs several calls in one request. Each result is wrapped in an
+// array, and each failure is reported as an error object in its place.
+func (s *rpcServer) multicall(params []json.RawMessage) (interface{}, error) {
+	var calls []struct {
+		MethodName string            `json:"methodName"`
+		Params     []json.RawMessage `json:"params"`
+	}
+	if len(params) == 0 {
+		return nil, invalidParams("missing list of calls")
+	}
+	if err := param(params, 0, &calls); err != nil {
+		return nil, err
+	}
+
+	results := make([]interface{}, len(calls))
+	for i, call := range calls {
+		if call.MethodName == "system.multicall" {
+			results[i] = &rpcError{Code: rpcCodeFailed, Message: "Recursive system.multicall forbidden"}
+			continue
+		}
+		result, err := s.dispatch(call.MethodName, call.Params)
+		if err != nil {
+			results[i] = rpcFailure(nil, err).Error
+			continue
+		}
+		results[i] = []interface{}{result}
+	}
+	return results, nil
+}
+
+func (s *rpcServer) addURI(params []json.RawMessage) (interface{}, error) {
+	var uris []string
+	var options map[string]interface{}
+	if err := param(params, 0, &uris); err != nil {
+		return nil, err
+	}
+	if err := param(params, 1, &options); err != nil {
+		return nil, err
+	}
+
+	// Further URIs are mirrors of the same file in aria2; a share has one
+	if len(uris) == 0 {
+		return nil, invalidParams("no URI to download")
+	}
+	url := uris[0]
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, invalidParams("URI must start with http:// or https://")
+	}
+
+	var outputDir string
+	if dir, ok := options["dir"]; ok {
+		outputDir = optionString(dir)
+		if !filepath.IsAbs(outputDir) {
+			return nil, invalidParams("dir must be an absolute path")
+		}
+	}
+
+	job, err := s.daemon.Add(url, "", outputDir)
+	if err != nil {
+		return nil, err
+	}
+	return gid(job.ID), nil
+}
+
+func (s *rpcServer) remove(params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.daemon.Remove(id); err != nil {
+		return nil, err
+	}
+	return gid(id), nil
+}
+
+func (s *rpcServer) pause(params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.daemon.Pause(id); err != nil {
+		return nil, err
+	}
+	return gid(id), nil
+}
+
+func (s *rpcServer) pauseAll(params []json.RawMessage) (interface{}, error) {
+	for _, job := range s.daemon.queue.Jobs() {
+		if job.Status == StatusQueued || job.Status == StatusDownloading {
+			s.daemon.Pause(job.ID)
+		}
+	}
+	return "OK", nil
+}
+
+func (s *rpcServer) unpause(params []json.RawMessage) (interface{}, error) {
+	id, err := gidParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.daemon.Unpause(id); err != nil {
+		return nil, err
+	}
+	return gid(id), nil
+}
+
+func (s *rpcServer) unpauseAll(params []json.RawMessage) (interface{}, error) {
+	for _, job := range s.daemon.queue.Jobs() {
+		if job.Status == StatusPaused {
+			s.daemon.Unpause(job.ID)
+		}
+	}
+	return "OK", nil
+}
+
+func (s *rpcServer) tellStatus(params []json.RawMessage) (interface{}, error) {
+	job, err := s.jobParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	if err := param(params, 1, &keys); err != nil {
+		return nil, err
+	}
+	return s.status(job, keys), nil
+}
+
+func (s *rpcServer) getURIs(params []json.RawMessage) (interface{}, error) {
+	job, err := s.jobParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return uris(job), nil
+}
+
+func (s *rpcServer) getFiles(params []json.RawMessage) (interface{}, error) {
+	job, err := s.jobParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return files(job), nil
+}
+
+func (s *rpcServer) tellActive(params []json.RawMessage) (interface{}, error) {
+	var keys []string
+	if err := param(params, 0, &keys); err != nil {
+		return nil, err
+	}
+	return s.statuses(s.jobsWithStatus(StatusDownloading), keys), nil
+}
+
+// tellWaiting lists queued and paused jobs
+func (s *rpcServer) tellWaiting(params []json.RawMessage) (interface{}, error) {
+	return s.tellRange(params, StatusQueued, StatusPaused)
+}
+
+// tellStopped lists completed and failed jobs
+func (s *rpcServer) tellStopped(params []json.RawMessage) (interface{}, error) {
+	return s.tellRange(params, StatusCompleted, StatusFailed)
+}
+
+// tellRange answers tellWaiting and tellStopped, which take an offset, a
+// count and optional keys
+func (s *rpcServer) tellRange(params []json.RawMessage, statuses ...JobStatus) (interface{}, error) {
+	var offset, num int
+	var keys []string
+	if len(params) < 2 {
+		return nil, invalidParams("offset and num are required")
+	}
+	if err := param(params, 0, &offset); err != nil {
+		return nil, err
+	}
+	if err := param(params, 1, &num); err != nil {
+		return nil, err
+	}
+	if err := param(params, 2, &keys); err != nil {
+		return nil, err
+	}
+	return s.statuses(window(s.jobsWithStatus(statuses...), offset, num), keys), nil
+}
+
+// getOption reports the options of a job
+func (s *rpcServer) getOption(params []json.RawMessage) (interface{}, error) {
+	job, err := s.jobParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"dir":   job.OutputDir,
+		"split": strconv.Itoa(s.daemon.config.Threads),
+	}, nil
+}
+
+// changeOption accepts and ignores per-job options, which TeraFetch doesn't
+// have
+func (s *rpcServer) changeOption(params []json.RawMessage) (interface{}, error) {
+	if _, err := s.jobParam(params, 0); err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+func (s *rpcServer) getGlobalOption(params []json.RawMessage) (interface{}, error) {
+	return map[string]string{
+		"dir":                        s.daemon.config.OutputDir,
+		"max-concurrent-downloads":   strconv.Itoa(s.daemon.config.Concurrent),
+		"max-overall-download-limit": strconv.FormatInt(s.daemon.RateLimit(), 10),
+		"split":                      strconv.Itoa(s.daemon.config.Threads),
+	}, nil
+}
+
+// changeGlobalOption applies max-overall-download-limit to the running jobs.
+// Other options are ignored, since web UIs send many at once.
+func (s *rpcServer) changeGlobalOption(params []json.RawMessage) (interface{}, error) {
+	var options map[string]interface{}
+	if err := param(params, 0, &options); err != nil {
+		return nil, err
+	}
+
+	if limit, ok := options["max-overall-download-limit"]; ok {
+		rate, err := utils.ParseRateLimit(optionString(limit))
+		if err != nil {
+			return nil, invalidParams("invalid max-overall-download-limit: %v", err)
+		}
+		if rate < 0 {
+			return nil, invalidParams("max-overall-download-limit must not be negative")
+		}
+		s.daemon.SetRateLimit(rate)
+	}
+	return "OK", nil
+}
+
+func (s *rpcServer) getGlobalStat(params []json.RawMessage) (interface{}, error) {
+	var speed float64
+	var active, waiting, stopped int
+	for _, job := range s.daemon.Jobs() {
+		switch job.Status {
+		case StatusDownloading:
+			active++
+			speed += job.Speed
+		case StatusQueued, StatusPaused:
+			waiting++
+		default:
+			stopped++
+		}
+	}
+
+	return map[string]string{
+		"downloadSpeed":   strconv.FormatInt(int64(speed), 10),
+		"uploadSpeed":     "0",
+		"numActive":       strconv.Itoa(active),
+		"numWaiting":      strconv.Itoa(waiting),
+		"numStopped":      strconv.Itoa(stopped),
+		"numStoppedTotal": strconv.Itoa(stopped),
+	}, nil
+}
+
+// removeDownloadResult removes a completed or failed job from the queue
+func (s *rpcServer) removeDownloadResult(params []json.RawMessage) (interface{}, error) {
+	job, err := s.jobParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != StatusCompleted && job.Status != StatusFailed {
+		return nil, fmt.Errorf("could not remove download result of GID#%s: download is %s", gid(job.ID), job.Status)
+	}
+	if _, err := s.daemon.Remove(job.ID); err != nil {
+		return nil, err
+	}
+	return "OK", nil
+}
+
+// purgeDownloadResult removes every completed and failed job from the queue
+func (s *rpcServer) purgeDownloadResult(params []json.RawMessage) (interface{}, error) {
+	for _, job := range s.jobsWithStatus(StatusCompleted, StatusFailed) {
+		s.daemon.Remove(job.ID)
+	}
+	return "OK", nil
+}
+
+func (s *rpcServer) getVersion(params []json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"version":         aria2Version,
+		"enabledFeatures": []string{},
+	}, nil
+}
+
+func (s *rpcServer) getSessionInfo(params []json.RawMessage) (interface{}, error) {
+	return map[string]string{"sessionId": s.sessionID}, nil
+}
+
+// saveSession is a no-op: the queue is saved on every change
+func (s *rpcServer) saveSession(params []json.RawMessage) (interface{}, error) {
+	return "OK", nil
+}
+
+// jobsWithStatus returns the jobs in any of statuses, in queue order
+func (s *rpcServer) jobsWithStatus(statuses ...JobStatus) []Job {
+	var matched []Job
+	for _, job := range s.daemon.Jobs() {
+		for _, status := range statuses {
+			if job.Status == status {
+				matched = append(matched, job)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// window returns up to num jobs starting at offset. A negative offset counts
+// from the end and walks towards the front, as in aria2.
+func window(jobs []Job, offset, num int) []Job {
+	selected := make([]Job, 0)
+	if offset >= 0 {
+		for i := offset; i < len(jobs) && len(selected) < num; i++ {
+			selected = append(selected, jobs[i])
+		}
+		return selected
+	}
+	for i := len(jobs) + offset; i >= 0 && len(selected) < num; i-- {
+		selected = append(selected, jobs[i])
+	}
+	return selected
+}
+
+// statuses builds the status of each job
+func (s *rpcServer) statuses(jobs []Job, keys []string) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(jobs))
+	for i, job := range jobs {
+		result[i] = s.status(job, keys)
+	}
+	return result
+}
+
+// status builds the aria2 status of a job, keeping only keys if any are given.
+// aria2 sends every number as a string.
+func (s *rpcServer) status(job Job, keys []string) map[string]interface{} {
+	connections := 0
+	if job.Status == StatusDownloading {
+		connections = s.daemon.config.Threads
+	}
+
+	status := map[string]interface{}{
+		"gid":             gid(job.ID),
+		"status":          aria2Status(job.Status),
+		"totalLength":     strconv.FormatInt(totalLength(job), 10),
+		"completedLength": strconv.FormatInt(job.Downloaded, 10),
+		"uploadLength":    "0",
+		"downloadSpeed":   strconv.FormatInt(int64(job.Speed), 10),
+		"uploadSpeed":     "0",
+		"connections":     strconv.Itoa(connections),
+		"dir":             job.OutputDir,
+		"files":           files(job),
+	}
+	if job.Status == StatusFailed {
+		status["errorCode"] = strconv.Itoa(rpcCodeFailed)
+		status["errorMessage"] = job.Error
+	}
+
+	if len(keys) == 0 {
+		return status
+	}
+	filtered := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if value, ok := status[key]; ok {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// aria2Status maps a job status to aria2's name for it
+func aria2Status(status JobStatus) string {
+	switch status {
+	case StatusDownloading:
+		return "active"
+	case StatusQueued:
+		return "waiting"
+	case StatusPaused:
+		return "paused"
+	case StatusCompleted:
+		return "complete"
+	default:
+		return "error"
+	}
+}
+
+// files describes the single file of a job; its path is empty until the share
+// has been resolved
+func files(job Job) []map[string]interface{} {
+	return []map[string]interface{}{{
+		"index":           "1",
+		"path":            job.OutputPath,
+		"length":          strconv.FormatInt(totalLength(job), 10),
+		"completedLength": strconv.FormatInt(job.Downloaded, 10),
+		"selected":        "true",
+		"uris":            uris(job),
+	}}
+}
+
+func uris(job Job) []map[string]string {
+	return []map[string]string{{"uri": job.URL, "status": "used"}}
+}
+
+func totalLength(job Job) int64 {
+	if job.Metadata == nil {
+		return 0
+	}
+	return job.Metadata.Size
+}
+
+// gid formats a job ID as an aria2 GID, 16 hex digits
+func gid(id int) string {
+	return fmt.Sprintf("%016x", id)
+}
+
+// gidParam reads the GID parameter at index i and returns the job ID
+func gidParam(params []json.RawMessage, i int) (int, error) {
+	if i >= len(params) {
+		return 0, invalidParams("GID is required")
+	}
+	var value string
+	if err := param(params, i, &value); err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseInt(value, 16, 64)
+	if err != nil || id <= 0 {
+		return 0, invalidParams("invalid GID %s", value)
+	}
+	return int(id), nil
+}
+
+// jobParam reads the GID parameter at index i and returns its job
+func (s *rpcServer) jobParam(params []json.RawMessage, i int) (Job, error) {
+	id, err := gidParam(params, i)
+	if err != nil {
+		return Job{}, err
+	}
+	job, ok := s.daemon.Job(id)
+	if !ok {
+		return Job{}, fmt.Errorf("GID %s is not found", gid(id))
+	}
+	return job, nil
+}
+
+// optionString formats an option value. aria2 options are strings, but some
+// clients send numbers.
+func optionString(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// param decodes the parameter at index i into v, leaving v unchanged when the
+// parameter is missing
+func param(params []json.RawMessage, i int, v interface{}) error {
+	if i >= len(params) {
+		return nil
+	}
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return invalidParams("invalid parameter %d: %v", i+1, err)
+	}
+	return nil
+}
@@ -0,0 +1,405 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"terafetch/internal/faketerabox"
+)
+
+const testRPCSecret = "s3cret"
+
+// startTestRPC runs a daemon against the fake server with its JSON-RPC
+// endpoint on a test HTTP server, until the returned stop function is called
+func startTestRPC(t *testing.T, server *faketerabox.Server, dir string) (*httptest.Server, func()) {
+	t.Helper()
+
+	d := newTestDaemon(t, server, dir, dir)
+	rpc := newRPCServer(d, testRPCSecret, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	go rpc.run(ctx)
+
+	_, stopDaemon := runTestDaemon(t, d, dir)
+	httpServer := httptest.NewServer(rpc.handler())
+	return httpServer, func() {
+		httpServer.Close()
+		rpc.closeSockets()
+		cancel()
+		stopDaemon()
+	}
+}
+
+// callRPC POSTs a single JSON-RPC call and decodes its result into out
+func callRPC(t *testing.T, url, method string, out interface{}, params ...interface{}) *rpcError {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "test",
+		"method":  method,
+		"params":  params,
+	})
+	resp, err := http.Post(url+"/jsonrpc", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s failed: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		ID     string          `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode %s response: %v", method, err)
+	}
+	if response.ID != "test" {
+		t.Errorf("Expected the request ID to be echoed, got %q", response.ID)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if out != nil {
+		if err := json.Unmarshal(response.Result, out); err != nil {
+			t.Fatalf("Failed to decode %s result %s: %v", method, response.Result, err)
+		}
+	}
+	return nil
+}
+
+// waitForStatus polls tellStatus until the download has the given aria2 status
+func waitForStatus(t *testing.T, url, gid string, want ...string) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		var status map[string]interface{}
+		if rpcErr := callRPC(t, url, "aria2.tellStatus", &status, "token:"+testRPCSecret, gid); rpcErr != nil {
+			t.Fatalf("tellStatus failed: %s", rpcErr.Message)
+		}
+		for _, w := range want {
+			if status["status"] == w {
+				return status
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s to be %v", gid, want)
+	return nil
+}
+
+func TestRPC_Unauthorized(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_rpc_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rpc, stop := startTestRPC(t, server, tempDir)
+	defer stop()
+
+	tests := []struct {
+		name   string
+		params []interface{}
+	}{
+		{"no token", nil},
+		{"wrong token", []interface{}{"token:wrong"}},
+		{"bare secret", []interface{}{testRPCSecret}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rpcErr := callRPC(t, rpc.URL, "aria2.tellActive", nil, tt.params...)
+			if rpcErr == nil || rpcErr.Code != rpcCodeFailed || rpcErr.Message != "Unauthorized" {
+				t.Errorf("Expected Unauthorized, got %+v", rpcErr)
+			}
+		})
+	}
+
+	// Like aria2, listing methods needs no token
+	var methods []string
+	if rpcErr := callRPC(t, rpc.URL, "system.listMethods", &methods); rpcErr != nil {
+		t.Fatalf("listMethods failed: %s", rpcErr.Message)
+	}
+	if !strings.Contains(strings.Join(methods, ","), "aria2.addUri") {
+		t.Errorf("Expected aria2.addUri among %v", methods)
+	}
+
+	if rpcErr := callRPC(t, rpc.URL, "aria2.noSuchMethod", nil, "token:"+testRPCSecret); rpcErr == nil || rpcErr.Code != rpcCodeNoMethod {
+		t.Errorf("Expected method not found, got %+v", rpcErr)
+	}
+}
+
+func TestRPC_AddURIAndTellStatus(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(256 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1RPCFile",
+		Files: []*faketerabox.File{{Path: "clip.mp4", Content: content}},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_rpc_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rpc, stop := startTestRPC(t, server, tempDir)
+	defer stop()
+
+	var gid string
+	if rpcErr := callRPC(t, rpc.URL, "aria2.addUri", &gid, "token:"+testRPCSecret, []string{shareURL}, map[string]string{"dir": tempDir}); rpcErr != nil {
+		t.Fatalf("addUri failed: %s", rpcErr.Message)
+	}
+	if len(gid) != 16 {
+		t.Fatalf("Expected a 16 digit GID, got %q", gid)
+	}
+
+	status := waitForStatus(t, rpc.URL, gid, "complete", "error")
+	if status["status"] != "complete" {
+		t.Fatalf("Download failed: %v", status["errorMessage"])
+	}
+	if status["totalLength"] != "262144" || status["completedLength"] != "262144" {
+		t.Errorf("Expected 262144 of 262144 bytes, got %v of %v", status["completedLength"], status["totalLength"])
+	}
+	files := status["files"].([]interface{})
+	path := files[0].(map[string]interface{})["path"].(string)
+	downloaded, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(downloaded, content) {
+		t.Errorf("Downloaded file %s does not match the share (%v)", path, err)
+	}
+
+	// Only the requested keys are returned
+	var filtered map[string]interface{}
+	callRPC(t, rpc.URL, "aria2.tellStatus", &filtered, "token:"+testRPCSecret, gid, []string{"gid", "status"})
+	if len(filtered) != 2 || filtered["gid"] != gid {
+		t.Errorf("Expected only gid and status, got %v", filtered)
+	}
+
+	var stopped []map[string]interface{}
+	callRPC(t, rpc.URL, "aria2.tellStopped", &stopped, "token:"+testRPCSecret, 0, 10)
+	if len(stopped) != 1 || stopped[0]["gid"] != gid {
+		t.Errorf("Expected the download among stopped ones, got %v", stopped)
+	}
+
+	var stat map[string]string
+	callRPC(t, rpc.URL, "aria2.getGlobalStat", &stat, "token:"+testRPCSecret)
+	if stat["numStopped"] != "1" || stat["numActive"] != "0" || stat["numWaiting"] != "0" {
+		t.Errorf("Unexpected global stats %v", stat)
+	}
+
+	var result string
+	if rpcErr := callRPC(t, rpc.URL, "aria2.removeDownloadResult", &result, "token:"+testRPCSecret, gid); rpcErr != nil || result != "OK" {
+		t.Fatalf("removeDownloadResult failed: %+v", rpcErr)
+	}
+	if rpcErr := callRPC(t, rpc.URL, "aria2.tellStatus", nil, "token:"+testRPCSecret, gid); rpcErr == nil {
+		t.Error("Expected the removed download to be gone")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the completed file to be kept: %v", err)
+	}
+}
+
+func TestRPC_PauseAndRateLimit(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(2 * 1024 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1RPCPause",
+		Files: []*faketerabox.File{{Path: "pause.bin", Content: content}},
+	})
+	server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{ChunkDelay: 100 * time.Millisecond})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_rpc_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rpc, stop := startTestRPC(t, server, tempDir)
+	defer stop()
+
+	var gid string
+	if rpcErr := callRPC(t, rpc.URL, "aria2.addUri", &gid, "token:"+testRPCSecret, []string{shareURL}); rpcErr != nil {
+		t.Fatalf("addUri failed: %s", rpcErr.Message)
+	}
+	waitForStatus(t, rpc.URL, gid, "active")
+
+	var active []map[string]interface{}
+	callRPC(t, rpc.URL, "aria2.tellActive", &active, "token:"+testRPCSecret, []string{"gid"})
+	if len(active) != 1 || active[0]["gid"] != gid {
+		t.Errorf("Expected the download to be active, got %v", active)
+	}
+
+	if rpcErr := callRPC(t, rpc.URL, "aria2.pause", nil, "token:"+testRPCSecret, gid); rpcErr != nil {
+		t.Fatalf("pause failed: %s", rpcErr.Message)
+	}
+	waitForStatus(t, rpc.URL, gid, "paused")
+
+	var waiting []map[string]interface{}
+	callRPC(t, rpc.URL, "aria2.tellWaiting", &waiting, "token:"+testRPCSecret, 0, 10)
+	if len(waiting) != 1 || waiting[0]["status"] != "paused" {
+		t.Errorf("Expected the paused download among waiting ones, got %v", waiting)
+	}
+
+	var result string
+	callRPC(t, rpc.URL, "aria2.changeGlobalOption", &result, "token:"+testRPCSecret, map[string]string{"max-overall-download-limit": "50M"})
+	if result != "OK" {
+		t.Fatalf("changeGlobalOption failed")
+	}
+	var options map[string]string
+	callRPC(t, rpc.URL, "aria2.getGlobalOption", &options, "token:"+testRPCSecret)
+	if options["max-overall-download-limit"] != "52428800" {
+		t.Errorf("Expected the new rate limit, got %v", options["max-overall-download-limit"])
+	}
+
+	// The download continues from its resume metadata when unpaused
+	server.ClearFaults()
+	if rpcErr := callRPC(t, rpc.URL, "aria2.unpause", nil, "token:"+testRPCSecret, gid); rpcErr != nil {
+		t.Fatalf("unpause failed: %s", rpcErr.Message)
+	}
+	status := waitForStatus(t, rpc.URL, gid, "complete", "error")
+	if status["status"] != "complete" {
+		t.Fatalf("Download failed after unpause: %v", status["errorMessage"])
+	}
+	path := status["files"].([]interface{})[0].(map[string]interface{})["path"].(string)
+	downloaded, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(downloaded, content) {
+		t.Errorf("Downloaded file does not match the share (%v)", err)
+	}
+
+	if rpcErr := callRPC(t, rpc.URL, "aria2.unpause", nil, "token:"+testRPCSecret, gid); rpcErr == nil {
+		t.Error("Expected unpausing a completed download to fail")
+	}
+}
+
+func TestRPC_Multicall(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_rpc_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rpc, stop := startTestRPC(t, server, tempDir)
+	defer stop()
+
+	calls := []map[string]interface{}{
+		{"methodName": "aria2.getVersion", "params": []interface{}{"token:" + testRPCSecret}},
+		{"methodName": "aria2.getVersion", "params": []interface{}{"token:wrong"}},
+	}
+	var results []json.RawMessage
+	if rpcErr := callRPC(t, rpc.URL, "system.multicall", &results, calls); rpcErr != nil {
+		t.Fatalf("multicall failed: %s", rpcErr.Message)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	var version []map[string]interface{}
+	if err := json.Unmarshal(results[0], &version); err != nil || len(version) != 1 || version[0]["version"] != aria2Version {
+		t.Errorf("Expected the version wrapped in an array, got %s", results[0])
+	}
+	var failure rpcError
+	if err := json.Unmarshal(results[1], &failure); err != nil || failure.Message != "Unauthorized" {
+		t.Errorf("Expected an Unauthorized error object, got %s", results[1])
+	}
+
+	// Batch requests are answered in order
+	batch := `[{"jsonrpc":"2.0","id":1,"method":"aria2.getVersion","params":["token:s3cret"]},` +
+		`{"jsonrpc":"2.0","id":2,"method":"aria2.getGlobalStat","params":["token:s3cret"]}]`
+	resp, err := http.Post(rpc.URL+"/jsonrpc", "application/json", strings.NewReader(batch))
+	if err != nil {
+		t.Fatalf("Batch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var responses []rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil || len(responses) != 2 {
+		t.Fatalf("Expected 2 responses, got %v (%v)", responses, err)
+	}
+	if string(responses[0].ID) != "1" || string(responses[1].ID) != "2" || responses[1].Error != nil {
+		t.Errorf("Unexpected batch responses %+v", responses)
+	}
+}
+
+func TestRPC_WebSocketNotifications(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1RPCSocket",
+		Files: []*faketerabox.File{{Path: "socket.bin", Content: testContent(128 * 1024)}},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_rpc_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	rpc, stop := startTestRPC(t, server, tempDir)
+	defer stop()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(rpc.URL, "http")+"/jsonrpc", "", rpc.URL)
+	if err != nil {
+		t.Fatalf("WebSocket dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(15 * time.Second))
+
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      "ws",
+		"method":  "aria2.addUri",
+		"params":  []interface{}{"token:" + testRPCSecret, []string{shareURL}},
+	}
+	if err := websocket.JSON.Send(conn, request); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	// The response and the start notification may arrive in either order
+	var gid string
+	var notifications []string
+	for len(notifications) == 0 || notifications[len(notifications)-1] != "aria2.onDownloadComplete" {
+		var message struct {
+			ID     string              `json:"id"`
+			Result string              `json:"result"`
+			Method string              `json:"method"`
+			Params []map[string]string `json:"params"`
+		}
+		if err := websocket.JSON.Receive(conn, &message); err != nil {
+			t.Fatalf("Receive failed after %v: %v", notifications, err)
+		}
+		if message.ID == "ws" {
+			gid = message.Result
+			continue
+		}
+		if gid != "" && message.Params[0]["gid"] != gid {
+			t.Errorf("Notification %s for unexpected GID %s", message.Method, message.Params[0]["gid"])
+		}
+		notifications = append(notifications, message.Method)
+	}
+
+	if gid == "" {
+		t.Error("Expected a response to addUri")
+	}
+	if notifications[0] != "aria2.onDownloadStart" {
+		t.Errorf("Expected the start notification first, got %v", notifications)
+	}
+}
@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"terafetch/internal"
+)
+
+// AddRequest is the body of a request to queue a download
+type AddRequest struct {
+	URL       string `json:"url"`
+	Password  string `json:"password,omitempty"`
+	OutputDir string `json:"output_dir,omitempty"`
+}
+
+// errorResponse is the body of a failed request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// handler serves the queue API:
+//
+//	GET    /jobs       list every job
+//	POST   /jobs       queue a download (AddRequest)
+//	DELETE /jobs/{id}  remove a job
+func (d *Daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", d.handleList)
+	mux.HandleFunc("POST /jobs", d.handleAdd)
+	mux.HandleFunc("DELETE /jobs/{id}", d.handleRemove)
+	return mux
+}
+
+func (d *Daemon) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, d.Jobs())
+}
+
+func (d *Daemon) handleAdd(w http.ResponseWriter, r *http.Request) {
+	var req AddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		writeError(w, http.StatusBadRequest, "URL must start with http:// or https://")
+		return
+	}
+
+	job, err := d.Add(req.URL, req.Password, req.OutputDir)
+	if err != nil {
+		internal.LogError("Failed to queue %s: %v", req.URL, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (d *Daemon) handleRemove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job ID: "+r.PathValue("id"))
+		return
+	}
+
+	if _, ok := d.queue.Get(id); !ok {
+		writeError(w, http.StatusNotFound, "job "+strconv.Itoa(id)+" not found")
+		return
+	}
+
+	job, err := d.Remove(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,260 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+// userInfoPath is the account API endpoint reporting who a session belongs to
+const userInfoPath = "/passport/get_info"
+
+// errnoNotLoggedIn is the errno the account API answers for cookies that are
+// not signed in, such as a session revoked by a password change
+const errnoNotLoggedIn = -6
+
+// ErrSessionRevoked is returned when Terabox no longer accepts the session
+// cookies, for example after the account's password has been changed
+var ErrSessionRevoked = errors.New("session has been revoked, sign in again and export fresh cookies")
+
+// userInfoResponse represents the response from the account API
+type userInfoResponse struct {
+	TeraboxAPIResponse
+	Data struct {
+		DisplayName string `json:"display_name"`
+		VIPType     int    `json:"vip_type"` // 0 for free accounts
+	} `json:"data"`
+}
+
+// SetHTTPClient sets the client account API requests are made with
+func (a *CookieAuthManager) SetHTTPClient(httpClient *utils.HTTPClient) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.httpClient = httpClient
+}
+
+// SetBaseURL points the account API requests at another server, such as a
+// local mock of the Terabox API
+func (a *CookieAuthManager) SetBaseURL(baseURL string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+// CheckSession asks the account API whether auth is a live session and fills
+// in the account's username and VIP status. A session Terabox no longer
+// accepts fails with ErrSessionRevoked. Cookies the API rotates with
+// Set-Cookie replace the ones in auth; RefreshSession also saves them.
+func (a *CookieAuthManager) CheckSession(ctx context.Context, auth *internal.AuthContext) error {
+	_, err := a.checkSession(ctx, auth)
+	return err
+}
+
+// RefreshSession checks auth online like CheckSession and writes cookies the
+// server rotated back to the cookie file or session store they were loaded
+// from, so the next run starts with them. Only the rotated entries of a
+// cookie file are changed. Failing to save them is logged, not returned.
+func (a *CookieAuthManager) RefreshSession(ctx context.Context, auth *internal.AuthContext) error {
+	rotated, err := a.checkSession(ctx, auth)
+	if err != nil || len(rotated) == 0 {
+		return err
+	}
+
+	a.mutex.RLock()
+	writeBack := a.writeBack
+	a.mutex.RUnlock()
+	if writeBack == nil {
+		internal.LogInfo("Terabox rotated %s, but the cookie source cannot be updated", strings.Join(rotated, ", "))
+		return nil
+	}
+
+	cookies := make([]*http.Cookie, 0, len(rotated))
+	for _, name := range rotated {
+		cookies = append(cookies, auth.Cookies[name])
+	}
+	// The session is live either way, so a source that cannot be updated
+	// only costs the next run the rotation
+	if err := writeBack(cookies); err != nil {
+		internal.LogWarn("Could not save the cookies Terabox rotated: %v", err)
+		return nil
+	}
+	internal.LogInfo("Saved the cookies Terabox rotated: %s", strings.Join(rotated, ", "))
+	return nil
+}
+
+// checkSession makes the account API request of CheckSession and returns the
+// names of the cookies the server rotated
+func (a *CookieAuthManager) checkSession(ctx context.Context, auth *internal.AuthContext) ([]string, error) {
+	if auth == nil {
+		return nil, fmt.Errorf("auth context is nil")
+	}
+	if auth.Bypass || auth.BDUSS == "" {
+		return nil, fmt.Errorf("BDUSS cookie is required for authentication")
+	}
+
+	a.mutex.Lock()
+	if a.httpClient == nil {
+		a.httpClient = utils.NewHTTPClient()
+	}
+	httpClient, baseURL := a.httpClient, a.baseURL
+	a.mutex.Unlock()
+	if baseURL == "" {
+		baseURL = DefaultAPIBaseURL
+	}
+
+	apiURL, err := url.Parse(baseURL + userInfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account API URL: %w", err)
+	}
+	cookies := make([]*http.Cookie, 0, len(auth.Cookies))
+	for _, cookie := range auth.Cookies {
+		cookies = append(cookies, cookie)
+	}
+	if len(cookies) == 0 {
+		cookies = append(cookies, &http.Cookie{Name: "BDUSS", Value: auth.BDUSS, Domain: headerCookieDomain, Path: "/"})
+	}
+	httpClient.AddCookies(apiURL, cookies)
+
+	headers := map[string]string{
+		"Referer":          "https://www.terabox.com/",
+		"X-Requested-With": "XMLHttpRequest",
+	}
+	resp, err := httpClient.GetWithHeadersContext(ctx, apiURL.String(), headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call account API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrSessionRevoked
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("account API answered %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	var apiResp userInfoResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+	switch apiResp.Errno {
+	case 0:
+	case errnoNotLoggedIn:
+		return nil, ErrSessionRevoked
+	default:
+		return nil, internal.NewTeraboxError(apiResp.Errno, fmt.Sprintf("account API error: %s", apiResp.Errmsg), internal.ErrInvalidResponse)
+	}
+
+	rotated := a.applyRotatedCookies(auth, resp.Cookies())
+	auth.Username = apiResp.Data.DisplayName
+	auth.VIP = apiResp.Data.VIPType > 0
+	return rotated, nil
+}
+
+// applyRotatedCookies replaces the cookies of auth and of the manager with the
+// ones a response set, keeping the domain and path of the cookies replaced,
+// and returns the names of the cookies that changed
+func (a *CookieAuthManager) applyRotatedCookies(auth *internal.AuthContext, setCookies []*http.Cookie) []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var rotated []string
+	for _, cookie := range setCookies {
+		// Deleted cookies are left alone; a revoked session fails the check
+		if cookie.MaxAge < 0 || cookie.Value == "" {
+			continue
+		}
+		existing := auth.Cookies[cookie.Name]
+		if existing != nil && existing.Value == cookie.Value {
+			continue
+		}
+
+		replacement := *cookie
+		replacement.Raw, replacement.Unparsed = "", nil
+		replacement.HttpOnly = true
+		if cookie.MaxAge > 0 {
+			replacement.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+			replacement.MaxAge = 0
+		}
+		switch {
+		case existing != nil && replacement.Domain == "":
+			replacement.Domain = existing.Domain
+		case replacement.Domain == "":
+			replacement.Domain = headerCookieDomain
+		}
+		switch {
+		case existing != nil && replacement.Path == "":
+			replacement.Path = existing.Path
+		case replacement.Path == "":
+			replacement.Path = "/"
+		}
+
+		if auth.Cookies == nil {
+			auth.Cookies = make(map[string]*http.Cookie)
+		}
+		auth.Cookies[cookie.Name] = &replacement
+		a.cookieStore[cookie.Name] = &replacement
+		rotated = append(rotated, cookie.Name)
+
+		switch cookie.Name {
+		case "BDUSS":
+			auth.BDUSS = replacement.Value
+			if !replacement.Expires.IsZero() {
+				auth.ExpiresAt = replacement.Expires
+			}
+		case "STOKEN":
+			auth.STOKEN = replacement.Value
+		}
+	}
+	return rotated
+}
+
+// writeCookieFile updates the rotated cookies in a cookie file of the given
+// format, leaving the rest of it alone, and replaces the file through a
+// temporary one so that a crash never truncates it
+func writeCookieFile(path, format string, rotated []*http.Cookie) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read cookie file: %w", err)
+	}
+	if detected := detectCookieFormat(data); detected != format {
+		return fmt.Errorf("cookie file changed from %s to %s format since it was loaded", format, detected)
+	}
+
+	switch format {
+	case cookieFormatJSON:
+		if data, err = updateJSONCookies(data, rotated); err != nil {
+			return err
+		}
+	case cookieFormatHeader:
+		data = updateCookieHeader(data, rotated)
+	default:
+		data = updateNetscapeCookies(data, rotated)
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, mode); err != nil {
+		return fmt.Errorf("failed to write cookie file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write cookie file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"terafetch/internal"
+	"terafetch/internal/faketerabox"
+)
+
+// newAccountManager returns a manager whose account API requests go to server
+func newAccountManager(server *faketerabox.Server) *CookieAuthManager {
+	authManager := NewCookieAuthManager()
+	authManager.SetHTTPClient(newFakeTeraboxClient())
+	authManager.SetBaseURL(server.URL)
+	return authManager
+}
+
+func TestCookieAuthManager_CheckSession(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+	account := server.AddAccount(&faketerabox.Account{BDUSS: testBDUSS, Username: "alice", VIP: true})
+	server.AddAccount(&faketerabox.Account{BDUSS: strings.Repeat("f", 40), Username: "bob"})
+
+	authManager := newAccountManager(server)
+	auth, err := authManager.LoadCookieHeader("BDUSS=" + testBDUSS + "; STOKEN=" + testSTOKEN)
+	if err != nil {
+		t.Fatalf("LoadCookieHeader failed: %v", err)
+	}
+	if err := authManager.CheckSession(context.Background(), auth); err != nil {
+		t.Fatalf("CheckSession failed: %v", err)
+	}
+	if auth.Username != "alice" || !auth.VIP {
+		t.Errorf("Expected alice's VIP account, got %q (VIP %v)", auth.Username, auth.VIP)
+	}
+
+	// A context without cookies is checked with its BDUSS
+	free := &internal.AuthContext{BDUSS: strings.Repeat("f", 40)}
+	if err := authManager.CheckSession(context.Background(), free); err != nil || free.Username != "bob" || free.VIP {
+		t.Errorf("Expected bob's free account, got %q (VIP %v, %v)", free.Username, free.VIP, err)
+	}
+
+	tests := []struct {
+		name    string
+		auth    *internal.AuthContext
+		wantErr error
+	}{
+		{"unknown session", &internal.AuthContext{BDUSS: strings.Repeat("0", 40)}, ErrSessionRevoked},
+		{"bypass", NewCookieAuthManager().CreateBypassAuthContext(), nil},
+		{"nil", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authManager.CheckSession(context.Background(), tt.auth)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Other API errors are not mistaken for a revoked session
+	server.InjectFault(faketerabox.EndpointUserInfo, faketerabox.Fault{Errno: 2, Times: 1})
+	if err := authManager.CheckSession(context.Background(), auth); err == nil || errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected an API error, got %v", err)
+	}
+
+	account.Revoke()
+	if err := authManager.CheckSession(context.Background(), auth); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected ErrSessionRevoked after the account was signed out, got %v", err)
+	}
+}
+
+func TestCookieAuthManager_RefreshSession(t *testing.T) {
+	const rotatedBDUSS = "rotated0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string // Line the file holds after the refresh
+	}{
+		{
+			name: "netscape",
+			content: "# Netscape HTTP Cookie File\n" +
+				".terabox.com\tTRUE\t/\tFALSE\t1893456000\tBDUSS\t" + testBDUSS + "\n" +
+				".terabox.com\tTRUE\t/\tFALSE\t1893456000\tSTOKEN\t" + testSTOKEN + "\n",
+			want: ".terabox.com\tTRUE\t/\tFALSE\t4102444800\tBDUSS\t" + rotatedBDUSS,
+		},
+		{
+			name:    "JSON",
+			content: `[{"name": "BDUSS", "value": "` + testBDUSS + `", "domain": ".terabox.com", "expirationDate": 1893456000}, {"name": "STOKEN", "value": "` + testSTOKEN + `", "domain": ".terabox.com"}]`,
+			want:    `"value": "` + rotatedBDUSS + `"`,
+		},
+		{
+			name:    "Cookie header",
+			content: "BDUSS=" + testBDUSS + "; STOKEN=" + testSTOKEN + "\n",
+			want:    "BDUSS=" + rotatedBDUSS + "; STOKEN=" + testSTOKEN + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := faketerabox.NewServer()
+			defer server.Close()
+			account := server.AddAccount(&faketerabox.Account{BDUSS: testBDUSS, Username: "alice"})
+			account.RotateCookies(map[string]string{"BDUSS": rotatedBDUSS})
+
+			path := filepath.Join(t.TempDir(), "cookies")
+			if err := os.WriteFile(path, []byte(tt.content), 0640); err != nil {
+				t.Fatalf("Failed to write cookie file: %v", err)
+			}
+			authManager := newAccountManager(server)
+			auth, err := authManager.LoadCookies(path)
+			if err != nil {
+				t.Fatalf("LoadCookies failed: %v", err)
+			}
+
+			if err := authManager.RefreshSession(context.Background(), auth); err != nil {
+				t.Fatalf("RefreshSession failed: %v", err)
+			}
+			if auth.BDUSS != rotatedBDUSS || auth.Cookies["BDUSS"].Domain != ".terabox.com" || auth.ExpiresAt.Year() != 2100 {
+				t.Errorf("Expected the rotated BDUSS on .terabox.com until 2100, got %+v", auth.Cookies["BDUSS"])
+			}
+
+			data, _ := os.ReadFile(path)
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("Expected the cookie file to hold %q, got:\n%s", tt.want, data)
+			}
+			if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+				t.Errorf("Expected the file mode to be kept, got %v", info.Mode().Perm())
+			}
+
+			// The file loads again with the rotated session, which is live
+			reloaded, err := newAccountManager(server).LoadCookies(path)
+			if err != nil {
+				t.Fatalf("LoadCookies of the rewritten file failed: %v", err)
+			}
+			if reloaded.BDUSS != rotatedBDUSS || reloaded.STOKEN != testSTOKEN {
+				t.Errorf("Expected the rotated BDUSS and the old STOKEN, got %s and %s", reloaded.BDUSS, reloaded.STOKEN)
+			}
+			if err := authManager.RefreshSession(context.Background(), reloaded); err != nil {
+				t.Errorf("Expected the rotated session to be live, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCookieAuthManager_RefreshSession_KeepsOtherCookies(t *testing.T) {
+	const rotatedBDUSS = "rotated0123456789abcdef0123456789abcdef"
+	otherBDUSS := strings.Repeat("e", 40)
+
+	server := faketerabox.NewServer()
+	defer server.Close()
+	account := server.AddAccount(&faketerabox.Account{BDUSS: testBDUSS, Username: "alice"})
+	account.RotateCookies(map[string]string{"BDUSS": rotatedBDUSS})
+
+	// Only the BDUSS the session was loaded with may change; the same name on
+	// another domain, the comments and the markers stay as they are
+	content := "# Netscape HTTP Cookie File\n" +
+		"# Exported for another tool as well\n" +
+		".1024tera.com\tTRUE\t/\tFALSE\t1893456000\tBDUSS\t" + otherBDUSS + "\n" +
+		"#HttpOnly_.terabox.com\tTRUE\t/\tFALSE\t1893456000\tBDUSS\t" + testBDUSS + "\n" +
+		".terabox.com TRUE / FALSE 1893456000 STOKEN " + testSTOKEN + "\n" +
+		".example.com\tTRUE\t/\tFALSE\t1893456000\tsession\tunrelated\n"
+	want := strings.Replace(content,
+		"1893456000\tBDUSS\t"+testBDUSS, "4102444800\tBDUSS\t"+rotatedBDUSS, 1)
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write cookie file: %v", err)
+	}
+	authManager := newAccountManager(server)
+	auth, err := authManager.LoadCookies(path)
+	if err != nil {
+		t.Fatalf("LoadCookies failed: %v", err)
+	}
+	if err := authManager.RefreshSession(context.Background(), auth); err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("Expected only the rotated cookie to change, got:\n%s", data)
+	}
+
+	// A JSON export keeps its other cookies and fields too
+	jsonContent := `{"cookies": [` +
+		`{"name": "BDUSS", "value": "` + otherBDUSS + `", "domain": ".1024tera.com", "path": "/", "expires": 1893456000},` +
+		`{"name": "BDUSS", "value": "` + rotatedBDUSS + `", "domain": ".terabox.com", "path": "/", "expires": 1893456000, "sameSite": "Lax"}` +
+		`], "origins": []}`
+	if err := os.WriteFile(path, []byte(jsonContent), 0600); err != nil {
+		t.Fatalf("Failed to write cookie file: %v", err)
+	}
+	account.RotateCookies(map[string]string{"BDUSS": testBDUSS})
+	auth, err = authManager.LoadCookies(path)
+	if err != nil {
+		t.Fatalf("LoadCookies failed: %v", err)
+	}
+	if err := authManager.RefreshSession(context.Background(), auth); err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	cookies, err := parseJSONCookies(data)
+	if err != nil {
+		t.Fatalf("Rewritten JSON export does not parse: %v", err)
+	}
+	if len(cookies) != 2 || cookies[0].Value != otherBDUSS || cookies[1].Value != testBDUSS || cookies[1].Expires.Year() != 2100 {
+		t.Errorf("Expected only the .terabox.com BDUSS to change, got %+v and %+v", cookies[0], cookies[1])
+	}
+	for _, field := range []string{`"sameSite": "Lax"`, `"origins": []`, `"expires": 4102444800`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("Expected the JSON export to keep %s, got:\n%s", field, data)
+		}
+	}
+}
+
+func TestCookieAuthManager_RefreshSession_StoredSession(t *testing.T) {
+	fastSessionKDF(t)
+	server := faketerabox.NewServer()
+	defer server.Close()
+	account := server.AddAccount(&faketerabox.Account{BDUSS: testBDUSS, Username: "alice"})
+	account.RotateCookies(map[string]string{"STOKEN": "rotated-stoken"})
+
+	store := NewSessionStore(filepath.Join(t.TempDir(), "session.enc"))
+	saver := NewCookieAuthManager()
+	if _, err := saver.LoadCookieHeader("BDUSS=" + testBDUSS + "; STOKEN=" + testSTOKEN); err != nil {
+		t.Fatal(err)
+	}
+	if err := saver.StoreSession(store, "secret"); err != nil {
+		t.Fatalf("StoreSession failed: %v", err)
+	}
+
+	authManager := newAccountManager(server)
+	auth, err := authManager.LoadStoredSession(store, "secret")
+	if err != nil {
+		t.Fatalf("LoadStoredSession failed: %v", err)
+	}
+	if err := authManager.RefreshSession(context.Background(), auth); err != nil {
+		t.Fatalf("RefreshSession failed: %v", err)
+	}
+
+	stored, err := NewCookieAuthManager().LoadStoredSession(store, "secret")
+	if err != nil {
+		t.Fatalf("LoadStoredSession failed: %v", err)
+	}
+	if stored.STOKEN != "rotated-stoken" || stored.BDUSS != testBDUSS {
+		t.Errorf("Expected the rotated STOKEN in the store, got BDUSS %s and STOKEN %s", stored.BDUSS, stored.STOKEN)
+	}
+}
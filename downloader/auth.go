@@ -2,7 +2,9 @@ package downloader
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -11,6 +13,7 @@ import (
 	"time"
 
 	"terafetch/internal"
+	"terafetch/utils"
 )
 
 // CookieAuthManager implements the AuthManager interface with secure cookie handling
@@ -18,6 +21,13 @@ type CookieAuthManager struct {
 	// Secure in-memory cookie storage
 	cookieStore map[string]*http.Cookie
 	mutex       sync.RWMutex
+
+	httpClient *utils.HTTPClient // Client of the account API, created when first needed
+	baseURL    string
+
+	// writeBack saves the cookies the server rotated to where they were
+	// loaded from, nil when the source cannot be written to
+	writeBack func(rotated []*http.Cookie) error
 }
 
 // NewCookieAuthManager creates a new instance of CookieAuthManager
@@ -27,7 +37,8 @@ func NewCookieAuthManager() *CookieAuthManager {
 	}
 }
 
-// LoadCookies loads cookies from a Netscape-format file
+// LoadCookies loads cookies from a file in Netscape format, a JSON export or
+// a Cookie header, detecting the format from the content
 func (a *CookieAuthManager) LoadCookies(path string) (*internal.AuthContext, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -35,19 +46,56 @@ func (a *CookieAuthManager) LoadCookies(path string) (*internal.AuthContext, err
 	}
 	defer file.Close()
 
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cookie file: %w", err)
+	}
+
+	var cookies []*http.Cookie
+	format := detectCookieFormat(data)
+	switch format {
+	case cookieFormatJSON:
+		cookies, err = parseJSONCookies(data)
+	case cookieFormatHeader:
+		cookies, err = parseCookieHeader(string(data))
+	default:
+		cookies, err = a.parseNetscapeCookies(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
 	// Clear existing cookies for security
 	a.clearCookies()
+	a.writeBack = func(rotated []*http.Cookie) error {
+		return writeCookieFile(path, format, rotated)
+	}
+
+	// Store cookies securely in memory
+	for _, cookie := range cookies {
+		a.cookieStore[cookie.Name] = cookie
+	}
 
-	scanner := bufio.NewScanner(file)
+	return a.authContext(), nil
+}
+
+// parseNetscapeCookies parses the content of a Netscape-format cookie file
+func (a *CookieAuthManager) parseNetscapeCookies(data []byte) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	lineNum := 0
 
 	for scanner.Scan() {
 		lineNum++
 		line := strings.TrimSpace(scanner.Text())
 
+		// HttpOnly cookies are written with a prefix that makes them look
+		// like comments
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+
 		// Skip comments and empty lines
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
@@ -57,16 +105,18 @@ func (a *CookieAuthManager) LoadCookies(path string) (*internal.AuthContext, err
 		if err != nil {
 			return nil, fmt.Errorf("invalid cookie format at line %d: %w", lineNum, err)
 		}
-
-		// Store cookie securely in memory
-		a.cookieStore[cookie.Name] = cookie
+		cookies = append(cookies, cookie)
 	}
 
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error reading cookie file: %w", err)
 	}
 
-	// Create AuthContext from loaded cookies
+	return cookies, nil
+}
+
+// authContext creates an AuthContext from the loaded cookies
+func (a *CookieAuthManager) authContext() *internal.AuthContext {
 	authContext := &internal.AuthContext{
 		Cookies: make(map[string]*http.Cookie),
 	}
@@ -91,13 +141,25 @@ func (a *CookieAuthManager) LoadCookies(path string) (*internal.AuthContext, err
 		authContext.ExpiresAt = time.Now().Add(24 * time.Hour)
 	}
 
-	return authContext, nil
+	return authContext
 }
 
+// httpOnlyPrefix marks HttpOnly cookies in Netscape cookie files written by
+// curl and most browser extensions
+const httpOnlyPrefix = "#HttpOnly_"
+
 // parseNetscapeCookieLine parses a single line from Netscape cookie format
 // Format: domain	flag	path	secure	expiration	name	value
+// Fields separated by spaces instead of tabs are accepted too, and the value
+// may be missing when it is empty.
 func (a *CookieAuthManager) parseNetscapeCookieLine(line string) (*http.Cookie, error) {
 	fields := strings.Split(line, "\t")
+	if len(fields) == 1 {
+		fields = strings.Fields(line)
+	}
+	if len(fields) == 6 {
+		fields = append(fields, "")
+	}
 	if len(fields) != 7 {
 		return nil, fmt.Errorf("expected 7 fields, got %d", len(fields))
 	}
@@ -169,37 +231,6 @@ func (a *CookieAuthManager) ValidateSession(auth *internal.AuthContext) error {
 	return nil
 }
 
-// RefreshSession attempts to refresh an expired session using available tokens
-func (a *CookieAuthManager) RefreshSession(auth *internal.AuthContext) error {
-	if auth == nil {
-		return fmt.Errorf("auth context is nil")
-	}
-
-	// Check if we have the necessary tokens for refresh
-	if auth.STOKEN == "" {
-		return fmt.Errorf("STOKEN is required for session refresh")
-	}
-
-	// For Terabox/Baidu, session refresh typically requires making a request
-	// to their token refresh endpoint with the current STOKEN
-	// This is a simplified implementation - in practice, you'd need to:
-	// 1. Make HTTP request to Baidu's token refresh endpoint
-	// 2. Parse the response to get new tokens
-	// 3. Update the AuthContext with new tokens and expiration
-
-	// Since we don't have access to the actual refresh endpoint in this implementation,
-	// we'll extend the current session if it's still within a reasonable timeframe
-	if time.Now().Before(auth.ExpiresAt.Add(-1*time.Hour)) {
-		// Session is still valid for at least 1 hour, extend it
-		auth.ExpiresAt = time.Now().Add(24 * time.Hour)
-		return nil
-	}
-
-	// If session is too close to expiry or already expired, we can't refresh it
-	// without making actual API calls to Terabox
-	return fmt.Errorf("session cannot be refreshed - please provide fresh cookies")
-}
-
 // CreateBypassAuthContext creates a minimal auth context for bypass attempts
 func (a *CookieAuthManager) CreateBypassAuthContext() *internal.AuthContext {
 	return &internal.AuthContext{
@@ -247,4 +278,5 @@ func (a *CookieAuthManager) Cleanup() {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 	a.clearCookies()
+	a.writeBack = nil
 }
\ No newline at end of file
@@ -157,79 +157,6 @@ func TestCookieAuthManager_ValidateSession(t *testing.T) {
 	}
 }
 
-func TestCookieAuthManager_RefreshSession(t *testing.T) {
-	authManager := NewCookieAuthManager()
-
-	tests := []struct {
-		name        string
-		authContext *internal.AuthContext
-		expectError bool
-		errorMsg    string
-	}{
-		{
-			name:        "nil auth context",
-			authContext: nil,
-			expectError: true,
-			errorMsg:    "auth context is nil",
-		},
-		{
-			name: "missing STOKEN",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				ExpiresAt: time.Now().Add(time.Hour),
-			},
-			expectError: true,
-			errorMsg:    "STOKEN is required",
-		},
-		{
-			name: "session can be extended",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				STOKEN:    "valid_stoken_123456789012345678901234567890",
-				ExpiresAt: time.Now().Add(2 * time.Hour),
-			},
-			expectError: false,
-		},
-		{
-			name: "session too close to expiry",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				STOKEN:    "valid_stoken_123456789012345678901234567890",
-				ExpiresAt: time.Now().Add(30 * time.Minute),
-			},
-			expectError: true,
-			errorMsg:    "session cannot be refreshed",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			originalExpiry := time.Time{}
-			if tt.authContext != nil {
-				originalExpiry = tt.authContext.ExpiresAt
-			}
-
-			err := authManager.RefreshSession(tt.authContext)
-
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("Expected error containing '%s', but got nil", tt.errorMsg)
-				} else if !contains(err.Error(), tt.errorMsg) {
-					t.Errorf("Expected error containing '%s', got '%s'", tt.errorMsg, err.Error())
-				}
-			} else {
-				if err != nil {
-					t.Errorf("Expected no error, got: %v", err)
-				}
-				// Verify that expiry was extended
-				if tt.authContext != nil && !tt.authContext.ExpiresAt.After(originalExpiry) {
-					t.Error("Expected session expiry to be extended")
-				}
-			}
-		})
-	}
-}
-
 func TestParseNetscapeCookieLine(t *testing.T) {
 	authManager := NewCookieAuthManager()
 
@@ -596,82 +523,6 @@ func TestCookieAuthManager_ValidateSessionComprehensive(t *testing.T) {
 	}
 }
 
-// TestCookieAuthManager_RefreshSessionEdgeCases tests edge cases for session refresh
-func TestCookieAuthManager_RefreshSessionEdgeCases(t *testing.T) {
-	authManager := NewCookieAuthManager()
-
-	tests := []struct {
-		name        string
-		authContext *internal.AuthContext
-		expectError bool
-		errorMsg    string
-		checkExpiry bool
-	}{
-		{
-			name: "session expires in exactly 1 hour",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				STOKEN:    "valid_stoken_123456789012345678901234567890",
-				ExpiresAt: time.Now().Add(time.Hour),
-			},
-			expectError: true,
-			errorMsg:    "session cannot be refreshed",
-		},
-		{
-			name: "session expires in 1 hour and 1 minute",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				STOKEN:    "valid_stoken_123456789012345678901234567890",
-				ExpiresAt: time.Now().Add(time.Hour + time.Minute),
-			},
-			expectError: false,
-			checkExpiry: true,
-		},
-		{
-			name: "already expired session",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				STOKEN:    "valid_stoken_123456789012345678901234567890",
-				ExpiresAt: time.Now().Add(-time.Hour),
-			},
-			expectError: true,
-			errorMsg:    "session cannot be refreshed",
-		},
-		{
-			name: "session with zero expiration time",
-			authContext: &internal.AuthContext{
-				BDUSS:     "valid_bduss_1234567890123456789012345678901234567890",
-				STOKEN:    "valid_stoken_123456789012345678901234567890",
-				ExpiresAt: time.Time{},
-			},
-			expectError: true,
-			errorMsg:    "session cannot be refreshed",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			originalExpiry := tt.authContext.ExpiresAt
-			err := authManager.RefreshSession(tt.authContext)
-
-			if tt.expectError {
-				if err == nil {
-					t.Errorf("Expected error containing '%s', but got nil", tt.errorMsg)
-				} else if !contains(err.Error(), tt.errorMsg) {
-					t.Errorf("Expected error containing '%s', got '%s'", tt.errorMsg, err.Error())
-				}
-			} else {
-				if err != nil {
-					t.Errorf("Expected no error, got: %v", err)
-				}
-				if tt.checkExpiry && !tt.authContext.ExpiresAt.After(originalExpiry) {
-					t.Error("Expected session expiry to be extended")
-				}
-			}
-		})
-	}
-}
-
 // TestCookieAuthManager_ConcurrentAccess tests thread safety
 func TestCookieAuthManager_ConcurrentAccess(t *testing.T) {
 	authManager := NewCookieAuthManager()
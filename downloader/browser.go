@@ -0,0 +1,528 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"terafetch/internal"
+	"terafetch/internal/sqlite"
+)
+
+// Browsers whose cookie stores --cookies-from-browser reads
+const (
+	BrowserFirefox  = "firefox"
+	BrowserChromium = "chromium"
+	BrowserChrome   = "chrome"
+)
+
+// teraboxCookieDomains are the domains whose cookies are read from a browser
+var teraboxCookieDomains = []string{"terabox.com", "terabox.app", "1024terabox.com", "1024tera.com"}
+
+// chromiumEpochOffset is the number of seconds from 1601-01-01, where
+// Chromium's timestamps count from, to the Unix epoch
+const chromiumEpochOffset = 11644473600
+
+// keyringPassword returns the password Chromium keeps in the desktop keyring
+// to encrypt v11 cookies, or "" when there is none. It is a variable so that
+// tests can stand in for the keyring.
+var keyringPassword = func(browser string) string {
+	application, label := "chromium", "Chromium"
+	if browser == BrowserChrome {
+		application, label = "chrome", "Chrome"
+	}
+
+	if path, err := exec.LookPath("secret-tool"); err == nil {
+		if out, err := exec.Command(path, "lookup", "application", application).Output(); err == nil && len(out) > 0 {
+			return strings.TrimRight(string(out), "\n")
+		}
+	}
+	if path, err := exec.LookPath("kwallet-query"); err == nil {
+		out, err := exec.Command(path, "--read-password", label+" Safe Storage", "--folder", label+" Keys", "kdewallet").Output()
+		if err == nil && len(out) > 0 && !bytes.Contains(out, []byte("not found")) {
+			return strings.TrimRight(string(out), "\n")
+		}
+	}
+	return ""
+}
+
+// ParseBrowserSpec splits a --cookies-from-browser value of the form
+// browser[:profile]. The profile is a profile name or the path of a profile
+// directory, and is empty to pick the most recently used profile.
+func ParseBrowserSpec(spec string) (browser, profile string, err error) {
+	browser, profile, _ = strings.Cut(spec, ":")
+	browser = strings.ToLower(strings.TrimSpace(browser))
+	switch browser {
+	case BrowserFirefox, BrowserChromium, BrowserChrome:
+		return browser, profile, nil
+	case "google-chrome":
+		return BrowserChrome, profile, nil
+	default:
+		return "", "", fmt.Errorf("unsupported browser %q, use firefox, chromium or chrome", browser)
+	}
+}
+
+// LoadBrowserCookies loads the Terabox cookies of a browser profile, given as
+// browser[:profile], straight from the browser's cookie store
+func (a *CookieAuthManager) LoadBrowserCookies(spec string) (*internal.AuthContext, error) {
+	browser, profile, err := ParseBrowserSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := browserCookieStore(browser, profile)
+	if err != nil {
+		return nil, err
+	}
+	internal.LogDebug("Reading %s cookies from %s", browser, path)
+
+	var cookies []*http.Cookie
+	if browser == BrowserFirefox {
+		cookies, err = readFirefoxCookies(path)
+	} else {
+		cookies, err = readChromiumCookies(browser, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("no Terabox cookies found in %s, log in to Terabox in %s first", path, browser)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// Clear existing cookies for security
+	a.clearCookies()
+	a.writeBack = nil
+
+	// A cookie set on several Terabox domains is taken from the newest login
+	for _, cookie := range cookies {
+		if existing := a.cookieStore[cookie.Name]; existing == nil || cookie.Expires.After(existing.Expires) {
+			a.cookieStore[cookie.Name] = cookie
+		}
+	}
+	internal.LogInfo("Loaded %d Terabox cookies from %s", len(a.cookieStore), browser)
+
+	return a.authContext(), nil
+}
+
+// browserCookieStore returns the path of the cookie database of a browser profile
+func browserCookieStore(browser, profile string) (string, error) {
+	fileNames := []string{filepath.Join("Network", "Cookies"), "Cookies"}
+	if browser == BrowserFirefox {
+		fileNames = []string{"cookies.sqlite"}
+	}
+
+	// A profile given as a path is used as is
+	if strings.ContainsAny(profile, `/\`) {
+		info, err := os.Stat(profile)
+		if err != nil {
+			return "", fmt.Errorf("browser profile not found: %w", err)
+		}
+		if !info.IsDir() {
+			return profile, nil
+		}
+		if path := findCookieFile(profile, fileNames); path != "" {
+			return path, nil
+		}
+		return "", fmt.Errorf("no %s cookie store in %s", browser, profile)
+	}
+
+	roots := browserProfileRoots(browser)
+	profiles := make(map[string]string) // Profile directory by name
+	for _, root := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				profiles[entry.Name()] = filepath.Join(root, entry.Name())
+			}
+		}
+		if browser == BrowserFirefox {
+			for name, dir := range firefoxProfileNames(root) {
+				profiles[name] = dir
+			}
+		}
+	}
+
+	if profile != "" {
+		dir, ok := profiles[profile]
+		if !ok && browser == BrowserFirefox {
+			// Firefox profile directories are named <random>.<profile>
+			for name, candidate := range profiles {
+				if strings.HasSuffix(name, "."+profile) {
+					dir, ok = candidate, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("%s profile %q not found in %s", browser, profile, strings.Join(roots, ", "))
+		}
+		if path := findCookieFile(dir, fileNames); path != "" {
+			return path, nil
+		}
+		return "", fmt.Errorf("no %s cookie store in %s", browser, dir)
+	}
+
+	// Without a profile, the one used most recently is read
+	var newest string
+	var newestTime time.Time
+	for _, dir := range profiles {
+		path := findCookieFile(dir, fileNames)
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && (newest == "" || info.ModTime().After(newestTime)) {
+			newest, newestTime = path, info.ModTime()
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("no %s cookie store found in %s", browser, strings.Join(roots, ", "))
+	}
+	return newest, nil
+}
+
+// browserProfileRoots returns the directories a browser keeps its profiles in
+func browserProfileRoots(browser string) []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	if browser == BrowserFirefox {
+		switch runtime.GOOS {
+		case "windows":
+			return []string{filepath.Join(os.Getenv("APPDATA"), "Mozilla", "Firefox", "Profiles")}
+		case "darwin":
+			return []string{filepath.Join(home, "Library", "Application Support", "Firefox", "Profiles")}
+		default:
+			return []string{
+				filepath.Join(home, ".mozilla", "firefox"),
+				filepath.Join(home, "snap", "firefox", "common", ".mozilla", "firefox"),
+				filepath.Join(home, ".var", "app", "org.mozilla.firefox", ".mozilla", "firefox"),
+			}
+		}
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	if browser == BrowserChrome {
+		return []string{filepath.Join(configHome, "google-chrome")}
+	}
+	return []string{filepath.Join(configHome, "chromium"), filepath.Join(home, "snap", "chromium", "common", "chromium")}
+}
+
+// firefoxProfileNames reads the profile names of profiles.ini in a Firefox
+// profile root, mapping each to its directory
+func firefoxProfileNames(root string) map[string]string {
+	file, err := os.Open(filepath.Join(root, "profiles.ini"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	profiles := make(map[string]string)
+	var name, path string
+	relative := true
+	flush := func() {
+		if name != "" && path != "" {
+			if relative {
+				path = filepath.Join(root, filepath.FromSlash(path))
+			}
+			profiles[name] = path
+		}
+		name, path, relative = "", "", true
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			flush()
+			continue
+		}
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "Name":
+			name = value
+		case "Path":
+			path = value
+		case "IsRelative":
+			relative = value != "0"
+		}
+	}
+	flush()
+	return profiles
+}
+
+// findCookieFile returns the first of fileNames that exists in dir, or ""
+func findCookieFile(dir string, fileNames []string) string {
+	for _, name := range fileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// readFirefoxCookies reads the Terabox cookies of a Firefox cookies.sqlite
+func readFirefoxCookies(path string) ([]*http.Cookie, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Firefox cookie store: %w", err)
+	}
+	rows, err := db.Rows("moz_cookies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Firefox cookie store: %w", err)
+	}
+
+	var cookies []*http.Cookie
+	for _, row := range rows {
+		host := rowText(row, "host")
+		if !isTeraboxCookieDomain(host) {
+			continue
+		}
+
+		var expires time.Time
+		if expiry := rowInt(row, "expiry"); expiry > 1e11 {
+			// Newer releases store milliseconds
+			expires = time.UnixMilli(expiry)
+		} else if expiry > 0 {
+			expires = time.Unix(expiry, 0)
+		}
+
+		cookies = append(cookies, &http.Cookie{
+			Name:     rowText(row, "name"),
+			Value:    rowText(row, "value"),
+			Domain:   host,
+			Path:     rowText(row, "path"),
+			Expires:  expires,
+			Secure:   rowInt(row, "isSecure") != 0,
+			HttpOnly: rowInt(row, "isHttpOnly") != 0,
+		})
+	}
+	return cookies, nil
+}
+
+// readChromiumCookies reads and decrypts the Terabox cookies of a Chromium
+// Cookies database
+func readChromiumCookies(browser, path string) ([]*http.Cookie, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s cookie store: %w", browser, err)
+	}
+	rows, err := db.Rows("cookies")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s cookie store: %w", browser, err)
+	}
+
+	// From version 24 the decrypted value starts with the SHA-256 of the host
+	var version int
+	if meta, err := db.Rows("meta"); err == nil {
+		for _, row := range meta {
+			if rowText(row, "key") == "version" {
+				version, _ = strconv.Atoi(rowText(row, "value"))
+			}
+		}
+	}
+	decryptor := &chromiumDecryptor{browser: browser, hostPrefix: version >= 24}
+
+	var cookies []*http.Cookie
+	var failed int
+	for _, row := range rows {
+		host := rowText(row, "host_key")
+		if !isTeraboxCookieDomain(host) {
+			continue
+		}
+
+		name := rowText(row, "name")
+		value := rowText(row, "value")
+		if encrypted := rowBytes(row, "encrypted_value"); len(encrypted) > 0 {
+			value, err = decryptor.decrypt(host, encrypted)
+			if err != nil {
+				internal.LogWarn("Skipping %s cookie %s for %s: %v", browser, name, host, err)
+				failed++
+				continue
+			}
+		}
+
+		var expires time.Time
+		if expiresUTC := rowInt(row, "expires_utc"); expiresUTC > 0 {
+			expires = time.UnixMicro(expiresUTC - chromiumEpochOffset*1e6)
+		}
+
+		cookies = append(cookies, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Domain:   host,
+			Path:     rowText(row, "path"),
+			Expires:  expires,
+			Secure:   rowInt(row, "is_secure", "secure") != 0,
+			HttpOnly: rowInt(row, "is_httponly", "httponly") != 0,
+		})
+	}
+
+	if len(cookies) == 0 && failed > 0 {
+		return nil, fmt.Errorf("failed to decrypt the %d Terabox cookies of %s, check that the desktop keyring is unlocked", failed, browser)
+	}
+	return cookies, nil
+}
+
+// chromiumDecryptor decrypts Chromium cookie values as encrypted on Linux:
+// AES-128-CBC with a key derived from "peanuts" for v10 values, and from the
+// password in the desktop keyring for v11 values
+type chromiumDecryptor struct {
+	browser    string
+	hostPrefix bool
+	v11Keys    [][]byte // Derived on first use, since reading the keyring can prompt
+}
+
+// decrypt returns the value of an encrypted cookie of host
+func (d *chromiumDecryptor) decrypt(host string, encrypted []byte) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("decrypting %s cookies is only supported on Linux", d.browser)
+	}
+	if len(encrypted) < 3 {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+
+	var keys [][]byte
+	switch version := string(encrypted[:3]); version {
+	case "v10":
+		keys = [][]byte{chromiumKey("peanuts")}
+	case "v11":
+		if d.v11Keys == nil {
+			// Without a keyring Chromium falls back to the v10 password, or
+			// to an empty one in some releases
+			if password := keyringPassword(d.browser); password != "" {
+				d.v11Keys = append(d.v11Keys, chromiumKey(password))
+			}
+			d.v11Keys = append(d.v11Keys, chromiumKey("peanuts"), chromiumKey(""))
+		}
+		keys = d.v11Keys
+	default:
+		return "", fmt.Errorf("unsupported encryption version %q", version)
+	}
+
+	hostHash := sha256.Sum256([]byte(host))
+	for _, key := range keys {
+		plaintext, ok := decryptAESCBC(key, encrypted[3:])
+		if !ok {
+			continue
+		}
+		if d.hostPrefix {
+			if len(plaintext) < len(hostHash) || !bytes.Equal(plaintext[:len(hostHash)], hostHash[:]) {
+				continue
+			}
+			plaintext = plaintext[len(hostHash):]
+		}
+		if utf8.Valid(plaintext) {
+			return string(plaintext), nil
+		}
+	}
+	return "", fmt.Errorf("no key decrypts the %s value", encrypted[:3])
+}
+
+// chromiumKey derives an AES-128 key from password the way Chromium does on
+// Linux: PBKDF2-HMAC-SHA1 with the salt "saltysalt" and a single iteration,
+// which for a 16 byte key is the first block of HMAC-SHA1(password, salt || 1)
+func chromiumKey(password string) []byte {
+	mac := hmac.New(sha1.New, []byte(password))
+	mac.Write([]byte("saltysalt"))
+	binary.Write(mac, binary.BigEndian, uint32(1))
+	return mac.Sum(nil)[:16]
+}
+
+// decryptAESCBC decrypts AES-CBC data with Chromium's IV of 16 spaces and
+// removes the PKCS#7 padding, reporting false if the padding is invalid
+func decryptAESCBC(key, data []byte) ([]byte, bool) {
+	block, err := aes.NewCipher(key)
+	if err != nil || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, false
+	}
+
+	plaintext := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, bytes.Repeat([]byte{' '}, aes.BlockSize)).CryptBlocks(plaintext, data)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, false
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, false
+		}
+	}
+	return plaintext[:len(plaintext)-padding], true
+}
+
+// isTeraboxCookieDomain reports whether a cookie's host belongs to Terabox
+func isTeraboxCookieDomain(host string) bool {
+	host = strings.ToLower(strings.TrimPrefix(host, "."))
+	for _, domain := range teraboxCookieDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// rowText returns a text column of a cookie store row
+func rowText(row sqlite.Row, column string) string {
+	switch value := row[column].(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	}
+	return ""
+}
+
+// rowBytes returns a blob column of a cookie store row
+func rowBytes(row sqlite.Row, column string) []byte {
+	switch value := row[column].(type) {
+	case []byte:
+		return value
+	case string:
+		return []byte(value)
+	}
+	return nil
+}
+
+// rowInt returns the first of the integer columns that a cookie store row
+// has, as column names differ between browser releases
+func rowInt(row sqlite.Row, columns ...string) int64 {
+	for _, column := range columns {
+		switch value := row[column].(type) {
+		case int64:
+			return value
+		case float64:
+			return int64(value)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The cookie stores in testdata/browsers are written by SQLite itself, with
+// Chromium values encrypted as on Linux, see generate.py
+
+// browserHome lays the fixture cookie stores out in a fresh home directory
+// and points HOME at it
+func browserHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	copyTree(t, filepath.Join("testdata", "browsers", "firefox"), filepath.Join(home, ".mozilla", "firefox"))
+	copyTree(t, filepath.Join("testdata", "browsers", "chromium"), filepath.Join(home, ".config", "chromium"))
+	return home
+}
+
+// copyTree copies the files under src to dst
+func copyTree(t *testing.T, src, dst string) {
+	t.Helper()
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatalf("Failed to copy %s: %v", src, err)
+	}
+}
+
+// touch sets the modification time of a file to at
+func touch(t *testing.T, path string, at time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatalf("Failed to touch %s: %v", path, err)
+	}
+}
+
+func TestParseBrowserSpec(t *testing.T) {
+	tests := []struct {
+		spec, browser, profile string
+		wantErr                bool
+	}{
+		{"firefox", BrowserFirefox, "", false},
+		{"Firefox:work", BrowserFirefox, "work", false},
+		{"chromium:Profile 1", BrowserChromium, "Profile 1", false},
+		{"google-chrome", BrowserChrome, "", false},
+		{"chromium:/home/me/.config/chromium/Default", BrowserChromium, "/home/me/.config/chromium/Default", false},
+		{"safari", "", "", true},
+		{"", "", "", true},
+	}
+
+	for _, tt := range tests {
+		browser, profile, err := ParseBrowserSpec(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tt.spec)
+			}
+			continue
+		}
+		if err != nil || browser != tt.browser || profile != tt.profile {
+			t.Errorf("%q: expected %s and %q, got %s and %q (%v)", tt.spec, tt.browser, tt.profile, browser, profile, err)
+		}
+	}
+}
+
+func TestCookieAuthManager_LoadBrowserCookies_Firefox(t *testing.T) {
+	home := browserHome(t)
+	root := filepath.Join(home, ".mozilla", "firefox")
+	now := time.Now()
+	touch(t, filepath.Join(root, "abcd1234.default-release", "cookies.sqlite"), now)
+	touch(t, filepath.Join(root, "wxyz9876.work", "cookies.sqlite"), now.Add(-time.Hour))
+
+	tests := []struct {
+		spec  string
+		bduss string
+	}{
+		{"firefox", "FirefoxBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMN"},
+		{"firefox:work", "WorkProfileBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJ"},
+		{"firefox:wxyz9876.work", "WorkProfileBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJ"},
+		{"firefox:" + filepath.Join(root, "wxyz9876.work"), "WorkProfileBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			auth, err := NewCookieAuthManager().LoadBrowserCookies(tt.spec)
+			if err != nil {
+				t.Fatalf("LoadBrowserCookies failed: %v", err)
+			}
+			if auth.BDUSS != tt.bduss {
+				t.Errorf("Expected BDUSS %s, got %s", tt.bduss, auth.BDUSS)
+			}
+			if auth.ExpiresAt.Year() != 2100 {
+				t.Errorf("Expected the session to expire with BDUSS in 2100, got %v", auth.ExpiresAt)
+			}
+		})
+	}
+
+	// Only Terabox cookies are read, with expiry in seconds or milliseconds
+	auth, err := NewCookieAuthManager().LoadBrowserCookies("firefox:default-release")
+	if err != nil {
+		t.Fatalf("LoadBrowserCookies failed: %v", err)
+	}
+	if len(auth.Cookies) != 3 || auth.Cookies["session"] != nil {
+		t.Errorf("Expected BDUSS, STOKEN and ndus, got %v", auth.Cookies)
+	}
+	if ndus := auth.Cookies["ndus"]; ndus == nil || ndus.Domain != "www.1024terabox.com" || ndus.Expires.Year() != 2100 {
+		t.Errorf("Unexpected ndus cookie: %+v", ndus)
+	}
+
+	// Unknown profiles are reported
+	if _, err := NewCookieAuthManager().LoadBrowserCookies("firefox:missing"); err == nil || !strings.Contains(err.Error(), `"missing" not found`) {
+		t.Errorf("Expected a profile not found error, got %v", err)
+	}
+}
+
+func TestCookieAuthManager_LoadBrowserCookies_Chromium(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Chromium cookies are only decrypted on Linux")
+	}
+	home := browserHome(t)
+	root := filepath.Join(home, ".config", "chromium")
+	now := time.Now()
+	touch(t, filepath.Join(root, "Default", "Network", "Cookies"), now)
+	touch(t, filepath.Join(root, "Profile 1", "Cookies"), now.Add(-time.Hour))
+
+	original := keyringPassword
+	defer func() { keyringPassword = original }()
+	keyringPassword = func(browser string) string { return "keyring-secret" }
+
+	// v10 and v11 values of the current database version, which prefixes
+	// values with the hash of their host
+	auth, err := NewCookieAuthManager().LoadBrowserCookies("chromium")
+	if err != nil {
+		t.Fatalf("LoadBrowserCookies failed: %v", err)
+	}
+	if auth.BDUSS != "ChromiumBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLM" || auth.STOKEN != "chromium-stoken" {
+		t.Errorf("Unexpected BDUSS %q or STOKEN %q", auth.BDUSS, auth.STOKEN)
+	}
+	if len(auth.Cookies) != 3 || auth.Cookies["ndus"] == nil || auth.Cookies["ndus"].Value != "chromium-ndus" {
+		t.Errorf("Expected BDUSS, STOKEN and the unencrypted ndus, got %v", auth.Cookies)
+	}
+	if expires := auth.Cookies["BDUSS"].Expires; !expires.Equal(time.Unix(4102444800, 0)) {
+		t.Errorf("Expected BDUSS to expire in 2100, got %v", expires)
+	}
+
+	// An older database, and a v11 value encrypted without a keyring
+	keyringPassword = func(browser string) string { return "" }
+	auth, err = NewCookieAuthManager().LoadBrowserCookies("chromium:Profile 1")
+	if err != nil {
+		t.Fatalf("LoadBrowserCookies failed: %v", err)
+	}
+	if auth.BDUSS != "OldChromiumBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJ" || auth.STOKEN != "old-stoken" {
+		t.Errorf("Unexpected BDUSS %q or STOKEN %q", auth.BDUSS, auth.STOKEN)
+	}
+
+	// Without the keyring password the v11 value cannot be decrypted
+	auth, err = NewCookieAuthManager().LoadBrowserCookies("chromium:Default")
+	if err != nil {
+		t.Fatalf("LoadBrowserCookies failed: %v", err)
+	}
+	if auth.STOKEN != "" || auth.BDUSS == "" {
+		t.Errorf("Expected only the v10 BDUSS to be decrypted, got BDUSS %q and STOKEN %q", auth.BDUSS, auth.STOKEN)
+	}
+}
+
+func TestIsTeraboxCookieDomain(t *testing.T) {
+	for host, want := range map[string]bool{
+		".terabox.com":        true,
+		"www.1024terabox.com": true,
+		"terabox.app":         true,
+		".1024tera.com":       true,
+		".example.com":        false,
+		"notterabox.com":      false,
+		"terabox.com.evil.io": false,
+	} {
+		if got := isTeraboxCookieDomain(host); got != want {
+			t.Errorf("%s: expected %v, got %v", host, want, got)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package downloader
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"terafetch/internal"
+)
+
+// checkpointInterval is how often in-flight segment offsets are flushed to the resume metadata
+const checkpointInterval = 2 * time.Second
+
+// segmentTracker records byte-level progress for every segment of a download and
+// periodically persists it to the resume metadata, so an interrupted segment
+// resumes from the last checkpoint instead of from its start offset. It also owns
+// segment boundaries: an active segment can be split so an idle worker takes over
+// its tail, and workers read their segment's current end from the tracker.
+type segmentTracker struct {
+	planner    *DownloadPlanner
+	outputPath string
+	meta       *internal.FileMetadata
+	source     *internal.ResumeSource // Recorded with every checkpoint, may be nil
+	createdAt  time.Time
+
+	mutex     sync.Mutex
+	segments  []internal.SegmentInfo
+	positions map[int]int  // segment index -> position in segments
+	active    map[int]bool // segments a worker is currently downloading
+	dirty     bool
+
+	// writeMutex keeps flushes in order so an older snapshot never overwrites a newer one
+	writeMutex sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newSegmentTracker creates a tracker for segments. Checkpointed offsets that
+// don't fit their segment are discarded so the segment is downloaded again.
+func newSegmentTracker(planner *DownloadPlanner, outputPath string, meta *internal.FileMetadata, segments []internal.SegmentInfo) *segmentTracker {
+	t := &segmentTracker{
+		planner:    planner,
+		outputPath: outputPath,
+		meta:       meta,
+		createdAt:  time.Now(),
+		segments:   make([]internal.SegmentInfo, len(segments)),
+		positions:  make(map[int]int, len(segments)),
+		active:     make(map[int]bool),
+	}
+
+	copy(t.segments, segments)
+	for i := range t.segments {
+		segment := &t.segments[i]
+		if segment.Downloaded < 0 || segment.Downloaded > segment.Size() {
+			segment.Downloaded = 0
+		}
+		t.positions[segment.Index] = i
+	}
+
+	return t
+}
+
+// start begins flushing checkpoints every interval until stopCheckpoints is called
+func (t *segmentTracker) start(interval time.Duration) {
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.flush(); err != nil {
+					internal.LogWarn("Failed to checkpoint download progress: %v", err)
+				}
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopCheckpoints stops the periodic flush and writes a final checkpoint
+func (t *segmentTracker) stopCheckpoints() error {
+	if t.stop != nil {
+		close(t.stop)
+		<-t.done
+		t.stop = nil
+	}
+	return t.flush()
+}
+
+// advance records n more bytes written to the segment with the given index
+func (t *segmentTracker) advance(index int, n int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if i, ok := t.positions[index]; ok {
+		t.segments[i].Downloaded += n
+		t.dirty = true
+	}
+}
+
+// complete marks a segment as finished and persists it immediately
+func (t *segmentTracker) complete(index int) error {
+	t.mutex.Lock()
+	i, ok := t.positions[index]
+	if !ok {
+		t.mutex.Unlock()
+		return fmt.Errorf("invalid segment index: %d", index)
+	}
+	t.segments[i].Completed = true
+	t.segments[i].Downloaded = t.segments[i].Size()
+	t.dirty = true
+	t.mutex.Unlock()
+
+	return t.flush()
+}
+
+// setActive marks whether a worker is currently downloading the segment
+func (t *segmentTracker) setActive(index int, active bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if active {
+		t.active[index] = true
+	} else {
+		delete(t.active, index)
+	}
+}
+
+// end returns the current last byte of the segment, which moves down when the
+// segment is split
+func (t *segmentTracker) end(index int) (int64, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i, ok := t.positions[index]
+	if !ok {
+		return 0, false
+	}
+	return t.segments[i].End, true
+}
+
+// split cuts the active segment with the most bytes left in half and returns the
+// new segment covering its second half. Both halves keep at least the planner's
+// minimum segment size; when no segment is large enough, ok is false.
+//
+// The worker on the split segment may have one copy buffer read but not yet
+// recorded, so the split point always lies at least a full buffer beyond the
+// checkpointed offset and that write can never cross into the new segment.
+func (t *segmentTracker) split() (internal.SegmentInfo, bool) {
+	minSize := t.planner.minSegmentSize
+	if minSize < 2*copyBufferSize {
+		minSize = 2 * copyBufferSize
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	largest, largestRemaining := -1, int64(0)
+	for index := range t.active {
+		i, ok := t.positions[index]
+		if !ok || t.segments[i].Completed {
+			continue
+		}
+		if remaining := t.segments[i].Remaining(); remaining > largestRemaining {
+			largest, largestRemaining = i, remaining
+		}
+	}
+	if largest < 0 || largestRemaining < 2*minSize {
+		return internal.SegmentInfo{}, false
+	}
+
+	segment := &t.segments[largest]
+	splitAt := segment.Offset() + largestRemaining/2
+
+	// New segments are appended, so indexes keep matching slice positions for
+	// resume files written by the planner
+	index := len(t.segments)
+	for _, taken := t.positions[index]; taken; _, taken = t.positions[index] {
+		index++
+	}
+	tail := internal.SegmentInfo{
+		Index: index,
+		Start: splitAt,
+		End:   segment.End,
+	}
+	segment.End = splitAt - 1
+
+	t.positions[tail.Index] = len(t.segments)
+	t.segments = append(t.segments, tail)
+	t.dirty = true
+
+	internal.LogDebug("Split segment %d at byte %d, new segment %d covers %d-%d",
+		segment.Index, splitAt, tail.Index, tail.Start, tail.End)
+	return tail, true
+}
+
+// incomplete returns the number of segments that have not finished
+func (t *segmentTracker) incomplete() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	count := 0
+	for _, segment := range t.segments {
+		if !segment.Completed {
+			count++
+		}
+	}
+	return count
+}
+
+// downloaded returns the number of bytes written across all segments
+func (t *segmentTracker) downloaded() int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var total int64
+	for _, segment := range t.segments {
+		if segment.Completed {
+			total += segment.End - segment.Start + 1
+		} else {
+			total += segment.Downloaded
+		}
+	}
+	return total
+}
+
+// setLink records a refreshed direct link so the next checkpoint persists it
+// and a later resume starts from the working link
+func (t *segmentTracker) setLink(url string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.meta.DirectURL == url {
+		return
+	}
+	// The metadata is shared with the caller, so swap in a copy
+	meta := *t.meta
+	meta.DirectURL = url
+	t.meta = &meta
+	t.dirty = true
+}
+
+// flush writes the current segment state to the resume metadata if it changed
+func (t *segmentTracker) flush() error {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	t.mutex.Lock()
+	if !t.dirty {
+		t.mutex.Unlock()
+		return nil
+	}
+	snapshot := make([]internal.SegmentInfo, len(t.segments))
+	copy(snapshot, t.segments)
+	meta := t.meta
+	t.dirty = false
+	t.mutex.Unlock()
+
+	resumeData := &internal.ResumeMetadata{
+		FileMetadata: meta,
+		Source:       t.source,
+		Segments:     snapshot,
+		CreatedAt:    t.createdAt,
+		LastUpdate:   time.Now(),
+	}
+	if err := t.planner.saveResumeMetadataStruct(t.outputPath, resumeData); err != nil {
+		t.mutex.Lock()
+		t.dirty = true
+		t.mutex.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"terafetch/internal"
+)
+
+func TestSegmentTracker_Checkpoint(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "terafetch_checkpoint_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	planner := NewDownloadPlanner()
+	outputPath := filepath.Join(tempDir, "file.bin")
+	meta := &internal.FileMetadata{Filename: "file.bin", Size: 2000}
+	segments := []internal.SegmentInfo{
+		{Index: 0, Start: 0, End: 999},
+		{Index: 1, Start: 1000, End: 1999, Downloaded: 5000}, // Offset past the segment end
+	}
+
+	tracker := newSegmentTracker(planner, outputPath, meta, segments)
+
+	tracker.advance(0, 100)
+	tracker.advance(0, 150)
+	if err := tracker.flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	resumeData, err := planner.LoadResumeMetadata(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if resumeData.Segments[0].Downloaded != 250 {
+		t.Errorf("Expected checkpointed offset 250, got %d", resumeData.Segments[0].Downloaded)
+	}
+	if resumeData.Segments[1].Downloaded != 0 {
+		t.Errorf("Expected out-of-range checkpoint to be discarded, got %d", resumeData.Segments[1].Downloaded)
+	}
+
+	if err := tracker.complete(1); err != nil {
+		t.Fatalf("complete failed: %v", err)
+	}
+	resumeData, err = planner.LoadResumeMetadata(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if !resumeData.Segments[1].Completed || resumeData.Segments[1].Downloaded != 1000 {
+		t.Errorf("Expected segment 1 to be persisted as complete, got %+v", resumeData.Segments[1])
+	}
+
+	if err := tracker.complete(7); err == nil {
+		t.Error("Expected error for unknown segment index")
+	}
+
+	// The segments passed in are not modified
+	if segments[0].Downloaded != 0 {
+		t.Error("Expected tracker to work on a copy of the segments")
+	}
+}
+
+func TestMultiThreadEngine_ResumeFromCheckpoint(t *testing.T) {
+	const fileSize = 64 * 1024
+	const checkpoint = 40 * 1024
+
+	testData := make([]byte, fileSize)
+	for i := range testData {
+		testData[i] = byte(i % 251)
+	}
+
+	var mutex sync.Mutex
+	var rangeStarts []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+			t.Errorf("Unexpected Range header %q", r.Header.Get("Range"))
+			return
+		}
+
+		mutex.Lock()
+		rangeStarts = append(rangeStarts, start)
+		mutex.Unlock()
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(testData[start : end+1])
+	}))
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_checkpoint_resume_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	engine := NewMultiThreadEngine()
+	meta := &internal.FileMetadata{
+		Filename:  "checkpoint.bin",
+		Size:      fileSize,
+		DirectURL: server.URL,
+		ShareID:   "checkpoint123",
+		Timestamp: time.Now(),
+	}
+	outputPath := filepath.Join(tempDir, "checkpoint.bin")
+
+	// Simulate an interrupted download: the first bytes are on disk and checkpointed
+	partData := make([]byte, fileSize)
+	copy(partData, testData[:checkpoint])
+	if err := os.WriteFile(outputPath+".part", partData, 0644); err != nil {
+		t.Fatalf("Failed to create part file: %v", err)
+	}
+	segments := []internal.SegmentInfo{
+		{Index: 0, Start: 0, End: fileSize - 1, Downloaded: checkpoint},
+	}
+	if err := engine.planner.SaveResumeMetadata(outputPath, meta, segments); err != nil {
+		t.Fatalf("Failed to save resume metadata: %v", err)
+	}
+
+	config := &internal.DownloadConfig{
+		OutputPath: outputPath,
+		Threads:    1,
+		Quiet:      true,
+	}
+	if err := engine.Download(context.Background(), meta, config); err != nil {
+		t.Fatalf("Resume download failed: %v", err)
+	}
+
+	if len(rangeStarts) != 1 || rangeStarts[0] != checkpoint {
+		t.Errorf("Expected a single request from offset %d, got %v", checkpoint, rangeStarts)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(content, testData) {
+		t.Error("Downloaded content does not match after resuming from a checkpoint")
+	}
+}
+
+func TestSegmentTracker_Split(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "terafetch_split_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	planner := NewDownloadPlanner()
+	outputPath := filepath.Join(tempDir, "file.bin")
+	meta := &internal.FileMetadata{Filename: "file.bin", Size: 8 * MinSegmentSize}
+	segments := []internal.SegmentInfo{
+		{Index: 0, Start: 0, End: 4*MinSegmentSize - 1, Downloaded: MinSegmentSize},
+		{Index: 1, Start: 4 * MinSegmentSize, End: 8*MinSegmentSize - 1},
+	}
+
+	tracker := newSegmentTracker(planner, outputPath, meta, segments)
+
+	// Only segments a worker is downloading are split
+	if _, ok := tracker.split(); ok {
+		t.Fatal("Expected no split without active segments")
+	}
+
+	tracker.setActive(0, true)
+	tracker.setActive(1, true)
+
+	// Segment 1 has the most bytes left, so its second half is split off
+	tail, ok := tracker.split()
+	if !ok {
+		t.Fatal("Expected the largest active segment to be split")
+	}
+	if tail.Index != 2 || tail.Start != 6*MinSegmentSize || tail.End != 8*MinSegmentSize-1 {
+		t.Errorf("Unexpected split segment: %+v", tail)
+	}
+	if end, _ := tracker.end(1); end != 6*MinSegmentSize-1 {
+		t.Errorf("Expected segment 1 to end at %d, got %d", 6*MinSegmentSize-1, end)
+	}
+
+	// Segment 0 now has 3MB left and splits after its checkpointed offset
+	tracker.setActive(1, false)
+	tail, ok = tracker.split()
+	if !ok {
+		t.Fatal("Expected segment 0 to be split")
+	}
+	if expected := int64(MinSegmentSize + 3*MinSegmentSize/2); tail.Start != expected {
+		t.Errorf("Expected split at %d, got %d", expected, tail.Start)
+	}
+
+	// Nothing left has room for two minimum-size halves
+	if _, ok := tracker.split(); ok {
+		t.Error("Expected no split below the minimum segment size")
+	}
+
+	// New boundaries are persisted so resume picks them up
+	if err := tracker.flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	resumeData, err := planner.LoadResumeMetadata(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if len(resumeData.Segments) != 4 {
+		t.Fatalf("Expected 4 segments in resume metadata, got %d", len(resumeData.Segments))
+	}
+
+	var covered int64
+	for i, segment := range resumeData.Segments {
+		if segment.Index != i {
+			t.Errorf("Expected segment index %d to match its position, got %d", i, segment.Index)
+		}
+		covered += segment.Size()
+	}
+	if covered != meta.Size {
+		t.Errorf("Expected segments to cover %d bytes, got %d", meta.Size, covered)
+	}
+}
+
+func TestMultiThreadEngine_DynamicSplitting(t *testing.T) {
+	const fileSize = 2 * 1024 * 1024
+
+	testData := make([]byte, fileSize)
+	for i := range testData {
+		testData[i] = byte(i % 251)
+	}
+
+	var mutex sync.Mutex
+	var rangeStarts []int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		rangeStarts = append(rangeStarts, start)
+		mutex.Unlock()
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+
+		// The connection serving the start of the file is slow
+		for offset := start; offset <= end; offset += 32 * 1024 {
+			chunkEnd := offset + 32*1024
+			if chunkEnd > end+1 {
+				chunkEnd = end + 1
+			}
+			if _, err := w.Write(testData[offset:chunkEnd]); err != nil {
+				return
+			}
+			if start == 0 {
+				w.(http.Flusher).Flush()
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}))
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_split_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	engine := NewMultiThreadEngine()
+	engine.planner.minSegmentSize = 128 * 1024
+
+	outputPath := filepath.Join(tempDir, "split.bin")
+	meta := &internal.FileMetadata{
+		Filename:  "split.bin",
+		Size:      fileSize,
+		DirectURL: server.URL,
+		ShareID:   "split123",
+		Timestamp: time.Now(),
+	}
+	config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 2, Quiet: true}
+
+	if err := engine.Download(context.Background(), meta, config); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	downloaded, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(downloaded, testData) {
+		t.Fatal("Downloaded data does not match source")
+	}
+
+	// The idle worker must have taken over part of the slow segment
+	mutex.Lock()
+	defer mutex.Unlock()
+	split := false
+	for _, start := range rangeStarts {
+		if start != 0 && start != fileSize/2 {
+			split = true
+		}
+	}
+	if !split {
+		t.Errorf("Expected a request for a split segment, got range starts %v", rangeStarts)
+	}
+}
@@ -0,0 +1,144 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// StaleReason says why a partial download is considered stale
+type StaleReason string
+
+const (
+	// StaleOrphanedMetadata is a resume file whose .part file is gone
+	StaleOrphanedMetadata StaleReason = "orphaned metadata"
+	// StaleOrphanedPart is a .part file without a resume file
+	StaleOrphanedPart StaleReason = "no metadata"
+	// StaleCorrupt is a resume file that cannot be read
+	StaleCorrupt StaleReason = "corrupt metadata"
+	// StaleExpired is a download whose last checkpoint is older than the cutoff
+	StaleExpired StaleReason = "expired"
+)
+
+// StaleDownload is a partial download found by FindStaleDownloads
+type StaleDownload struct {
+	OutputPath string
+	Reason     StaleReason
+	Files      []string      // The files Remove deletes
+	Size       int64         // Bytes taken up by Files
+	Age        time.Duration // Time since the last checkpoint, or since the files were last written
+}
+
+// Remove deletes the download's files. Files that are already gone are ignored.
+func (d *StaleDownload) Remove() error {
+	var errs []error
+	for _, path := range d.Files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FindStaleDownloads finds the partial downloads under dir and its
+// subdirectories that can no longer be resumed: resume files without a .part
+// file, .part files without a resume file last written more than maxAge ago,
+// unreadable resume files, and downloads last checkpointed more than maxAge
+// ago. A maxAge of zero or less keeps downloads and .part files of any age.
+// Resume files written by a newer version of TeraFetch are never reported.
+// Nothing is removed.
+func (p *DownloadPlanner) FindStaleDownloads(dir string, maxAge time.Duration) ([]*StaleDownload, error) {
+	var metadataPaths, partPaths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+		case strings.HasSuffix(entry.Name(), ResumeMetadataExt):
+			metadataPaths = append(metadataPaths, path)
+		case strings.HasSuffix(entry.Name(), ".part"):
+			partPaths = append(partPaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	var stale []*StaleDownload
+	tracked := make(map[string]bool)
+	for _, metadataPath := range metadataPaths {
+		outputPath := strings.TrimSuffix(metadataPath, ResumeMetadataExt)
+		tracked[outputPath+".part"] = true
+		if download := p.inspectStaleDownload(outputPath, maxAge); download != nil {
+			stale = append(stale, download)
+		}
+	}
+
+	// A .part file without metadata may belong to another program or to a
+	// download that is just starting, so only old ones are reported
+	for _, partPath := range partPaths {
+		if tracked[partPath] || maxAge <= 0 {
+			continue
+		}
+		download := &StaleDownload{
+			OutputPath: strings.TrimSuffix(partPath, ".part"),
+			Reason:     StaleOrphanedPart,
+		}
+		addStaleFile(download, partPath)
+		if len(download.Files) > 0 && download.Age > maxAge {
+			stale = append(stale, download)
+		}
+	}
+
+	return stale, nil
+}
+
+// inspectStaleDownload returns the download at outputPath if it is stale, or nil
+func (p *DownloadPlanner) inspectStaleDownload(outputPath string, maxAge time.Duration) *StaleDownload {
+	download := &StaleDownload{OutputPath: outputPath}
+	metadataPath := outputPath + ResumeMetadataExt
+	partPath := outputPath + ".part"
+
+	resumeData, err := p.LoadResumeMetadata(outputPath)
+	switch {
+	case errors.Is(err, ErrUnsupportedResumeVersion):
+		return nil // Belongs to a newer version, which may still resume it
+	case err != nil:
+		download.Reason = StaleCorrupt
+	default:
+		download.Age = time.Since(resumeData.LastUpdate)
+		if _, err := os.Stat(partPath); os.IsNotExist(err) {
+			download.Reason = StaleOrphanedMetadata
+		} else if maxAge > 0 && download.Age > maxAge {
+			download.Reason = StaleExpired
+		} else {
+			return nil
+		}
+	}
+
+	addStaleFile(download, metadataPath)
+	addStaleFile(download, partPath)
+	return download
+}
+
+// addStaleFile adds path to the download's files if it exists. Downloads
+// without a checkpoint take their age from the newest file.
+func addStaleFile(download *StaleDownload, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	download.Files = append(download.Files, path)
+	download.Size += info.Size()
+	if download.Reason == StaleCorrupt || download.Reason == StaleOrphanedPart {
+		if age := time.Since(info.ModTime()); len(download.Files) == 1 || age < download.Age {
+			download.Age = age
+		}
+	}
+}
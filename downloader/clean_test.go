@@ -0,0 +1,157 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terafetch/internal"
+)
+
+func TestDownloadPlanner_FindStaleDownloads(t *testing.T) {
+	planner := NewDownloadPlanner()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_clean_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	write := func(path string, data []byte) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// save writes a resume file checkpointed at lastUpdate and, if partSize is
+	// not negative, a .part file of that size
+	save := func(relPath string, lastUpdate time.Time, partSize int) string {
+		outputPath := filepath.Join(tempDir, relPath)
+		meta := &internal.FileMetadata{Filename: filepath.Base(relPath), Size: 1000}
+		if err := planner.SaveResumeMetadata(outputPath, meta, []internal.SegmentInfo{{Index: 0, Start: 0, End: 999}}); err != nil {
+			t.Fatalf("Failed to save resume metadata: %v", err)
+		}
+		resumeData, err := planner.LoadResumeMetadata(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to load resume metadata: %v", err)
+		}
+		resumeData.LastUpdate = lastUpdate
+		if err := planner.saveResumeMetadataStruct(outputPath, resumeData); err != nil {
+			t.Fatalf("Failed to backdate resume metadata: %v", err)
+		}
+		if partSize >= 0 {
+			write(outputPath+".part", make([]byte, partSize))
+		}
+		return outputPath
+	}
+
+	active := save("active.bin", time.Now(), 400)
+	expired := save(filepath.Join("old", "expired.bin"), time.Now().Add(-10*24*time.Hour), 300)
+	orphanedMeta := save("orphaned.bin", time.Now(), -1)
+	orphanedPart := filepath.Join(tempDir, "lonely.bin")
+	write(orphanedPart+".part", make([]byte, 200))
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	if err := os.Chtimes(orphanedPart+".part", old, old); err != nil {
+		t.Fatalf("Failed to backdate .part file: %v", err)
+	}
+	// A new .part file without metadata may be another program's download
+	freshPart := filepath.Join(tempDir, "starting.bin")
+	write(freshPart+".part", make([]byte, 50))
+	corrupt := filepath.Join(tempDir, "corrupt.bin")
+	write(corrupt+ResumeMetadataExt, []byte("{not json"))
+	future := filepath.Join(tempDir, "future.bin")
+	write(future+ResumeMetadataExt, []byte(`{"version": 99, "file_metadata": {"filename": "future.bin", "size": 1}}`))
+
+	t.Run("with max age", func(t *testing.T) {
+		stale, err := planner.FindStaleDownloads(tempDir, 7*24*time.Hour)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		byPath := make(map[string]*StaleDownload)
+		for _, download := range stale {
+			byPath[download.OutputPath] = download
+		}
+
+		expected := map[string]struct {
+			reason StaleReason
+			files  int
+		}{
+			expired:      {StaleExpired, 2},
+			orphanedMeta: {StaleOrphanedMetadata, 1},
+			orphanedPart: {StaleOrphanedPart, 1},
+			corrupt:      {StaleCorrupt, 1},
+		}
+		if len(byPath) != len(expected) {
+			t.Errorf("Expected %d stale downloads, got %d", len(expected), len(byPath))
+		}
+		for outputPath, want := range expected {
+			download := byPath[outputPath]
+			if download == nil {
+				t.Errorf("Expected %s to be reported", outputPath)
+				continue
+			}
+			if download.Reason != want.reason || len(download.Files) != want.files {
+				t.Errorf("%s: expected %q with %d files, got %q with %v", outputPath, want.reason, want.files, download.Reason, download.Files)
+			}
+		}
+
+		if download := byPath[expired]; download != nil {
+			metaInfo, _ := os.Stat(expired + ResumeMetadataExt)
+			if download.Size != 300+metaInfo.Size() {
+				t.Errorf("Expected the expired download to take %d bytes, got %d", 300+metaInfo.Size(), download.Size)
+			}
+			if download.Age < 10*24*time.Hour {
+				t.Errorf("Expected the age to come from the checkpoint, got %v", download.Age)
+			}
+		}
+		if byPath[active] != nil || byPath[future] != nil {
+			t.Error("Expected active downloads and newer resume files to be left out")
+		}
+		if byPath[freshPart] != nil {
+			t.Error("Expected a new .part file without metadata to be left out")
+		}
+
+		// Finding must not remove anything
+		if _, err := os.Stat(expired + ".part"); err != nil {
+			t.Errorf("Expected the expired .part file to be kept: %v", err)
+		}
+
+		for _, download := range stale {
+			if err := download.Remove(); err != nil {
+				t.Errorf("Failed to remove %s: %v", download.OutputPath, err)
+			}
+			for _, path := range download.Files {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be removed", path)
+				}
+			}
+		}
+		for _, path := range []string{active + ".part", active + ResumeMetadataExt, future + ResumeMetadataExt, freshPart + ".part"} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("Expected %s to be kept: %v", path, err)
+			}
+		}
+	})
+
+	t.Run("without max age", func(t *testing.T) {
+		save("ancient.bin", time.Now().Add(-365*24*time.Hour), 100)
+		ancientPart := filepath.Join(tempDir, "ancient-orphan.bin.part")
+		write(ancientPart, make([]byte, 100))
+		if err := os.Chtimes(ancientPart, old, old); err != nil {
+			t.Fatalf("Failed to backdate .part file: %v", err)
+		}
+
+		stale, err := planner.FindStaleDownloads(tempDir, 0)
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		if len(stale) != 0 {
+			t.Errorf("Expected no stale downloads without a max age, got %+v", stale[0])
+		}
+	})
+}
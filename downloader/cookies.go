@@ -0,0 +1,436 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"terafetch/internal"
+)
+
+// Cookie file formats LoadCookies detects
+const (
+	cookieFormatNetscape = "netscape"
+	cookieFormatJSON     = "json"
+	cookieFormatHeader   = "header"
+)
+
+// headerCookieDomain is the domain of cookies given as a Cookie header, which
+// carries none
+const headerCookieDomain = ".terabox.com"
+
+// detectCookieFormat tells a JSON export and a Cookie header from a Netscape
+// cookie file
+func detectCookieFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return cookieFormatNetscape
+	}
+	if trimmed[0] == '[' || trimmed[0] == '{' {
+		return cookieFormatJSON
+	}
+	if isCookieHeader(string(trimmed)) {
+		return cookieFormatHeader
+	}
+	return cookieFormatNetscape
+}
+
+// isCookieHeader reports whether s is a Cookie header, with or without the
+// "Cookie:" prefix: a single line of NAME=VALUE pairs separated by semicolons
+func isCookieHeader(s string) bool {
+	if trimmed, ok := cutHeaderPrefix(s); ok {
+		return strings.Contains(trimmed, "=")
+	}
+	if strings.ContainsAny(s, "\t\n") || strings.HasPrefix(s, "#") {
+		return false
+	}
+	first, _, _ := strings.Cut(s, ";")
+	name, _, ok := strings.Cut(first, "=")
+	return ok && name != "" && !strings.ContainsAny(strings.TrimSpace(name), " ")
+}
+
+// cutHeaderPrefix removes a leading "Cookie:", reporting whether there was one
+func cutHeaderPrefix(s string) (string, bool) {
+	const prefix = "cookie:"
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// LoadCookieHeader loads cookies from a Cookie header string such as
+// "BDUSS=...; STOKEN=...", as copied from the browser's developer tools
+func (a *CookieAuthManager) LoadCookieHeader(header string) (*internal.AuthContext, error) {
+	cookies, err := parseCookieHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clearCookies()
+	a.writeBack = nil
+	for _, cookie := range cookies {
+		a.cookieStore[cookie.Name] = cookie
+	}
+	return a.authContext(), nil
+}
+
+// parseCookieHeader parses the NAME=VALUE pairs of a Cookie header. The
+// cookies are set on the Terabox domain, since the header carries none.
+func parseCookieHeader(header string) ([]*http.Cookie, error) {
+	header, _ = cutHeaderPrefix(strings.TrimSpace(header))
+
+	var cookies []*http.Cookie
+	for _, pair := range strings.Split(header, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid cookie %q in header, expected NAME=VALUE", pair)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+
+		cookies = append(cookies, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Domain:   headerCookieDomain,
+			Path:     "/",
+			HttpOnly: true,
+		})
+	}
+
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("no cookies found in header")
+	}
+	return cookies, nil
+}
+
+// jsonCookie is a cookie as exported by browser extensions such as
+// Cookie-Editor and EditThisCookie, or saved by Puppeteer, Playwright and
+// Selenium, which each name the expiry differently
+type jsonCookie struct {
+	Name           string     `json:"name"`
+	Value          string     `json:"value"`
+	Domain         string     `json:"domain"`
+	Path           string     `json:"path"`
+	Secure         bool       `json:"secure"`
+	Session        bool       `json:"session"`
+	ExpirationDate jsonExpiry `json:"expirationDate"`
+	Expires        jsonExpiry `json:"expires"`
+	Expiry         jsonExpiry `json:"expiry"`
+}
+
+// jsonExpiry is a cookie expiry given as Unix seconds, milliseconds or a
+// date string. Zero and negative values mark session cookies.
+type jsonExpiry struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *jsonExpiry) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		text = string(data)
+	}
+	if seconds, err := strconv.ParseFloat(text, 64); err == nil {
+		switch {
+		case seconds <= 0:
+		case seconds > 1e11:
+			e.Time = time.UnixMilli(int64(seconds))
+		default:
+			e.Time = time.Unix(int64(seconds), int64((seconds-float64(int64(seconds)))*1e9))
+		}
+		return nil
+	}
+	for _, layout := range []string{time.RFC3339, http.TimeFormat, time.RFC1123Z} {
+		if t, err := time.Parse(layout, text); err == nil {
+			e.Time = t
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid cookie expiry %s", data)
+}
+
+// parseJSONCookies parses a JSON cookie export: an array of cookies, or an
+// object with a "cookies" array as saved by Playwright
+func parseJSONCookies(data []byte) ([]*http.Cookie, error) {
+	var exported []jsonCookie
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var wrapper struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid JSON cookie file: %w", err)
+		}
+		exported = wrapper.Cookies
+	} else if err := json.Unmarshal(trimmed, &exported); err != nil {
+		return nil, fmt.Errorf("invalid JSON cookie file: %w", err)
+	}
+
+	cookies := make([]*http.Cookie, 0, len(exported))
+	for i, c := range exported {
+		if c.Name == "" {
+			return nil, fmt.Errorf("invalid JSON cookie file: cookie %d has no name", i+1)
+		}
+
+		var expires time.Time
+		if !c.Session {
+			for _, candidate := range []jsonExpiry{c.ExpirationDate, c.Expires, c.Expiry} {
+				if !candidate.IsZero() {
+					expires = candidate.Time
+					break
+				}
+			}
+		}
+
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     path,
+			Expires:  expires,
+			Secure:   c.Secure,
+			HttpOnly: true, // Default to HttpOnly for security, as for Netscape files
+		})
+	}
+	return cookies, nil
+}
+
+// updateNetscapeCookies sets the values and expiry of the cookies in a
+// Netscape cookie file that have the name, domain and path of a rotated
+// cookie, and appends rotated cookies the file doesn't have. Every other
+// line, including comments and #HttpOnly_ markers, is kept as it is.
+func updateNetscapeCookies(data []byte, rotated []*http.Cookie) []byte {
+	found := make(map[*http.Cookie]bool)
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+		eol := line[len(content):]
+
+		trimmed := strings.TrimSpace(content)
+		prefix := ""
+		if strings.HasPrefix(trimmed, httpOnlyPrefix) {
+			prefix, trimmed = httpOnlyPrefix, trimmed[len(httpOnlyPrefix):]
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Keep the separator the line was written with
+		sep, fields := "\t", strings.Split(trimmed, "\t")
+		if len(fields) == 1 {
+			sep, fields = " ", strings.Fields(trimmed)
+		}
+		if len(fields) == 6 {
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			continue
+		}
+
+		for _, cookie := range rotated {
+			if cookie.Name != fields[5] || !sameCookieDomain(cookie.Domain, fields[0]) || cookie.Path != fields[2] {
+				continue
+			}
+			fields[4] = strconv.FormatInt(cookieExpiry(cookie), 10)
+			fields[6] = cookie.Value
+			lines[i] = prefix + strings.Join(fields, sep) + eol
+			found[cookie] = true
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(lines, ""))
+	for _, cookie := range rotated {
+		if found[cookie] {
+			continue
+		}
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		includeSubdomains := "FALSE"
+		if strings.HasPrefix(cookie.Domain, ".") {
+			includeSubdomains = "TRUE"
+		}
+		secure := "FALSE"
+		if cookie.Secure {
+			secure = "TRUE"
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			cookie.Domain, includeSubdomains, cookie.Path, secure, cookieExpiry(cookie), cookie.Name, cookie.Value)
+	}
+	return []byte(b.String())
+}
+
+// updateJSONCookies sets the values and expiry of the cookies in a JSON export
+// that have the name, domain and path of a rotated cookie, and appends rotated
+// cookies the export doesn't have. Other cookies and fields are kept, though
+// the file is written with its keys sorted.
+func updateJSONCookies(data []byte, rotated []*http.Cookie) ([]byte, error) {
+	var wrapper map[string]json.RawMessage
+	var exported []map[string]json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid JSON cookie file: %w", err)
+		}
+		if raw, ok := wrapper["cookies"]; ok {
+			if err := json.Unmarshal(raw, &exported); err != nil {
+				return nil, fmt.Errorf("invalid JSON cookie file: %w", err)
+			}
+		}
+	} else if err := json.Unmarshal(trimmed, &exported); err != nil {
+		return nil, fmt.Errorf("invalid JSON cookie file: %w", err)
+	}
+
+	found := make(map[*http.Cookie]bool)
+	for _, fields := range exported {
+		var name, domain, path string
+		json.Unmarshal(fields["name"], &name)
+		json.Unmarshal(fields["domain"], &domain)
+		json.Unmarshal(fields["path"], &path)
+		if path == "" {
+			path = "/"
+		}
+		for _, cookie := range rotated {
+			if cookie.Name != name || !sameCookieDomain(cookie.Domain, domain) || cookie.Path != path {
+				continue
+			}
+			fields["value"], _ = json.Marshal(cookie.Value)
+			setJSONExpiry(fields, cookie)
+			found[cookie] = true
+		}
+	}
+	for _, cookie := range rotated {
+		if found[cookie] {
+			continue
+		}
+		fields := make(map[string]json.RawMessage)
+		for key, value := range map[string]interface{}{
+			"name":     cookie.Name,
+			"value":    cookie.Value,
+			"domain":   cookie.Domain,
+			"path":     cookie.Path,
+			"secure":   cookie.Secure,
+			"httpOnly": cookie.HttpOnly,
+		} {
+			fields[key], _ = json.Marshal(value)
+		}
+		setJSONExpiry(fields, cookie)
+		exported = append(exported, fields)
+	}
+
+	var out interface{} = exported
+	if wrapper != nil {
+		wrapper["cookies"], _ = json.Marshal(exported)
+		out = wrapper
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cookies: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// setJSONExpiry sets the expiry of an exported cookie under the key and in
+// the unit it already uses, or as expirationDate in seconds
+func setJSONExpiry(fields map[string]json.RawMessage, cookie *http.Cookie) {
+	if cookie.Expires.IsZero() {
+		return
+	}
+	key := "expirationDate"
+	for _, candidate := range []string{"expirationDate", "expires", "expiry"} {
+		if _, ok := fields[candidate]; ok {
+			key = candidate
+			break
+		}
+	}
+
+	var current interface{}
+	json.Unmarshal(fields[key], &current)
+	switch v := current.(type) {
+	case string:
+		fields[key], _ = json.Marshal(cookie.Expires.UTC().Format(time.RFC3339))
+	case float64:
+		if v > 1e11 {
+			fields[key], _ = json.Marshal(cookie.Expires.UnixMilli())
+			break
+		}
+		fields[key], _ = json.Marshal(cookie.Expires.Unix())
+	default:
+		fields[key], _ = json.Marshal(cookie.Expires.Unix())
+	}
+	if _, ok := fields["session"]; ok {
+		fields["session"] = json.RawMessage("false")
+	}
+}
+
+// updateCookieHeader sets the values of the rotated cookies in a Cookie
+// header, keeping the order of its pairs, and appends the ones it doesn't have
+func updateCookieHeader(data []byte, rotated []*http.Cookie) []byte {
+	content := strings.TrimRight(string(data), "\r\n")
+	eol := string(data[len(content):])
+	header, hasPrefix := cutHeaderPrefix(strings.TrimSpace(content))
+
+	found := make(map[*http.Cookie]bool)
+	var pairs []string
+	for _, pair := range strings.Split(header, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(pair, "=")
+		for _, cookie := range rotated {
+			if cookie.Name == strings.TrimSpace(name) {
+				pair = cookie.Name + "=" + cookie.Value
+				found[cookie] = true
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	for _, cookie := range rotated {
+		if !found[cookie] {
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		}
+	}
+
+	line := strings.Join(pairs, "; ")
+	if hasPrefix {
+		line = "Cookie: " + line
+	}
+	return []byte(line + eol)
+}
+
+// sameCookieDomain reports whether two cookie domains are the same, with or
+// without the leading dot
+func sameCookieDomain(a, b string) bool {
+	return strings.EqualFold(strings.TrimPrefix(a, "."), strings.TrimPrefix(b, "."))
+}
+
+// cookieExpiry returns the expiry of a cookie in Unix seconds, 0 for session
+// cookies as in Netscape cookie files
+func cookieExpiry(cookie *http.Cookie) int64 {
+	if cookie.Expires.IsZero() {
+		return 0
+	}
+	return cookie.Expires.Unix()
+}
@@ -0,0 +1,145 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testBDUSS  = "abcdef1234567890abcdef1234567890abcdef12"
+	testSTOKEN = "xyz789xyz789xyz789xyz789xyz789xyz789"
+)
+
+// TestCookieAuthManager_LoadCookiesFormats tests that every supported cookie
+// file format is detected and loaded
+func TestCookieAuthManager_LoadCookiesFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		expires time.Time // Expiry of BDUSS, zero for a session cookie
+		cookies int
+	}{
+		{
+			name: "netscape with HttpOnly prefix",
+			content: "# Netscape HTTP Cookie File\n" +
+				"#HttpOnly_.terabox.com\tTRUE\t/\tFALSE\t1735689600\tBDUSS\t" + testBDUSS + "\n" +
+				".terabox.com\tTRUE\t/\tFALSE\t1735689600\tSTOKEN\t" + testSTOKEN + "\n",
+			expires: time.Unix(1735689600, 0),
+			cookies: 2,
+		},
+		{
+			name: "netscape separated by spaces",
+			content: ".terabox.com  TRUE  /  FALSE  1735689600  BDUSS  " + testBDUSS + "\n" +
+				".terabox.com TRUE / FALSE 1735689600 STOKEN " + testSTOKEN + "\n" +
+				".terabox.com\tTRUE\t/\tFALSE\t0\tlang\t\n",
+			expires: time.Unix(1735689600, 0),
+			cookies: 3,
+		},
+		{
+			name: "JSON export",
+			content: `[
+  {"domain": ".terabox.com", "expirationDate": 1735689600.5, "hostOnly": false, "httpOnly": true,
+   "name": "BDUSS", "path": "/", "sameSite": "no_restriction", "secure": true, "session": false,
+   "storeId": "0", "value": "` + testBDUSS + `"},
+  {"domain": ".terabox.com", "name": "STOKEN", "value": "` + testSTOKEN + `", "session": true},
+  {"domain": "www.terabox.com", "name": "ndus", "value": "n", "expires": -1}
+]`,
+			expires: time.Unix(1735689600, 5e8),
+			cookies: 3,
+		},
+		{
+			name: "Playwright storage state",
+			content: `{"cookies": [
+  {"name": "BDUSS", "value": "` + testBDUSS + `", "domain": ".terabox.com", "path": "/", "expires": 1735689600000},
+  {"name": "STOKEN", "value": "` + testSTOKEN + `", "domain": ".terabox.com", "path": "/", "expires": "2024-12-31T23:59:59Z"}
+], "origins": []}`,
+			expires: time.Unix(1735689600, 0),
+			cookies: 2,
+		},
+		{
+			name:    "Cookie header",
+			content: "Cookie: BDUSS=" + testBDUSS + "; STOKEN=\"" + testSTOKEN + "\"; lang=en\n",
+			cookies: 3,
+		},
+		{
+			name:    "bare cookie header",
+			content: "BDUSS=" + testBDUSS + ";STOKEN=" + testSTOKEN,
+			cookies: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cookies")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write cookie file: %v", err)
+			}
+
+			auth, err := NewCookieAuthManager().LoadCookies(path)
+			if err != nil {
+				t.Fatalf("LoadCookies failed: %v", err)
+			}
+			if auth.BDUSS != testBDUSS || auth.STOKEN != testSTOKEN {
+				t.Errorf("Expected BDUSS %s and STOKEN %s, got %s and %s", testBDUSS, testSTOKEN, auth.BDUSS, auth.STOKEN)
+			}
+			if len(auth.Cookies) != tt.cookies {
+				t.Errorf("Expected %d cookies, got %d", tt.cookies, len(auth.Cookies))
+			}
+			if expires := auth.Cookies["BDUSS"].Expires; !expires.Equal(tt.expires) {
+				t.Errorf("Expected BDUSS to expire at %v, got %v", tt.expires, expires)
+			}
+			if auth.Cookies["BDUSS"].Domain == "" {
+				t.Error("Expected BDUSS to have a domain")
+			}
+		})
+	}
+}
+
+// TestCookieAuthManager_LoadCookiesFormatErrors tests that broken files of
+// each format are rejected
+func TestCookieAuthManager_LoadCookiesFormatErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"truncated JSON", `[{"name": "BDUSS", "value": "x"`, "invalid JSON cookie file"},
+		{"JSON cookie without name", `[{"value": "x"}]`, "has no name"},
+		{"JSON with invalid expiry", `[{"name": "BDUSS", "value": "x", "expirationDate": "soon"}]`, "invalid cookie expiry"},
+		{"header with a bare word", "Cookie: BDUSS=x; STOKEN", "expected NAME=VALUE"},
+		{"netscape with too few fields", ".terabox.com TRUE / FALSE 0", "invalid cookie format at line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cookies")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("Failed to write cookie file: %v", err)
+			}
+			if _, err := NewCookieAuthManager().LoadCookies(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error mentioning %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// TestCookieAuthManager_LoadCookieHeader tests loading a --cookie header
+func TestCookieAuthManager_LoadCookieHeader(t *testing.T) {
+	auth, err := NewCookieAuthManager().LoadCookieHeader("BDUSS=" + testBDUSS + "; STOKEN=" + testSTOKEN + ";")
+	if err != nil {
+		t.Fatalf("LoadCookieHeader failed: %v", err)
+	}
+	if auth.BDUSS != testBDUSS || auth.STOKEN != testSTOKEN {
+		t.Errorf("Unexpected BDUSS %s or STOKEN %s", auth.BDUSS, auth.STOKEN)
+	}
+	if domain := auth.Cookies["BDUSS"].Domain; domain != headerCookieDomain {
+		t.Errorf("Expected header cookies on %s, got %s", headerCookieDomain, domain)
+	}
+
+	if _, err := NewCookieAuthManager().LoadCookieHeader(" ; "); err == nil {
+		t.Error("Expected an error for a header without cookies")
+	}
+}
@@ -0,0 +1,616 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terafetch/internal"
+	"terafetch/internal/faketerabox"
+	"terafetch/utils"
+)
+
+// newFakeTeraboxClient returns an HTTP client with short retry delays for tests
+// against the fake Terabox server
+func newFakeTeraboxClient() *utils.HTTPClient {
+	return utils.NewHTTPClientWithConfig(&utils.HTTPClientConfig{
+		Timeout: 10 * time.Second,
+		RetryConfig: &utils.RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			MaxDelay:    50 * time.Millisecond,
+			Multiplier:  2.0,
+		},
+	})
+}
+
+func testContent(size int) []byte {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+func TestEndToEnd_PublicShare(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(3 * 1024 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1E2eFile",
+		Files: []*faketerabox.File{{Path: "movie.mkv", Content: content}},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_e2e_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client := newFakeTeraboxClient()
+	resolver := NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+	engine := NewMultiThreadEngineWithClient(client)
+
+	download := func(t *testing.T, name string) error {
+		meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+		if err != nil {
+			t.Fatalf("ResolvePublicLink failed: %v", err)
+		}
+		if meta.Filename != "movie.mkv" || meta.Size != int64(len(content)) || meta.Checksum == "" {
+			t.Fatalf("Unexpected metadata: %+v", meta)
+		}
+
+		outputPath := filepath.Join(tempDir, name)
+		config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 3, Quiet: true}
+		if err := engine.Download(context.Background(), meta, config); err != nil {
+			return err
+		}
+
+		downloaded, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read downloaded file: %v", err)
+		}
+		if !bytes.Equal(downloaded, content) {
+			t.Fatal("Downloaded data does not match the share")
+		}
+		return nil
+	}
+
+	t.Run("download", func(t *testing.T) {
+		if err := download(t, "plain.mkv"); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+	})
+
+	t.Run("rate_limited_segment", func(t *testing.T) {
+		server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{Status: http.StatusTooManyRequests, Times: 1})
+		if err := download(t, "throttled.mkv"); err != nil {
+			t.Fatalf("Expected a single 429 to be retried, got %v", err)
+		}
+	})
+
+	t.Run("mid_stream_reset", func(t *testing.T) {
+		server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{
+			ChunkDelay: time.Millisecond,
+			ResetAfter: 256 * 1024,
+			Times:      1,
+		})
+		before := server.Requests(faketerabox.EndpointFile)
+		if err := download(t, "reset.mkv"); err != nil {
+			t.Fatalf("Expected the reset segment to resume, got %v", err)
+		}
+		// Three segments plus at least one retry of the reset one
+		if requests := server.Requests(faketerabox.EndpointFile) - before; requests < 4 {
+			t.Errorf("Expected the reset segment to be requested again, got %d requests", requests)
+		}
+	})
+}
+
+func TestEndToEnd_CorruptedContent(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl: "1E2eCorrupt",
+		Files: []*faketerabox.File{{
+			Path:    "broken.bin",
+			Content: testContent(64 * 1024),
+			MD5:     "00000000000000000000000000000000",
+		}},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_e2e_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client := newFakeTeraboxClient()
+	resolver := NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+
+	meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+	if err != nil {
+		t.Fatalf("ResolvePublicLink failed: %v", err)
+	}
+
+	config := &internal.DownloadConfig{OutputPath: filepath.Join(tempDir, "broken.bin"), Threads: 2, Quiet: true}
+	err = NewMultiThreadEngineWithClient(client).Download(context.Background(), meta, config)
+
+	var teraboxErr *internal.TeraboxError
+	if !errors.As(err, &teraboxErr) || teraboxErr.Type != internal.ErrCorruptedFile {
+		t.Errorf("Expected ErrCorruptedFile, got %v", err)
+	}
+}
+
+func TestEndToEnd_APIErrors(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1E2eErrors",
+		Files: []*faketerabox.File{{Path: "file.bin", Content: testContent(1024)}},
+	})
+
+	resolver := NewTeraboxResolverWithClient(newFakeTeraboxClient())
+	resolver.SetBaseURL(server.URL)
+
+	tests := []struct {
+		name     string
+		fault    faketerabox.Fault
+		expected internal.ErrorType
+	}{
+		{"rate_limit_errno", faketerabox.Fault{Errno: -6, Times: 1}, internal.ErrRateLimit},
+		{"share_expired_errno", faketerabox.Fault{Errno: 12, Times: 1}, internal.ErrFileNotFound},
+		{"forbidden", faketerabox.Fault{Status: http.StatusForbidden}, internal.ErrRateLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.ClearFaults()
+			server.InjectFault(faketerabox.EndpointShareDownload, tt.fault)
+
+			_, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+			var teraboxErr *internal.TeraboxError
+			if !errors.As(err, &teraboxErr) || teraboxErr.Type != tt.expected {
+				t.Errorf("Expected %v error, got %v", tt.expected, err)
+			}
+		})
+	}
+	server.ClearFaults()
+
+	t.Run("unknown_share", func(t *testing.T) {
+		_, err := resolver.ResolvePublicLink(context.Background(), faketerabox.ShareURL("1Missing"))
+		var teraboxErr *internal.TeraboxError
+		if !errors.As(err, &teraboxErr) || teraboxErr.Code != faketerabox.ErrnoShareNotFound {
+			t.Errorf("Expected share not found error, got %v", err)
+		}
+	})
+}
+
+func TestEndToEnd_ProtectedFolderShare(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:     "1E2eFolder",
+		Password: "x7k2",
+		Files: []*faketerabox.File{
+			{Path: "Season 1/ep01.mkv", Content: testContent(2048)},
+			{Path: "Season 1/ep02.mkv", Content: testContent(4096)},
+			{Path: "notes.txt", Content: []byte("notes")},
+		},
+	})
+
+	resolver := NewTeraboxResolverWithClient(newFakeTeraboxClient())
+	resolver.SetBaseURL(server.URL)
+
+	if _, err := resolver.ResolveFolder(context.Background(), shareURL, nil); !IsSharePasswordError(err) {
+		t.Fatalf("Expected a share password error before unlocking, got %v", err)
+	}
+	if _, err := resolver.VerifySharePassword(context.Background(), shareURL, "wrong", nil); !IsSharePasswordError(err) {
+		t.Errorf("Expected a share password error for a wrong password, got %v", err)
+	}
+
+	auth, err := resolver.VerifySharePassword(context.Background(), shareURL, "x7k2", nil)
+	if err != nil {
+		t.Fatalf("VerifySharePassword failed: %v", err)
+	}
+
+	tree, err := resolver.ResolveFolder(context.Background(), shareURL, auth)
+	if err != nil {
+		t.Fatalf("ResolveFolder failed: %v", err)
+	}
+
+	files := tree.Files()
+	expected := []string{"Season 1/ep01.mkv", "Season 1/ep02.mkv", "notes.txt"}
+	if len(files) != len(expected) {
+		t.Fatalf("Expected %d files, got %d", len(expected), len(files))
+	}
+	for i, file := range files {
+		if file.Path != expected[i] {
+			t.Errorf("File %d: expected path %q, got %q", i, expected[i], file.Path)
+		}
+	}
+	if tree.TotalSize() != 2048+4096+5 {
+		t.Errorf("Expected total size %d, got %d", 2048+4096+5, tree.TotalSize())
+	}
+}
+
+func TestEndToEnd_PrivateShareSession(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	const bduss = "AbCdEfGhIjKlMnOpQrStUvWxYz0123456789AbCd"
+	content := testContent(256 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:    "1E2ePrivate",
+		Session: bduss,
+		Files:   []*faketerabox.File{{Path: "private.mkv", Content: content}},
+	})
+
+	auth := &internal.AuthContext{
+		BDUSS:  bduss,
+		STOKEN: "stoken",
+		Cookies: map[string]*http.Cookie{
+			"BDUSS":  {Name: "BDUSS", Value: bduss, Path: "/"},
+			"STOKEN": {Name: "STOKEN", Value: "stoken", Path: "/"},
+		},
+	}
+
+	tempDir, err := os.MkdirTemp("", "terafetch_e2e_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The engine shares the resolver's client and with it the session cookies
+	client := newFakeTeraboxClient()
+	resolver := NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+
+	meta, err := resolver.ResolvePrivateLink(context.Background(), shareURL, auth)
+	if err != nil {
+		t.Fatalf("ResolvePrivateLink failed: %v", err)
+	}
+
+	outputPath := filepath.Join(tempDir, "private.mkv")
+	config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 2, Quiet: true}
+	if err := NewMultiThreadEngineWithClient(client).Download(context.Background(), meta, config); err != nil {
+		t.Fatalf("Download with the session cookies failed: %v", err)
+	}
+	downloaded, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(downloaded, content) {
+		t.Fatal("Downloaded data does not match the share")
+	}
+
+	// Without the session the download link is refused
+	config.OutputPath = filepath.Join(tempDir, "anonymous.mkv")
+	if err := NewMultiThreadEngineWithClient(newFakeTeraboxClient()).Download(context.Background(), meta, config); err == nil {
+		t.Error("Expected the download without session cookies to fail")
+	}
+}
+
+func TestEndToEnd_Events(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(2 * 1024 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1E2eEvents",
+		Files: []*faketerabox.File{{Path: "events.bin", Content: content}},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_e2e_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client := newFakeTeraboxClient()
+	resolver := NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+	resolver.SetOutput(io.Discard)
+
+	meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+	if err != nil {
+		t.Fatalf("ResolvePublicLink failed: %v", err)
+	}
+
+	var output bytes.Buffer
+	engine := NewMultiThreadEngineWithClient(client)
+	engine.SetEvents(utils.NewEventWriter(&output))
+	engine.SetOutput(io.Discard)
+
+	outputPath := filepath.Join(tempDir, "events.bin")
+	config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 2, Quiet: true}
+	if err := engine.Download(context.Background(), meta, config); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+
+	var events []utils.Event
+	for _, line := range bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n")) {
+		var event utils.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			t.Fatalf("Invalid event line %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	if len(events) < 3 {
+		t.Fatalf("Expected start, progress and complete events, got %s", output.String())
+	}
+
+	start, last := events[0], events[len(events)-1]
+	if start.Type != utils.EventStart || start.File != outputPath || start.Segments != 2 || start.Metadata == nil {
+		t.Errorf("Unexpected start event: %+v", start)
+	}
+	if last.Type != utils.EventComplete || last.Summary == nil || last.Summary.TotalBytes != int64(len(content)) {
+		t.Errorf("Unexpected complete event: %+v", last)
+	}
+
+	progress := events[len(events)-2]
+	if progress.Type != utils.EventProgress || progress.Downloaded != int64(len(content)) {
+		t.Errorf("Expected final progress event before completion, got %+v", progress)
+	}
+}
+
+func TestEndToEnd_PauseResume(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(2 * 1024 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1E2ePause",
+		Files: []*faketerabox.File{{Path: "pause.bin", Content: content}},
+	})
+	server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{ChunkDelay: 50 * time.Millisecond})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_e2e_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client := newFakeTeraboxClient()
+	resolver := NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+	resolver.SetOutput(io.Discard)
+
+	meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+	if err != nil {
+		t.Fatalf("ResolvePublicLink failed: %v", err)
+	}
+
+	engine := NewMultiThreadEngineWithClient(client)
+	engine.SetOutput(io.Discard)
+	outputPath := filepath.Join(tempDir, "pause.bin")
+	source := &internal.ResumeSource{ShareURL: shareURL, AuthMode: internal.AuthModePublic, Threads: 2, OutputPath: outputPath}
+	config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 2, Quiet: true, Source: source}
+	handle := engine.Start(context.Background(), meta, config)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if downloaded, _, _, ok := engine.Progress(); ok && downloaded > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Download made no progress")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Workers stop at their next buffer and checkpoint their offsets
+	handle.Pause()
+	if !handle.Paused() {
+		t.Fatal("Expected the handle to report paused")
+	}
+	time.Sleep(300 * time.Millisecond)
+	paused, _, _, _ := engine.Progress()
+	time.Sleep(500 * time.Millisecond)
+	if still, _, _, _ := engine.Progress(); still != paused {
+		t.Errorf("Expected no progress while paused, went from %d to %d bytes", paused, still)
+	}
+	select {
+	case <-handle.Done():
+		t.Fatalf("Download finished while paused: %v", handle.Wait())
+	default:
+	}
+
+	resumeData, err := engine.planner.LoadResumeMetadata(outputPath)
+	if err != nil {
+		t.Fatalf("Expected resume metadata while paused: %v", err)
+	}
+	var checkpointed int64
+	for _, segment := range resumeData.Segments {
+		checkpointed += segment.Downloaded
+	}
+	if checkpointed != paused {
+		t.Errorf("Expected the %d paused bytes to be checkpointed, got %d", paused, checkpointed)
+	}
+	if resumeData.Source == nil || *resumeData.Source != *source {
+		t.Errorf("Expected the resume metadata to record source %+v, got %+v", source, resumeData.Source)
+	}
+
+	server.ClearFaults()
+	handle.Resume()
+	if err := handle.Wait(); err != nil {
+		t.Fatalf("Download failed after resuming: %v", err)
+	}
+	downloaded, err := os.ReadFile(outputPath)
+	if err != nil || !bytes.Equal(downloaded, content) {
+		t.Errorf("Downloaded file does not match the share (%v)", err)
+	}
+}
+
+func TestEndToEnd_ExpiredLink(t *testing.T) {
+	server := faketerabox.NewServer()
+	defer server.Close()
+
+	content := testContent(2 * 1024 * 1024)
+	shareURL := server.AddShare(&faketerabox.Share{
+		Surl:  "1E2eExpire",
+		Files: []*faketerabox.File{{Path: "expire.bin", Content: content}},
+	})
+	folderURL := server.AddShare(&faketerabox.Share{
+		Surl:     "1E2eExpireFolder",
+		Password: "x7k2",
+		Files: []*faketerabox.File{
+			{Path: "Season 1/ep01.mkv", Content: testContent(64 * 1024)},
+			{Path: "Season 1/ep02.mkv", Content: content},
+		},
+	})
+
+	tempDir, err := os.MkdirTemp("", "terafetch_e2e_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	client := newFakeTeraboxClient()
+	resolver := NewTeraboxResolverWithClient(client)
+	resolver.SetBaseURL(server.URL)
+	resolver.SetOutput(io.Discard)
+
+	newEngine := func(refresher LinkRefresher) *MultiThreadEngine {
+		engine := NewMultiThreadEngineWithClient(client)
+		engine.SetOutput(io.Discard)
+		engine.SetLinkRefresher(refresher)
+		return engine
+	}
+
+	checkFile := func(t *testing.T, path string, expected []byte) {
+		downloaded, err := os.ReadFile(path)
+		if err != nil || !bytes.Equal(downloaded, expected) {
+			t.Errorf("Downloaded file does not match the share (%v)", err)
+		}
+	}
+
+	t.Run("without refresher", func(t *testing.T) {
+		meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+		if err != nil {
+			t.Fatalf("ResolvePublicLink failed: %v", err)
+		}
+		server.ExpireLinks()
+
+		config := &internal.DownloadConfig{OutputPath: filepath.Join(tempDir, "stale.bin"), Threads: 4, Quiet: true}
+		if err := newEngine(nil).Download(context.Background(), meta, config); !isExpiredLink(err) {
+			t.Errorf("Expected an expired link error, got %v", err)
+		}
+	})
+
+	t.Run("refresh once for all workers", func(t *testing.T) {
+		meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+		if err != nil {
+			t.Fatalf("ResolvePublicLink failed: %v", err)
+		}
+		server.ExpireLinks()
+
+		before := server.Requests(faketerabox.EndpointShareDownload)
+		outputPath := filepath.Join(tempDir, "refreshed.bin")
+		config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 4, Quiet: true}
+		if err := newEngine(resolver.LinkRefresher(shareURL, nil, "")).Download(context.Background(), meta, config); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		checkFile(t, outputPath, content)
+
+		if refreshes := server.Requests(faketerabox.EndpointShareDownload) - before; refreshes != 1 {
+			t.Errorf("Expected the link to be refreshed once, got %d refreshes", refreshes)
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		meta, err := resolver.ResolvePublicLink(context.Background(), shareURL)
+		if err != nil {
+			t.Fatalf("ResolvePublicLink failed: %v", err)
+		}
+
+		// Interrupt a slow download partway through
+		server.InjectFault(faketerabox.EndpointFile, faketerabox.Fault{ChunkDelay: 50 * time.Millisecond})
+		engine := newEngine(resolver.LinkRefresher(shareURL, nil, ""))
+		outputPath := filepath.Join(tempDir, "resumed.bin")
+		config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 2, Quiet: true}
+		ctx, cancel := context.WithCancel(context.Background())
+		handle := engine.Start(ctx, meta, config)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if downloaded, _, _, ok := engine.Progress(); ok && downloaded > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Download made no progress")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		if err := handle.Wait(); err == nil {
+			t.Fatal("Expected the cancelled download to fail")
+		}
+		server.ClearFaults()
+		server.ExpireLinks()
+
+		// The stored link is dead, so resume has to resolve a fresh one before
+		// requesting any content
+		before := server.Requests(faketerabox.EndpointFile)
+		refreshed := -1
+		refresh := resolver.LinkRefresher(shareURL, nil, "")
+		engine.SetLinkRefresher(func(ctx context.Context, meta *internal.FileMetadata) (string, error) {
+			if refreshed < 0 {
+				refreshed = server.Requests(faketerabox.EndpointFile) - before
+			}
+			return refresh(ctx, meta)
+		})
+		config = &internal.DownloadConfig{Threads: 2, Quiet: true}
+		if err := engine.Resume(context.Background(), outputPath+".part", config); err != nil {
+			t.Fatalf("Resume failed: %v", err)
+		}
+		checkFile(t, outputPath, content)
+
+		if refreshed != 0 {
+			t.Errorf("Expected the link to be refreshed before any file request, got %d requests first", refreshed)
+		}
+	})
+
+	t.Run("folder share", func(t *testing.T) {
+		auth, err := resolver.VerifySharePassword(context.Background(), folderURL, "x7k2", nil)
+		if err != nil {
+			t.Fatalf("VerifySharePassword failed: %v", err)
+		}
+		tree, err := resolver.ResolveFolder(context.Background(), folderURL, auth)
+		if err != nil {
+			t.Fatalf("ResolveFolder failed: %v", err)
+		}
+		file := tree.Files()[1]
+		server.ExpireLinks()
+
+		// The file is found again by its path inside the share, without
+		// resolving the links of the other files
+		links := server.Requests(faketerabox.EndpointDownload)
+		lists := server.Requests(faketerabox.EndpointList)
+		outputPath := filepath.Join(tempDir, "ep02.mkv")
+		config := &internal.DownloadConfig{OutputPath: outputPath, Threads: 4, Quiet: true}
+		if err := newEngine(resolver.LinkRefresher(folderURL, nil, "x7k2")).Download(context.Background(), file, config); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		checkFile(t, outputPath, content)
+
+		if refreshes := server.Requests(faketerabox.EndpointDownload) - links; refreshes != 1 {
+			t.Errorf("Expected one link to be resolved, got %d", refreshes)
+		}
+		if listed := server.Requests(faketerabox.EndpointList) - lists; listed != 2 {
+			t.Errorf("Expected the root and Season 1 to be listed, got %d listings", listed)
+		}
+	})
+}
@@ -2,18 +2,26 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"terafetch/internal"
 	"terafetch/utils"
 )
 
+// copyBufferSize is the size of the buffer segment data is copied through
+const copyBufferSize = 32 * 1024
+
+// progressInterval is how often the progress display is refreshed from the
+// bytes written so far
+const progressInterval = 250 * time.Millisecond
+
 // DownloadJob represents a segment download job
 type DownloadJob struct {
 	Segment    internal.SegmentInfo
@@ -40,13 +48,29 @@ type WorkerPool struct {
 	cancel      context.CancelFunc
 	httpClient  *utils.HTTPClient
 	rateLimiter internal.RateLimiter
+	tracker     *segmentTracker    // Records byte offsets for resume checkpoints, may be nil
+	events      *utils.EventWriter // Receives segment retry events, may be nil
+	file        string             // Output path reported in events
+	handle      *DownloadHandle    // Pauses the workers, may be nil
+	link        *linkSource        // Replaces expired links, may be nil
+
+	// steal is called by workers that find the job queue drained; it returns a job
+	// split off an in-flight segment, or false when there is nothing left to split
+	steal func() (DownloadJob, bool)
 }
 
 // MultiThreadEngine implements the DownloadEngine interface
 type MultiThreadEngine struct {
-	httpClient *utils.HTTPClient
-	planner    *DownloadPlanner
-	fileOps    *utils.FileOperations
+	httpClient  *utils.HTTPClient
+	planner     *DownloadPlanner
+	fileOps     *utils.FileOperations
+	rateLimiter internal.RateLimiter // Shared limiter; nil creates one per download
+	events      *utils.EventWriter   // Machine-readable events, may be nil
+	output      io.Writer            // Human-readable status messages
+	refresher   LinkRefresher        // Re-resolves expired links, may be nil
+
+	progressMutex sync.Mutex
+	progress      *utils.ProgressTracker // Tracker of the current download, for Progress
 }
 
 // NewMultiThreadEngine creates a new instance of MultiThreadEngine
@@ -55,11 +79,92 @@ func NewMultiThreadEngine() *MultiThreadEngine {
 		httpClient: utils.NewHTTPClient(),
 		planner:    NewDownloadPlanner(),
 		fileOps:    utils.NewFileOperations(),
+		output:     os.Stdout,
+	}
+}
+
+// NewMultiThreadEngineWithClient creates a new instance with a custom HTTP client,
+// letting several engines share one connection pool
+func NewMultiThreadEngineWithClient(httpClient *utils.HTTPClient) *MultiThreadEngine {
+	return &MultiThreadEngine{
+		httpClient: httpClient,
+		planner:    NewDownloadPlanner(),
+		fileOps:    utils.NewFileOperations(),
+		output:     os.Stdout,
+	}
+}
+
+// SetRateLimiter makes the engine draw bandwidth from a shared limiter instead of
+// creating one per download from DownloadConfig.RateLimit
+func (e *MultiThreadEngine) SetRateLimiter(limiter internal.RateLimiter) {
+	e.rateLimiter = limiter
+}
+
+// SetEvents makes the engine report start, progress, retry and completion
+// events to events
+func (e *MultiThreadEngine) SetEvents(events *utils.EventWriter) {
+	e.events = events
+}
+
+// SetOutput redirects the engine's human-readable status messages, for example
+// to io.Discard when events are the only output
+func (e *MultiThreadEngine) SetOutput(w io.Writer) {
+	e.output = w
+	e.planner.output = w
+}
+
+// SetLinkRefresher makes the engine re-resolve a file's direct link when the
+// server starts rejecting it mid-download, and before resuming a download
+// whose stored link has likely expired
+func (e *MultiThreadEngine) SetLinkRefresher(refresher LinkRefresher) {
+	e.refresher = refresher
+}
+
+// Progress reports the bytes downloaded, the total size and the current speed
+// in bytes per second of the engine's current download. ok is false until the
+// download has started transferring data.
+func (e *MultiThreadEngine) Progress() (downloaded, total int64, speed float64, ok bool) {
+	e.progressMutex.Lock()
+	tracker := e.progress
+	e.progressMutex.Unlock()
+
+	if tracker == nil {
+		return 0, 0, 0, false
 	}
+	downloaded, total = tracker.GetProgress()
+	speed, _, _ = tracker.GetCurrentStats()
+	return downloaded, total, speed, true
+}
+
+// Download starts a new multi-threaded download with automatic resume detection.
+// Cancelling ctx stops all workers and leaves the .part file and resume metadata
+// consistent, so the download can be resumed later.
+func (e *MultiThreadEngine) Download(ctx context.Context, meta *internal.FileMetadata, config *internal.DownloadConfig) error {
+	return e.Start(ctx, meta, config).Wait()
+}
+
+// Start runs Download in the background and returns a handle that pauses,
+// resumes and waits for it
+func (e *MultiThreadEngine) Start(ctx context.Context, meta *internal.FileMetadata, config *internal.DownloadConfig) *DownloadHandle {
+	handle := newDownloadHandle()
+	go func() {
+		handle.finish(e.download(ctx, meta, config, handle))
+	}()
+	return handle
 }
 
-// Download starts a new multi-threaded download with automatic resume detection
-func (e *MultiThreadEngine) Download(meta *internal.FileMetadata, config *internal.DownloadConfig) error {
+// StartResume runs Resume in the background and returns a handle that pauses,
+// resumes and waits for it
+func (e *MultiThreadEngine) StartResume(ctx context.Context, partialPath string, config *internal.DownloadConfig) *DownloadHandle {
+	handle := newDownloadHandle()
+	go func() {
+		handle.finish(e.resume(ctx, partialPath, config, handle))
+	}()
+	return handle
+}
+
+// download implements Download, pausing while handle is paused
+func (e *MultiThreadEngine) download(ctx context.Context, meta *internal.FileMetadata, config *internal.DownloadConfig, handle *DownloadHandle) error {
 	if meta == nil {
 		return fmt.Errorf("file metadata cannot be nil")
 	}
@@ -80,24 +185,33 @@ func (e *MultiThreadEngine) Download(meta *internal.FileMetadata, config *intern
 
 	// Check for existing resumable download
 	resumeData, err := e.planner.DetectResumableDownload(outputPath)
+	if errors.Is(err, ErrUnsupportedResumeVersion) {
+		return err // Starting over would overwrite the newer version's .part file
+	}
 	if err != nil {
-		fmt.Printf("Warning: %v\n", err)
+		fmt.Fprintf(e.output, "Warning: %v\n", err)
 		resumeData = nil
 	}
 
+	// A resumed download keeps the source it was started with unless the
+	// caller records a new one, even when its segments have to start over
+	if config.Source == nil && resumeData != nil {
+		config.Source = resumeData.Source
+	}
+
 	var segments []internal.SegmentInfo
 	
 	if resumeData != nil {
 		// Validate resume compatibility
 		if err := e.planner.ValidateResumeCompatibility(resumeData, meta); err != nil {
-			fmt.Printf("Resume validation failed: %v, starting fresh download\n", err)
+			fmt.Fprintf(e.output, "Resume validation failed: %v, starting fresh download\n", err)
 			// Cleanup invalid resume data
 			e.planner.CleanupResumeMetadata(outputPath)
 			os.Remove(outputPath + ".part")
 			resumeData = nil
 		} else {
 			// Resume existing download
-			fmt.Printf("Resuming download from %.1f%% completion\n", 
+			fmt.Fprintf(e.output, "Resuming download from %.1f%% completion\n", 
 				e.planner.CalculateResumeProgress(resumeData.Segments))
 			segments = resumeData.Segments
 			config.ResumeData = resumeData
@@ -115,24 +229,38 @@ func (e *MultiThreadEngine) Download(meta *internal.FileMetadata, config *intern
 	// Create part file path
 	partPath := outputPath + ".part"
 
-	// Create or validate part file
-	if resumeData == nil {
-		if err := e.fileOps.CreatePartialFile(partPath, meta.Size); err != nil {
-			return fmt.Errorf("failed to create part file: %w", err)
-		}
-	} else {
+	// Validate the part file of a resumed download
+	if resumeData != nil {
 		if err := e.fileOps.ValidatePartialFile(partPath, meta.Size); err != nil {
 			return fmt.Errorf("failed to validate part file: %w", err)
 		}
 	}
 
-	// Save/update resume metadata
-	if err := e.planner.SaveResumeMetadata(outputPath, meta, segments); err != nil {
+	// Save/update resume metadata before a new part file is created, so that
+	// 'terafetch clean' never sees the part file without it
+	if err := e.planner.SaveResumeMetadataWithSource(outputPath, meta, config.Source, segments); err != nil {
 		return fmt.Errorf("failed to save resume metadata: %w", err)
 	}
 
+	if resumeData == nil {
+		if err := e.fileOps.CreatePartialFile(partPath, meta.Size); err != nil {
+			e.planner.CleanupResumeMetadata(outputPath)
+			return fmt.Errorf("failed to create part file: %w", err)
+		}
+	}
+
+	e.events.Emit(utils.Event{
+		Type:     utils.EventStart,
+		File:     outputPath,
+		Metadata: meta,
+		Threads:  config.Threads,
+		Segments: len(segments),
+		Resumed:  resumeData != nil,
+	})
+
 	// Execute the download with retry logic
-	if err := e.executeDownloadWithRetry(meta, segments, outputPath, partPath, config); err != nil {
+	summary, err := e.executeDownloadWithRetry(ctx, meta, segments, outputPath, partPath, config, handle)
+	if err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
 
@@ -144,20 +272,33 @@ func (e *MultiThreadEngine) Download(meta *internal.FileMetadata, config *intern
 	// Cleanup resume metadata
 	if err := e.planner.CleanupResumeMetadata(outputPath); err != nil {
 		// Log warning but don't fail the download
-		fmt.Printf("Warning: failed to cleanup resume metadata: %v\n", err)
+		fmt.Fprintf(e.output, "Warning: failed to cleanup resume metadata: %v\n", err)
 	}
 
+	e.events.Emit(utils.Event{Type: utils.EventComplete, File: outputPath, Metadata: meta, Summary: summary})
 	return nil
 }
 
-// Resume continues an interrupted download
-func (e *MultiThreadEngine) Resume(partialPath string, config *internal.DownloadConfig) error {
+// Resume continues an interrupted download from its .part file. With a link
+// refresher set, the stored direct link is re-resolved before downloading.
+func (e *MultiThreadEngine) Resume(ctx context.Context, partialPath string, config *internal.DownloadConfig) error {
+	return e.StartResume(ctx, partialPath, config).Wait()
+}
+
+// resume implements Resume, pausing while handle is paused
+func (e *MultiThreadEngine) resume(ctx context.Context, partialPath string, config *internal.DownloadConfig, handle *DownloadHandle) error {
 	if config == nil {
 		return fmt.Errorf("download config cannot be nil")
 	}
 
+	// The metadata sits next to the final path, not the .part file
+	outputPath := strings.TrimSuffix(partialPath, ".part")
+	if config.OutputPath == "" {
+		config.OutputPath = outputPath
+	}
+
 	// Load resume metadata
-	resumeData, err := e.planner.LoadResumeMetadata(partialPath)
+	resumeData, err := e.planner.LoadResumeMetadata(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to load resume metadata: %w", err)
 	}
@@ -165,76 +306,170 @@ func (e *MultiThreadEngine) Resume(partialPath string, config *internal.Download
 	// Update config with resume data
 	config.ResumeData = resumeData
 
+	// The stored link has usually expired by the time a download is resumed
+	if e.refresher != nil && resumeData.FileMetadata != nil {
+		meta := resumeData.FileMetadata
+		if url, err := e.refresher(ctx, meta); err != nil {
+			fmt.Fprintf(e.output, "Warning: failed to refresh download link, trying the stored one: %v\n", err)
+		} else {
+			meta.DirectURL = url
+		}
+	}
+
 	// Continue download with existing metadata
-	return e.Download(resumeData.FileMetadata, config)
+	return e.download(ctx, resumeData.FileMetadata, config, handle)
 }
 
 // executeDownloadWithRetry performs download with automatic retry and recovery
-func (e *MultiThreadEngine) executeDownloadWithRetry(meta *internal.FileMetadata, segments []internal.SegmentInfo, outputPath, partPath string, config *internal.DownloadConfig) error {
-	maxGlobalRetries := 3
+func (e *MultiThreadEngine) executeDownloadWithRetry(ctx context.Context, meta *internal.FileMetadata, segments []internal.SegmentInfo, outputPath, partPath string, config *internal.DownloadConfig, handle *DownloadHandle) (*utils.DownloadSummary, error) {
+	// The retry policy of the HTTP client applies to whole download attempts too
+	retry := e.httpClient.RetryConfig()
+	maxGlobalRetries := retry.MaxAttempts
+
+	// A refreshed link carries over to later attempts
+	var link *linkSource
+	if e.refresher != nil {
+		link = newLinkSource(meta, e.refresher)
+	}
 	
 	for attempt := 0; attempt < maxGlobalRetries; attempt++ {
-		err := e.executeDownload(meta, segments, outputPath, partPath, config)
+		summary, err := e.executeDownload(ctx, meta, segments, outputPath, partPath, config, handle, link)
 		if err == nil {
-			return nil // Success
+			return summary, nil // Success
+		}
+
+		// Never retry a cancelled download
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
 		
 		// Check if error is recoverable
 		if !e.isRecoverableError(err) {
-			return err // Non-recoverable error
+			return nil, err // Non-recoverable error
 		}
 		
-		fmt.Printf("Download attempt %d failed: %v\n", attempt+1, err)
+		fmt.Fprintf(e.output, "Download attempt %d failed: %v\n", attempt+1, err)
 		
 		if attempt < maxGlobalRetries-1 {
 			// Reload segments to get current state
 			resumeData, loadErr := e.planner.LoadResumeMetadata(outputPath)
 			if loadErr != nil {
-				fmt.Printf("Warning: failed to reload resume data: %v\n", loadErr)
+				fmt.Fprintf(e.output, "Warning: failed to reload resume data: %v\n", loadErr)
 			} else {
 				segments = resumeData.Segments
 			}
 			
 			// Wait before retry with exponential backoff
-			backoffDelay := time.Duration(1<<uint(attempt)) * time.Second
-			fmt.Printf("Retrying in %v...\n", backoffDelay)
-			time.Sleep(backoffDelay)
+			backoffDelay := retry.Delay(attempt + 1)
+			fmt.Fprintf(e.output, "Retrying in %v...\n", backoffDelay.Round(time.Millisecond))
+			e.events.Emit(utils.Event{
+				Type:    utils.EventRetry,
+				File:    outputPath,
+				Attempt: attempt + 1,
+				Delay:   backoffDelay.Seconds(),
+				Error:   internal.AsTeraboxError(err),
+			})
+			select {
+			case <-time.After(backoffDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 	}
 	
-	return fmt.Errorf("download failed after %d attempts", maxGlobalRetries)
+	return nil, fmt.Errorf("download failed after %d attempts", maxGlobalRetries)
 }
 
 // executeDownload performs the actual multi-threaded download
-func (e *MultiThreadEngine) executeDownload(meta *internal.FileMetadata, segments []internal.SegmentInfo, outputPath, partPath string, config *internal.DownloadConfig) error {
+func (e *MultiThreadEngine) executeDownload(ctx context.Context, meta *internal.FileMetadata, segments []internal.SegmentInfo, outputPath, partPath string, config *internal.DownloadConfig, handle *DownloadHandle, link *linkSource) (*utils.DownloadSummary, error) {
 	// Create or open part file
 	partFile, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to create part file: %w", err)
+		return nil, fmt.Errorf("failed to create part file: %w", err)
 	}
 	defer partFile.Close()
 
 	// Truncate or extend file to expected size
 	if err := partFile.Truncate(meta.Size); err != nil {
-		return fmt.Errorf("failed to set part file size: %w", err)
+		return nil, fmt.Errorf("failed to set part file size: %w", err)
 	}
 
-	// Create worker pool
-	pool := e.createWorkerPool(config.Threads, config.RateLimit)
+	// Checkpoint byte offsets while downloading; the final flush runs after the
+	// workers have stopped so it captures everything written to the part file
+	tracker := newSegmentTracker(e.planner, outputPath, meta, segments)
+	tracker.source = config.Source
+	if link != nil {
+		url, _ := link.current()
+		tracker.setLink(url)
+	}
+	tracker.start(checkpointInterval)
+	defer func() {
+		if err := tracker.stopCheckpoints(); err != nil {
+			fmt.Fprintf(e.output, "Warning: failed to save resume checkpoint: %v\n", err)
+		}
+	}()
+
+	// Create worker pool. Workers that run out of queued segments split the
+	// largest in-flight one, so the tail of the download isn't bounded by the
+	// slowest connection.
+	pool := e.createWorkerPool(ctx, config.Threads, config.RateLimit)
+	pool.tracker = tracker
+	pool.events = e.events
+	pool.file = outputPath
+	pool.handle = handle
+	pool.link = link
+	pool.steal = func() (DownloadJob, bool) {
+		segment, ok := tracker.split()
+		if !ok {
+			return DownloadJob{}, false
+		}
+		return DownloadJob{
+			Segment:    segment,
+			FileURL:    meta.DirectURL,
+			OutputPath: outputPath,
+			PartPath:   partPath,
+		}, true
+	}
 	defer pool.shutdown()
 
-	// Start progress tracking
+	// Start progress tracking. Progress is read from the segment tracker, which
+	// counts bytes as they are written rather than when a segment finishes.
 	progressTracker := utils.NewProgressTracker(meta.Size, config.Quiet)
-	defer func() {
-		summary := progressTracker.Finish()
-		if summary != nil {
-			summary.Filename = outputPath
+	progressTracker.SetEvents(e.events, outputPath)
+	progressTracker.Update(tracker.downloaded())
+	e.progressMutex.Lock()
+	e.progress = progressTracker
+	e.progressMutex.Unlock()
+	stopProgress := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progressTracker.SetPaused(handle.Paused())
+				progressTracker.Update(tracker.downloaded())
+			case <-stopProgress:
+				return
+			}
 		}
 	}()
-
-	// Track total progress
-	var totalProgress int64
-	var progressMutex sync.Mutex
+	var summary *utils.DownloadSummary
+	var finishOnce sync.Once
+	finishProgress := func() *utils.DownloadSummary {
+		finishOnce.Do(func() {
+			close(stopProgress)
+			<-progressDone
+			summary = progressTracker.Finish()
+			if summary != nil {
+				summary.Filename = outputPath
+			}
+		})
+		return summary
+	}
+	defer finishProgress()
 
 	// Start workers
 	pool.start()
@@ -243,78 +478,78 @@ func (e *MultiThreadEngine) executeDownload(meta *internal.FileMetadata, segment
 	go func() {
 		defer close(pool.jobs)
 		for _, segment := range segments {
-			if !segment.Completed {
-				job := DownloadJob{
-					Segment:    segment,
-					FileURL:    meta.DirectURL,
-					OutputPath: outputPath,
-					PartPath:   partPath,
-				}
-				select {
-				case pool.jobs <- job:
-				case <-pool.ctx.Done():
-					return
-				}
-			} else {
-				// Add completed segment size to progress
-				segmentSize := segment.End - segment.Start + 1
-				atomic.AddInt64(&totalProgress, segmentSize)
-				progressTracker.Update(atomic.LoadInt64(&totalProgress))
+			if segment.Completed {
+				continue
+			}
+
+			job := DownloadJob{
+				Segment:    segment,
+				FileURL:    meta.DirectURL,
+				OutputPath: outputPath,
+				PartPath:   partPath,
+			}
+			select {
+			case pool.jobs <- job:
+			case <-pool.ctx.Done():
+				return
 			}
 		}
 	}()
 
 	// Process results
-	completedSegments := 0
-	expectedSegments := len(segments)
-	
-	// Count already completed segments
-	for _, segment := range segments {
-		if segment.Completed {
-			completedSegments++
-		}
-	}
-
+	// Drain every result, even after a failure or cancellation, so segments that
+	// finished are recorded in the resume metadata before returning
+	var downloadErr error
 	for result := range pool.results {
 		if result.Error != nil {
+			if downloadErr == nil && ctx.Err() == nil {
+				downloadErr = fmt.Errorf("segment %d download failed: %w", result.SegmentIndex, result.Error)
+			}
 			pool.cancel()
-			return fmt.Errorf("segment %d download failed: %w", result.SegmentIndex, result.Error)
+			continue
 		}
 
 		if result.Completed {
 			// Update segment progress in metadata
-			if err := e.planner.UpdateSegmentProgress(outputPath, result.SegmentIndex, true); err != nil {
-				fmt.Printf("Warning: failed to update segment progress: %v\n", err)
+			if err := tracker.complete(result.SegmentIndex); err != nil {
+				fmt.Fprintf(e.output, "Warning: failed to update segment progress: %v\n", err)
 			}
-			completedSegments++
 		}
 
 		// Update progress
-		progressMutex.Lock()
-		totalProgress += result.BytesWritten
-		progressTracker.Update(totalProgress)
-		progressMutex.Unlock()
+		progressTracker.Update(tracker.downloaded())
+	}
 
-		// Check if all segments are complete
-		if completedSegments >= expectedSegments {
-			break
-		}
+	if downloadErr != nil {
+		return nil, downloadErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if remaining := tracker.incomplete(); remaining > 0 {
+		return nil, fmt.Errorf("download incomplete: %d segments unfinished", remaining)
+	}
+	finishProgress()
+
+	// Verify the checksum before the file takes its final name
+	if err := e.verifyDownload(meta, outputPath, partPath, config); err != nil {
+		return nil, err
 	}
 
 	// Perform atomic rename from .part to final file
 	if err := e.fileOps.AtomicRename(partPath, outputPath); err != nil {
-		return fmt.Errorf("failed to rename part file to final file: %w", err)
+		return nil, fmt.Errorf("failed to rename part file to final file: %w", err)
 	}
 
-	return nil
+	return summary, nil
 }
 
 // createWorkerPool creates a new worker pool for downloads
-func (e *MultiThreadEngine) createWorkerPool(workers int, rateLimit int64) *WorkerPool {
-	ctx, cancel := context.WithCancel(context.Background())
+func (e *MultiThreadEngine) createWorkerPool(parent context.Context, workers int, rateLimit int64) *WorkerPool {
+	ctx, cancel := context.WithCancel(parent)
 	
-	var rateLimiter internal.RateLimiter
-	if rateLimit > 0 {
+	rateLimiter := e.rateLimiter
+	if rateLimiter == nil && rateLimit > 0 {
 		rateLimiter = utils.NewDistributedRateLimiter(rateLimit, workers)
 	}
 
@@ -365,22 +600,40 @@ func (wp *WorkerPool) worker(id int) {
 		select {
 		case job, ok := <-wp.jobs:
 			if !ok {
+				wp.stealWork()
 				return
 			}
-			result := wp.processJob(job)
-			select {
-			case wp.results <- result:
-			case <-wp.ctx.Done():
-				return
-			}
+			// The collector drains results until the pool stops, so this never blocks for good
+			wp.results <- wp.processJob(job)
 		case <-wp.ctx.Done():
 			return
 		}
 	}
 }
 
+// stealWork keeps an idle worker busy with segments split off in-flight ones
+// until nothing is left worth splitting
+func (wp *WorkerPool) stealWork() {
+	if wp.steal == nil {
+		return
+	}
+
+	for wp.ctx.Err() == nil {
+		job, ok := wp.steal()
+		if !ok {
+			return
+		}
+		wp.results <- wp.processJob(job)
+	}
+}
+
 // processJob downloads a single segment with retry logic
 func (wp *WorkerPool) processJob(job DownloadJob) DownloadResult {
+	if wp.tracker != nil {
+		wp.tracker.setActive(job.Segment.Index, true)
+		defer wp.tracker.setActive(job.Segment.Index, false)
+	}
+
 	result := DownloadResult{
 		SegmentIndex: job.Segment.Index,
 		BytesWritten: 0,
@@ -388,13 +641,53 @@ func (wp *WorkerPool) processJob(job DownloadJob) DownloadResult {
 		Completed:    false,
 	}
 
-	maxRetries := 3
+	retry := wp.httpClient.RetryConfig()
+	maxRetries := retry.MaxAttempts
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		err := wp.downloadSegment(job, &result)
+		// A paused download holds its workers here between requests
+		if err := wp.handle.wait(wp.ctx); err != nil {
+			result.Error = err
+			return result
+		}
+
+		// Every attempt picks up the latest link in case another worker refreshed it
+		generation := 0
+		if wp.link != nil {
+			job.FileURL, generation = wp.link.current()
+		}
+
+		// Each attempt continues from the bytes the previous one managed to write
+		err := wp.downloadSegment(&job, &result)
 		if err == nil {
 			result.Completed = true
 			return result
 		}
+
+		// Pausing isn't a failure: checkpoint the offset and wait to continue
+		if errors.Is(err, errPaused) {
+			if wp.tracker != nil {
+				if err := wp.tracker.flush(); err != nil {
+					internal.LogWarn("Failed to checkpoint paused segment %d: %v", job.Segment.Index, err)
+				}
+			}
+			attempt--
+			continue
+		}
+
+		// An expired link is re-resolved once for all workers and the segment
+		// continues with the new one without using up an attempt
+		if wp.link != nil && isExpiredLink(err) {
+			url, refreshErr := wp.link.renew(wp.ctx, generation)
+			if refreshErr != nil {
+				result.Error = refreshErr
+				return result
+			}
+			if wp.tracker != nil {
+				wp.tracker.setLink(url)
+			}
+			attempt--
+			continue
+		}
 		
 		// Check if error is recoverable
 		if !wp.isNetworkError(err) || attempt == maxRetries-1 {
@@ -403,7 +696,16 @@ func (wp *WorkerPool) processJob(job DownloadJob) DownloadResult {
 		}
 		
 		// Wait before retry with exponential backoff
-		backoffDelay := time.Duration(1<<uint(attempt)) * time.Second
+		backoffDelay := retry.Delay(attempt + 1)
+		segmentIndex := job.Segment.Index
+		wp.events.Emit(utils.Event{
+			Type:    utils.EventRetry,
+			File:    wp.file,
+			Attempt: attempt + 1,
+			Segment: &segmentIndex,
+			Delay:   backoffDelay.Seconds(),
+			Error:   internal.AsTeraboxError(err),
+		})
 		select {
 		case <-time.After(backoffDelay):
 			// Continue to retry
@@ -416,8 +718,25 @@ func (wp *WorkerPool) processJob(job DownloadJob) DownloadResult {
 	return result
 }
 
-// downloadSegment performs the actual segment download
-func (wp *WorkerPool) downloadSegment(job DownloadJob, result *DownloadResult) error {
+// downloadSegment performs the actual segment download, starting from the
+// segment's checkpointed offset and advancing it as bytes are written. The
+// segment's end is re-read while copying, since an idle worker may split off
+// its tail mid-download.
+func (wp *WorkerPool) downloadSegment(job *DownloadJob, result *DownloadResult) error {
+	segmentEnd := func() int64 {
+		if wp.tracker != nil {
+			if end, ok := wp.tracker.end(job.Segment.Index); ok {
+				job.Segment.End = end
+			}
+		}
+		return job.Segment.End
+	}
+
+	offset := job.Segment.Offset()
+	if segmentEnd()-offset+1 <= 0 {
+		return nil // Everything was written before the last checkpoint
+	}
+
 	// Open part file for writing at specific offset
 	file, err := os.OpenFile(job.PartPath, os.O_WRONLY, 0644)
 	if err != nil {
@@ -425,13 +744,13 @@ func (wp *WorkerPool) downloadSegment(job DownloadJob, result *DownloadResult) e
 	}
 	defer file.Close()
 
-	// Seek to segment start position
-	if _, err := file.Seek(job.Segment.Start, io.SeekStart); err != nil {
+	// Seek to segment resume position
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
 		return fmt.Errorf("failed to seek to segment position: %w", err)
 	}
 
 	// Create HTTP request with Range header
-	rangeHeader := fmt.Sprintf("bytes=%d-%d", job.Segment.Start, job.Segment.End)
+	rangeHeader := fmt.Sprintf("bytes=%d-%d", offset, job.Segment.End)
 
 	// Execute request with retry-aware HTTP client
 	resp, err := wp.httpClient.GetWithContext(wp.ctx, job.FileURL, map[string]string{
@@ -458,13 +777,31 @@ func (wp *WorkerPool) downloadSegment(job DownloadJob, result *DownloadResult) e
 		}
 	}
 
+	// A full response would be written at the wrong offset
+	if resp.StatusCode == http.StatusOK && offset > 0 {
+		return fmt.Errorf("server ignored range request for offset %d", offset)
+	}
+
 	// Copy data with rate limiting and progress tracking
-	bytesWritten, err := wp.copyWithRateLimit(file, resp.Body, job.Segment.End-job.Segment.Start+1)
+	limit := func() int64 {
+		return segmentEnd() - offset + 1
+	}
+	bytesWritten, err := wp.copyWithRateLimit(file, resp.Body, limit, func(n int64) {
+		job.Segment.Downloaded += n
+		if wp.tracker != nil {
+			wp.tracker.advance(job.Segment.Index, n)
+		}
+	})
+	result.BytesWritten += bytesWritten
 	if err != nil {
 		return fmt.Errorf("failed to copy segment data: %w", err)
 	}
 
-	result.BytesWritten = bytesWritten
+	// A body that ends early is retried from the new offset instead of being marked complete
+	if remaining := limit(); bytesWritten < remaining {
+		return fmt.Errorf("segment %d truncated: unexpected EOF after %d of %d bytes", job.Segment.Index, bytesWritten, remaining)
+	}
+
 	return nil
 }
 
@@ -496,16 +833,20 @@ func (wp *WorkerPool) isNetworkError(err error) bool {
 	return false
 }
 
-// copyWithRateLimit copies data from reader to writer with rate limiting
-func (wp *WorkerPool) copyWithRateLimit(dst io.Writer, src io.Reader, maxBytes int64) (int64, error) {
-	const bufferSize = 32 * 1024 // 32KB buffer
-	buffer := make([]byte, bufferSize)
+// copyWithRateLimit copies data from reader to writer with rate limiting,
+// reporting every successful write to onWrite. limit returns the total number
+// of bytes to copy and is checked before every read, so it may shrink mid-copy.
+func (wp *WorkerPool) copyWithRateLimit(dst io.Writer, src io.Reader, limit func() int64, onWrite func(n int64)) (int64, error) {
+	buffer := make([]byte, copyBufferSize)
 	var totalWritten int64
 
-	for totalWritten < maxBytes {
+	for {
 		// Calculate how much to read
-		toRead := bufferSize
-		remaining := maxBytes - totalWritten
+		remaining := limit() - totalWritten
+		if remaining <= 0 {
+			break
+		}
+		toRead := copyBufferSize
 		if int64(toRead) > remaining {
 			toRead = int(remaining)
 		}
@@ -523,6 +864,9 @@ func (wp *WorkerPool) copyWithRateLimit(dst io.Writer, src io.Reader, maxBytes i
 			// Write to destination
 			written, writeErr := dst.Write(buffer[:n])
 			totalWritten += int64(written)
+			if written > 0 && onWrite != nil {
+				onWrite(int64(written))
+			}
 
 			if writeErr != nil {
 				return totalWritten, writeErr
@@ -546,6 +890,11 @@ func (wp *WorkerPool) copyWithRateLimit(dst io.Writer, src io.Reader, maxBytes i
 			return totalWritten, wp.ctx.Err()
 		default:
 		}
+
+		// Drain at the buffer boundary when the download is paused
+		if wp.handle.Paused() {
+			return totalWritten, errPaused
+		}
 	}
 
 	return totalWritten, nil
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"time"
 
 	"terafetch/internal"
+	"terafetch/utils"
 )
 
 // TestMultiThreadEngine_Download tests the basic download functionality
@@ -69,7 +71,7 @@ func TestMultiThreadEngine_Download(t *testing.T) {
 	}
 
 	// Execute download
-	err = engine.Download(meta, config)
+	err = engine.Download(context.Background(), meta, config)
 	if err != nil {
 		t.Fatalf("Download failed: %v", err)
 	}
@@ -161,7 +163,7 @@ func TestMultiThreadEngine_Resume(t *testing.T) {
 	}
 
 	// Execute download (should resume)
-	err = engine.Download(meta, config)
+	err = engine.Download(context.Background(), meta, config)
 	if err != nil {
 		t.Fatalf("Resume download failed: %v", err)
 	}
@@ -181,6 +183,104 @@ func TestMultiThreadEngine_Resume(t *testing.T) {
 	}
 }
 
+// TestMultiThreadEngine_DownloadCancellation tests that cancelling the context stops
+// the workers and leaves a resumable .part file and metadata behind
+func TestMultiThreadEngine_DownloadCancellation(t *testing.T) {
+	const fileSize = 4 * 1024 * 1024
+	chunk := make([]byte, 16*1024)
+
+	// Serve every range slowly so the download is still running when cancelled
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+			start, end = 0, fileSize-1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
+		w.WriteHeader(http.StatusPartialContent)
+
+		for remaining := end - start + 1; remaining > 0; remaining -= int64(len(chunk)) {
+			n := int64(len(chunk))
+			if remaining < n {
+				n = remaining
+			}
+			if _, err := w.Write(chunk[:n]); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-time.After(20 * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_cancel_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	engine := NewMultiThreadEngine()
+	meta := &internal.FileMetadata{
+		Filename:  "cancel.bin",
+		Size:      fileSize,
+		DirectURL: server.URL,
+		ShareID:   "cancel123",
+		Timestamp: time.Now(),
+	}
+	outputPath := filepath.Join(tempDir, "cancel.bin")
+	config := &internal.DownloadConfig{
+		OutputPath: outputPath,
+		Threads:    4,
+		Quiet:      true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.Download(ctx, meta, config)
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Download did not stop after cancellation")
+	}
+
+	if engine.fileOps.FileExists(outputPath) {
+		t.Error("Cancelled download must not be renamed to the final file")
+	}
+	if !engine.fileOps.FileExists(outputPath + ".part") {
+		t.Error("Expected .part file to be kept after cancellation")
+	}
+
+	resumeData, err := engine.planner.LoadResumeMetadata(outputPath)
+	if err != nil {
+		t.Fatalf("Expected resume metadata after cancellation: %v", err)
+	}
+	if progress := engine.planner.CalculateResumeProgress(resumeData.Segments); progress >= 100 {
+		t.Errorf("Expected incomplete resume metadata, got %.1f%% progress", progress)
+	}
+
+	// Bytes written before the cancellation are checkpointed, not thrown away
+	var checkpointed int64
+	for _, segment := range resumeData.Segments {
+		checkpointed += segment.Downloaded
+	}
+	if checkpointed == 0 {
+		t.Error("Expected byte offsets to be checkpointed on cancellation")
+	}
+}
+
 // TestWorkerPool tests the worker pool functionality
 func TestWorkerPool(t *testing.T) {
 	// Create test server
@@ -209,7 +309,7 @@ func TestWorkerPool(t *testing.T) {
 
 	// Create engine and worker pool
 	engine := NewMultiThreadEngine()
-	pool := engine.createWorkerPool(2, 0)
+	pool := engine.createWorkerPool(context.Background(), 2, 0)
 	defer pool.shutdown()
 
 	// Start worker pool
@@ -253,6 +353,37 @@ func TestWorkerPool(t *testing.T) {
 	}
 }
 
+// TestSharedRateLimiter tests that engines sharing a client and limiter hand both to their worker pools
+func TestSharedRateLimiter(t *testing.T) {
+	client := utils.NewHTTPClient()
+	limiter := utils.NewTokenBucketLimiter(1024 * 1024)
+
+	engines := []*MultiThreadEngine{
+		NewMultiThreadEngineWithClient(client),
+		NewMultiThreadEngineWithClient(client),
+	}
+	for _, engine := range engines {
+		engine.SetRateLimiter(limiter)
+
+		// The per-download rate is ignored once a shared limiter is set
+		pool := engine.createWorkerPool(context.Background(), 2, 512)
+		if pool.rateLimiter != limiter {
+			t.Error("Expected worker pool to use the shared rate limiter")
+		}
+		if pool.httpClient != client {
+			t.Error("Expected worker pool to use the shared HTTP client")
+		}
+		pool.shutdown()
+	}
+
+	// Without a shared limiter a per-download limiter is still created
+	pool := NewMultiThreadEngine().createWorkerPool(context.Background(), 2, 512)
+	defer pool.shutdown()
+	if pool.rateLimiter == nil || pool.rateLimiter == limiter {
+		t.Error("Expected a dedicated rate limiter when none is shared")
+	}
+}
+
 // TestFileIntegrityVerification tests the file integrity verification
 func TestFileIntegrityVerification(t *testing.T) {
 	// Create temporary file
@@ -408,7 +539,7 @@ func TestCompleteDownloadWorkflow(t *testing.T) {
 
 	// Execute complete download workflow
 	startTime := time.Now()
-	err = engine.Download(meta, config)
+	err = engine.Download(context.Background(), meta, config)
 	downloadDuration := time.Since(startTime)
 	
 	if err != nil {
@@ -546,7 +677,7 @@ func TestDownloadResumeWorkflow(t *testing.T) {
 
 	// First download attempt (will be interrupted)
 	t.Run("initial_download_with_interruption", func(t *testing.T) {
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		// This should fail due to simulated network interruption
 		if err == nil {
 			t.Logf("Download completed without interruption (test server behavior may vary)")
@@ -585,7 +716,7 @@ func TestDownloadResumeWorkflow(t *testing.T) {
 	t.Run("resume_download", func(t *testing.T) {
 		initialRequestCount := requestCount
 		
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		if err != nil {
 			t.Fatalf("Resume download failed: %v", err)
 		}
@@ -665,7 +796,7 @@ func TestDownloadErrorHandling(t *testing.T) {
 			Quiet:      true,
 		}
 
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		if err == nil {
 			t.Errorf("Expected error for 404 response, got nil")
 		}
@@ -691,7 +822,7 @@ func TestDownloadErrorHandling(t *testing.T) {
 			Quiet:      true,
 		}
 
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		if err == nil {
 			t.Errorf("Expected error for 403 response, got nil")
 		}
@@ -718,7 +849,7 @@ func TestDownloadErrorHandling(t *testing.T) {
 			Quiet:      true,
 		}
 
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		if err == nil {
 			t.Errorf("Expected error for 429 response, got nil")
 		}
@@ -750,7 +881,7 @@ func TestDownloadErrorHandling(t *testing.T) {
 			Quiet:      true,
 		}
 
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		if err == nil {
 			t.Errorf("Expected error for invalid range response, got nil")
 		}
@@ -763,7 +894,7 @@ func TestDownloadErrorHandling(t *testing.T) {
 			Quiet:      true,
 		}
 
-		err := engine.Download(nil, config)
+		err := engine.Download(context.Background(), nil, config)
 		if err == nil {
 			t.Errorf("Expected error for nil metadata, got nil")
 		}
@@ -775,7 +906,7 @@ func TestDownloadErrorHandling(t *testing.T) {
 			Size:     1024,
 		}
 
-		err := engine.Download(meta, nil)
+		err := engine.Download(context.Background(), meta, nil)
 		if err == nil {
 			t.Errorf("Expected error for nil config, got nil")
 		}
@@ -848,7 +979,7 @@ func TestDownloadConcurrencyAndRaceConditions(t *testing.T) {
 					Quiet:      true,
 				}
 
-				if err := engine.Download(meta, config); err != nil {
+				if err := engine.Download(context.Background(), meta, config); err != nil {
 					errors <- fmt.Errorf("download %d failed: %w", id, err)
 				}
 			}(i)
@@ -899,7 +1030,7 @@ func TestDownloadConcurrencyAndRaceConditions(t *testing.T) {
 			Quiet:      true,
 		}
 
-		err := engine.Download(meta, config)
+		err := engine.Download(context.Background(), meta, config)
 		if err != nil {
 			t.Fatalf("High thread count download failed: %v", err)
 		}
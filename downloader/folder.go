@@ -0,0 +1,289 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+const (
+	// maxFolderDepth bounds recursion when walking nested folder shares
+	maxFolderDepth = 32
+	// folderPageSize is the number of entries requested per list API page
+	folderPageSize = 100
+	// maxFolderPages bounds pagination for a single directory listing
+	maxFolderPages = 1000
+)
+
+// folderWalker walks a folder share and builds a tree of file metadata
+type folderWalker struct {
+	list    func(dir string, page int) ([]FileInfo, error)
+	link    func(file FileInfo) (string, error) // nil when only listing
+	shareID string
+}
+
+// ResolveFolder walks a folder share recursively and returns its file tree.
+// Every file node carries resolved FileMetadata whose Path is relative to the
+// share root, so callers can recreate the directory layout locally.
+func (r *TeraboxResolver) ResolveFolder(ctx context.Context, url string, auth *internal.AuthContext) (*internal.FileTreeNode, error) {
+	return r.walkFolder(ctx, url, auth, true)
+}
+
+// ListFolder walks a folder share like ResolveFolder without resolving the
+// download link of any file, for inspecting a share. A file's DirectURL is
+// empty unless the listing itself carried one.
+func (r *TeraboxResolver) ListFolder(ctx context.Context, url string, auth *internal.AuthContext) (*internal.FileTreeNode, error) {
+	return r.walkFolder(ctx, url, auth, false)
+}
+
+// walkFolder builds the file tree of a folder share, resolving the download
+// links of its files when links is set
+func (r *TeraboxResolver) walkFolder(ctx context.Context, url string, auth *internal.AuthContext, links bool) (*internal.FileTreeNode, error) {
+	// Parse and validate the URL
+	urlInfo, err := r.urlValidator.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	walker := r.newFolderWalker(ctx, urlInfo, auth)
+	if !links {
+		walker.link = nil
+	}
+	children, err := walker.walk("/", "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &internal.FileTreeNode{
+		Name:     urlInfo.GetIdentifier(),
+		IsDir:    true,
+		Children: children,
+	}
+
+	if root.FileCount() == 0 {
+		return nil, internal.NewTeraboxError(0, "no files found in share", internal.ErrFileNotFound).WithURL(url)
+	}
+
+	if links {
+		internal.LogInfo("Folder share resolved: %d files, %d bytes", root.FileCount(), root.TotalSize())
+	} else {
+		internal.LogInfo("Folder share listed: %d files, %d bytes", root.FileCount(), root.TotalSize())
+	}
+	return root, nil
+}
+
+// newFolderWalker returns a walker listing the share of urlInfo with auth
+func (r *TeraboxResolver) newFolderWalker(ctx context.Context, urlInfo *utils.URLInfo, auth *internal.AuthContext) *folderWalker {
+	return &folderWalker{
+		list: func(dir string, page int) ([]FileInfo, error) {
+			return r.callListAPI(ctx, urlInfo, dir, page, auth)
+		},
+		link: func(file FileInfo) (string, error) {
+			if auth != nil && !auth.Bypass && auth.BDUSS != "" {
+				return r.callDownloadAPI(ctx, &file, auth)
+			}
+			return r.tryGetDirectLink(ctx, file.FsID, urlInfo.Surl)
+		},
+		shareID: urlInfo.GetIdentifier(),
+	}
+}
+
+// walk lists a directory and recursively descends into its subdirectories
+func (w *folderWalker) walk(dir, relPath string, depth int) ([]*internal.FileTreeNode, error) {
+	if depth > maxFolderDepth {
+		return nil, fmt.Errorf("folder share exceeds maximum depth of %d at %s", maxFolderDepth, dir)
+	}
+
+	entries, err := w.listAll(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	nodes := make([]*internal.FileTreeNode, 0, len(entries))
+	for _, entry := range entries {
+		name := sanitizeShareName(entry.Filename)
+		nodePath := path.Join(relPath, name)
+
+		if entry.IsDir != 0 {
+			entryDir := entry.Path
+			if entryDir == "" {
+				entryDir = path.Join(dir, entry.Filename)
+			}
+
+			children, err := w.walk(entryDir, nodePath, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			nodes = append(nodes, &internal.FileTreeNode{
+				Name:     name,
+				Path:     nodePath,
+				IsDir:    true,
+				Children: children,
+			})
+			continue
+		}
+
+		file, err := w.file(entry, nodePath)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, &internal.FileTreeNode{
+			Name: name,
+			Path: nodePath,
+			File: file,
+		})
+	}
+
+	return nodes, nil
+}
+
+// find lists only the directories on the way to the file at relPath and
+// returns its entry, reporting whether the share still has it
+func (w *folderWalker) find(relPath string) (FileInfo, bool, error) {
+	dir := "/"
+	parts := strings.Split(relPath, "/")
+	for i, part := range parts {
+		entries, err := w.listAll(dir)
+		if err != nil {
+			return FileInfo{}, false, fmt.Errorf("failed to list %s: %w", dir, err)
+		}
+
+		last := i == len(parts)-1
+		found := false
+		for _, entry := range entries {
+			if sanitizeShareName(entry.Filename) != part || (entry.IsDir != 0) == last {
+				continue
+			}
+			if last {
+				return entry, true, nil
+			}
+			if entry.Path != "" {
+				dir = entry.Path
+			} else {
+				dir = path.Join(dir, entry.Filename)
+			}
+			found = true
+			break
+		}
+		if !found {
+			return FileInfo{}, false, nil
+		}
+	}
+	return FileInfo{}, false, nil
+}
+
+// file returns the metadata of the file entry at nodePath, resolving its
+// download link unless the listing carried one or the walker only lists
+func (w *folderWalker) file(entry FileInfo, nodePath string) (*internal.FileMetadata, error) {
+	dlink := entry.Dlink
+	if dlink == "" && w.link != nil {
+		var err error
+		dlink, err = w.link(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get download link for %s: %w", nodePath, err)
+		}
+	}
+
+	name := path.Base(nodePath)
+	return &internal.FileMetadata{
+		Filename:  name,
+		Size:      entry.Size,
+		DirectURL: dlink,
+		ShareID:   w.shareID,
+		Timestamp: time.Now(),
+		Checksum:  entry.MD5,
+		Path:      nodePath,
+	}, nil
+}
+
+// listAll fetches every page of a directory listing
+func (w *folderWalker) listAll(dir string) ([]FileInfo, error) {
+	var entries []FileInfo
+	for page := 1; page <= maxFolderPages; page++ {
+		batch, err := w.list(dir, page)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, batch...)
+		if len(batch) < folderPageSize {
+			return entries, nil
+		}
+	}
+
+	return nil, fmt.Errorf("directory %s exceeds %d pages", dir, maxFolderPages)
+}
+
+// callListAPI calls the Terabox list API for a single directory page
+func (r *TeraboxResolver) callListAPI(ctx context.Context, urlInfo *utils.URLInfo, dir string, page int, auth *internal.AuthContext) ([]FileInfo, error) {
+	apiURL := r.apiURL("/api/list")
+
+	params := url.Values{}
+	if urlInfo.Surl != "" {
+		params.Set("surl", urlInfo.Surl)
+	} else if urlInfo.ShareID != "" {
+		params.Set("shareid", urlInfo.ShareID)
+	}
+	if dir == "/" {
+		params.Set("root", "1")
+	}
+	params.Set("dir", dir)
+	params.Set("page", strconv.Itoa(page))
+	params.Set("num", strconv.Itoa(folderPageSize))
+	params.Set("order", "name")
+	params.Set("desc", "0")
+	params.Set("web", "1")
+	params.Set("app_id", "250528")
+	setShareKeyParam(params, auth)
+
+	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
+
+	headers := map[string]string{
+		"Referer":          "https://www.terabox.com/",
+		"Origin":           "https://www.terabox.com",
+		"X-Requested-With": "XMLHttpRequest",
+	}
+	r.useCookies(auth)
+
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call list API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var listResp FileMetasResponse
+	if err := json.Unmarshal(body, &listResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	if err := r.handleAPIError(listResp.TeraboxAPIResponse); err != nil {
+		return nil, err
+	}
+
+	return listResp.List, nil
+}
+
+// sanitizeShareName makes a remote file name safe to use as a local path element
+func sanitizeShareName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.NewReplacer("/", "_", "\\", "_", "\x00", "").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,189 @@
+package downloader
+
+import (
+	"fmt"
+	"testing"
+
+	"terafetch/internal"
+)
+
+func TestFolderWalker_Walk(t *testing.T) {
+	listings := map[string][]FileInfo{
+		"/": {
+			{Filename: "Season 1", Path: "/share/Season 1", IsDir: 1},
+			{Filename: "readme.txt", Size: 10, MD5: "abc", FsID: 1, Dlink: "https://d.terabox.com/readme"},
+		},
+		"/share/Season 1": {
+			{Filename: "ep01.mkv", Size: 100, FsID: 2},
+			{Filename: "extras", IsDir: 1},
+		},
+		"/share/Season 1/extras": {
+			{Filename: "../escape.txt", Size: 5, FsID: 3},
+		},
+	}
+
+	var linkCalls []int64
+	walker := &folderWalker{
+		list: func(dir string, page int) ([]FileInfo, error) {
+			if page > 1 {
+				return nil, nil
+			}
+			entries, ok := listings[dir]
+			if !ok {
+				return nil, fmt.Errorf("unexpected directory %s", dir)
+			}
+			return entries, nil
+		},
+		link: func(file FileInfo) (string, error) {
+			linkCalls = append(linkCalls, file.FsID)
+			return fmt.Sprintf("https://d.terabox.com/file/%d", file.FsID), nil
+		},
+		shareID: "1AbC123",
+	}
+
+	nodes, err := walker.walk("/", "", 0)
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+
+	root := &internal.FileTreeNode{IsDir: true, Children: nodes}
+	files := root.Files()
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+
+	expectedPaths := []string{"Season 1/ep01.mkv", "Season 1/extras/.._escape.txt", "readme.txt"}
+	for i, file := range files {
+		if file.Path != expectedPaths[i] {
+			t.Errorf("file %d: expected path %q, got %q", i, expectedPaths[i], file.Path)
+		}
+		if file.ShareID != "1AbC123" {
+			t.Errorf("file %d: expected share ID to be propagated, got %q", i, file.ShareID)
+		}
+		if file.DirectURL == "" {
+			t.Errorf("file %d: expected a resolved download link", i)
+		}
+	}
+
+	// The readme already carried a dlink, so only two link lookups are needed
+	if len(linkCalls) != 2 {
+		t.Errorf("expected 2 link lookups, got %d", len(linkCalls))
+	}
+
+	if root.TotalSize() != 115 {
+		t.Errorf("expected total size 115, got %d", root.TotalSize())
+	}
+}
+
+func TestFolderWalker_ListOnly(t *testing.T) {
+	walker := &folderWalker{
+		list: func(dir string, page int) ([]FileInfo, error) {
+			return []FileInfo{
+				{Filename: "a.bin", Size: 10, FsID: 1},
+				{Filename: "b.bin", Size: 20, FsID: 2, Dlink: "https://d.terabox.com/b"},
+			}, nil
+		},
+	}
+
+	// Without a link lookup the files are listed as they are
+	nodes, err := walker.walk("/", "", 0)
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(nodes))
+	}
+	if nodes[0].File.DirectURL != "" || nodes[0].File.Size != 10 {
+		t.Errorf("expected a.bin to be listed without a link, got %+v", nodes[0].File)
+	}
+	if nodes[1].File.DirectURL != "https://d.terabox.com/b" {
+		t.Errorf("expected b.bin to keep its listed dlink, got %q", nodes[1].File.DirectURL)
+	}
+}
+
+func TestFolderWalker_Pagination(t *testing.T) {
+	walker := &folderWalker{
+		list: func(dir string, page int) ([]FileInfo, error) {
+			count := folderPageSize
+			if page == 3 {
+				count = 7
+			}
+			entries := make([]FileInfo, count)
+			for i := range entries {
+				entries[i] = FileInfo{Filename: fmt.Sprintf("p%d-%d.bin", page, i), Dlink: "https://d.terabox.com/x"}
+			}
+			return entries, nil
+		},
+		link: func(file FileInfo) (string, error) {
+			t.Fatalf("unexpected link lookup for %s", file.Filename)
+			return "", nil
+		},
+	}
+
+	nodes, err := walker.walk("/", "", 0)
+	if err != nil {
+		t.Fatalf("walk failed: %v", err)
+	}
+
+	if expected := 2*folderPageSize + 7; len(nodes) != expected {
+		t.Errorf("expected %d entries across pages, got %d", expected, len(nodes))
+	}
+}
+
+func TestFolderWalker_Errors(t *testing.T) {
+	t.Run("list_error", func(t *testing.T) {
+		walker := &folderWalker{
+			list: func(dir string, page int) ([]FileInfo, error) {
+				return nil, internal.NewTeraboxError(14, "share password required", internal.ErrAuthRequired)
+			},
+		}
+		if _, err := walker.walk("/", "", 0); err == nil {
+			t.Error("expected list error to be propagated")
+		}
+	})
+
+	t.Run("link_error", func(t *testing.T) {
+		walker := &folderWalker{
+			list: func(dir string, page int) ([]FileInfo, error) {
+				return []FileInfo{{Filename: "a.bin", FsID: 1}}, nil
+			},
+			link: func(file FileInfo) (string, error) {
+				return "", fmt.Errorf("no download link found in response")
+			},
+		}
+		if _, err := walker.walk("/", "", 0); err == nil {
+			t.Error("expected link error to be propagated")
+		}
+	})
+
+	t.Run("depth_limit", func(t *testing.T) {
+		walker := &folderWalker{
+			list: func(dir string, page int) ([]FileInfo, error) {
+				return []FileInfo{{Filename: "loop", IsDir: 1, Path: dir + "/loop"}}, nil
+			},
+		}
+		if _, err := walker.walk("/", "", 0); err == nil {
+			t.Error("expected depth limit error for self-nesting share")
+		}
+	})
+}
+
+func TestSanitizeShareName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"episode.mkv", "episode.mkv"},
+		{"a/b.txt", "a_b.txt"},
+		{"a\\b.txt", "a_b.txt"},
+		{"..", "_"},
+		{".", "_"},
+		{"  ", "_"},
+	}
+
+	for _, tt := range tests {
+		if got := sanitizeShareName(tt.input); got != tt.expected {
+			t.Errorf("sanitizeShareName(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errPaused stops a segment copy at a buffer boundary when its download is paused
+var errPaused = errors.New("download paused")
+
+// DownloadHandle controls a download started with MultiThreadEngine.Start.
+// Pausing stops every worker at its next buffer boundary, closing its
+// connection and checkpointing its offset to the resume metadata; resuming
+// continues each segment from there. Cancelling the download's context works
+// while it is paused.
+type DownloadHandle struct {
+	mutex   sync.Mutex
+	paused  bool
+	resumed chan struct{} // Closed while the download is not paused
+
+	done chan struct{}
+	err  error
+}
+
+func newDownloadHandle() *DownloadHandle {
+	resumed := make(chan struct{})
+	close(resumed)
+	return &DownloadHandle{
+		resumed: resumed,
+		done:    make(chan struct{}),
+	}
+}
+
+// Pause stops the download's workers at their next buffer boundary. Pausing a
+// paused or finished download has no effect.
+func (h *DownloadHandle) Pause() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.setPaused(true)
+}
+
+// Resume lets a paused download continue
+func (h *DownloadHandle) Resume() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.setPaused(false)
+}
+
+// Toggle pauses a running download or resumes a paused one, and reports
+// whether the download is now paused
+func (h *DownloadHandle) Toggle() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.setPaused(!h.paused)
+	return h.paused
+}
+
+// setPaused pauses or resumes the download. The caller must hold the mutex.
+func (h *DownloadHandle) setPaused(paused bool) {
+	if paused == h.paused {
+		return
+	}
+	h.paused = paused
+	if paused {
+		h.resumed = make(chan struct{})
+	} else {
+		close(h.resumed)
+	}
+}
+
+// Paused reports whether the download is paused
+func (h *DownloadHandle) Paused() bool {
+	if h == nil {
+		return false
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.paused
+}
+
+// Done is closed when the download has finished, successfully or not
+func (h *DownloadHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Wait blocks until the download has finished and returns its error
+func (h *DownloadHandle) Wait() error {
+	<-h.done
+	return h.err
+}
+
+// wait blocks while the download is paused, or until ctx is cancelled
+func (h *DownloadHandle) wait(ctx context.Context) error {
+	if h == nil {
+		return nil
+	}
+
+	h.mutex.Lock()
+	resumed := h.resumed
+	h.mutex.Unlock()
+
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// finish records the outcome of the download and releases Wait
+func (h *DownloadHandle) finish(err error) {
+	h.err = err
+	close(h.done)
+}
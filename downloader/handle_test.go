@@ -0,0 +1,40 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestDownloadHandle_Toggle(t *testing.T) {
+	handle := newDownloadHandle()
+
+	// Every toggle flips the state, so concurrent toggles must report each
+	// state exactly half of the time
+	const toggles = 200
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	paused := 0
+	for i := 0; i < toggles; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if handle.Toggle() {
+				mutex.Lock()
+				paused++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if paused != toggles/2 {
+		t.Errorf("Expected %d toggles to pause, got %d", toggles/2, paused)
+	}
+	if handle.Paused() {
+		t.Fatal("Expected the download to run after an even number of toggles")
+	}
+	if err := handle.wait(context.Background()); err != nil {
+		t.Errorf("Expected a running download not to block, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"terafetch/internal"
+)
+
+// ErrUnsupportedResumeVersion means a resume file was written by a newer
+// version of TeraFetch. Such files are left alone rather than discarded.
+var ErrUnsupportedResumeVersion = errors.New("unsupported resume metadata version")
+
+// migrateResumeMetadata upgrades resume metadata loaded from outputPath's
+// resume file to the current schema
+func migrateResumeMetadata(resumeData *internal.ResumeMetadata, outputPath string) error {
+	if resumeData.Version > internal.ResumeSchemaVersion {
+		return fmt.Errorf("%w: %s has version %d, this build reads up to %d",
+			ErrUnsupportedResumeVersion, outputPath+ResumeMetadataExt, resumeData.Version, internal.ResumeSchemaVersion)
+	}
+
+	if resumeData.Version <= 1 {
+		migrateResumeMetadataV1(resumeData, outputPath)
+	}
+	return nil
+}
+
+// migrateResumeMetadataV1 fills in the source of a version 1 file from what it
+// does record: the share URL is rebuilt from the file's ShareID and the output
+// path is where the file was found. The auth mode and thread count stay unknown.
+func migrateResumeMetadataV1(resumeData *internal.ResumeMetadata, outputPath string) {
+	source := &internal.ResumeSource{OutputPath: outputPath}
+	if absPath, err := filepath.Abs(outputPath); err == nil {
+		source.OutputPath = absPath
+	}
+	if meta := resumeData.FileMetadata; meta != nil && meta.ShareID != "" {
+		source.ShareURL = shareURLFromID(meta.ShareID)
+	}
+
+	resumeData.Source = source
+	resumeData.Version = internal.ResumeSchemaVersion
+}
@@ -0,0 +1,119 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terafetch/internal"
+)
+
+// resumeFileV1 is a resume file as written before the schema was versioned
+const resumeFileV1 = `{
+  "file_metadata": {
+    "filename": "movie.mkv",
+    "size": 2048,
+    "direct_url": "https://d.terabox.com/file/1001",
+    "share_id": "1AbC123",
+    "timestamp": "2025-10-01T12:00:00Z"
+  },
+  "segments": [
+    {"index": 0, "start": 0, "end": 1023, "downloaded": 1024, "completed": true, "retries": 0},
+    {"index": 1, "start": 1024, "end": 2047, "downloaded": 512, "completed": false, "retries": 0}
+  ],
+  "created_at": "2025-10-01T12:00:00Z",
+  "last_update": "2025-10-01T12:05:00Z"
+}`
+
+func TestDownloadPlanner_MigrateResumeMetadata(t *testing.T) {
+	planner := NewDownloadPlanner()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_migrate_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	t.Run("v1", func(t *testing.T) {
+		outputPath := filepath.Join(tempDir, "movie.mkv")
+		if err := os.WriteFile(outputPath+ResumeMetadataExt, []byte(resumeFileV1), 0644); err != nil {
+			t.Fatalf("Failed to write resume file: %v", err)
+		}
+
+		resumeData, err := planner.LoadResumeMetadata(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to load v1 resume file: %v", err)
+		}
+		if resumeData.Version != internal.ResumeSchemaVersion {
+			t.Errorf("Expected version %d after migration, got %d", internal.ResumeSchemaVersion, resumeData.Version)
+		}
+		if resumeData.Segments[1].Downloaded != 512 || resumeData.FileMetadata.ShareID != "1AbC123" {
+			t.Errorf("Migration lost recorded progress: %+v", resumeData)
+		}
+
+		source := resumeData.Source
+		if source == nil {
+			t.Fatal("Expected a source to be filled in")
+		}
+		if source.ShareURL != "https://terabox.com/s/1AbC123" {
+			t.Errorf("Expected the share URL to be rebuilt from the share ID, got %q", source.ShareURL)
+		}
+		if source.OutputPath != outputPath {
+			t.Errorf("Expected output path %q, got %q", outputPath, source.OutputPath)
+		}
+		if source.AuthMode != "" || source.Threads != 0 {
+			t.Errorf("Expected auth mode and threads to stay unknown, got %+v", source)
+		}
+	})
+
+	t.Run("source round trip", func(t *testing.T) {
+		outputPath := filepath.Join(tempDir, "source.bin")
+		meta := &internal.FileMetadata{Filename: "source.bin", Size: 1024, ShareID: "1Src", Timestamp: time.Now()}
+		source := &internal.ResumeSource{
+			ShareURL:    "https://terabox.com/s/1Src",
+			AuthMode:    internal.AuthModeCookies,
+			CookiesPath: "/home/user/cookies.txt",
+			Threads:     12,
+			OutputPath:  outputPath,
+		}
+		segments := []internal.SegmentInfo{{Index: 0, Start: 0, End: 1023}}
+
+		if err := planner.SaveResumeMetadataWithSource(outputPath, meta, source, segments); err != nil {
+			t.Fatalf("Failed to save resume metadata: %v", err)
+		}
+		resumeData, err := planner.LoadResumeMetadata(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to load resume metadata: %v", err)
+		}
+		if resumeData.Version != internal.ResumeSchemaVersion {
+			t.Errorf("Expected version %d, got %d", internal.ResumeSchemaVersion, resumeData.Version)
+		}
+		if resumeData.Source == nil || *resumeData.Source != *source {
+			t.Errorf("Expected source %+v, got %+v", source, resumeData.Source)
+		}
+	})
+
+	t.Run("newer version", func(t *testing.T) {
+		outputPath := filepath.Join(tempDir, "future.bin")
+		data := `{"version": 99, "file_metadata": {"filename": "future.bin", "size": 1024}, "segments": []}`
+		if err := os.WriteFile(outputPath+ResumeMetadataExt, []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write resume file: %v", err)
+		}
+		if err := os.WriteFile(outputPath+".part", make([]byte, 1024), 0644); err != nil {
+			t.Fatalf("Failed to write part file: %v", err)
+		}
+
+		if _, err := planner.DetectResumableDownload(outputPath); !errors.Is(err, ErrUnsupportedResumeVersion) {
+			t.Errorf("Expected ErrUnsupportedResumeVersion, got %v", err)
+		}
+
+		// The files belong to a newer version and must survive
+		for _, path := range []string{outputPath + ResumeMetadataExt, outputPath + ".part"} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("Expected %s to be kept: %v", path, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"terafetch/internal"
+)
+
+// shareKeyCookie is the cookie Terabox uses to carry the unlocked share key
+const shareKeyCookie = "BDCLND"
+
+// ShareVerifyResponse represents the response from the share verify API
+type ShareVerifyResponse struct {
+	TeraboxAPIResponse
+	Randsk string `json:"randsk"`
+}
+
+// VerifySharePassword runs the share-verify handshake for a password-protected
+// share and returns an auth context carrying the resulting share key. The
+// password falls back to the URL's pwd parameter when empty. The given auth
+// context is not modified.
+func (r *TeraboxResolver) VerifySharePassword(ctx context.Context, shareURL, password string, auth *internal.AuthContext) (*internal.AuthContext, error) {
+	// Parse and validate the URL
+	urlInfo, err := r.urlValidator.ParseURL(shareURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	if password == "" {
+		password = urlInfo.Password
+	}
+	if password == "" {
+		return nil, internal.NewAuthRequiredError("share password required").
+			WithSuggestion("Provide the extraction code with --password or a pwd= URL parameter")
+	}
+
+	params := url.Values{}
+	if urlInfo.Surl != "" {
+		params.Set("surl", urlInfo.Surl)
+	} else if urlInfo.ShareID != "" {
+		params.Set("shareid", urlInfo.ShareID)
+	}
+	params.Set("channel", "dubox")
+	params.Set("web", "1")
+	params.Set("app_id", "250528")
+	params.Set("clienttype", "0")
+
+	fullURL := fmt.Sprintf("%s?%s", r.apiURL("/share/verify"), params.Encode())
+
+	form := url.Values{}
+	form.Set("pwd", password)
+	form.Set("vcode", "")
+	form.Set("vcode_str", "")
+
+	headers := map[string]string{
+		"Referer":          "https://www.terabox.com/",
+		"Origin":           "https://www.terabox.com",
+		"X-Requested-With": "XMLHttpRequest",
+	}
+	r.useCookies(auth)
+
+	resp, err := r.httpClient.PostFormWithContext(ctx, fullURL, form, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call share verify API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var verifyResp ShareVerifyResponse
+	if err := json.Unmarshal(body, &verifyResp); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	// The verify endpoint reports a wrong extraction code as -9
+	if verifyResp.Errno == -9 {
+		verifyResp.Errno = 15
+	}
+	if err := r.handleAPIError(verifyResp.TeraboxAPIResponse); err != nil {
+		return nil, err
+	}
+
+	if verifyResp.Randsk == "" {
+		return nil, internal.NewTeraboxError(0, "no share key found in verify response", internal.ErrInvalidResponse)
+	}
+
+	internal.LogInfo("Share password accepted for %s", urlInfo.GetIdentifier())
+	return withShareKey(auth, verifyResp.Randsk), nil
+}
+
+// ResolveProtectedLink unlocks a password-protected share and re-runs
+// resolution with the share key. The unlocked auth context is returned so
+// callers can reuse it for further requests against the same share.
+func (r *TeraboxResolver) ResolveProtectedLink(ctx context.Context, shareURL, password string, auth *internal.AuthContext) (*internal.FileMetadata, *internal.AuthContext, error) {
+	unlocked, err := r.VerifySharePassword(ctx, shareURL, password, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Logged-in sessions go through the private APIs, anonymous ones through sharedownload
+	if unlocked.BDUSS != "" && !unlocked.Bypass {
+		meta, err := r.ResolvePrivateLink(ctx, shareURL, unlocked)
+		return meta, unlocked, err
+	}
+
+	urlInfo, err := r.urlValidator.ParseURL(shareURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	meta, err := r.callShareDownloadAPI(ctx, urlInfo, unlocked)
+	return meta, unlocked, err
+}
+
+// IsSharePasswordError reports whether err means the share needs a (different) password
+func IsSharePasswordError(err error) bool {
+	var teraboxErr *internal.TeraboxError
+	if !errors.As(err, &teraboxErr) {
+		return false
+	}
+	return teraboxErr.Code == 14 || teraboxErr.Code == 15
+}
+
+// withShareKey returns a copy of auth that carries the unlocked share key
+func withShareKey(auth *internal.AuthContext, randsk string) *internal.AuthContext {
+	unlocked := &internal.AuthContext{
+		Cookies: make(map[string]*http.Cookie),
+	}
+	if auth != nil {
+		copied := *auth
+		unlocked = &copied
+		unlocked.Cookies = make(map[string]*http.Cookie, len(auth.Cookies)+1)
+		for name, cookie := range auth.Cookies {
+			unlocked.Cookies[name] = cookie
+		}
+		if unlocked.Bypass {
+			// A bypass context has no real cookies; the share key is real authentication
+			unlocked.Bypass = false
+			unlocked.BDUSS = ""
+			unlocked.STOKEN = ""
+		}
+	}
+
+	unlocked.ShareKey = randsk
+	unlocked.Cookies[shareKeyCookie] = &http.Cookie{
+		Name:     shareKeyCookie,
+		Value:    randsk,
+		Domain:   ".terabox.com",
+		Path:     "/",
+		HttpOnly: true,
+	}
+	return unlocked
+}
@@ -0,0 +1,75 @@
+package downloader
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"terafetch/internal"
+)
+
+func TestIsSharePasswordError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"password_required", internal.NewTeraboxError(14, "share password required", internal.ErrAuthRequired), true},
+		{"password_incorrect", internal.NewTeraboxError(15, "share password incorrect", internal.ErrAuthRequired), true},
+		{"wrapped", fmt.Errorf("resolve: %w", internal.NewTeraboxError(15, "share password incorrect", internal.ErrAuthRequired)), true},
+		{"other_api_error", internal.NewTeraboxError(2, "invalid parameters", internal.ErrInvalidResponse), false},
+		{"plain_error", fmt.Errorf("connection refused"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSharePasswordError(tt.err); got != tt.expected {
+				t.Errorf("IsSharePasswordError() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithShareKey(t *testing.T) {
+	t.Run("anonymous", func(t *testing.T) {
+		unlocked := withShareKey(nil, "randsk123")
+		if unlocked.ShareKey != "randsk123" {
+			t.Errorf("expected share key to be set, got %q", unlocked.ShareKey)
+		}
+		if cookie := unlocked.Cookies[shareKeyCookie]; len(unlocked.Cookies) != 1 || cookie.Value != "randsk123" {
+			t.Errorf("expected only the share key cookie, got %+v", unlocked.Cookies)
+		}
+	})
+
+	t.Run("does_not_modify_original", func(t *testing.T) {
+		auth := &internal.AuthContext{
+			BDUSS:   "bduss",
+			STOKEN:  "stoken",
+			Cookies: map[string]*http.Cookie{"BDUSS": {Name: "BDUSS", Value: "bduss"}},
+		}
+		unlocked := withShareKey(auth, "randsk123")
+
+		if _, ok := auth.Cookies[shareKeyCookie]; ok {
+			t.Error("expected original cookie map to be left untouched")
+		}
+		if auth.ShareKey != "" {
+			t.Error("expected original auth context to be left untouched")
+		}
+		if unlocked.BDUSS != "bduss" || len(unlocked.Cookies) != 2 {
+			t.Errorf("expected session cookies to be carried over, got %+v", unlocked.Cookies)
+		}
+	})
+
+	t.Run("bypass_context", func(t *testing.T) {
+		auth := NewCookieAuthManager().CreateBypassAuthContext()
+		unlocked := withShareKey(auth, "randsk123")
+
+		if unlocked.Bypass || unlocked.BDUSS != "" || unlocked.STOKEN != "" {
+			t.Error("expected placeholder bypass credentials to be dropped")
+		}
+		if cookie := unlocked.Cookies[shareKeyCookie]; len(unlocked.Cookies) != 1 || cookie.Value != "randsk123" {
+			t.Errorf("expected only the share key cookie, got %+v", unlocked.Cookies)
+		}
+	})
+}
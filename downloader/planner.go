@@ -2,7 +2,9 @@ package downloader
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -23,6 +25,7 @@ const (
 type DownloadPlanner struct {
 	minSegmentSize int64
 	maxThreads     int
+	output         io.Writer // Human-readable warnings
 }
 
 // NewDownloadPlanner creates a new instance of DownloadPlanner
@@ -30,6 +33,7 @@ func NewDownloadPlanner() *DownloadPlanner {
 	return &DownloadPlanner{
 		minSegmentSize: MinSegmentSize,
 		maxThreads:     MaxThreads,
+		output:         os.Stdout,
 	}
 }
 
@@ -166,32 +170,26 @@ func (p *DownloadPlanner) planResumeDownload(resumeData *internal.ResumeMetadata
 
 // SaveResumeMetadata saves download progress metadata to disk
 func (p *DownloadPlanner) SaveResumeMetadata(outputPath string, meta *internal.FileMetadata, segments []internal.SegmentInfo) error {
+	return p.SaveResumeMetadataWithSource(outputPath, meta, nil, segments)
+}
+
+// SaveResumeMetadataWithSource saves download progress metadata along with
+// where the download came from and how it was started
+func (p *DownloadPlanner) SaveResumeMetadataWithSource(outputPath string, meta *internal.FileMetadata, source *internal.ResumeSource, segments []internal.SegmentInfo) error {
 	resumeData := &internal.ResumeMetadata{
 		FileMetadata: meta,
+		Source:       source,
 		Segments:     segments,
 		CreatedAt:    time.Now(),
 		LastUpdate:   time.Now(),
 	}
 	
-	metadataPath := outputPath + ResumeMetadataExt
-	
 	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(metadataPath), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(outputPath+ResumeMetadataExt), 0755); err != nil {
 		return fmt.Errorf("failed to create metadata directory: %w", err)
 	}
 	
-	// Marshal to JSON
-	data, err := json.MarshalIndent(resumeData, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal resume metadata: %w", err)
-	}
-	
-	// Write to file
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write resume metadata: %w", err)
-	}
-	
-	return nil
+	return p.saveResumeMetadataStruct(outputPath, resumeData)
 }
 
 // LoadResumeMetadata loads download progress metadata from disk
@@ -214,6 +212,12 @@ func (p *DownloadPlanner) LoadResumeMetadata(outputPath string) (*internal.Resum
 	if err := json.Unmarshal(data, &resumeData); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal resume metadata: %w", err)
 	}
+
+	// Files from older versions are upgraded in memory and rewritten in the
+	// current format at the next checkpoint
+	if err := migrateResumeMetadata(&resumeData, outputPath); err != nil {
+		return nil, err
+	}
 	
 	return &resumeData, nil
 }
@@ -299,6 +303,9 @@ func (p *DownloadPlanner) DetectResumableDownload(outputPath string) (*internal.
 	
 	// Load and validate resume metadata
 	resumeData, err := p.LoadResumeMetadata(outputPath)
+	if errors.Is(err, ErrUnsupportedResumeVersion) {
+		return nil, err // Written by a newer version, so keep it for that version
+	}
 	if err != nil {
 		// Invalid metadata - cleanup and start fresh
 		os.Remove(metadataPath)
@@ -338,7 +345,7 @@ func (p *DownloadPlanner) ValidateResumeCompatibility(resumeData *internal.Resum
 	// Check filename compatibility (allow some flexibility)
 	if resumeData.FileMetadata.Filename != currentMeta.Filename {
 		// Log warning but don't fail - filename might have been updated
-		fmt.Printf("Warning: filename changed from %s to %s\n", 
+		fmt.Fprintf(p.output, "Warning: filename changed from %s to %s\n", 
 			resumeData.FileMetadata.Filename, currentMeta.Filename)
 	}
 	
@@ -381,7 +388,7 @@ func (p *DownloadPlanner) RecoverFromNetworkInterruption(outputPath string, segm
 		backoffDelay = 30 * time.Second
 	}
 	
-	fmt.Printf("Network interruption on segment %d (retry %d/%d), backing off for %v\n", 
+	fmt.Fprintf(p.output, "Network interruption on segment %d (retry %d/%d), backing off for %v\n", 
 		segmentIndex, segment.Retries, maxRetries, backoffDelay)
 	
 	time.Sleep(backoffDelay)
@@ -411,6 +418,9 @@ func (p *DownloadPlanner) CalculateResumeProgress(segments []internal.SegmentInf
 		totalBytes += segmentSize
 		if segment.Completed {
 			completedBytes += segmentSize
+		} else if segment.Downloaded > 0 && segment.Downloaded <= segmentSize {
+			// Partially downloaded segments count up to their last checkpoint
+			completedBytes += segment.Downloaded
 		}
 	}
 	
@@ -424,6 +434,7 @@ func (p *DownloadPlanner) CalculateResumeProgress(segments []internal.SegmentInf
 // saveResumeMetadataStruct saves a ResumeMetadata struct to disk
 func (p *DownloadPlanner) saveResumeMetadataStruct(outputPath string, resumeData *internal.ResumeMetadata) error {
 	metadataPath := outputPath + ResumeMetadataExt
+	resumeData.Version = internal.ResumeSchemaVersion
 	
 	// Marshal to JSON
 	data, err := json.MarshalIndent(resumeData, "", "  ")
@@ -431,8 +442,14 @@ func (p *DownloadPlanner) saveResumeMetadataStruct(outputPath string, resumeData
 		return fmt.Errorf("failed to marshal resume metadata: %w", err)
 	}
 	
-	// Write to file
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	// Write to a temporary file and rename it, so an interrupted checkpoint never
+	// leaves a truncated metadata file behind
+	tmpPath := metadataPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write resume metadata: %w", err)
+	}
+	if err := os.Rename(tmpPath, metadataPath); err != nil {
+		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write resume metadata: %w", err)
 	}
 	
@@ -0,0 +1,165 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"terafetch/internal"
+	"terafetch/utils"
+)
+
+// maxLinkRefreshes bounds how often one download re-resolves its link, so a
+// share that really is forbidden fails instead of refreshing forever
+const maxLinkRefreshes = 5
+
+// LinkRefresher resolves a fresh direct link for a file whose link has expired
+type LinkRefresher func(ctx context.Context, meta *internal.FileMetadata) (string, error)
+
+// LinkRefresher returns a LinkRefresher that re-resolves files of shareURL
+// through r. auth is used the same way as for the first resolution; a
+// non-empty password unlocks protected shares before each refresh.
+func (r *TeraboxResolver) LinkRefresher(shareURL string, auth *internal.AuthContext, password string) LinkRefresher {
+	return func(ctx context.Context, meta *internal.FileMetadata) (string, error) {
+		return r.RefreshLink(ctx, shareURL, meta, auth, password)
+	}
+}
+
+// RefreshLink resolves a fresh direct link for meta from the share at
+// shareURL, falling back to a URL rebuilt from its stored ShareID when
+// shareURL is empty. Files of folder shares are looked up again by their
+// Path. The share must still hold a file of the same size, so a replaced file
+// is never spliced into a partial download.
+func (r *TeraboxResolver) RefreshLink(ctx context.Context, shareURL string, meta *internal.FileMetadata, auth *internal.AuthContext, password string) (string, error) {
+	if shareURL == "" {
+		if meta.ShareID == "" {
+			return "", fmt.Errorf("no share ID recorded for %s", meta.Filename)
+		}
+		shareURL = shareURLFromID(meta.ShareID)
+	}
+
+	if password != "" {
+		unlocked, err := r.VerifySharePassword(ctx, shareURL, password, auth)
+		if err != nil {
+			return "", fmt.Errorf("failed to unlock share: %w", err)
+		}
+		auth = unlocked
+	}
+
+	var fresh *internal.FileMetadata
+	var err error
+	switch {
+	case meta.Path != "":
+		fresh, err = r.resolveFolderFile(ctx, shareURL, meta.Path, auth)
+	case auth != nil && auth.BDUSS != "" && !auth.Bypass:
+		fresh, err = r.ResolvePrivateLink(ctx, shareURL, auth)
+	default:
+		var urlInfo *utils.URLInfo
+		urlInfo, err = r.urlValidator.ParseURL(shareURL)
+		if err == nil {
+			fresh, err = r.callShareDownloadAPI(ctx, urlInfo, auth)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if fresh.Size != meta.Size {
+		return "", internal.NewResumeIncompatibleError(fmt.Sprintf("file size changed: expected %d bytes, share now has %d", meta.Size, fresh.Size))
+	}
+
+	internal.LogInfo("Refreshed download link for %s", meta.Filename)
+	return fresh.DirectURL, nil
+}
+
+// resolveFolderFile looks up the file at relPath in a folder share, listing
+// only the directories leading to it and resolving only its link
+func (r *TeraboxResolver) resolveFolderFile(ctx context.Context, shareURL, relPath string, auth *internal.AuthContext) (*internal.FileMetadata, error) {
+	urlInfo, err := r.urlValidator.ParseURL(shareURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	walker := r.newFolderWalker(ctx, urlInfo, auth)
+	entry, found, err := walker.find(relPath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, internal.NewFileNotFoundError(shareURL).WithContext("path", relPath)
+	}
+	return walker.file(entry, relPath)
+}
+
+// shareURLFromID rebuilds the share URL for a ShareID recorded in FileMetadata.
+// It only serves metadata that never recorded the URL it was resolved from.
+func shareURLFromID(shareID string) string {
+	validator := utils.NewURLValidator()
+	if _, err := strconv.ParseUint(shareID, 10, 64); err == nil {
+		return validator.GetShareURL(&utils.URLInfo{ShareID: shareID})
+	}
+	return validator.GetShareURL(&utils.URLInfo{Surl: shareID})
+}
+
+// isExpiredLink reports whether a segment request failed because the direct
+// link went stale. Terabox answers expired dlinks with 403 Forbidden, which the
+// HTTP client only gives up on after rotating its user agent.
+func isExpiredLink(err error) bool {
+	var teraboxErr *internal.TeraboxError
+	return errors.As(err, &teraboxErr) && teraboxErr.Code == http.StatusForbidden
+}
+
+// linkSource hands out the direct link of a download to its workers and
+// replaces it once for all of them when it expires, so a burst of 403s from
+// every connection triggers a single refresh
+type linkSource struct {
+	refresh LinkRefresher
+	meta    *internal.FileMetadata
+
+	mutex      sync.Mutex
+	url        string
+	generation int // Incremented every time the link is replaced
+	refreshes  int
+}
+
+func newLinkSource(meta *internal.FileMetadata, refresh LinkRefresher) *linkSource {
+	return &linkSource{
+		refresh: refresh,
+		meta:    meta,
+		url:     meta.DirectURL,
+	}
+}
+
+// current returns the link and its generation
+func (l *linkSource) current() (string, int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.url, l.generation
+}
+
+// renew replaces the link of the given generation with a freshly resolved one
+// and returns it. Workers that saw the same link expire wait for the first
+// one's refresh and get its result instead of resolving again.
+func (l *linkSource) renew(ctx context.Context, generation int) (string, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if generation != l.generation {
+		return l.url, nil
+	}
+	if l.refreshes >= maxLinkRefreshes {
+		return "", fmt.Errorf("download link expired %d times, giving up", l.refreshes+1)
+	}
+	l.refreshes++
+
+	url, err := l.refresh(ctx, l.meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh expired download link: %w", err)
+	}
+	l.url = url
+	l.generation++
+	return url, nil
+}
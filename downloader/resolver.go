@@ -1,11 +1,13 @@
 package downloader
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -15,10 +17,15 @@ import (
 	"terafetch/utils"
 )
 
+// DefaultAPIBaseURL is the scheme and host every Terabox API call goes to
+const DefaultAPIBaseURL = "https://www.terabox.com"
+
 // TeraboxResolver implements the LinkResolver interface
 type TeraboxResolver struct {
 	httpClient   *utils.HTTPClient
 	urlValidator *utils.URLValidator
+	baseURL      string
+	output       io.Writer // Human-readable progress of resolution attempts
 }
 
 // TeraboxAPIResponse represents the common structure of Terabox API responses
@@ -54,6 +61,7 @@ type FileInfo struct {
 	Category   int    `json:"category"`
 	CreateTime int64  `json:"server_ctime"`
 	ModTime    int64  `json:"server_mtime"`
+	Dlink      string `json:"dlink,omitempty"`
 }
 
 // DownloadResponse represents the response from download API
@@ -67,6 +75,8 @@ func NewTeraboxResolver() *TeraboxResolver {
 	return &TeraboxResolver{
 		httpClient:   utils.NewHTTPClient(),
 		urlValidator: utils.NewURLValidator(),
+		baseURL:      DefaultAPIBaseURL,
+		output:       os.Stdout,
 	}
 }
 
@@ -75,11 +85,37 @@ func NewTeraboxResolverWithClient(httpClient *utils.HTTPClient) *TeraboxResolver
 	return &TeraboxResolver{
 		httpClient:   httpClient,
 		urlValidator: utils.NewURLValidator(),
+		baseURL:      DefaultAPIBaseURL,
+		output:       os.Stdout,
+	}
+}
+
+// SetAllowedDomains sets the hosts share URLs are accepted from
+func (r *TeraboxResolver) SetAllowedDomains(domains []string) {
+	r.urlValidator = utils.NewURLValidatorWithDomains(domains)
+}
+
+// SetBaseURL points the resolver's API calls at another server, such as a
+// local mock of the Terabox API. Share URLs are still parsed as Terabox URLs.
+func (r *TeraboxResolver) SetBaseURL(baseURL string) {
+	r.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+// SetOutput redirects the messages printed while trying resolution approaches
+func (r *TeraboxResolver) SetOutput(w io.Writer) {
+	r.output = w
+}
+
+// apiURL returns the absolute URL of an API path on the configured base URL
+func (r *TeraboxResolver) apiURL(path string) string {
+	if r.baseURL == "" {
+		return DefaultAPIBaseURL + path
 	}
+	return r.baseURL + path
 }
 
 // ResolvePublicLink resolves a public Terabox share URL to download metadata
-func (r *TeraboxResolver) ResolvePublicLink(url string) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) ResolvePublicLink(ctx context.Context, url string) (*internal.FileMetadata, error) {
 	// Parse and validate the URL
 	urlInfo, err := r.urlValidator.ParseURL(url)
 	if err != nil {
@@ -92,11 +128,11 @@ func (r *TeraboxResolver) ResolvePublicLink(url string) (*internal.FileMetadata,
 	}
 
 	// Call the sharedownload API
-	return r.callShareDownloadAPI(urlInfo)
+	return r.callShareDownloadAPI(ctx, urlInfo, nil)
 }
 
 // ResolvePrivateLink resolves a private Terabox URL using authentication
-func (r *TeraboxResolver) ResolvePrivateLink(url string, auth *internal.AuthContext) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) ResolvePrivateLink(ctx context.Context, url string, auth *internal.AuthContext) (*internal.FileMetadata, error) {
 	if auth == nil {
 		return nil, internal.NewTeraboxError(0, "authentication context is required for private links", internal.ErrAuthRequired)
 	}
@@ -108,13 +144,13 @@ func (r *TeraboxResolver) ResolvePrivateLink(url string, auth *internal.AuthCont
 	}
 
 	// First, get file metadata using filemetas API
-	fileInfo, err := r.callFileMetasAPI(urlInfo, auth)
+	fileInfo, err := r.callFileMetasAPI(ctx, urlInfo, auth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file metadata: %w", err)
 	}
 
 	// Then get the download link using download API
-	dlink, err := r.callDownloadAPI(fileInfo, auth)
+	dlink, err := r.callDownloadAPI(ctx, fileInfo, auth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get download link: %w", err)
 	}
@@ -131,7 +167,7 @@ func (r *TeraboxResolver) ResolvePrivateLink(url string, auth *internal.AuthCont
 }
 
 // ResolveWithBypass attempts to resolve URLs using bypass techniques
-func (r *TeraboxResolver) ResolveWithBypass(url string) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) ResolveWithBypass(ctx context.Context, url string) (*internal.FileMetadata, error) {
 	// Parse and validate the URL
 	urlInfo, err := r.urlValidator.ParseURL(url)
 	if err != nil {
@@ -141,7 +177,7 @@ func (r *TeraboxResolver) ResolveWithBypass(url string) (*internal.FileMetadata,
 	// Try multiple bypass approaches
 	approaches := []struct {
 		name string
-		fn   func(*utils.URLInfo) (*internal.FileMetadata, error)
+		fn   func(context.Context, *utils.URLInfo) (*internal.FileMetadata, error)
 	}{
 		{"Direct Share API", r.tryDirectShareAPI},
 		{"Alternative API", r.tryAlternativeAPI},
@@ -150,20 +186,24 @@ func (r *TeraboxResolver) ResolveWithBypass(url string) (*internal.FileMetadata,
 
 	var errors []string
 	for i, approach := range approaches {
-		fmt.Printf("🔄 Trying %s...\n", approach.name)
-		metadata, err := approach.fn(urlInfo)
+		fmt.Fprintf(r.output, "🔄 Trying %s...\n", approach.name)
+		metadata, err := approach.fn(ctx, urlInfo)
 		if err == nil {
-			fmt.Printf("✅ %s succeeded!\n", approach.name)
+			fmt.Fprintf(r.output, "✅ %s succeeded!\n", approach.name)
 			return metadata, nil
 		}
 		
 		errorMsg := fmt.Sprintf("%s failed: %v", approach.name, err)
 		errors = append(errors, errorMsg)
-		fmt.Printf("❌ %s\n", errorMsg)
+		fmt.Fprintf(r.output, "❌ %s\n", errorMsg)
 		
 		// Add delay between attempts to avoid rate limiting
 		if i < len(approaches)-1 {
-			time.Sleep(time.Duration(i+1) * time.Second)
+			select {
+			case <-time.After(time.Duration(i+1) * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 	}
 
@@ -171,12 +211,15 @@ func (r *TeraboxResolver) ResolveWithBypass(url string) (*internal.FileMetadata,
 }
 
 // tryDirectShareAPI attempts to use the share API with different parameters
-func (r *TeraboxResolver) tryDirectShareAPI(urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) tryDirectShareAPI(ctx context.Context, urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
 	// Try different API endpoints and parameters
-	endpoints := []string{
-		"https://www.terabox.com/api/sharedownload",
-		"https://terabox.com/api/sharedownload", 
-		"https://www.terabox.app/api/sharedownload",
+	endpoints := []string{r.apiURL("/api/sharedownload")}
+	// The mirror domains only make sense against the real service
+	if r.baseURL == "" || r.baseURL == DefaultAPIBaseURL {
+		endpoints = append(endpoints,
+			"https://terabox.com/api/sharedownload",
+			"https://www.terabox.app/api/sharedownload",
+		)
 	}
 
 	for _, endpoint := range endpoints {
@@ -218,7 +261,7 @@ func (r *TeraboxResolver) tryDirectShareAPI(urlInfo *utils.URLInfo) (*internal.F
 				"X-Requested-With": "XMLHttpRequest",
 			}
 
-			resp, err := r.httpClient.GetWithHeaders(fullURL, headers)
+			resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
 			if err != nil {
 				continue
 			}
@@ -252,9 +295,9 @@ func (r *TeraboxResolver) tryDirectShareAPI(urlInfo *utils.URLInfo) (*internal.F
 }
 
 // tryAlternativeAPI attempts to use alternative API endpoints
-func (r *TeraboxResolver) tryAlternativeAPI(urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) tryAlternativeAPI(ctx context.Context, urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
 	// Try the list API which sometimes works without authentication
-	apiURL := "https://www.terabox.com/api/list"
+	apiURL := r.apiURL("/api/list")
 	
 	params := url.Values{}
 	if urlInfo.Surl != "" {
@@ -274,7 +317,7 @@ func (r *TeraboxResolver) tryAlternativeAPI(urlInfo *utils.URLInfo) (*internal.F
 		"Origin":  "https://www.terabox.com",
 	}
 
-	resp, err := r.httpClient.GetWithHeaders(fullURL, headers)
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call list API: %w", err)
 	}
@@ -294,11 +337,21 @@ func (r *TeraboxResolver) tryAlternativeAPI(urlInfo *utils.URLInfo) (*internal.F
 		return nil, fmt.Errorf("list API returned no files")
 	}
 
-	// Use the first file from the list
+	// Use the first file from the list; folder shares need ResolveFolder
 	fileInfo := listResp.List[0]
+	for _, entry := range listResp.List {
+		if entry.IsDir == 0 {
+			fileInfo = entry
+			break
+		}
+	}
+	if len(listResp.List) > 1 {
+		internal.LogWarn("Share contains %d entries, only %s will be downloaded (use --recursive for folder shares)",
+			len(listResp.List), fileInfo.Filename)
+	}
 	
 	// Try to get direct download link
-	dlink, err := r.tryGetDirectLink(fileInfo.FsID, urlInfo.Surl)
+	dlink, err := r.tryGetDirectLink(ctx, fileInfo.FsID, urlInfo.Surl)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get direct link: %w", err)
 	}
@@ -314,14 +367,14 @@ func (r *TeraboxResolver) tryAlternativeAPI(urlInfo *utils.URLInfo) (*internal.F
 }
 
 // tryWebScraping attempts to extract download links from the web page
-func (r *TeraboxResolver) tryWebScraping(urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) tryWebScraping(ctx context.Context, urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
 	// Get the share page URL
 	shareURL := fmt.Sprintf("https://terabox.com/s/%s", urlInfo.GetIdentifier())
 	
 	// Try different approaches to get the page content
 	approaches := []struct {
 		name string
-		fn   func(string) (*internal.FileMetadata, error)
+		fn   func(context.Context, string) (*internal.FileMetadata, error)
 	}{
 		{"Share Page", r.scrapeSharePage},
 		{"Redirect Follow", r.scrapeWithRedirect},
@@ -330,23 +383,23 @@ func (r *TeraboxResolver) tryWebScraping(urlInfo *utils.URLInfo) (*internal.File
 	
 	var errors []string
 	for _, approach := range approaches {
-		fmt.Printf("  🔄 Trying %s scraping...\n", approach.name)
-		metadata, err := approach.fn(shareURL)
+		fmt.Fprintf(r.output, "  🔄 Trying %s scraping...\n", approach.name)
+		metadata, err := approach.fn(ctx, shareURL)
 		if err == nil {
-			fmt.Printf("  ✅ %s scraping succeeded!\n", approach.name)
+			fmt.Fprintf(r.output, "  ✅ %s scraping succeeded!\n", approach.name)
 			return metadata, nil
 		}
 		
 		errorMsg := fmt.Sprintf("%s: %v", approach.name, err)
 		errors = append(errors, errorMsg)
-		fmt.Printf("  ❌ %s\n", errorMsg)
+		fmt.Fprintf(r.output, "  ❌ %s\n", errorMsg)
 	}
 	
 	return nil, fmt.Errorf("web scraping failed: %s", strings.Join(errors, "; "))
 }
 
 // scrapeSharePage attempts to scrape the main share page
-func (r *TeraboxResolver) scrapeSharePage(shareURL string) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) scrapeSharePage(ctx context.Context, shareURL string) (*internal.FileMetadata, error) {
 	headers := map[string]string{
 		"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
 		"Accept":     "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
@@ -355,7 +408,7 @@ func (r *TeraboxResolver) scrapeSharePage(shareURL string) (*internal.FileMetada
 		"Pragma": "no-cache",
 	}
 	
-	resp, err := r.httpClient.GetWithHeaders(shareURL, headers)
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, shareURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch share page: %w", err)
 	}
@@ -424,7 +477,7 @@ func (r *TeraboxResolver) scrapeSharePage(shareURL string) (*internal.FileMetada
 }
 
 // scrapeWithRedirect follows redirects and tries to scrape the final page
-func (r *TeraboxResolver) scrapeWithRedirect(shareURL string) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) scrapeWithRedirect(ctx context.Context, shareURL string) (*internal.FileMetadata, error) {
 	// Try following the redirect chain manually
 	redirectURL := shareURL
 	maxRedirects := 5
@@ -434,7 +487,7 @@ func (r *TeraboxResolver) scrapeWithRedirect(shareURL string) (*internal.FileMet
 			"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
 		}
 		
-		resp, err := r.httpClient.GetWithHeaders(redirectURL, headers)
+		resp, err := r.httpClient.GetWithHeadersContext(ctx, redirectURL, headers)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch redirect page: %w", err)
 		}
@@ -471,7 +524,7 @@ func (r *TeraboxResolver) scrapeWithRedirect(shareURL string) (*internal.FileMet
 }
 
 // scrapeAlternativeDomains tries scraping from alternative domains
-func (r *TeraboxResolver) scrapeAlternativeDomains(shareURL string) (*internal.FileMetadata, error) {
+func (r *TeraboxResolver) scrapeAlternativeDomains(ctx context.Context, shareURL string) (*internal.FileMetadata, error) {
 	// Extract share ID from URL
 	shareID := ""
 	if parts := strings.Split(shareURL, "/s/"); len(parts) > 1 {
@@ -497,7 +550,7 @@ func (r *TeraboxResolver) scrapeAlternativeDomains(shareURL string) (*internal.F
 			"Referer":    fmt.Sprintf("https://%s/", domain),
 		}
 		
-		resp, err := r.httpClient.GetWithHeaders(altURL, headers)
+		resp, err := r.httpClient.GetWithHeadersContext(ctx, altURL, headers)
 		if err != nil {
 			continue
 		}
@@ -654,8 +707,8 @@ func (r *TeraboxResolver) extractFromJavaScript(content string) *internal.FileMe
 }
 
 // tryGetDirectLink attempts to get a direct download link using file ID
-func (r *TeraboxResolver) tryGetDirectLink(fsID int64, surl string) (string, error) {
-	apiURL := "https://www.terabox.com/api/download"
+func (r *TeraboxResolver) tryGetDirectLink(ctx context.Context, fsID int64, surl string) (string, error) {
+	apiURL := r.apiURL("/api/download")
 	
 	params := url.Values{}
 	params.Set("fidlist", fmt.Sprintf("[%d]", fsID))
@@ -670,7 +723,7 @@ func (r *TeraboxResolver) tryGetDirectLink(fsID int64, surl string) (string, err
 		"Origin":  "https://www.terabox.com",
 	}
 
-	resp, err := r.httpClient.GetWithHeaders(fullURL, headers)
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
 	if err != nil {
 		return "", fmt.Errorf("failed to call download API: %w", err)
 	}
@@ -698,10 +751,11 @@ func (r *TeraboxResolver) tryGetDirectLink(fsID int64, surl string) (string, err
 	return "", fmt.Errorf("no download link found in response")
 }
 
-// callShareDownloadAPI calls the Terabox sharedownload API for public links
-func (r *TeraboxResolver) callShareDownloadAPI(urlInfo *utils.URLInfo) (*internal.FileMetadata, error) {
+// callShareDownloadAPI calls the Terabox sharedownload API for public links.
+// auth is optional and only carries cookies and the share key of unlocked shares.
+func (r *TeraboxResolver) callShareDownloadAPI(ctx context.Context, urlInfo *utils.URLInfo, auth *internal.AuthContext) (*internal.FileMetadata, error) {
 	// Construct the API URL
-	apiURL := "https://www.terabox.com/api/sharedownload"
+	apiURL := r.apiURL("/api/sharedownload")
 	
 	// Prepare query parameters
 	params := url.Values{}
@@ -714,6 +768,7 @@ func (r *TeraboxResolver) callShareDownloadAPI(urlInfo *utils.URLInfo) (*interna
 	params.Set("web", "1")
 	params.Set("app_id", "250528")
 	params.Set("clienttype", "0")
+	setShareKeyParam(params, auth)
 
 	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
 
@@ -723,9 +778,10 @@ func (r *TeraboxResolver) callShareDownloadAPI(urlInfo *utils.URLInfo) (*interna
 		"Origin":     "https://www.terabox.com",
 		"X-Requested-With": "XMLHttpRequest",
 	}
+	r.useCookies(auth)
 
 	// Make the API request
-	resp, err := r.httpClient.GetWithHeaders(fullURL, headers)
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call sharedownload API: %w", err)
 	}
@@ -765,9 +821,9 @@ func (r *TeraboxResolver) callShareDownloadAPI(urlInfo *utils.URLInfo) (*interna
 }
 
 // callFileMetasAPI calls the Terabox filemetas API for private links
-func (r *TeraboxResolver) callFileMetasAPI(urlInfo *utils.URLInfo, auth *internal.AuthContext) (*FileInfo, error) {
+func (r *TeraboxResolver) callFileMetasAPI(ctx context.Context, urlInfo *utils.URLInfo, auth *internal.AuthContext) (*FileInfo, error) {
 	// Construct the API URL
-	apiURL := "https://www.terabox.com/api/filemetas"
+	apiURL := r.apiURL("/api/filemetas")
 	
 	// Prepare query parameters
 	params := url.Values{}
@@ -781,38 +837,19 @@ func (r *TeraboxResolver) callFileMetasAPI(urlInfo *utils.URLInfo, auth *interna
 	params.Set("app_id", "250528")
 	params.Set("clienttype", "0")
 	params.Set("dir", "1") // Get directory listing
+	setShareKeyParam(params, auth)
 
 	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
 
-	// Prepare headers with authentication
+	// Prepare headers, the authentication cookies come from the client's jar
 	headers := map[string]string{
 		"Referer":    "https://www.terabox.com/",
 		"Origin":     "https://www.terabox.com",
 		"X-Requested-With": "XMLHttpRequest",
 	}
+	r.useCookies(auth)
 
-	// Add authentication cookies to the request
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	// Add cookies
-	for _, cookie := range auth.Cookies {
-		req.AddCookie(cookie)
-	}
-
-	// Make the request using the HTTP client's underlying client
-	// Note: We need to use the underlying client directly for cookie support
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call filemetas API: %w", err)
 	}
@@ -838,6 +875,10 @@ func (r *TeraboxResolver) callFileMetasAPI(urlInfo *utils.URLInfo, auth *interna
 	// Find the first file (not directory) in the response
 	for _, file := range apiResp.List {
 		if file.IsDir == 0 { // 0 means it's a file, not a directory
+			if len(apiResp.List) > 1 {
+				internal.LogWarn("Share contains %d entries, only %s will be downloaded (use --recursive for folder shares)",
+					len(apiResp.List), file.Filename)
+			}
 			return &file, nil
 		}
 	}
@@ -846,9 +887,9 @@ func (r *TeraboxResolver) callFileMetasAPI(urlInfo *utils.URLInfo, auth *interna
 }
 
 // callDownloadAPI calls the Terabox download API to get the direct download link
-func (r *TeraboxResolver) callDownloadAPI(fileInfo *FileInfo, auth *internal.AuthContext) (string, error) {
+func (r *TeraboxResolver) callDownloadAPI(ctx context.Context, fileInfo *FileInfo, auth *internal.AuthContext) (string, error) {
 	// Construct the API URL
-	apiURL := "https://www.terabox.com/api/download"
+	apiURL := r.apiURL("/api/download")
 	
 	// Prepare query parameters
 	params := url.Values{}
@@ -857,37 +898,19 @@ func (r *TeraboxResolver) callDownloadAPI(fileInfo *FileInfo, auth *internal.Aut
 	params.Set("web", "1")
 	params.Set("app_id", "250528")
 	params.Set("clienttype", "0")
+	setShareKeyParam(params, auth)
 
 	fullURL := fmt.Sprintf("%s?%s", apiURL, params.Encode())
 
-	// Prepare headers with authentication
+	// Prepare headers, the authentication cookies come from the client's jar
 	headers := map[string]string{
 		"Referer":    "https://www.terabox.com/",
 		"Origin":     "https://www.terabox.com",
 		"X-Requested-With": "XMLHttpRequest",
 	}
+	r.useCookies(auth)
 
-	// Add authentication cookies to the request
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	// Add cookies
-	for _, cookie := range auth.Cookies {
-		req.AddCookie(cookie)
-	}
-
-	// Make the request using a basic HTTP client for cookie support
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
+	resp, err := r.httpClient.GetWithHeadersContext(ctx, fullURL, headers)
 	if err != nil {
 		return "", fmt.Errorf("failed to call download API: %w", err)
 	}
@@ -918,6 +941,33 @@ func (r *TeraboxResolver) callDownloadAPI(fileInfo *FileInfo, auth *internal.Aut
 	return apiResp.Dlink, nil
 }
 
+// useCookies adds the session cookies of auth to the HTTP client's jar, so that
+// API requests and the downloads sharing the client carry them
+func (r *TeraboxResolver) useCookies(auth *internal.AuthContext) {
+	if auth == nil || auth.Bypass || len(auth.Cookies) == 0 {
+		return
+	}
+
+	apiURL, err := url.Parse(r.apiURL("/"))
+	if err != nil {
+		internal.LogWarn("Not sending cookies, invalid API URL: %v", err)
+		return
+	}
+
+	cookies := make([]*http.Cookie, 0, len(auth.Cookies))
+	for _, cookie := range auth.Cookies {
+		cookies = append(cookies, cookie)
+	}
+	r.httpClient.AddCookies(apiURL, cookies)
+}
+
+// setShareKeyParam adds the unlocked share key to API parameters when present
+func setShareKeyParam(params url.Values, auth *internal.AuthContext) {
+	if auth != nil && auth.ShareKey != "" {
+		params.Set("sekey", auth.ShareKey)
+	}
+}
+
 // handleAPIError processes Terabox API error responses and returns appropriate errors
 func (r *TeraboxResolver) handleAPIError(apiResp TeraboxAPIResponse) error {
 	if apiResp.Errno == 0 {
@@ -1,6 +1,10 @@
 package downloader
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -43,7 +47,7 @@ func TestTeraboxResolver_ResolvePublicLink(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := resolver.ResolvePublicLink(tt.url)
+			_, err := resolver.ResolvePublicLink(context.Background(), tt.url)
 			
 			if tt.expectError {
 				if err == nil {
@@ -99,7 +103,7 @@ func TestTeraboxResolver_ResolvePrivateLink(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := resolver.ResolvePrivateLink(tt.url, tt.auth)
+			_, err := resolver.ResolvePrivateLink(context.Background(), tt.url, tt.auth)
 			
 			if tt.expectError {
 				if err == nil {
@@ -238,4 +242,64 @@ func TestNewTeraboxResolverWithClient(t *testing.T) {
 	if resolver.urlValidator == nil {
 		t.Error("urlValidator is nil")
 	}
-}
\ No newline at end of file
+}
+
+func TestTeraboxResolver_UseCookies(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Cookie"))
+		// The first response refreshes the session token
+		if len(received) == 1 {
+			http.SetCookie(w, &http.Cookie{Name: "STOKEN", Value: "refreshed", Path: "/"})
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	auth := &internal.AuthContext{
+		Cookies: map[string]*http.Cookie{
+			"BDUSS":  {Name: "BDUSS", Value: "bduss", Domain: ".terabox.com", Path: "/"},
+			"STOKEN": {Name: "STOKEN", Value: "stoken", Path: "/"},
+		},
+	}
+
+	resolver := NewTeraboxResolverWithClient(utils.NewHTTPClient())
+	resolver.SetBaseURL(server.URL)
+	get := func() {
+		resolver.useCookies(auth)
+		resp, err := resolver.httpClient.GetWithHeadersContext(context.Background(), server.URL+"/api/filemetas", nil)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// The API host gets every session cookie, whatever its domain
+	get()
+	if got := received[0]; got != "BDUSS=bduss; STOKEN=stoken" && got != "STOKEN=stoken; BDUSS=bduss" {
+		t.Errorf("Expected both session cookies, got %q", got)
+	}
+
+	// Using the same auth context again keeps the refreshed token
+	get()
+	if got := received[1]; got != "BDUSS=bduss; STOKEN=refreshed" && got != "STOKEN=refreshed; BDUSS=bduss" {
+		t.Errorf("Expected the refreshed token, got %q", got)
+	}
+
+	// Bypass contexts carry no cookies
+	bypass := NewTeraboxResolverWithClient(utils.NewHTTPClient())
+	bypass.SetBaseURL(server.URL)
+	bypass.useCookies(NewCookieAuthManager().CreateBypassAuthContext())
+	if cookies := bypass.httpClient.Cookies(mustParseURL(t, server.URL)); len(cookies) != 0 {
+		t.Errorf("Expected no cookies in bypass mode, got %v", cookies)
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("Invalid URL %s: %v", raw, err)
+	}
+	return u
+}
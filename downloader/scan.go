@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"terafetch/internal"
+)
+
+// ResumableDownload describes an interrupted download found by ScanResumableDownloads
+type ResumableDownload struct {
+	OutputPath string
+	Metadata   *internal.ResumeMetadata // nil when the resume file could not be read
+	Progress   float64                  // Percentage of the file already downloaded
+	Age        time.Duration            // Time since the last checkpoint
+
+	// Err explains why the download cannot be resumed; nil if it can
+	Err error
+}
+
+// PartialPath returns the path of the download's .part file
+func (d *ResumableDownload) PartialPath() string {
+	return d.OutputPath + ".part"
+}
+
+// Resumable reports whether the download can be resumed
+func (d *ResumableDownload) Resumable() bool {
+	return d.Err == nil
+}
+
+// ScanResumableDownloads finds the resume files under dir and its
+// subdirectories and reports the state of each download. Unlike
+// DetectResumableDownload it never removes anything: downloads with a missing
+// .part file, unreadable metadata or checkpoints older than
+// ValidateResumeCompatibility allows are returned with Err set.
+func (p *DownloadPlanner) ScanResumableDownloads(dir string) ([]*ResumableDownload, error) {
+	var downloads []*ResumableDownload
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ResumeMetadataExt) {
+			return nil
+		}
+		downloads = append(downloads, p.inspectResumableDownload(strings.TrimSuffix(path, ResumeMetadataExt)))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return downloads, nil
+}
+
+// inspectResumableDownload reports the state of the download at outputPath
+func (p *DownloadPlanner) inspectResumableDownload(outputPath string) *ResumableDownload {
+	download := &ResumableDownload{OutputPath: outputPath}
+
+	resumeData, err := p.LoadResumeMetadata(outputPath)
+	if err != nil {
+		download.Err = err
+		return download
+	}
+	download.Metadata = resumeData
+	download.Progress = p.CalculateResumeProgress(resumeData.Segments)
+	download.Age = time.Since(resumeData.LastUpdate)
+
+	if _, err := os.Stat(download.PartialPath()); os.IsNotExist(err) {
+		download.Err = fmt.Errorf("partial file missing")
+		return download
+	} else if err != nil {
+		download.Err = fmt.Errorf("failed to stat partial file: %w", err)
+		return download
+	}
+
+	// Checking the metadata against itself leaves only the structural and age checks
+	if err := p.ValidateResumeCompatibility(resumeData, resumeData.FileMetadata); err != nil {
+		download.Err = err
+	}
+	return download
+}
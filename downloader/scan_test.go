@@ -0,0 +1,107 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"terafetch/internal"
+)
+
+func TestDownloadPlanner_ScanResumableDownloads(t *testing.T) {
+	planner := NewDownloadPlanner()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_scan_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// save writes a resume file and, unless withPart is false, its .part file
+	save := func(relPath string, downloaded int64, lastUpdate time.Time, withPart bool) string {
+		outputPath := filepath.Join(tempDir, relPath)
+		meta := &internal.FileMetadata{Filename: filepath.Base(relPath), Size: 1000, ShareID: "1Scan"}
+		segments := []internal.SegmentInfo{{Index: 0, Start: 0, End: 999, Downloaded: downloaded}}
+		if err := planner.SaveResumeMetadata(outputPath, meta, segments); err != nil {
+			t.Fatalf("Failed to save resume metadata: %v", err)
+		}
+
+		resumeData, err := planner.LoadResumeMetadata(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to load resume metadata: %v", err)
+		}
+		resumeData.LastUpdate = lastUpdate
+		if err := planner.saveResumeMetadataStruct(outputPath, resumeData); err != nil {
+			t.Fatalf("Failed to backdate resume metadata: %v", err)
+		}
+
+		if withPart {
+			if err := os.WriteFile(outputPath+".part", make([]byte, downloaded), 0644); err != nil {
+				t.Fatalf("Failed to write part file: %v", err)
+			}
+		}
+		return outputPath
+	}
+
+	fresh := save("fresh.bin", 250, time.Now().Add(-time.Hour), true)
+	nested := save(filepath.Join("season1", "episode.mkv"), 500, time.Now(), true)
+	stale := save("stale.bin", 750, time.Now().Add(-8*24*time.Hour), true)
+	orphan := save("orphan.bin", 100, time.Now(), false)
+	corrupt := filepath.Join(tempDir, "corrupt.bin")
+	if err := os.WriteFile(corrupt+ResumeMetadataExt, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write corrupt resume file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "unrelated.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write unrelated file: %v", err)
+	}
+
+	downloads, err := planner.ScanResumableDownloads(tempDir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	byPath := make(map[string]*ResumableDownload)
+	for _, download := range downloads {
+		byPath[download.OutputPath] = download
+	}
+	if len(byPath) != 5 {
+		t.Fatalf("Expected 5 downloads, got %d: %v", len(byPath), downloads)
+	}
+
+	tests := []struct {
+		outputPath string
+		resumable  bool
+		progress   float64
+	}{
+		{fresh, true, 25},
+		{nested, true, 50},
+		{stale, false, 75},
+		{orphan, false, 10},
+		{corrupt, false, 0},
+	}
+	for _, tt := range tests {
+		download := byPath[tt.outputPath]
+		if download == nil {
+			t.Errorf("Expected %s to be found", tt.outputPath)
+			continue
+		}
+		if download.Resumable() != tt.resumable {
+			t.Errorf("%s: expected resumable=%v, got error %v", tt.outputPath, tt.resumable, download.Err)
+		}
+		if download.Progress != tt.progress {
+			t.Errorf("%s: expected progress %.0f%%, got %.1f%%", tt.outputPath, tt.progress, download.Progress)
+		}
+	}
+
+	if age := byPath[stale].Age; age < 8*24*time.Hour {
+		t.Errorf("Expected the stale download to be at least 8 days old, got %v", age)
+	}
+
+	// Scanning must not clean anything up
+	for _, path := range []string{stale + ".part", orphan + ResumeMetadataExt, corrupt + ResumeMetadataExt} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package downloader
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"terafetch/internal"
+)
+
+// sessionStoreFile is the name of the session store in the config directory
+const sessionStoreFile = "session.enc"
+
+// Format of the session store file
+const (
+	sessionStoreVersion = 1
+	sessionStoreKDF     = "pbkdf2-sha256"
+)
+
+// sessionKDFIterations is the PBKDF2 iteration count new stores are written
+// with; the count is kept in the file, so raising it doesn't lock old ones
+var sessionKDFIterations = 600000
+
+// maxSessionKDFIterations bounds the iteration count read from a file
+const maxSessionKDFIterations = 10000000
+
+var (
+	// ErrNoStoredSession is returned when the session store doesn't exist
+	ErrNoStoredSession = errors.New("no stored session")
+
+	// ErrWrongPassphrase is returned when the session store cannot be
+	// decrypted with the passphrase given
+	ErrWrongPassphrase = errors.New("wrong passphrase for the stored session")
+)
+
+// SessionStore keeps session cookies in a file encrypted with AES-256-GCM,
+// under a key derived from a passphrase with PBKDF2
+type SessionStore struct {
+	path string
+}
+
+// sessionFile is the content of a session store file. The cookies are only
+// kept in Ciphertext; the other fields are authenticated with it.
+type sessionFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// storedCookie is a cookie as encrypted in the session store
+type storedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+// NewSessionStore returns the session store kept at path
+func NewSessionStore(path string) *SessionStore {
+	return &SessionStore{path: path}
+}
+
+// DefaultSessionStore returns the session store in the config directory
+func DefaultSessionStore() (*SessionStore, error) {
+	dir, err := internal.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewSessionStore(filepath.Join(dir, sessionStoreFile)), nil
+}
+
+// Path returns the path of the store file
+func (s *SessionStore) Path() string {
+	return s.path
+}
+
+// Exists reports whether a session has been stored
+func (s *SessionStore) Exists() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// SavedAt returns when the session was stored
+func (s *SessionStore) SavedAt() (time.Time, error) {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, ErrNoStoredSession
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Save encrypts cookies with passphrase and replaces the stored session
+func (s *SessionStore) Save(cookies []*http.Cookie, passphrase string) error {
+	stored := make([]storedCookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		stored = append(stored, storedCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		})
+	}
+	plaintext, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	defer clear(plaintext)
+
+	file := &sessionFile{
+		Version:    sessionStoreVersion,
+		KDF:        sessionStoreKDF,
+		Iterations: sessionKDFIterations,
+		Salt:       make([]byte, 16),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	aead, err := file.cipher(passphrase)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, file.additionalData())
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session store: %w", err)
+	}
+
+	// Write to a temporary file only the user can read and rename it, so a
+	// crash never leaves a truncated store behind
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create session store directory: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write session store: %w", err)
+	}
+	return nil
+}
+
+// Load decrypts the stored cookies with passphrase
+func (s *SessionStore) Load(passphrase string) ([]*http.Cookie, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoStoredSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	file := &sessionFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid session store %s: %w", s.path, err)
+	}
+	switch {
+	case file.Version != sessionStoreVersion:
+		return nil, fmt.Errorf("unsupported session store version %d in %s", file.Version, s.path)
+	case file.KDF != sessionStoreKDF:
+		return nil, fmt.Errorf("unsupported key derivation %q in %s", file.KDF, s.path)
+	case file.Iterations < 1 || file.Iterations > maxSessionKDFIterations:
+		return nil, fmt.Errorf("invalid iteration count %d in %s", file.Iterations, s.path)
+	}
+
+	aead, err := file.cipher(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid session store %s: bad nonce", s.path)
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, file.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	defer clear(plaintext)
+
+	var stored []storedCookie
+	if err := json.Unmarshal(plaintext, &stored); err != nil {
+		return nil, fmt.Errorf("invalid session in %s: %w", s.path, err)
+	}
+	cookies := make([]*http.Cookie, 0, len(stored))
+	for _, cookie := range stored {
+		cookies = append(cookies, &http.Cookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		})
+	}
+	return cookies, nil
+}
+
+// Wipe overwrites the store file with zeros and removes it
+func (s *SessionStore) Wipe() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNoStoredSession
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err == nil {
+		_, err = file.Write(make([]byte, info.Size()))
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to overwrite session store: %w", err)
+	}
+
+	if err := os.Remove(s.path); err != nil {
+		return fmt.Errorf("failed to remove session store: %w", err)
+	}
+	return nil
+}
+
+// cipher derives the key from passphrase and returns the AEAD sealing the
+// session
+func (f *sessionFile) cipher(passphrase string) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, f.Salt, f.Iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer clear(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the header fields to the ciphertext, so they cannot
+// be changed without the store failing to decrypt
+func (f *sessionFile) additionalData() []byte {
+	return fmt.Appendf(nil, "terafetch-session/%d/%s/%d", f.Version, f.KDF, f.Iterations)
+}
+
+// LoadStoredSession loads the cookies kept in store, decrypting them with
+// passphrase
+func (a *CookieAuthManager) LoadStoredSession(store *SessionStore, passphrase string) (*internal.AuthContext, error) {
+	cookies, err := store.Load(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clearCookies()
+	a.writeBack = func([]*http.Cookie) error {
+		return a.StoreSession(store, passphrase)
+	}
+	for _, cookie := range cookies {
+		a.cookieStore[cookie.Name] = cookie
+	}
+	return a.authContext(), nil
+}
+
+// StoreSession encrypts the loaded cookies with passphrase and saves them in
+// store, replacing the session kept there
+func (a *CookieAuthManager) StoreSession(store *SessionStore, passphrase string) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if len(a.cookieStore) == 0 {
+		return fmt.Errorf("no cookies loaded")
+	}
+
+	cookies := make([]*http.Cookie, 0, len(a.cookieStore))
+	for _, cookie := range a.cookieStore {
+		cookies = append(cookies, cookie)
+	}
+	return store.Save(cookies, passphrase)
+}
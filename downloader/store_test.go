@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fastSessionKDF lowers the PBKDF2 iteration count for the duration of a test
+func fastSessionKDF(t *testing.T) {
+	t.Helper()
+	original := sessionKDFIterations
+	sessionKDFIterations = 1000
+	t.Cleanup(func() { sessionKDFIterations = original })
+}
+
+func TestSessionStore_RoundTrip(t *testing.T) {
+	fastSessionKDF(t)
+	store := NewSessionStore(filepath.Join(t.TempDir(), "terafetch", "session.enc"))
+
+	manager := NewCookieAuthManager()
+	if _, err := manager.LoadCookieHeader("BDUSS=" + testBDUSS + "; STOKEN=" + testSTOKEN); err != nil {
+		t.Fatalf("LoadCookieHeader failed: %v", err)
+	}
+	expires := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager.cookieStore["BDUSS"].Expires = expires
+	if err := manager.StoreSession(store, "correct horse"); err != nil {
+		t.Fatalf("StoreSession failed: %v", err)
+	}
+
+	// Only the user can read the store, and the cookies are not in the clear
+	info, err := os.Stat(store.Path())
+	if err != nil {
+		t.Fatalf("Session store not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected the store to be private, got %v", perm)
+	}
+	data, _ := os.ReadFile(store.Path())
+	if strings.Contains(string(data), testBDUSS) || strings.Contains(string(data), "BDUSS") {
+		t.Error("Expected the cookies to be encrypted")
+	}
+
+	auth, err := NewCookieAuthManager().LoadStoredSession(store, "correct horse")
+	if err != nil {
+		t.Fatalf("LoadStoredSession failed: %v", err)
+	}
+	if auth.BDUSS != testBDUSS || auth.STOKEN != testSTOKEN {
+		t.Errorf("Expected BDUSS %s and STOKEN %s, got %s and %s", testBDUSS, testSTOKEN, auth.BDUSS, auth.STOKEN)
+	}
+	if !auth.ExpiresAt.Equal(expires) {
+		t.Errorf("Expected the session to expire at %v, got %v", expires, auth.ExpiresAt)
+	}
+	if cookie := auth.Cookies["STOKEN"]; cookie.Domain != headerCookieDomain || cookie.Path != "/" || !cookie.HttpOnly {
+		t.Errorf("Expected the cookie attributes to be kept, got %+v", cookie)
+	}
+
+	if _, err := store.Load("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestSessionStore_Tampered(t *testing.T) {
+	fastSessionKDF(t)
+	store := NewSessionStore(filepath.Join(t.TempDir(), "session.enc"))
+	manager := NewCookieAuthManager()
+	if _, err := manager.LoadCookieHeader("BDUSS=" + testBDUSS); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StoreSession(store, "secret"); err != nil {
+		t.Fatalf("StoreSession failed: %v", err)
+	}
+
+	// Lowering the iteration count invalidates the authenticated header
+	data, _ := os.ReadFile(store.Path())
+	tampered := strings.Replace(string(data), `"iterations": 1000`, `"iterations": 1`, 1)
+	if err := os.WriteFile(store.Path(), []byte(tampered), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("secret"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected the tampered store to fail, got %v", err)
+	}
+}
+
+func TestSessionStore_Wipe(t *testing.T) {
+	fastSessionKDF(t)
+	store := NewSessionStore(filepath.Join(t.TempDir(), "session.enc"))
+
+	if store.Exists() {
+		t.Fatal("Expected no store before a session is saved")
+	}
+	if _, err := store.Load("secret"); !errors.Is(err, ErrNoStoredSession) {
+		t.Errorf("Expected ErrNoStoredSession, got %v", err)
+	}
+	if err := NewCookieAuthManager().StoreSession(store, "secret"); err == nil {
+		t.Error("Expected an error storing a session without cookies")
+	}
+
+	manager := NewCookieAuthManager()
+	if _, err := manager.LoadCookieHeader("BDUSS=" + testBDUSS); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.StoreSession(store, "secret"); err != nil {
+		t.Fatalf("StoreSession failed: %v", err)
+	}
+	if err := store.Wipe(); err != nil {
+		t.Fatalf("Wipe failed: %v", err)
+	}
+	if store.Exists() {
+		t.Error("Expected the store to be removed")
+	}
+	if err := store.Wipe(); !errors.Is(err, ErrNoStoredSession) {
+		t.Errorf("Expected ErrNoStoredSession wiping twice, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+#!/usr/bin/env python3
+"""Generates the browser cookie stores the tests read. Run from this directory.
+
+Values are encrypted the way Chromium does on Linux: AES-128-CBC with a key
+derived from "peanuts" (v10) or the keyring password (v11).
+"""
+
+import hashlib
+import os
+import shutil
+import sqlite3
+import subprocess
+
+BDUSS = "FirefoxBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMN"
+WORK_BDUSS = "WorkProfileBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJ"
+CHROMIUM_BDUSS = "ChromiumBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLM"
+OLD_CHROMIUM_BDUSS = "OldChromiumBDUSS0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJ"
+KEYRING_PASSWORD = "keyring-secret"
+
+EXPIRY = 4102444800  # 2100-01-01
+CHROMIUM_EPOCH_OFFSET = 11644473600  # Seconds from 1601-01-01 to 1970-01-01
+
+for name in ["firefox", "chromium"]:
+    shutil.rmtree(name, ignore_errors=True)
+
+
+def firefox_profile(directory, cookies):
+    os.makedirs(directory)
+    db = sqlite3.connect(os.path.join(directory, "cookies.sqlite"))
+    db.execute("CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT NOT NULL DEFAULT '', "
+               "name TEXT, value TEXT, host TEXT, path TEXT, expiry INTEGER, lastAccessed INTEGER, "
+               "creationTime INTEGER, isSecure INTEGER, isHttpOnly INTEGER, inBrowserElement INTEGER DEFAULT 0, "
+               "sameSite INTEGER DEFAULT 0, rawSameSite INTEGER DEFAULT 0, schemeMap INTEGER DEFAULT 0, "
+               "CONSTRAINT moz_uniqueid UNIQUE (name, host, path, originAttributes))")
+    for name, value, host, expiry in cookies:
+        db.execute("INSERT INTO moz_cookies (name, value, host, path, expiry, lastAccessed, creationTime, "
+                   "isSecure, isHttpOnly) VALUES (?, ?, ?, '/', ?, 0, 0, 1, 1)", (name, value, host, expiry))
+    db.commit()
+    db.close()
+
+
+firefox_profile("firefox/abcd1234.default-release", [
+    ("BDUSS", BDUSS, ".terabox.com", EXPIRY),
+    ("STOKEN", "firefox-stoken", ".terabox.com", EXPIRY),
+    ("ndus", "firefox-ndus", "www.1024terabox.com", EXPIRY * 1000),  # Newer releases store milliseconds
+    ("session", "not-terabox", ".example.com", EXPIRY),
+])
+firefox_profile("firefox/wxyz9876.work", [
+    ("BDUSS", WORK_BDUSS, ".terabox.com", EXPIRY),
+    ("STOKEN", "work-stoken", ".terabox.com", EXPIRY),
+])
+with open("firefox/profiles.ini", "w") as f:
+    f.write("[Profile1]\nName=work\nIsRelative=1\nPath=wxyz9876.work\n\n"
+            "[Profile0]\nName=default-release\nIsRelative=1\nPath=abcd1234.default-release\nDefault=1\n")
+
+
+def encrypt(version, password, host, value, meta_version):
+    key = hashlib.pbkdf2_hmac("sha1", password.encode(), b"saltysalt", 1, 16)
+    plaintext = value.encode()
+    if meta_version >= 24:
+        plaintext = hashlib.sha256(host.encode()).digest() + plaintext
+    encrypted = subprocess.run(["openssl", "enc", "-aes-128-cbc", "-K", key.hex(), "-iv", (b" " * 16).hex()],
+                               input=plaintext, capture_output=True, check=True).stdout
+    return version + encrypted
+
+
+def chromium_profile(directory, meta_version, cookies):
+    os.makedirs(directory)
+    db = sqlite3.connect(os.path.join(directory, "Cookies"))
+    db.execute("CREATE TABLE meta(key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR)")
+    db.execute("INSERT INTO meta VALUES ('version', ?)", (str(meta_version),))
+    db.execute("CREATE TABLE cookies(creation_utc INTEGER NOT NULL,host_key TEXT NOT NULL,"
+               "top_frame_site_key TEXT NOT NULL,name TEXT NOT NULL,value TEXT NOT NULL,"
+               "encrypted_value BLOB NOT NULL,path TEXT NOT NULL,expires_utc INTEGER NOT NULL,"
+               "is_secure INTEGER NOT NULL,is_httponly INTEGER NOT NULL,last_access_utc INTEGER NOT NULL,"
+               "has_expires INTEGER NOT NULL,is_persistent INTEGER NOT NULL,priority INTEGER NOT NULL,"
+               "samesite INTEGER NOT NULL,source_scheme INTEGER NOT NULL,source_port INTEGER NOT NULL,"
+               "last_update_utc INTEGER NOT NULL,source_type INTEGER NOT NULL,has_cross_site_ancestor INTEGER NOT NULL)")
+    for name, host, plain, encrypted in cookies:
+        db.execute("INSERT INTO cookies VALUES (0, ?, '', ?, ?, ?, '/', ?, 1, 1, 0, 1, 1, 1, 0, 2, 443, 0, 0, 0)",
+                   (host, name, plain, encrypted, (EXPIRY + CHROMIUM_EPOCH_OFFSET) * 1000000))
+    db.commit()
+    db.close()
+
+
+chromium_profile("chromium/Default/Network", 24, [
+    ("BDUSS", ".terabox.com", "", encrypt(b"v10", "peanuts", ".terabox.com", CHROMIUM_BDUSS, 24)),
+    ("STOKEN", ".terabox.com", "", encrypt(b"v11", KEYRING_PASSWORD, ".terabox.com", "chromium-stoken", 24)),
+    ("ndus", ".terabox.app", "chromium-ndus", b""),
+    ("session", ".example.com", "", encrypt(b"v10", "peanuts", ".example.com", "not-terabox", 24)),
+])
+chromium_profile("chromium/Profile 1", 18, [
+    ("BDUSS", ".1024tera.com", "", encrypt(b"v10", "peanuts", ".1024tera.com", OLD_CHROMIUM_BDUSS, 18)),
+    ("STOKEN", ".1024tera.com", "", encrypt(b"v11", "", ".1024tera.com", "old-stoken", 18)),
+])
@@ -0,0 +1,124 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"terafetch/internal"
+)
+
+// verifyDownload checks the finished .part file against the MD5 Terabox reported
+// for it. A mismatch removes the .part file and resume metadata, since resuming
+// would only reproduce the same corrupted bytes.
+func (e *MultiThreadEngine) verifyDownload(meta *internal.FileMetadata, outputPath, partPath string, config *internal.DownloadConfig) error {
+	if config.SkipVerify || meta.Checksum == "" {
+		return nil
+	}
+
+	if _, err := NormalizeMD5(meta.Checksum); err != nil {
+		// An unrecognized checksum format says nothing about the file itself
+		internal.LogWarn("Skipping checksum verification for %s: %v", meta.Filename, err)
+		return nil
+	}
+
+	if !config.Quiet {
+		fmt.Fprintf(e.output, "🔐 Verifying MD5 checksum...\n")
+	}
+
+	err := verifyChecksum(partPath, meta.Checksum)
+	var teraboxErr *internal.TeraboxError
+	if errors.As(err, &teraboxErr) && teraboxErr.Type == internal.ErrCorruptedFile {
+		internal.LogError("Checksum verification failed for %s: %v", outputPath, err)
+		teraboxErr.WithContext("file", outputPath)
+		os.Remove(partPath)
+		if cleanupErr := e.planner.CleanupResumeMetadata(outputPath); cleanupErr != nil {
+			fmt.Fprintf(e.output, "Warning: failed to cleanup resume metadata: %v\n", cleanupErr)
+		}
+		return teraboxErr
+	}
+	if err != nil {
+		return fmt.Errorf("checksum verification failed: %w", err)
+	}
+
+	internal.LogInfo("Checksum verified for %s", outputPath)
+	if !config.Quiet {
+		fmt.Fprintf(e.output, "✅ Checksum verified\n")
+	}
+	return nil
+}
+
+// verifyChecksum hashes the file at path and compares it with the MD5 reported
+// by Terabox, which may be in its obfuscated form
+func verifyChecksum(path, checksum string) error {
+	expected, err := NormalizeMD5(checksum)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file for verification: %w", err)
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != expected {
+		return internal.NewCorruptedFileError(path, expected, actual)
+	}
+
+	return nil
+}
+
+// NormalizeMD5 returns checksum as a lowercase hex MD5, decoding Terabox's
+// obfuscated form when needed
+func NormalizeMD5(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimSpace(checksum))
+	if len(checksum) != 32 {
+		return "", fmt.Errorf("invalid MD5 checksum length: %d", len(checksum))
+	}
+
+	if _, err := hex.DecodeString(checksum); err == nil {
+		return checksum, nil
+	}
+
+	return decryptMD5(checksum)
+}
+
+// decryptMD5 decodes the obfuscated MD5 some Terabox APIs return. Every hex digit
+// is XORed with its position (mod 16), the tenth digit is shifted into g-v so the
+// value is not valid hex, and the four 8-digit groups are swapped pairwise.
+func decryptMD5(encrypted string) (string, error) {
+	var out strings.Builder
+	out.Grow(len(encrypted))
+
+	for i := 0; i < len(encrypted); i++ {
+		var n int64
+		if i == 9 {
+			n = int64(encrypted[i]) - 'g'
+			if n < 0 || n > 15 {
+				return "", fmt.Errorf("invalid obfuscated MD5 checksum: %s", encrypted)
+			}
+		} else {
+			digit, err := strconv.ParseInt(encrypted[i:i+1], 16, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid obfuscated MD5 checksum: %s", encrypted)
+			}
+			n = digit
+		}
+		out.WriteString(strconv.FormatInt(n^int64(15&i), 16))
+	}
+
+	decoded := out.String()
+	return decoded[8:16] + decoded[:8] + decoded[24:32] + decoded[16:24], nil
+}
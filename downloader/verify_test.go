@@ -0,0 +1,170 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"terafetch/internal"
+)
+
+// encryptMD5 is the inverse of decryptMD5, used to build obfuscated test values
+func encryptMD5(md5 string) string {
+	swapped := md5[8:16] + md5[:8] + md5[24:32] + md5[16:24]
+
+	var out strings.Builder
+	for i := 0; i < len(swapped); i++ {
+		n, _ := strconv.ParseInt(swapped[i:i+1], 16, 64)
+		n ^= int64(15 & i)
+		if i == 9 {
+			out.WriteByte(byte(n) + 'g')
+		} else {
+			out.WriteString(strconv.FormatInt(n, 16))
+		}
+	}
+	return out.String()
+}
+
+func TestNormalizeMD5(t *testing.T) {
+	const plain = "5eb63bbbe01eeed093cb22bb8f5acdc3" // md5("hello world")
+	encrypted := encryptMD5(plain)
+
+	tests := []struct {
+		name     string
+		checksum string
+		expected string
+		wantErr  bool
+	}{
+		{"plain", plain, plain, false},
+		{"uppercase", strings.ToUpper(plain), plain, false},
+		{"obfuscated", encrypted, plain, false},
+		{"too_short", "abc", "", true},
+		{"not_hex", "zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeMD5(tt.checksum)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error for %q", tt.checksum)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("NormalizeMD5(%q) = %q, expected %q", tt.checksum, got, tt.expected)
+			}
+		})
+	}
+
+	if encrypted == plain {
+		t.Fatal("obfuscated checksum should differ from the plain one")
+	}
+	if encrypted[9] < 'g' || encrypted[9] > 'v' {
+		t.Errorf("expected tenth digit of obfuscated checksum in g-v, got %q", encrypted[9])
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "terafetch_verify_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "hello.txt")
+	if err := os.WriteFile(path, []byte("hello world"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	if err := verifyChecksum(path, "5eb63bbbe01eeed093cb22bb8f5acdc3"); err != nil {
+		t.Errorf("expected plain checksum to verify, got %v", err)
+	}
+	if err := verifyChecksum(path, encryptMD5("5eb63bbbe01eeed093cb22bb8f5acdc3")); err != nil {
+		t.Errorf("expected obfuscated checksum to verify, got %v", err)
+	}
+
+	err = verifyChecksum(path, "00000000000000000000000000000000")
+	var teraboxErr *internal.TeraboxError
+	if !errors.As(err, &teraboxErr) || teraboxErr.Type != internal.ErrCorruptedFile {
+		t.Errorf("expected ErrCorruptedFile for mismatching checksum, got %v", err)
+	}
+}
+
+func TestMultiThreadEngine_ChecksumVerification(t *testing.T) {
+	testData := []byte(strings.Repeat("checksum verification ", 2000))
+	sum := md5.Sum(testData)
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(testData))
+	}))
+	defer server.Close()
+
+	tempDir, err := os.MkdirTemp("", "terafetch_verify_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	download := func(name, checksum string, skip bool) (string, error) {
+		outputPath := filepath.Join(tempDir, name)
+		meta := &internal.FileMetadata{
+			Filename:  name,
+			Size:      int64(len(testData)),
+			DirectURL: server.URL,
+			ShareID:   "verify123",
+			Timestamp: time.Now(),
+			Checksum:  checksum,
+		}
+		config := &internal.DownloadConfig{
+			OutputPath: outputPath,
+			Threads:    4,
+			Quiet:      true,
+			SkipVerify: skip,
+		}
+		return outputPath, NewMultiThreadEngine().Download(context.Background(), meta, config)
+	}
+
+	t.Run("match", func(t *testing.T) {
+		outputPath, err := download("match.bin", encryptMD5(checksum), false)
+		if err != nil {
+			t.Fatalf("expected download with matching checksum to succeed, got %v", err)
+		}
+		if _, err := os.Stat(outputPath); err != nil {
+			t.Errorf("expected final file to exist: %v", err)
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		outputPath, err := download("mismatch.bin", "00000000000000000000000000000000", false)
+		var teraboxErr *internal.TeraboxError
+		if !errors.As(err, &teraboxErr) || teraboxErr.Type != internal.ErrCorruptedFile {
+			t.Fatalf("expected ErrCorruptedFile, got %v", err)
+		}
+		for _, path := range []string{outputPath, outputPath + ".part", outputPath + ResumeMetadataExt} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("expected %s to be removed after a checksum mismatch", filepath.Base(path))
+			}
+		}
+	})
+
+	t.Run("skip_verify", func(t *testing.T) {
+		if _, err := download("skipped.bin", "00000000000000000000000000000000", true); err != nil {
+			t.Errorf("expected SkipVerify to bypass the checksum, got %v", err)
+		}
+	})
+}
@@ -4,21 +4,75 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds application configuration
 type Config struct {
 	DefaultThreads   int
-	DefaultTimeout   int
-	MaxRetries      int
-	UserAgentList   []string
+	DefaultTimeout   int      // Request timeout in seconds
+	MaxRetries      int      // Retries after a failed request, segment or download
+	RetryDelay      int      // Seconds before the first retry, doubling after each one
+	MaxRetryDelay   int      // Upper bound of the retry delay in seconds
+	UserAgentList   []string // User agents requests rotate through when blocked
 	AllowedDomains  []string
 	
+	// Download defaults the command line flags fall back to
+	RateLimit       string
+	RateSchedule    string // Time-of-day bandwidth limits, in the --rate-schedule format
+	ProxyURL        string
+	CookiesPath     string
+	CookiesBrowser  string // Browser cookie store read instead of CookiesPath, as browser[:profile]
+	Bypass          bool
+	
 	// Logging configuration
 	LogLevel        string
 	EnableDebug     bool
 	QuietMode       bool
 	LogFile         string
+	
+	// Where the settings came from
+	File            string            // Config file that was read, empty if none
+	Profile         string            // Config file profile that was applied, empty if none
+	Sources         map[string]string // Source of each setting that isn't a default, by setting name
+}
+
+// DefaultUserAgents are the browser user agents requests rotate through by default
+var DefaultUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/120.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:109.0) Gecko/20100101 Firefox/120.0",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/120.0",
+}
+
+// DefaultAllowedDomains are the hosts share URLs are accepted from by default
+var DefaultAllowedDomains = []string{
+	"terabox.com",
+	"www.terabox.com",
+	"terabox.app",
+	"www.terabox.app",
+	"1024terabox.com",
+	"www.1024terabox.com",
+	"dm.terabox.com",
+	"v1.terabox.com",
+	"d.terabox.com",
+	"data.terabox.com",
+	"v2.terabox.com",
+	"t.terabox.com",
+	"autodiscover.terabox.com",
+	"blog.terabox.com",
+	"us.terabox.com",
+	"jp.terabox.com",
+	"ca.terabox.com",
+	"email.terabox.com",
+	"s.terabox.com",
+	"op.terabox.com",
+	"pan.baidu.com",
+	"www.pan.baidu.com",
 }
 
 // DefaultConfig returns the default configuration
@@ -26,18 +80,11 @@ func DefaultConfig() *Config {
 	return &Config{
 		DefaultThreads: 8,
 		DefaultTimeout: 30,
-		MaxRetries:     3,
-		UserAgentList: []string{
-			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		},
-		AllowedDomains: []string{
-			"terabox.com",
-			"www.terabox.com",
-			"pan.baidu.com",
-			"www.pan.baidu.com",
-		},
+		MaxRetries:     2,
+		RetryDelay:     1,
+		MaxRetryDelay:  30,
+		UserAgentList:  append([]string(nil), DefaultUserAgents...),
+		AllowedDomains: append([]string(nil), DefaultAllowedDomains...),
 		
 		// Logging defaults
 		LogLevel:    "info",
@@ -52,30 +99,69 @@ func (c *Config) LoadFromEnv() {
 	if threads := os.Getenv("TERAFETCH_THREADS"); threads != "" {
 		if t, err := strconv.Atoi(threads); err == nil && t > 0 && t <= 32 {
 			c.DefaultThreads = t
+			c.SetSource(SettingThreads, "env TERAFETCH_THREADS")
 		}
 	}
 	
 	if timeout := os.Getenv("TERAFETCH_TIMEOUT"); timeout != "" {
 		if t, err := strconv.Atoi(timeout); err == nil && t > 0 {
 			c.DefaultTimeout = t
+			c.SetSource(SettingTimeout, "env TERAFETCH_TIMEOUT")
 		}
 	}
 	
+	c.loadIntFromEnv(SettingMaxRetries, "TERAFETCH_MAX_RETRIES", &c.MaxRetries)
+	c.loadIntFromEnv(SettingRetryDelay, "TERAFETCH_RETRY_DELAY", &c.RetryDelay)
+	c.loadIntFromEnv(SettingMaxRetryDelay, "TERAFETCH_MAX_RETRY_DELAY", &c.MaxRetryDelay)
+	
+	c.loadStringFromEnv(SettingRateLimit, "TERAFETCH_RATE_LIMIT", &c.RateLimit)
+	c.loadStringFromEnv(SettingRateSchedule, "TERAFETCH_RATE_SCHEDULE", &c.RateSchedule)
+	c.loadStringFromEnv(SettingProxy, "TERAFETCH_PROXY", &c.ProxyURL)
+	c.setCookieSource(envValue("TERAFETCH_COOKIES"), "env TERAFETCH_COOKIES",
+		envValue("TERAFETCH_COOKIES_FROM_BROWSER"), "env TERAFETCH_COOKIES_FROM_BROWSER")
+	c.loadBoolFromEnv(SettingBypass, "TERAFETCH_BYPASS", &c.Bypass)
+	
 	// Load logging configuration from environment
-	if logLevel := os.Getenv("TERAFETCH_LOG_LEVEL"); logLevel != "" {
-		c.LogLevel = logLevel
+	c.loadStringFromEnv(SettingLogLevel, "TERAFETCH_LOG_LEVEL", &c.LogLevel)
+	c.loadBoolFromEnv(SettingDebug, "TERAFETCH_DEBUG", &c.EnableDebug)
+	c.loadBoolFromEnv(SettingQuiet, "TERAFETCH_QUIET", &c.QuietMode)
+	c.loadStringFromEnv(SettingLogFile, "TERAFETCH_LOG_FILE", &c.LogFile)
+}
+
+// envValue returns the value of the environment variable env, or nil if it
+// is not set
+func envValue(env string) *string {
+	if value := os.Getenv(env); value != "" {
+		return &value
 	}
-	
-	if debug := os.Getenv("TERAFETCH_DEBUG"); debug != "" {
-		c.EnableDebug = debug == "true" || debug == "1"
+	return nil
+}
+
+// loadStringFromEnv sets *target from the environment variable env if it is set
+func (c *Config) loadStringFromEnv(key, env string, target *string) {
+	if value := os.Getenv(env); value != "" {
+		*target = value
+		c.SetSource(key, "env "+env)
 	}
-	
-	if quiet := os.Getenv("TERAFETCH_QUIET"); quiet != "" {
-		c.QuietMode = quiet == "true" || quiet == "1"
+}
+
+// loadIntFromEnv sets *target from the environment variable env if it holds a
+// number that isn't negative
+func (c *Config) loadIntFromEnv(key, env string, target *int) {
+	if value := os.Getenv(env); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			*target = n
+			c.SetSource(key, "env "+env)
+		}
 	}
-	
-	if logFile := os.Getenv("TERAFETCH_LOG_FILE"); logFile != "" {
-		c.LogFile = logFile
+}
+
+// loadBoolFromEnv sets *target from the environment variable env if it is
+// set; "true" and "1" mean true
+func (c *Config) loadBoolFromEnv(key, env string, target *bool) {
+	if value := os.Getenv(env); value != "" {
+		*target = strings.ToLower(value) == "true" || value == "1"
+		c.SetSource(key, "env "+env)
 	}
 }
 
@@ -101,6 +187,18 @@ func (c *Config) ValidateConfig() error {
 		return fmt.Errorf("invalid max retries: %d (must be >= 0)", c.MaxRetries)
 	}
 	
+	if c.RetryDelay < 0 {
+		return fmt.Errorf("invalid retry delay: %d (must be >= 0)", c.RetryDelay)
+	}
+	
+	if c.MaxRetryDelay < c.RetryDelay {
+		return fmt.Errorf("invalid max retry delay: %d (must be >= retry delay %d)", c.MaxRetryDelay, c.RetryDelay)
+	}
+	
+	if c.CookiesPath != "" && c.CookiesBrowser != "" {
+		return fmt.Errorf("cookies (%s) and cookies_from_browser (%s) cannot both be set", c.Source(SettingCookies), c.Source(SettingCookiesFromBrowser))
+	}
+	
 	if len(c.UserAgentList) == 0 {
 		return fmt.Errorf("user agent list cannot be empty")
 	}
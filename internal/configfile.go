@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Setting names, shared by config files and Config.Sources
+const (
+	SettingThreads            = "threads"
+	SettingTimeout            = "timeout"
+	SettingMaxRetries         = "max_retries"
+	SettingRetryDelay         = "retry_delay"
+	SettingMaxRetryDelay      = "max_retry_delay"
+	SettingUserAgents         = "user_agents"
+	SettingAllowedDomains     = "allowed_domains"
+	SettingRateLimit          = "rate_limit"
+	SettingRateSchedule       = "rate_schedule"
+	SettingProxy              = "proxy"
+	SettingCookies            = "cookies"
+	SettingCookiesFromBrowser = "cookies_from_browser"
+	SettingBypass             = "bypass"
+	SettingLogLevel           = "log_level"
+	SettingLogFile            = "log_file"
+	SettingDebug              = "debug"
+	SettingQuiet              = "quiet"
+)
+
+// SourceDefault marks settings nothing has overridden
+const SourceDefault = "default"
+
+// configFileNames are looked up in ConfigDir, in this order
+var configFileNames = []string{"config.toml", "config.yaml", "config.yml"}
+
+// ConfigSettings are the settings a config file or one of its profiles can
+// set. Settings left out are nil and keep their previous value.
+type ConfigSettings struct {
+	Threads            *int     `toml:"threads" yaml:"threads"`
+	Timeout            *int     `toml:"timeout" yaml:"timeout"`
+	MaxRetries         *int     `toml:"max_retries" yaml:"max_retries"`
+	RetryDelay         *int     `toml:"retry_delay" yaml:"retry_delay"`
+	MaxRetryDelay      *int     `toml:"max_retry_delay" yaml:"max_retry_delay"`
+	UserAgents         []string `toml:"user_agents" yaml:"user_agents"`
+	AllowedDomains     []string `toml:"allowed_domains" yaml:"allowed_domains"`
+	RateLimit          *string  `toml:"rate_limit" yaml:"rate_limit"`
+	RateSchedule       *string  `toml:"rate_schedule" yaml:"rate_schedule"`
+	Proxy              *string  `toml:"proxy" yaml:"proxy"`
+	Cookies            *string  `toml:"cookies" yaml:"cookies"`
+	CookiesFromBrowser *string  `toml:"cookies_from_browser" yaml:"cookies_from_browser"`
+	Bypass             *bool    `toml:"bypass" yaml:"bypass"`
+	LogLevel           *string  `toml:"log_level" yaml:"log_level"`
+	LogFile            *string  `toml:"log_file" yaml:"log_file"`
+	Debug              *bool    `toml:"debug" yaml:"debug"`
+	Quiet              *bool    `toml:"quiet" yaml:"quiet"`
+}
+
+// ConfigFile is the content of a config file: top-level settings, named
+// profiles that override them, and the profile used when none is requested
+type ConfigFile struct {
+	ConfigSettings `yaml:",inline"`
+	Profile        string                    `toml:"profile" yaml:"profile"`
+	Profiles       map[string]ConfigSettings `toml:"profiles" yaml:"profiles"`
+}
+
+// ConfigDir returns the directory config files are read from:
+// $XDG_CONFIG_HOME/terafetch, or ~/.config/terafetch when it is not set
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "terafetch"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "terafetch"), nil
+}
+
+// FindConfigFile returns the path of the config file in ConfigDir, or "" if
+// there is none
+func FindConfigFile() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range configFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// LoadConfigFile reads a TOML or YAML config file, picking the format from the
+// extension. Unknown settings are rejected so that typos don't go unnoticed.
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file := &ConfigFile{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		meta, err := toml.Decode(string(data), file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("invalid config file %s: unknown setting %q", path, undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(file); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q, use .toml or .yaml", filepath.Ext(path))
+	}
+	return file, nil
+}
+
+// LoadConfig builds the configuration from the defaults, the config file at
+// path (or the one in ConfigDir when path is empty) with its profile applied,
+// and the environment, each overriding the one before. profile selects a
+// profile of the config file; when empty, the file's own default is used.
+func LoadConfig(path, profile string) (*Config, error) {
+	c := DefaultConfig()
+
+	if path == "" {
+		found, err := FindConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
+
+	if path != "" {
+		file, err := LoadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.ApplyConfigFile(file, path, profile); err != nil {
+			return nil, err
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requested but no config file was found", profile)
+	}
+
+	c.LoadFromEnv()
+	return c, nil
+}
+
+// ApplyConfigFile applies the top-level settings of a config file read from
+// path and then those of the selected profile
+func (c *Config) ApplyConfigFile(file *ConfigFile, path, profile string) error {
+	c.File = path
+	c.applySettings(&file.ConfigSettings, "config file")
+
+	if profile == "" {
+		profile = file.Profile
+	}
+	if profile == "" {
+		return nil
+	}
+
+	settings, ok := file.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(file.Profiles))
+		for name := range file.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("profile %q not found in %s, which defines no profiles", profile, path)
+		}
+		return fmt.Errorf("profile %q not found in %s (available: %s)", profile, path, strings.Join(names, ", "))
+	}
+
+	c.Profile = profile
+	c.applySettings(&settings, "profile "+profile)
+	return nil
+}
+
+// applySettings copies the settings that are set, recording source for each
+func (c *Config) applySettings(settings *ConfigSettings, source string) {
+	setValue(c, SettingThreads, source, &c.DefaultThreads, settings.Threads)
+	setValue(c, SettingTimeout, source, &c.DefaultTimeout, settings.Timeout)
+	setValue(c, SettingMaxRetries, source, &c.MaxRetries, settings.MaxRetries)
+	setValue(c, SettingRetryDelay, source, &c.RetryDelay, settings.RetryDelay)
+	setValue(c, SettingMaxRetryDelay, source, &c.MaxRetryDelay, settings.MaxRetryDelay)
+	setValue(c, SettingRateLimit, source, &c.RateLimit, settings.RateLimit)
+	setValue(c, SettingRateSchedule, source, &c.RateSchedule, settings.RateSchedule)
+	setValue(c, SettingProxy, source, &c.ProxyURL, settings.Proxy)
+	c.setCookieSource(settings.Cookies, source, settings.CookiesFromBrowser, source)
+	setValue(c, SettingBypass, source, &c.Bypass, settings.Bypass)
+	setValue(c, SettingLogLevel, source, &c.LogLevel, settings.LogLevel)
+	setValue(c, SettingLogFile, source, &c.LogFile, settings.LogFile)
+	setValue(c, SettingDebug, source, &c.EnableDebug, settings.Debug)
+	setValue(c, SettingQuiet, source, &c.QuietMode, settings.Quiet)
+
+	if settings.UserAgents != nil {
+		c.UserAgentList = settings.UserAgents
+		c.SetSource(SettingUserAgents, source)
+	}
+	if settings.AllowedDomains != nil {
+		c.AllowedDomains = settings.AllowedDomains
+		c.SetSource(SettingAllowedDomains, source)
+	}
+}
+
+// setCookieSource sets the cookie file and browser given by one layer of the
+// configuration. They are one choice, as with the flags: a layer naming one
+// clears the other from the layers below, and only a layer naming both is
+// rejected by ValidateConfig.
+func (c *Config) setCookieSource(path *string, pathSource string, browser *string, browserSource string) {
+	setValue(c, SettingCookies, pathSource, &c.CookiesPath, path)
+	setValue(c, SettingCookiesFromBrowser, browserSource, &c.CookiesBrowser, browser)
+
+	if path != nil && *path != "" && browser == nil && c.CookiesBrowser != "" {
+		c.CookiesBrowser = ""
+		c.SetSource(SettingCookiesFromBrowser, pathSource)
+	}
+	if browser != nil && *browser != "" && path == nil && c.CookiesPath != "" {
+		c.CookiesPath = ""
+		c.SetSource(SettingCookies, browserSource)
+	}
+}
+
+// setValue sets *target to *value if value is set
+func setValue[T any](c *Config, key, source string, target *T, value *T) {
+	if value != nil {
+		*target = *value
+		c.SetSource(key, source)
+	}
+}
+
+// SetSource records where a setting came from
+func (c *Config) SetSource(key, source string) {
+	if c.Sources == nil {
+		c.Sources = make(map[string]string)
+	}
+	c.Sources[key] = source
+}
+
+// Source returns where a setting came from
+func (c *Config) Source(key string) string {
+	if source, ok := c.Sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Setting is one effective setting as shown by 'terafetch config show'
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Settings lists every setting with its effective value and source. Proxy
+// passwords are redacted.
+func (c *Config) Settings() []Setting {
+	proxy := c.ProxyURL
+	if parsed, err := url.Parse(proxy); err == nil && proxy != "" {
+		proxy = parsed.Redacted()
+	}
+
+	values := []struct{ key, value string }{
+		{SettingThreads, strconv.Itoa(c.DefaultThreads)},
+		{SettingTimeout, strconv.Itoa(c.DefaultTimeout)},
+		{SettingMaxRetries, strconv.Itoa(c.MaxRetries)},
+		{SettingRetryDelay, strconv.Itoa(c.RetryDelay)},
+		{SettingMaxRetryDelay, strconv.Itoa(c.MaxRetryDelay)},
+		{SettingRateLimit, c.RateLimit},
+		{SettingRateSchedule, c.RateSchedule},
+		{SettingProxy, proxy},
+		{SettingCookies, c.CookiesPath},
+		{SettingCookiesFromBrowser, c.CookiesBrowser},
+		{SettingBypass, strconv.FormatBool(c.Bypass)},
+		{SettingLogLevel, c.LogLevel},
+		{SettingLogFile, c.LogFile},
+		{SettingDebug, strconv.FormatBool(c.EnableDebug)},
+		{SettingQuiet, strconv.FormatBool(c.QuietMode)},
+		{SettingUserAgents, strings.Join(c.UserAgentList, "; ")},
+		{SettingAllowedDomains, strings.Join(c.AllowedDomains, ", ")},
+	}
+
+	settings := make([]Setting, len(values))
+	for i, v := range values {
+		settings[i] = Setting{Key: v.key, Value: v.value, Source: c.Source(v.key)}
+	}
+	return settings
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -436,4 +437,74 @@ func NewPartialFileInvalidError(path string, reason string) *TeraboxError {
 	return NewTeraboxError(422, fmt.Sprintf("Partial file invalid: %s", reason), ErrPartialFileInvalid).
 		WithContext("partial_file", path).
 		WithSuggestion("Delete the .part file and restart the download")
-}
\ No newline at end of file
+}
+
+// NewCorruptedFileError creates an error for a download whose checksum doesn't match
+func NewCorruptedFileError(path string, expected string, actual string) *TeraboxError {
+	return NewTeraboxError(422, fmt.Sprintf("Checksum mismatch: expected MD5 %s, got %s", expected, actual), ErrCorruptedFile).
+		WithContext("file", path).
+		WithContext("expected_md5", expected).
+		WithContext("actual_md5", actual).
+		WithSuggestion("Download the file again, or use --no-verify if the share's checksum is known to be unreliable")
+}
+
+// AsTeraboxError returns err as a TeraboxError for structured reporting. A
+// wrapped TeraboxError is returned as is; other errors are converted, keeping
+// their full message.
+func AsTeraboxError(err error) *TeraboxError {
+	if err == nil {
+		return nil
+	}
+
+	var teraboxErr *TeraboxError
+	if errors.As(err, &teraboxErr) {
+		return teraboxErr
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		errorType := ErrUnsupportedFormat
+		if validationErr.Field == "url" {
+			errorType = ErrInvalidURL
+		}
+		converted := NewTeraboxError(0, err.Error(), errorType).WithContext("field", validationErr.Field)
+		if validationErr.Suggestion != "" {
+			converted.WithSuggestion(validationErr.Suggestion)
+		}
+		return converted
+	}
+
+	return NewTeraboxError(0, err.Error(), ErrDownloadFailed)
+}
+
+// MarshalText encodes the error type by name, so JSON output is readable
+func (et ErrorType) MarshalText() ([]byte, error) {
+	return []byte(et.String()), nil
+}
+
+// UnmarshalText decodes an error type from its name
+func (et *ErrorType) UnmarshalText(text []byte) error {
+	for t := ErrInvalidURL; t <= ErrPartialFileInvalid; t++ {
+		if t.String() == string(text) {
+			*et = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown error type: %s", text)
+}
+
+// MarshalText encodes the severity by name, so JSON output is readable
+func (es ErrorSeverity) MarshalText() ([]byte, error) {
+	return []byte(es.String()), nil
+}
+
+// UnmarshalText decodes a severity from its name
+func (es *ErrorSeverity) UnmarshalText(text []byte) error {
+	for s := SeverityInfo; s <= SeverityCritical; s++ {
+		if s.String() == string(text) {
+			*es = s
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown error severity: %s", text)
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -264,6 +266,23 @@ func TestCommonErrorConstructors(t *testing.T) {
 			t.Error("Should set URL context")
 		}
 	})
+	
+	t.Run("NewCorruptedFileError", func(t *testing.T) {
+		err := NewCorruptedFileError("/tmp/file.zip", "0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+		
+		if err.Type != ErrCorruptedFile {
+			t.Error("Should create CorruptedFile error type")
+		}
+		if err.Context["file"] != "/tmp/file.zip" {
+			t.Error("Should set file context")
+		}
+		if !strings.Contains(err.Message, "0123456789abcdef0123456789abcdef") {
+			t.Error("Should include expected checksum in message")
+		}
+		if !strings.Contains(err.Suggestion, "--no-verify") {
+			t.Error("Should mention --no-verify in suggestion")
+		}
+	})
 }
 
 func TestGetDefaultSuggestion(t *testing.T) {
@@ -353,4 +372,46 @@ func TestRedactSensitiveURL(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestAsTeraboxError(t *testing.T) {
+	if AsTeraboxError(nil) != nil {
+		t.Error("Expected nil for a nil error")
+	}
+
+	original := NewTeraboxError(7, "file not found", ErrFileNotFound)
+	if got := AsTeraboxError(fmt.Errorf("resolve failed: %w", original)); got != original {
+		t.Errorf("Expected the wrapped TeraboxError to be returned, got %+v", got)
+	}
+
+	validationErr := NewValidationError("url", "missing share ID").WithSuggestion("Check the link")
+	converted := AsTeraboxError(validationErr)
+	if converted.Type != ErrInvalidURL || converted.Suggestion != "Check the link" {
+		t.Errorf("Unexpected conversion of validation error: %+v", converted)
+	}
+
+	converted = AsTeraboxError(fmt.Errorf("connection reset"))
+	if converted.Type != ErrDownloadFailed || converted.Message != "connection reset" {
+		t.Errorf("Unexpected conversion of plain error: %+v", converted)
+	}
+}
+
+func TestTeraboxError_JSON(t *testing.T) {
+	err := NewTeraboxError(429, "too many requests", ErrRateLimit)
+
+	data, marshalErr := json.Marshal(err)
+	if marshalErr != nil {
+		t.Fatalf("Marshal failed: %v", marshalErr)
+	}
+	if !strings.Contains(string(data), `"type":"RateLimit"`) || !strings.Contains(string(data), `"severity":"`+err.Severity.String()+`"`) {
+		t.Errorf("Expected type and severity by name, got %s", data)
+	}
+
+	var decoded TeraboxError
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Type != err.Type || decoded.Severity != err.Severity || decoded.Code != 429 {
+		t.Errorf("Round trip mismatch: %+v", decoded)
+	}
+}
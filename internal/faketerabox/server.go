@@ -0,0 +1,654 @@
+// Package faketerabox provides an in-process fake of the Terabox share API for
+// end-to-end tests. It serves the sharedownload, filemetas, list, download and
+// share/verify endpoints plus ranged file content, and can inject API errnos,
+// HTTP error statuses, slow bodies and mid-stream connection resets. Download
+// links can be expired to exercise link refreshes. Accounts answer the account
+// API and can be revoked or have their cookies rotated.
+//
+// Point a resolver at it with TeraboxResolver.SetBaseURL(server.URL) and resolve
+// the share URLs returned by AddShare.
+package faketerabox
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint paths served by the fake, used to target injected faults
+const (
+	EndpointShareDownload = "/api/sharedownload"
+	EndpointFileMetas     = "/api/filemetas"
+	EndpointList          = "/api/list"
+	EndpointDownload      = "/api/download"
+	EndpointShareVerify   = "/share/verify"
+	EndpointUserInfo      = "/passport/get_info"
+	EndpointFile          = "/file/"
+)
+
+// chunkSize is the size of the chunks slow file bodies are written in
+const chunkSize = 32 * 1024
+
+// Errnos the fake answers with for missing shares, files and passwords
+const (
+	ErrnoFileNotFound     = 7
+	ErrnoShareNotFound    = 10
+	ErrnoPasswordRequired = 14
+	ErrnoWrongPassword    = -9
+	ErrnoNotLoggedIn      = -6
+)
+
+// File is a file in a fake share
+type File struct {
+	Path    string // Slash-separated path relative to the share root
+	Content []byte
+	MD5     string // Reported checksum; defaults to the hex MD5 of Content
+
+	fsID  int64
+	share *Share
+}
+
+// FsID returns the file ID assigned when the share was added
+func (f *File) FsID() int64 {
+	return f.fsID
+}
+
+// Share is a fake share. Files in subdirectories make it a folder share.
+type Share struct {
+	Surl     string
+	Password string // Extraction code; empty for an open share
+	Session  string // BDUSS cookie the download links require; empty for none
+	Files    []*File
+}
+
+// Account is a fake Terabox account, signed in with its BDUSS cookie
+type Account struct {
+	BDUSS    string
+	Username string
+	VIP      bool
+	Revoked  bool              // The account API answers as if logged out
+	Rotate   map[string]string // Cookies replaced with Set-Cookie by the next account API request
+
+	server *Server
+}
+
+// Fault describes a misbehaviour injected into the responses of one endpoint
+type Fault struct {
+	Errno      int           // API endpoints answer with this errno
+	Status     int           // Respond with this HTTP status instead of a body
+	ChunkDelay time.Duration // File content is sent in 32KB chunks with this delay between them
+	ResetAfter int64         // The file content connection is dropped after this many body bytes
+	Times      int           // Number of requests affected; 0 affects every request
+}
+
+// Server is a running fake Terabox API
+type Server struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	shares   map[string]*Share
+	files    map[int64]*File
+	accounts []*Account
+	nextFsID int64
+	faults   map[string][]*Fault
+	requests map[string]int
+	linkSign int // Signature of the dlinks currently handed out
+}
+
+// NewServer starts a fake Terabox API. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		shares:   make(map[string]*Share),
+		files:    make(map[int64]*File),
+		nextFsID: 1000,
+		faults:   make(map[string][]*Fault),
+		requests: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(EndpointShareDownload, s.handleShareDownload)
+	mux.HandleFunc(EndpointFileMetas, s.handleFileMetas)
+	mux.HandleFunc(EndpointList, s.handleList)
+	mux.HandleFunc(EndpointDownload, s.handleDownload)
+	mux.HandleFunc(EndpointShareVerify, s.handleShareVerify)
+	mux.HandleFunc(EndpointUserInfo, s.handleUserInfo)
+	mux.HandleFunc(EndpointFile, s.handleFile)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddShare registers a share and returns its share URL. Files get their IDs
+// and default checksums here.
+func (s *Server) AddShare(share *Share) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, file := range share.Files {
+		file.Path = strings.Trim(file.Path, "/")
+		if file.MD5 == "" {
+			sum := md5.Sum(file.Content)
+			file.MD5 = hex.EncodeToString(sum[:])
+		}
+		s.nextFsID++
+		file.fsID = s.nextFsID
+		file.share = share
+		s.files[file.fsID] = file
+	}
+
+	s.shares[share.Surl] = share
+	return ShareURL(share.Surl)
+}
+
+// AddAccount registers an account. Changes to its fields after this go through
+// Revoke and RotateCookies.
+func (s *Server) AddAccount(account *Account) *Account {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	account.server = s
+	s.accounts = append(s.accounts, account)
+	return account
+}
+
+// Revoke signs the account out, the way changing the password revokes the
+// sessions of every device
+func (a *Account) Revoke() {
+	a.server.mutex.Lock()
+	defer a.server.mutex.Unlock()
+
+	a.Revoked = true
+}
+
+// RotateCookies makes the next account API request replace the given cookies
+// with Set-Cookie. A rotated BDUSS signs the account in from then on.
+func (a *Account) RotateCookies(cookies map[string]string) {
+	a.server.mutex.Lock()
+	defer a.server.mutex.Unlock()
+
+	a.Rotate = cookies
+}
+
+// ExpireLinks invalidates every dlink handed out so far. Requests for their
+// content are answered with 403 Forbidden, the way Terabox answers stale links;
+// resolving the share again hands out working ones.
+func (s *Server) ExpireLinks() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.linkSign++
+}
+
+// ShareURL returns the public Terabox URL of the share with the given surl
+func ShareURL(surl string) string {
+	return "https://www.terabox.com/s/" + surl
+}
+
+// ShareKey returns the key the share verify endpoint hands out for a share
+func ShareKey(surl string) string {
+	return "randsk-" + surl
+}
+
+// InjectFault adds a fault to an endpoint. Faults apply in the order they
+// were added; a fault with Times set is dropped once it has been used up.
+func (s *Server) InjectFault(endpoint string, fault Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults[endpoint] = append(s.faults[endpoint], &fault)
+}
+
+// ClearFaults removes every injected fault
+func (s *Server) ClearFaults() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.faults = make(map[string][]*Fault)
+}
+
+// Requests returns the number of requests an endpoint has received
+func (s *Server) Requests(endpoint string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests[endpoint]
+}
+
+// begin counts a request and returns the fault to apply to it, if any
+func (s *Server) begin(endpoint string) *Fault {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests[endpoint]++
+
+	faults := s.faults[endpoint]
+	if len(faults) == 0 {
+		return nil
+	}
+
+	fault := *faults[0]
+	if faults[0].Times > 0 {
+		faults[0].Times--
+		if faults[0].Times == 0 {
+			s.faults[endpoint] = faults[1:]
+		}
+	}
+	return &fault
+}
+
+// beginAPI handles the faults an API endpoint can answer with and reports
+// whether the response has already been written
+func (s *Server) beginAPI(w http.ResponseWriter, endpoint string) bool {
+	fault := s.begin(endpoint)
+	if fault == nil {
+		return false
+	}
+	if fault.Status != 0 {
+		writeStatus(w, fault.Status)
+		return true
+	}
+	if fault.Errno != 0 {
+		writeErrno(w, fault.Errno)
+		return true
+	}
+	return false
+}
+
+// share looks up the share a request refers to and checks its share key
+func (s *Server) share(w http.ResponseWriter, r *http.Request, checkKey bool) (*Share, bool) {
+	surl := r.URL.Query().Get("surl")
+	if surl == "" {
+		surl = r.URL.Query().Get("shareid")
+	}
+
+	s.mutex.Lock()
+	share, ok := s.shares[surl]
+	s.mutex.Unlock()
+
+	if !ok {
+		writeErrno(w, ErrnoShareNotFound)
+		return nil, false
+	}
+
+	if checkKey && share.Password != "" && !hasShareKey(r, share) {
+		writeErrno(w, ErrnoPasswordRequired)
+		return nil, false
+	}
+	return share, true
+}
+
+// hasShareKey reports whether a request carries the key of an unlocked share
+func hasShareKey(r *http.Request, share *Share) bool {
+	key := ShareKey(share.Surl)
+	if r.URL.Query().Get("sekey") == key {
+		return true
+	}
+	cookie, err := r.Cookie("BDCLND")
+	return err == nil && cookie.Value == key
+}
+
+// hasSession reports whether a request carries the session cookie a share requires
+func hasSession(r *http.Request, share *Share) bool {
+	if share.Session == "" {
+		return true
+	}
+	cookie, err := r.Cookie("BDUSS")
+	return err == nil && cookie.Value == share.Session
+}
+
+func (s *Server) handleShareDownload(w http.ResponseWriter, r *http.Request) {
+	if s.beginAPI(w, EndpointShareDownload) {
+		return
+	}
+	share, ok := s.share(w, r, true)
+	if !ok {
+		return
+	}
+	if len(share.Files) == 0 {
+		writeErrno(w, ErrnoFileNotFound)
+		return
+	}
+
+	file := share.Files[0]
+	writeJSON(w, map[string]interface{}{
+		"errno":    0,
+		"dlink":    s.dlink(file),
+		"filename": path.Base(file.Path),
+		"size":     len(file.Content),
+		"md5":      file.MD5,
+	})
+}
+
+func (s *Server) handleFileMetas(w http.ResponseWriter, r *http.Request) {
+	if s.beginAPI(w, EndpointFileMetas) {
+		return
+	}
+	share, ok := s.share(w, r, true)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errno": 0,
+		"list":  entries(share, "/"),
+	})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	if s.beginAPI(w, EndpointList) {
+		return
+	}
+	share, ok := s.share(w, r, true)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	dir := query.Get("dir")
+	if dir == "" || query.Get("root") == "1" {
+		dir = "/"
+	}
+
+	list := entries(share, dir)
+
+	// Page through the listing the way the real API does
+	page, _ := strconv.Atoi(query.Get("page"))
+	num, _ := strconv.Atoi(query.Get("num"))
+	if page < 1 {
+		page = 1
+	}
+	if num > 0 {
+		start := (page - 1) * num
+		if start > len(list) {
+			start = len(list)
+		}
+		end := start + num
+		if end > len(list) {
+			end = len(list)
+		}
+		list = list[start:end]
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errno": 0,
+		"list":  list,
+	})
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if s.beginAPI(w, EndpointDownload) {
+		return
+	}
+
+	var fsIDs []int64
+	if err := json.Unmarshal([]byte(r.URL.Query().Get("fidlist")), &fsIDs); err != nil || len(fsIDs) == 0 {
+		writeErrno(w, 2)
+		return
+	}
+
+	s.mutex.Lock()
+	file, ok := s.files[fsIDs[0]]
+	s.mutex.Unlock()
+	if !ok {
+		writeErrno(w, ErrnoFileNotFound)
+		return
+	}
+
+	// The private download API answers with dlink, the share one with dlist
+	dlink := s.dlink(file)
+	writeJSON(w, map[string]interface{}{
+		"errno": 0,
+		"dlink": dlink,
+		"dlist": []map[string]interface{}{
+			{"fs_id": file.fsID, "dlink": dlink},
+		},
+	})
+}
+
+func (s *Server) handleShareVerify(w http.ResponseWriter, r *http.Request) {
+	if s.beginAPI(w, EndpointShareVerify) {
+		return
+	}
+	share, ok := s.share(w, r, false)
+	if !ok {
+		return
+	}
+
+	if r.Method != http.MethodPost || r.PostFormValue("pwd") != share.Password {
+		writeErrno(w, ErrnoWrongPassword)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errno":  0,
+		"randsk": ShareKey(share.Surl),
+	})
+}
+
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	if s.beginAPI(w, EndpointUserInfo) {
+		return
+	}
+	cookie, err := r.Cookie("BDUSS")
+	if err != nil {
+		writeErrno(w, ErrnoNotLoggedIn)
+		return
+	}
+
+	s.mutex.Lock()
+	var account *Account
+	for _, candidate := range s.accounts {
+		if candidate.BDUSS == cookie.Value {
+			account = candidate
+		}
+	}
+	if account == nil || account.Revoked {
+		s.mutex.Unlock()
+		writeErrno(w, ErrnoNotLoggedIn)
+		return
+	}
+
+	// Rotated cookies are sent the way Terabox sends them, valid for years
+	for name, value := range account.Rotate {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     "/",
+			Expires:  time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+			HttpOnly: true,
+		})
+		if name == "BDUSS" {
+			account.BDUSS = value
+		}
+	}
+	account.Rotate = nil
+	username, vip := account.Username, account.VIP
+	s.mutex.Unlock()
+
+	vipType := 0
+	if vip {
+		vipType = 2
+	}
+	writeJSON(w, map[string]interface{}{
+		"errno": 0,
+		"data": map[string]interface{}{
+			"display_name": username,
+			"vip_type":     vipType,
+		},
+	})
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	fault := s.begin(EndpointFile)
+	if fault != nil && fault.Status != 0 {
+		writeStatus(w, fault.Status)
+		return
+	}
+
+	id, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, EndpointFile), "/")
+	fsID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mutex.Lock()
+	file, ok := s.files[fsID]
+	expired := r.URL.Query().Get("sign") != strconv.Itoa(s.linkSign)
+	s.mutex.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if expired || !hasSession(r, file.share) {
+		writeStatus(w, http.StatusForbidden)
+		return
+	}
+
+	var writer http.ResponseWriter = w
+	if fault != nil && (fault.ChunkDelay > 0 || fault.ResetAfter > 0) {
+		writer = &faultWriter{ResponseWriter: w, request: r, fault: fault}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(writer, r, path.Base(file.Path), time.Time{}, bytes.NewReader(file.Content))
+}
+
+// dlink returns the download URL of a file's content
+func (s *Server) dlink(file *File) string {
+	s.mutex.Lock()
+	sign := s.linkSign
+	s.mutex.Unlock()
+
+	return fmt.Sprintf("%s%s%d/%s?sign=%d", s.URL, EndpointFile, file.fsID, url.PathEscape(path.Base(file.Path)), sign)
+}
+
+// entries lists the files and directories directly inside dir, in the API's
+// FileInfo format
+func entries(share *Share, dir string) []map[string]interface{} {
+	dir = strings.Trim(dir, "/")
+
+	var list []map[string]interface{}
+	seenDirs := make(map[string]bool)
+	for _, file := range share.Files {
+		parent := path.Dir(file.Path)
+		if parent == "." {
+			parent = ""
+		}
+
+		if parent == dir {
+			list = append(list, map[string]interface{}{
+				"server_filename": path.Base(file.Path),
+				"size":            len(file.Content),
+				"md5":             file.MD5,
+				"fs_id":           file.fsID,
+				"path":            "/" + file.Path,
+				"isdir":           0,
+			})
+			continue
+		}
+
+		// Files further down make their top-level directory under dir visible
+		prefix := ""
+		if dir != "" {
+			prefix = dir + "/"
+		}
+		if !strings.HasPrefix(parent+"/", prefix) {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(file.Path, prefix), "/")
+		if seenDirs[name] {
+			continue
+		}
+		seenDirs[name] = true
+		list = append(list, map[string]interface{}{
+			"server_filename": name,
+			"path":            "/" + prefix + name,
+			"isdir":           1,
+		})
+	}
+	return list
+}
+
+// faultWriter slows down or cuts off a file body as described by a fault
+type faultWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	fault   *Fault
+	written int64
+}
+
+func (w *faultWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+
+		if w.fault.ResetAfter > 0 {
+			left := w.fault.ResetAfter - w.written
+			if left <= 0 {
+				// Drop the connection with the body incomplete
+				w.flush()
+				panic(http.ErrAbortHandler)
+			}
+			if int64(len(chunk)) > left {
+				chunk = chunk[:left]
+			}
+		}
+
+		n, err := w.ResponseWriter.Write(chunk)
+		w.written += int64(n)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		if w.fault.ChunkDelay > 0 {
+			w.flush()
+			select {
+			case <-time.After(w.fault.ChunkDelay):
+			case <-w.request.Context().Done():
+				return total, w.request.Context().Err()
+			}
+		}
+	}
+	return total, nil
+}
+
+func (w *faultWriter) flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func writeStatus(w http.ResponseWriter, status int) {
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+func writeErrno(w http.ResponseWriter, errno int) {
+	writeJSON(w, map[string]interface{}{
+		"errno":  errno,
+		"errmsg": fmt.Sprintf("fake terabox errno %d", errno),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
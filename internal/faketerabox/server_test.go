@@ -0,0 +1,247 @@
+package faketerabox
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func getJSON(t *testing.T, rawURL string) map[string]interface{} {
+	t.Helper()
+
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response from %s: %v", rawURL, err)
+	}
+	return body
+}
+
+func TestServer_List(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	server.AddShare(&Share{
+		Surl: "1folder",
+		Files: []*File{
+			{Path: "readme.txt", Content: []byte("hi")},
+			{Path: "Season 1/ep01.mkv", Content: []byte("one")},
+			{Path: "Season 1/extras/bonus.mkv", Content: []byte("bonus")},
+		},
+	})
+
+	tests := []struct {
+		dir      string
+		expected []string
+	}{
+		{"/", []string{"readme.txt", "Season 1"}},
+		{"/Season 1", []string{"ep01.mkv", "extras"}},
+		{"/Season 1/extras", []string{"bonus.mkv"}},
+	}
+
+	for _, tt := range tests {
+		body := getJSON(t, server.URL+EndpointList+"?surl=1folder&dir="+url.QueryEscape(tt.dir))
+		list, _ := body["list"].([]interface{})
+		if len(list) != len(tt.expected) {
+			t.Fatalf("dir %s: expected %d entries, got %d", tt.dir, len(tt.expected), len(list))
+		}
+		for i, entry := range list {
+			if name := entry.(map[string]interface{})["server_filename"]; name != tt.expected[i] {
+				t.Errorf("dir %s: expected entry %d to be %q, got %q", tt.dir, i, tt.expected[i], name)
+			}
+		}
+	}
+
+	if body := getJSON(t, server.URL+EndpointList+"?surl=missing&dir=/"); body["errno"] != float64(ErrnoShareNotFound) {
+		t.Errorf("Expected errno %d for unknown share, got %v", ErrnoShareNotFound, body["errno"])
+	}
+}
+
+func TestServer_Password(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	server.AddShare(&Share{
+		Surl:     "1locked",
+		Password: "x7k2",
+		Files:    []*File{{Path: "secret.bin", Content: []byte("secret")}},
+	})
+
+	if body := getJSON(t, server.URL+EndpointShareDownload+"?surl=1locked"); body["errno"] != float64(ErrnoPasswordRequired) {
+		t.Errorf("Expected errno %d without share key, got %v", ErrnoPasswordRequired, body["errno"])
+	}
+
+	resp, err := http.PostForm(server.URL+EndpointShareVerify+"?surl=1locked", url.Values{"pwd": {"x7k2"}})
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	resp.Body.Close()
+
+	body := getJSON(t, server.URL+EndpointShareDownload+"?surl=1locked&sekey="+ShareKey("1locked"))
+	if body["errno"] != float64(0) || body["filename"] != "secret.bin" {
+		t.Errorf("Expected unlocked share to resolve, got %v", body)
+	}
+}
+
+func TestServer_Faults(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	content := make([]byte, 100*1024)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	server.AddShare(&Share{Surl: "1file", Files: []*File{{Path: "data.bin", Content: content}}})
+
+	t.Run("errno", func(t *testing.T) {
+		server.InjectFault(EndpointShareDownload, Fault{Errno: -6, Times: 1})
+
+		if body := getJSON(t, server.URL+EndpointShareDownload+"?surl=1file"); body["errno"] != float64(-6) {
+			t.Errorf("Expected injected errno -6, got %v", body["errno"])
+		}
+		// The fault was used up
+		if body := getJSON(t, server.URL+EndpointShareDownload+"?surl=1file"); body["errno"] != float64(0) {
+			t.Errorf("Expected fault to be used up, got errno %v", body["errno"])
+		}
+	})
+
+	body := getJSON(t, server.URL+EndpointShareDownload+"?surl=1file")
+	dlink := body["dlink"].(string)
+
+	t.Run("status", func(t *testing.T) {
+		server.InjectFault(EndpointFile, Fault{Status: http.StatusTooManyRequests, Times: 1})
+
+		resp, err := http.Get(dlink)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+			t.Errorf("Expected 429 with Retry-After, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", dlink, nil)
+		req.Header.Set("Range", "bytes=1000-1999")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusPartialContent || len(data) != 1000 || data[0] != content[1000] {
+			t.Errorf("Expected 1000 bytes of partial content, got status %d and %d bytes", resp.StatusCode, len(data))
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		server.InjectFault(EndpointFile, Fault{ResetAfter: 40 * 1024, Times: 1})
+
+		resp, err := http.Get(dlink)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(resp.Body)
+		if err == nil {
+			t.Error("Expected the connection to be reset mid-body")
+		}
+		if len(data) != 40*1024 {
+			t.Errorf("Expected %d bytes before the reset, got %d", 40*1024, len(data))
+		}
+	})
+
+	if got := server.Requests(EndpointFile); got != 3 {
+		t.Errorf("Expected 3 file requests, got %d", got)
+	}
+}
+
+func TestServer_ExpireLinks(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	server.AddShare(&Share{Surl: "1file", Files: []*File{{Path: "data.bin", Content: []byte("content")}}})
+
+	status := func(dlink string) int {
+		resp, err := http.Get(dlink)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	stale := getJSON(t, server.URL+EndpointShareDownload+"?surl=1file")["dlink"].(string)
+	server.ExpireLinks()
+
+	if got := status(stale); got != http.StatusForbidden {
+		t.Errorf("Expected 403 for an expired link, got %d", got)
+	}
+
+	fresh := getJSON(t, server.URL+EndpointShareDownload+"?surl=1file")["dlink"].(string)
+	if fresh == stale {
+		t.Fatal("Expected resolving again to hand out a new link")
+	}
+	if got := status(fresh); got != http.StatusOK {
+		t.Errorf("Expected 200 for a fresh link, got %d", got)
+	}
+}
+
+func TestServer_Accounts(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	account := server.AddAccount(&Account{BDUSS: "session-1", Username: "alice", VIP: true})
+
+	userInfo := func(bduss string) (map[string]interface{}, []*http.Cookie) {
+		req, _ := http.NewRequest("GET", server.URL+EndpointUserInfo, nil)
+		req.AddCookie(&http.Cookie{Name: "BDUSS", Value: bduss})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode user info: %v", err)
+		}
+		return body, resp.Cookies()
+	}
+
+	body, _ := userInfo("session-1")
+	data, _ := body["data"].(map[string]interface{})
+	if body["errno"] != float64(0) || data["display_name"] != "alice" || data["vip_type"] != float64(2) {
+		t.Errorf("Expected alice's VIP account, got %v", body)
+	}
+	if body, _ := userInfo("unknown"); body["errno"] != float64(ErrnoNotLoggedIn) {
+		t.Errorf("Expected errno %d for an unknown session, got %v", ErrnoNotLoggedIn, body["errno"])
+	}
+
+	// Rotated cookies are sent once and the new BDUSS replaces the old one
+	account.RotateCookies(map[string]string{"BDUSS": "session-2"})
+	if _, cookies := userInfo("session-1"); len(cookies) != 1 || cookies[0].Value != "session-2" {
+		t.Errorf("Expected the rotated BDUSS, got %v", cookies)
+	}
+	if body, _ := userInfo("session-1"); body["errno"] != float64(ErrnoNotLoggedIn) {
+		t.Errorf("Expected the old session to be signed out, got %v", body)
+	}
+	if body, cookies := userInfo("session-2"); body["errno"] != float64(0) || len(cookies) != 0 {
+		t.Errorf("Expected the rotated session without new cookies, got %v and %v", body, cookies)
+	}
+
+	account.Revoke()
+	if body, _ := userInfo("session-2"); body["errno"] != float64(ErrnoNotLoggedIn) {
+		t.Errorf("Expected the revoked session to be signed out, got %v", body)
+	}
+}
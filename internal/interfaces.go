@@ -4,21 +4,22 @@ import "context"
 
 // LinkResolver handles Terabox URL resolution
 type LinkResolver interface {
-	ResolvePublicLink(url string) (*FileMetadata, error)
-	ResolvePrivateLink(url string, auth *AuthContext) (*FileMetadata, error)
+	ResolvePublicLink(ctx context.Context, url string) (*FileMetadata, error)
+	ResolvePrivateLink(ctx context.Context, url string, auth *AuthContext) (*FileMetadata, error)
 }
 
 // DownloadEngine manages multi-threaded downloads
 type DownloadEngine interface {
-	Download(meta *FileMetadata, config *DownloadConfig) error
-	Resume(partialPath string, config *DownloadConfig) error
+	Download(ctx context.Context, meta *FileMetadata, config *DownloadConfig) error
+	Resume(ctx context.Context, partialPath string, config *DownloadConfig) error
 }
 
 // AuthManager handles authentication and session management
 type AuthManager interface {
 	LoadCookies(path string) (*AuthContext, error)
 	ValidateSession(auth *AuthContext) error
-	RefreshSession(auth *AuthContext) error
+	CheckSession(ctx context.Context, auth *AuthContext) error
+	RefreshSession(ctx context.Context, auth *AuthContext) error
 }
 
 // RateLimiter controls bandwidth usage
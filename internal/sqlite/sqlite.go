@@ -0,0 +1,535 @@
+// Package sqlite reads tables from SQLite database files. It only supports
+// what reading a browser's cookie store needs: rowid tables in UTF-8
+// databases, including changes still in the write-ahead log. The files are
+// read into memory, so a database the browser is using can be read as is.
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// headerMagic starts every SQLite database file
+const headerMagic = "SQLite format 3\x00"
+
+// Page types of table b-trees
+const (
+	pageInteriorTable = 0x05
+	pageLeafTable     = 0x0d
+)
+
+// ErrNoTable is returned for tables that are not in the database
+var ErrNoTable = errors.New("no such table")
+
+// DB is a read-only SQLite database
+type DB struct {
+	data       []byte
+	pageSize   int
+	usableSize int
+	wal        map[uint32][]byte // Pages replaced by committed frames of the write-ahead log
+	tables     map[string]*table
+}
+
+// table is an entry of the schema
+type table struct {
+	rootPage uint32
+	columns  []string
+	real     []bool // Columns with REAL affinity, whose whole numbers are stored as integers
+	rowidCol int    // Index of the INTEGER PRIMARY KEY column that aliases the rowid, or -1
+}
+
+// Row is a table row by column name. Values are nil, int64, float64, string
+// or []byte.
+type Row map[string]interface{}
+
+// Open reads the database at path together with its write-ahead log, if any
+func Open(path string) (*DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	wal, err := os.ReadFile(path + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(wal) > 0 {
+		db.wal = readWAL(wal, db.pageSize)
+	}
+
+	if err := db.readSchema(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+// parse checks the database header
+func parse(data []byte) (*DB, error) {
+	if len(data) < 100 || string(data[:16]) != headerMagic {
+		return nil, fmt.Errorf("not a SQLite database")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+	if encoding := binary.BigEndian.Uint32(data[56:60]); encoding > 1 {
+		return nil, fmt.Errorf("unsupported text encoding %d, only UTF-8 is supported", encoding)
+	}
+
+	return &DB{
+		data:       data,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(data[20]),
+	}, nil
+}
+
+// readWAL returns the pages of the committed frames of a write-ahead log.
+// Frames after the last commit, or left over from before the log was last
+// reset, are ignored.
+func readWAL(wal []byte, pageSize int) map[uint32][]byte {
+	const headerSize, frameHeaderSize = 32, 24
+	if len(wal) < headerSize {
+		return nil
+	}
+
+	magic := binary.BigEndian.Uint32(wal[0:4])
+	if magic != 0x377f0682 && magic != 0x377f0683 {
+		return nil
+	}
+	if int(binary.BigEndian.Uint32(wal[8:12])) != pageSize {
+		return nil
+	}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if magic&1 == 1 {
+		order = binary.BigEndian
+	}
+
+	// Checksums run over the header and then every frame in turn
+	var s0, s1 uint32
+	checksum := func(b []byte) {
+		for i := 0; i+8 <= len(b); i += 8 {
+			s0 += order.Uint32(b[i:]) + s1
+			s1 += order.Uint32(b[i+4:]) + s0
+		}
+	}
+	checksum(wal[:24])
+	if s0 != binary.BigEndian.Uint32(wal[24:28]) || s1 != binary.BigEndian.Uint32(wal[28:32]) {
+		return nil
+	}
+	salt := wal[16:24]
+
+	committed := make(map[uint32][]byte)
+	pending := make(map[uint32][]byte)
+	for offset := headerSize; offset+frameHeaderSize+pageSize <= len(wal); offset += frameHeaderSize + pageSize {
+		frame := wal[offset : offset+frameHeaderSize]
+		end := offset + frameHeaderSize + pageSize
+		page := wal[offset+frameHeaderSize : end : end]
+		if !bytes.Equal(frame[8:16], salt) {
+			break
+		}
+		checksum(frame[:8])
+		checksum(page)
+		if s0 != binary.BigEndian.Uint32(frame[16:20]) || s1 != binary.BigEndian.Uint32(frame[20:24]) {
+			break
+		}
+
+		pending[binary.BigEndian.Uint32(frame[0:4])] = page
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			for number, data := range pending {
+				committed[number] = data
+			}
+			pending = make(map[uint32][]byte)
+		}
+	}
+	return committed
+}
+
+// page returns page number n, counting from 1
+func (db *DB) page(n uint32) ([]byte, error) {
+	if page, ok := db.wal[n]; ok {
+		return page, nil
+	}
+	start := int64(n-1) * int64(db.pageSize)
+	if n == 0 || start+int64(db.pageSize) > int64(len(db.data)) {
+		return nil, fmt.Errorf("page %d is out of range", n)
+	}
+	// Cap the slice so a read past the page cannot reach the next one
+	end := start + int64(db.pageSize)
+	return db.data[start:end:end], nil
+}
+
+// readSchema reads the tables of the database from sqlite_schema
+func (db *DB) readSchema() error {
+	db.tables = make(map[string]*table)
+	return db.walk(1, func(rowid int64, values []interface{}) error {
+		if len(values) < 5 {
+			return nil
+		}
+		kind, _ := values[0].(string)
+		name, _ := values[1].(string)
+		root, _ := values[3].(int64)
+		sql, _ := values[4].(string)
+		if kind != "table" || root <= 0 {
+			return nil
+		}
+
+		t := parseTable(sql)
+		t.rootPage = uint32(root)
+		db.tables[strings.ToLower(name)] = t
+		return nil
+	})
+}
+
+// Rows returns every row of a table
+func (db *DB) Rows(name string) ([]Row, error) {
+	t, ok := db.tables[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoTable, name)
+	}
+
+	var rows []Row
+	err := db.walk(t.rootPage, func(rowid int64, values []interface{}) error {
+		row := make(Row, len(t.columns))
+		for i, column := range t.columns {
+			// Columns added after the row was written are missing from its record
+			var value interface{}
+			if i < len(values) {
+				value = values[i]
+			}
+			if n, ok := value.(int64); ok && t.real[i] {
+				value = float64(n)
+			}
+			row[column] = value
+		}
+		if t.rowidCol >= 0 {
+			row[t.columns[t.rowidCol]] = rowid
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read table %s: %w", name, err)
+	}
+	return rows, nil
+}
+
+// walk calls fn with the record of every row of the table b-tree at root
+func (db *DB) walk(root uint32, fn func(rowid int64, values []interface{}) error) error {
+	visited := make(map[uint32]bool)
+	var visit func(n uint32) error
+	visit = func(n uint32) error {
+		if visited[n] {
+			return fmt.Errorf("page %d is referenced twice", n)
+		}
+		visited[n] = true
+
+		page, err := db.page(n)
+		if err != nil {
+			return err
+		}
+		header := page
+		if n == 1 {
+			header = page[100:]
+		}
+
+		cells := int(binary.BigEndian.Uint16(header[3:5]))
+		switch header[0] {
+		case pageInteriorTable:
+			pointers := header[12:]
+			for i := 0; i < cells; i++ {
+				// An interior cell starts with the 4-byte left child pointer
+				cell, err := cellAt(page, pointers, i, 4)
+				if err != nil {
+					return fmt.Errorf("page %d: %w", n, err)
+				}
+				if err := visit(binary.BigEndian.Uint32(cell[:4])); err != nil {
+					return err
+				}
+			}
+			return visit(binary.BigEndian.Uint32(header[8:12]))
+
+		case pageLeafTable:
+			pointers := header[8:]
+			for i := 0; i < cells; i++ {
+				cell, err := cellAt(page, pointers, i, 1)
+				if err != nil {
+					return fmt.Errorf("page %d: %w", n, err)
+				}
+				rowid, values, err := db.leafCell(cell)
+				if err != nil {
+					return fmt.Errorf("page %d: %w", n, err)
+				}
+				if err := fn(rowid, values); err != nil {
+					return err
+				}
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("page %d is not a table b-tree page (type %#x)", n, header[0])
+		}
+	}
+	return visit(root)
+}
+
+// cellAt returns the content of cell i of a page from its cell pointer array,
+// which must leave at least minSize bytes of the page for the cell
+func cellAt(page, pointers []byte, i, minSize int) ([]byte, error) {
+	if 2*i+2 > len(pointers) {
+		return nil, fmt.Errorf("cell pointer %d is out of range", i)
+	}
+	offset := int(binary.BigEndian.Uint16(pointers[2*i:]))
+	if offset < 4 || offset+minSize > len(page) {
+		return nil, fmt.Errorf("cell offset %d is out of range", offset)
+	}
+	return page[offset:], nil
+}
+
+// leafCell decodes a table leaf cell into its rowid and record
+func (db *DB) leafCell(cell []byte) (int64, []interface{}, error) {
+	size, n := varint(cell)
+	rowid, m := varint(cell[n:])
+	if n == 0 || m == 0 {
+		return 0, nil, fmt.Errorf("truncated cell")
+	}
+	cell = cell[n+m:]
+
+	// No payload is larger than the database it is stored in
+	if size > uint64(len(db.data))+uint64(len(db.wal)*db.pageSize) {
+		return 0, nil, fmt.Errorf("payload size %d is out of range", size)
+	}
+	payload, err := db.payload(cell, int(size))
+	if err != nil {
+		return 0, nil, err
+	}
+	values, err := record(payload)
+	return int64(rowid), values, err
+}
+
+// payload returns a cell's payload of the given size, following its overflow pages
+func (db *DB) payload(cell []byte, size int) ([]byte, error) {
+	u := db.usableSize
+	maxLocal := u - 35
+	if size <= maxLocal {
+		if size > len(cell) {
+			return nil, fmt.Errorf("payload extends past the page")
+		}
+		return cell[:size], nil
+	}
+
+	minLocal := (u-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(u-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	if local+4 > len(cell) {
+		return nil, fmt.Errorf("payload extends past the page")
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	visited := make(map[uint32]bool)
+	for len(payload) < size {
+		if next == 0 || visited[next] {
+			return nil, fmt.Errorf("broken overflow chain")
+		}
+		visited[next] = true
+		page, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := page[4:u]
+		if remaining := size - len(payload); len(chunk) > remaining {
+			chunk = chunk[:remaining]
+		}
+		payload = append(payload, chunk...)
+		next = binary.BigEndian.Uint32(page[:4])
+	}
+	return payload, nil
+}
+
+// record decodes a record into its values
+func record(payload []byte) ([]interface{}, error) {
+	headerSize, n := varint(payload)
+	if n == 0 || headerSize < uint64(n) || headerSize > uint64(len(payload)) {
+		return nil, fmt.Errorf("invalid record header")
+	}
+	header := payload[n:headerSize]
+	body := payload[headerSize:]
+
+	var values []interface{}
+	for len(header) > 0 {
+		serialType, m := varint(header)
+		header = header[m:]
+
+		size := serialSize(serialType)
+		if size > uint64(len(body)) {
+			return nil, fmt.Errorf("record value extends past the payload")
+		}
+		data := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType <= 6:
+			values = append(values, bigEndianInt(data))
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(data)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType >= 12 && serialType%2 == 0:
+			values = append(values, append([]byte(nil), data...))
+		case serialType >= 13:
+			values = append(values, string(data))
+		default:
+			return nil, fmt.Errorf("invalid serial type %d", serialType)
+		}
+	}
+	return values, nil
+}
+
+// serialSize returns the size in bytes of a value of the given serial type.
+// It is kept unsigned, as a corrupt serial type may not fit in an int.
+func serialSize(serialType uint64) uint64 {
+	switch {
+	case serialType >= 12:
+		return (serialType - 12) / 2
+	case serialType == 5:
+		return 6
+	case serialType == 6, serialType == 7:
+		return 8
+	case serialType >= 1 && serialType <= 4:
+		return serialType
+	default:
+		return 0
+	}
+}
+
+// bigEndianInt decodes a signed big-endian integer of 1 to 8 bytes
+func bigEndianInt(data []byte) int64 {
+	var v int64
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		v = -1
+	}
+	for _, b := range data {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+// varint decodes a SQLite variable-length integer and returns it with its size
+func varint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
+
+// parseTable reads the columns of a table from its CREATE TABLE statement
+func parseTable(sql string) *table {
+	t := &table{rowidCol: -1}
+	start, end := strings.Index(sql, "("), strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return t
+	}
+
+	for _, def := range splitDefinitions(sql[start+1 : end]) {
+		def = strings.TrimSpace(def)
+		name, rest := splitIdentifier(def)
+		if name == "" {
+			continue
+		}
+		// Table constraints start with a keyword, which a column name can only be if quoted
+		if quoted := strings.IndexByte("\"`'[", def[0]) >= 0; !quoted {
+			switch strings.ToUpper(name) {
+			case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+				continue
+			}
+		}
+
+		fields := strings.Fields(strings.ToUpper(rest))
+		if len(fields) > 0 && fields[0] == "INTEGER" && strings.Contains(strings.Join(fields, " "), "PRIMARY KEY") {
+			t.rowidCol = len(t.columns)
+		}
+		t.columns = append(t.columns, name)
+		t.real = append(t.real, len(fields) > 0 && hasRealAffinity(fields[0]))
+	}
+	return t
+}
+
+// hasRealAffinity applies SQLite's affinity rules to a declared column type
+func hasRealAffinity(declared string) bool {
+	for _, other := range []string{"INT", "CHAR", "CLOB", "TEXT", "BLOB"} {
+		if strings.Contains(declared, other) {
+			return false
+		}
+	}
+	return strings.Contains(declared, "REAL") || strings.Contains(declared, "FLOA") || strings.Contains(declared, "DOUB")
+}
+
+// splitIdentifier splits the leading, possibly quoted, identifier off s
+func splitIdentifier(s string) (string, string) {
+	if s == "" {
+		return "", ""
+	}
+	closing := map[byte]byte{'"': '"', '`': '`', '\'': '\'', '[': ']'}[s[0]]
+	if closing != 0 {
+		if end := strings.IndexByte(s[1:], closing); end >= 0 {
+			return s[1 : end+1], s[end+2:]
+		}
+	}
+	if end := strings.IndexAny(s, " \t\n"); end >= 0 {
+		return s[:end], s[end:]
+	}
+	return s, ""
+}
+
+// splitDefinitions splits the body of a CREATE TABLE statement at the commas
+// that are not inside parentheses or quotes
+func splitDefinitions(body string) []string {
+	var defs []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range body {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			defs = append(defs, body[start:i])
+			start = i + 1
+		}
+	}
+	return append(defs, body[start:])
+}
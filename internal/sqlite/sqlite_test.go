@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The databases in testdata are written by SQLite itself, see generate.py
+
+func TestRows(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "people.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	rows, err := db.Rows("people")
+	if err != nil {
+		t.Fatalf("Failed to read rows: %v", err)
+	}
+	if len(rows) != 500 {
+		t.Fatalf("Expected 500 rows, got %d", len(rows))
+	}
+
+	for i, row := range rows {
+		id := int64(i + 1)
+		if row["id"] != id {
+			t.Fatalf("Expected rows in rowid order, row %d has id %v", i, row["id"])
+		}
+		if row["score"] != float64(id)/4 || row["balance"] != -id*100000 {
+			t.Errorf("Row %d: unexpected score %v or balance %v", id, row["score"], row["balance"])
+		}
+		if avatar, _ := row["avatar"].([]byte); !bytes.Equal(avatar, bytes.Repeat([]byte{byte(id)}, 3)) {
+			t.Errorf("Row %d: unexpected avatar %v", id, row["avatar"])
+		}
+
+		switch id {
+		case 7:
+			// Stored mostly on overflow pages
+			if row["name"] != strings.Repeat("x", 5000) || row["nick name"] != "seven" {
+				t.Errorf("Row 7: unexpected name of %d bytes or nick name %v", len(row["name"].(string)), row["nick name"])
+			}
+		default:
+			// Written before the column was added
+			if row["name"] != "person-"+strconv.FormatInt(id, 10) || row["nick name"] != nil {
+				t.Errorf("Row %d: unexpected name %v or nick name %v", id, row["name"], row["nick name"])
+			}
+		}
+	}
+
+	if _, err := db.Rows("missing"); !errors.Is(err, ErrNoTable) {
+		t.Errorf("Expected ErrNoTable, got %v", err)
+	}
+}
+
+func TestRows_WAL(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "wal.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	rows, err := db.Rows("kv")
+	if err != nil {
+		t.Fatalf("Failed to read rows: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["value"] != "from wal" || rows[1]["key"] != "b" {
+		t.Errorf("Expected the rows committed to the WAL, got %v", rows)
+	}
+
+	// Without the log only the checkpointed row is there
+	dir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join("testdata", "wal.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "wal.db"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(filepath.Join(dir, "wal.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	rows, err = db.Rows("kv")
+	if err != nil {
+		t.Fatalf("Failed to read rows: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["value"] != "checkpointed" {
+		t.Errorf("Expected only the checkpointed row, got %v", rows)
+	}
+}
+
+func TestOpen_NotADatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(path, []byte("# Netscape HTTP Cookie File\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "not a SQLite database") {
+		t.Errorf("Expected a not a SQLite database error, got %v", err)
+	}
+}
+
+func TestMalformedCells(t *testing.T) {
+	db := &DB{data: make([]byte, 4*4096), pageSize: 4096, usableSize: 4096}
+
+	tests := []struct {
+		name string
+		cell []byte
+	}{
+		{"empty cell", nil},
+		{"header size of zero", []byte{0x03, 0x01, 0x00, 0x01, 0x08}},
+		{"header size past the payload", []byte{0x03, 0x01, 0x7f, 0x01, 0x08}},
+		{"header size that overflows an int", []byte{0x0b, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x08}},
+		{"negative payload size", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x02, 0x01}},
+		{"payload larger than the database", []byte{0x84, 0x80, 0x80, 0x80, 0x00, 0x01, 0x02, 0x01}},
+		{"serial type that overflows an int", []byte{0x0c, 0x01, 0x0b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x61, 0x62}},
+		{"value past the payload", []byte{0x03, 0x01, 0x02, 0x19, 0x61}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := db.leafCell(tt.cell); err == nil {
+				t.Error("Expected an error for the malformed cell")
+			}
+		})
+	}
+
+	// A well-formed cell still decodes
+	_, values, err := db.leafCell([]byte{0x03, 0x05, 0x02, 0x0f, 0x61})
+	if err != nil || len(values) != 1 || values[0] != "a" {
+		t.Errorf("Expected the value \"a\", got %v (%v)", values, err)
+	}
+}
+
+func TestMalformedInteriorPage(t *testing.T) {
+	const pageSize = 512
+	data := make([]byte, 2*pageSize)
+	page := data[pageSize:]
+	page[0] = pageInteriorTable
+	binary.BigEndian.PutUint16(page[3:5], 1)     // One cell
+	binary.BigEndian.PutUint16(page[12:14], 511) // Too close to the end for its child pointer
+	db := &DB{data: data, pageSize: pageSize, usableSize: pageSize}
+
+	err := db.walk(2, func(int64, []interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Expected a cell offset error, got %v", err)
+	}
+
+	// Pages cannot be read past their end into the next one
+	first, err := db.page(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cap(first) != pageSize {
+		t.Errorf("Expected page 1 to be capped at %d bytes, got %d", pageSize, cap(first))
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	table := parseTable(`CREATE TABLE cookies(creation_utc INTEGER NOT NULL, host_key TEXT NOT NULL, ` +
+		`"top frame" TEXT DEFAULT '', value TEXT, price DECIMAL(10, 2), UNIQUE (host_key, value))`)
+	want := []string{"creation_utc", "host_key", "top frame", "value", "price"}
+	if strings.Join(table.columns, "|") != strings.Join(want, "|") || table.rowidCol != -1 {
+		t.Errorf("Expected %v without a rowid alias, got %v and %d", want, table.columns, table.rowidCol)
+	}
+
+	table = parseTable("CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, name TEXT)")
+	if table.rowidCol != 0 {
+		t.Errorf("Expected id to alias the rowid, got %d", table.rowidCol)
+	}
+}
@@ -0,0 +1,40 @@
+#!/usr/bin/env python3
+"""Generates the test databases. Run from this directory."""
+
+import os
+import shutil
+import sqlite3
+
+for name in ["people.db", "wal.db", "wal.db-wal"]:
+    if os.path.exists(name):
+        os.remove(name)
+
+# Enough rows for interior pages, a row that overflows, and a column added later
+db = sqlite3.connect("people.db")
+db.execute("PRAGMA page_size = 1024")
+db.execute("CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT NOT NULL, "
+           "score REAL, balance INTEGER, avatar BLOB, UNIQUE (name))")
+for i in range(1, 501):
+    db.execute("INSERT INTO people (id, name, score, balance, avatar) VALUES (?, ?, ?, ?, ?)",
+               (i, "person-%d" % i, i / 4, -i * 100000, bytes([i % 256]) * 3))
+db.execute("UPDATE people SET name = ? WHERE id = 7", ("x" * 5000,))
+db.execute('ALTER TABLE people ADD COLUMN "nick name" TEXT')
+db.execute("UPDATE people SET \"nick name\" = 'seven' WHERE id = 7")
+db.commit()
+db.close()
+
+# Rows committed to the write-ahead log but not yet checkpointed
+db = sqlite3.connect("wal-source.db")
+db.execute("PRAGMA journal_mode = wal")
+db.execute("PRAGMA wal_autocheckpoint = 0")
+db.execute("CREATE TABLE kv (key TEXT, value TEXT)")
+db.execute("INSERT INTO kv VALUES ('a', 'checkpointed')")
+db.commit()
+db.execute("PRAGMA wal_checkpoint(TRUNCATE)")
+db.execute("UPDATE kv SET value = 'from wal' WHERE key = 'a'")
+db.execute("INSERT INTO kv VALUES ('b', 'from wal')")
+db.commit()
+shutil.copy("wal-source.db", "wal.db")
+shutil.copy("wal-source.db-wal", "wal.db-wal")
+db.close()
+os.remove("wal-source.db")
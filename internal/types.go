@@ -13,6 +13,50 @@ type FileMetadata struct {
 	ShareID   string    `json:"share_id"`
 	Timestamp time.Time `json:"timestamp"`
 	Checksum  string    `json:"checksum,omitempty"`
+	Path      string    `json:"path,omitempty"` // Relative path inside a folder share
+}
+
+// FileTreeNode represents a file or directory inside a shared folder
+type FileTreeNode struct {
+	Name     string          `json:"name"`
+	Path     string          `json:"path"`
+	IsDir    bool            `json:"is_dir"`
+	File     *FileMetadata   `json:"file,omitempty"`
+	Children []*FileTreeNode `json:"children,omitempty"`
+}
+
+// Files returns the metadata of every file in the tree in depth-first order
+func (n *FileTreeNode) Files() []*FileMetadata {
+	if n == nil {
+		return nil
+	}
+
+	if !n.IsDir {
+		if n.File == nil {
+			return nil
+		}
+		return []*FileMetadata{n.File}
+	}
+
+	var files []*FileMetadata
+	for _, child := range n.Children {
+		files = append(files, child.Files()...)
+	}
+	return files
+}
+
+// FileCount returns the number of files in the tree
+func (n *FileTreeNode) FileCount() int {
+	return len(n.Files())
+}
+
+// TotalSize returns the combined size of every file in the tree
+func (n *FileTreeNode) TotalSize() int64 {
+	var total int64
+	for _, file := range n.Files() {
+		total += file.Size
+	}
+	return total
 }
 
 // DownloadConfig contains configuration for download operations
@@ -22,7 +66,9 @@ type DownloadConfig struct {
 	RateLimit  int64 // bytes per second
 	ProxyURL   string
 	Quiet      bool
+	SkipVerify bool // skip MD5 verification of the finished file
 	ResumeData *ResumeMetadata
+	Source     *ResumeSource // recorded in the resume metadata, may be nil
 }
 
 // AuthContext contains authentication information for Terabox
@@ -32,22 +78,70 @@ type AuthContext struct {
 	STOKEN    string
 	ExpiresAt time.Time
 	UserAgent string
-	Bypass    bool // Indicates if this is a bypass attempt without real authentication
+	Bypass    bool   // Indicates if this is a bypass attempt without real authentication
+	ShareKey  string // Share key (randsk) from the share password handshake, sent as sekey
+	Username  string // Account name reported by the account API, empty until checked online
+	VIP       bool   // Whether the account API reports a premium subscription
 }
 
 // SegmentInfo represents a download segment for multi-threaded downloads
 type SegmentInfo struct {
-	Index     int   `json:"index"`
-	Start     int64 `json:"start"`
-	End       int64 `json:"end"`
-	Completed bool  `json:"completed"`
-	Retries   int   `json:"retries"`
+	Index      int   `json:"index"`
+	Start      int64 `json:"start"`
+	End        int64 `json:"end"`
+	Downloaded int64 `json:"downloaded"` // Bytes already written from Start, checkpointed while downloading
+	Completed  bool  `json:"completed"`
+	Retries    int   `json:"retries"`
 }
 
+// Size returns the number of bytes covered by the segment
+func (s SegmentInfo) Size() int64 {
+	return s.End - s.Start + 1
+}
+
+// Offset returns the absolute file offset the segment resumes from
+func (s SegmentInfo) Offset() int64 {
+	return s.Start + s.Downloaded
+}
+
+// Remaining returns the number of bytes still to be downloaded
+func (s SegmentInfo) Remaining() int64 {
+	if s.Completed {
+		return 0
+	}
+	return s.Size() - s.Downloaded
+}
+
+// ResumeSchemaVersion is the version of the resume metadata format written by
+// this build. Version 1 files have no version field and no source.
+const ResumeSchemaVersion = 2
+
 // ResumeMetadata contains information needed to resume interrupted downloads
 type ResumeMetadata struct {
+	Version      int           `json:"version"`
 	FileMetadata *FileMetadata `json:"file_metadata"`
+	Source       *ResumeSource `json:"source,omitempty"`
 	Segments     []SegmentInfo `json:"segments"`
 	CreatedAt    time.Time     `json:"created_at"`
 	LastUpdate   time.Time     `json:"last_update"`
+}
+
+// Auth modes recorded in ResumeSource, matching how a share was resolved
+const (
+	AuthModePublic   = "public"
+	AuthModeCookies  = "cookies"
+	AuthModePassword = "password"
+	AuthModeBypass   = "bypass"
+)
+
+// ResumeSource records where a download came from and how it was started, so
+// resume can re-resolve the share and reproduce the original settings. Empty
+// fields are unknown, as in files migrated from version 1.
+type ResumeSource struct {
+	ShareURL       string `json:"share_url,omitempty"`
+	AuthMode       string `json:"auth_mode,omitempty"`       // public, cookies, password or bypass
+	CookiesPath    string `json:"cookies_path,omitempty"`    // Cookie file used for the cookies auth mode
+	CookiesBrowser string `json:"cookies_browser,omitempty"` // Browser profile the cookies were read from instead, as browser[:profile]
+	Threads        int    `json:"threads,omitempty"`
+	OutputPath     string `json:"output_path,omitempty"`
 }
\ No newline at end of file
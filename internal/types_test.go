@@ -0,0 +1,80 @@
+package internal
+
+import "testing"
+
+func TestFileTreeNode_Files(t *testing.T) {
+	tree := &FileTreeNode{
+		Name:  "share",
+		IsDir: true,
+		Children: []*FileTreeNode{
+			{
+				Name:  "Season 1",
+				Path:  "Season 1",
+				IsDir: true,
+				Children: []*FileTreeNode{
+					{Name: "ep01.mkv", Path: "Season 1/ep01.mkv", File: &FileMetadata{Filename: "ep01.mkv", Size: 100}},
+					{Name: "ep02.mkv", Path: "Season 1/ep02.mkv", File: &FileMetadata{Filename: "ep02.mkv", Size: 200}},
+				},
+			},
+			{Name: "empty", Path: "empty", IsDir: true},
+			{Name: "notes.txt", Path: "notes.txt", File: &FileMetadata{Filename: "notes.txt", Size: 3}},
+		},
+	}
+
+	files := tree.Files()
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %d", len(files))
+	}
+
+	expectedOrder := []string{"ep01.mkv", "ep02.mkv", "notes.txt"}
+	for i, file := range files {
+		if file.Filename != expectedOrder[i] {
+			t.Errorf("File %d: expected %s, got %s", i, expectedOrder[i], file.Filename)
+		}
+	}
+
+	if tree.FileCount() != 3 {
+		t.Errorf("Expected file count 3, got %d", tree.FileCount())
+	}
+
+	if tree.TotalSize() != 303 {
+		t.Errorf("Expected total size 303, got %d", tree.TotalSize())
+	}
+}
+
+func TestFileTreeNode_NilAndEmpty(t *testing.T) {
+	var nilTree *FileTreeNode
+	if files := nilTree.Files(); len(files) != 0 {
+		t.Errorf("Expected no files for nil tree, got %d", len(files))
+	}
+
+	empty := &FileTreeNode{IsDir: true}
+	if empty.FileCount() != 0 || empty.TotalSize() != 0 {
+		t.Error("Expected empty tree to have no files and zero size")
+	}
+
+	// A file node without metadata is ignored rather than returned as nil
+	broken := &FileTreeNode{Name: "broken"}
+	if files := broken.Files(); len(files) != 0 {
+		t.Errorf("Expected no files for node without metadata, got %d", len(files))
+	}
+}
+
+func TestSegmentInfo_Offsets(t *testing.T) {
+	segment := SegmentInfo{Start: 100, End: 199, Downloaded: 40}
+
+	if segment.Size() != 100 {
+		t.Errorf("Expected size 100, got %d", segment.Size())
+	}
+	if segment.Offset() != 140 {
+		t.Errorf("Expected resume offset 140, got %d", segment.Offset())
+	}
+	if segment.Remaining() != 60 {
+		t.Errorf("Expected 60 bytes remaining, got %d", segment.Remaining())
+	}
+
+	segment.Completed = true
+	if segment.Remaining() != 0 {
+		t.Errorf("Expected no bytes remaining for a completed segment, got %d", segment.Remaining())
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"terafetch/internal"
+)
+
+// EventType identifies the kind of a machine-readable output event
+type EventType string
+
+const (
+	// EventResolved reports the file metadata a share URL resolved to
+	EventResolved EventType = "resolved"
+	// EventStart reports that a download is starting
+	EventStart EventType = "start"
+	// EventProgress reports periodic download progress
+	EventProgress EventType = "progress"
+	// EventRetry reports that a failed download or segment is being retried
+	EventRetry EventType = "retry"
+	// EventComplete reports a finished download with its summary
+	EventComplete EventType = "complete"
+	// EventError reports a failure as a serialized TeraboxError
+	EventError EventType = "error"
+)
+
+// progressEventInterval is the minimum time between two progress events for a download
+const progressEventInterval = time.Second
+
+// Event is a single machine-readable output event. Fields that don't apply to
+// the event type are omitted from the JSON.
+type Event struct {
+	Type     EventType              `json:"event"`
+	Time     time.Time              `json:"time"`
+	URL      string                 `json:"url,omitempty"`
+	File     string                 `json:"file,omitempty"` // Output path of the download
+	Metadata *internal.FileMetadata `json:"metadata,omitempty"`
+
+	// Start
+	Threads  int  `json:"threads,omitempty"`
+	Segments int  `json:"segments,omitempty"`
+	Resumed  bool `json:"resumed,omitempty"`
+
+	// Progress
+	Downloaded int64   `json:"downloaded,omitempty"`
+	Total      int64   `json:"total,omitempty"`
+	Percent    float64 `json:"percent,omitempty"`
+	Speed      float64 `json:"speed,omitempty"` // bytes per second
+
+	// Retry
+	Attempt int     `json:"attempt,omitempty"`
+	Segment *int    `json:"segment,omitempty"`
+	Delay   float64 `json:"delay_seconds,omitempty"`
+
+	Summary *DownloadSummary       `json:"summary,omitempty"`
+	Error   *internal.TeraboxError `json:"error,omitempty"`
+}
+
+// EventWriter writes events as JSON lines. Methods on a nil *EventWriter do
+// nothing, so callers can emit unconditionally.
+type EventWriter struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewEventWriter creates an EventWriter that writes one JSON object per line to w
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{encoder: json.NewEncoder(w)}
+}
+
+// Emit writes an event, stamping it with the current time if unset
+func (w *EventWriter) Emit(event Event) {
+	if w == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.encoder.Encode(&event); err != nil {
+		internal.LogWarn("Failed to write %s event: %v", event.Type, err)
+	}
+}
+
+// EmitError writes an error event for err, converting it to a TeraboxError
+func (w *EventWriter) EmitError(url, file string, err error) {
+	if w == nil || err == nil {
+		return
+	}
+	w.Emit(Event{Type: EventError, URL: url, File: file, Error: internal.AsTeraboxError(err)})
+}
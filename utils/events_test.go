@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"terafetch/internal"
+)
+
+// decodeEvents parses the JSON lines written by an EventWriter
+func decodeEvents(t *testing.T, output string) []Event {
+	t.Helper()
+
+	var events []Event
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventWriter_Emit(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewEventWriter(&buf)
+
+	writer.Emit(Event{
+		Type:     EventResolved,
+		URL:      "https://terabox.com/s/1AbC123",
+		Metadata: &internal.FileMetadata{Filename: "movie.mkv", Size: 1024},
+	})
+	segment := 2
+	writer.Emit(Event{Type: EventRetry, Attempt: 1, Segment: &segment, Delay: 1})
+
+	events := decodeEvents(t, buf.String())
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	resolved := events[0]
+	if resolved.Type != EventResolved || resolved.Metadata == nil || resolved.Metadata.Filename != "movie.mkv" {
+		t.Errorf("Unexpected resolved event: %+v", resolved)
+	}
+	if resolved.Time.IsZero() {
+		t.Error("Expected event to be timestamped")
+	}
+
+	retry := events[1]
+	if retry.Type != EventRetry || retry.Segment == nil || *retry.Segment != 2 || retry.Delay != 1 {
+		t.Errorf("Unexpected retry event: %+v", retry)
+	}
+
+	// Fields that don't apply to an event are left out
+	if strings.Contains(buf.String(), `"summary"`) || strings.Contains(buf.String(), `"error"`) {
+		t.Errorf("Expected empty fields to be omitted, got %s", buf.String())
+	}
+}
+
+func TestEventWriter_EmitError(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewEventWriter(&buf)
+
+	writer.EmitError("https://terabox.com/s/1AbC123", "", nil)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no event for a nil error, got %s", buf.String())
+	}
+
+	cause := internal.NewTeraboxError(429, "too many requests", internal.ErrRateLimit)
+	writer.EmitError("https://terabox.com/s/1AbC123", "movie.mkv", errors.Join(errors.New("download failed"), cause))
+
+	events := decodeEvents(t, buf.String())
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Type != EventError || event.File != "movie.mkv" || event.Error == nil {
+		t.Fatalf("Unexpected error event: %+v", event)
+	}
+	if event.Error.Code != 429 || event.Error.Type != internal.ErrRateLimit {
+		t.Errorf("Expected the wrapped TeraboxError, got %+v", event.Error)
+	}
+	if !strings.Contains(buf.String(), `"type":"RateLimit"`) {
+		t.Errorf("Expected error type to be serialized by name, got %s", buf.String())
+	}
+}
+
+func TestEventWriter_Nil(t *testing.T) {
+	var writer *EventWriter
+
+	// Emitting on a nil writer is a no-op
+	writer.Emit(Event{Type: EventStart})
+	writer.EmitError("", "", errors.New("failed"))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // FileOperations provides file system utilities
@@ -95,4 +96,20 @@ func (f *FileOperations) CreatePartialFile(partPath string, size int64) (err err
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
+
+// SafeJoin joins a slash-separated relative path from a remote share onto a
+// local base directory, rejecting paths that would escape the base directory
+func SafeJoin(base, rel string) (string, error) {
+	rel = strings.ReplaceAll(rel, "\\", "/")
+	cleaned := filepath.Clean(filepath.FromSlash(strings.TrimLeft(rel, "/")))
+	if cleaned == "." || cleaned == "" {
+		return "", fmt.Errorf("empty relative path: %q", rel)
+	}
+
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path escapes output directory: %q", rel)
+	}
+
+	return filepath.Join(base, cleaned), nil
+}
@@ -348,4 +348,43 @@ func TestFileOperations_ExistingMethods(t *testing.T) {
 			t.Errorf("File content mismatch after rename")
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestSafeJoin(t *testing.T) {
+	base := filepath.Join("downloads", "share")
+
+	tests := []struct {
+		name        string
+		rel         string
+		expected    string
+		expectError bool
+	}{
+		{"simple_file", "a.txt", filepath.Join(base, "a.txt"), false},
+		{"nested_path", "Season 1/ep01.mkv", filepath.Join(base, "Season 1", "ep01.mkv"), false},
+		{"leading_slash", "/dir/file.bin", filepath.Join(base, "dir", "file.bin"), false},
+		{"backslashes", "dir\\file.bin", filepath.Join(base, "dir", "file.bin"), false},
+		{"inner_dotdot", "dir/../file.bin", filepath.Join(base, "file.bin"), false},
+		{"escape_parent", "../file.bin", "", true},
+		{"escape_nested", "dir/../../file.bin", "", true},
+		{"empty", "", "", true},
+		{"dot", ".", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SafeJoin(base, tt.rel)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %q", tt.rel, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -8,12 +8,14 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/net/proxy"
+	"golang.org/x/net/publicsuffix"
 
 	"terafetch/internal"
 )
@@ -29,20 +31,48 @@ type RetryConfig struct {
 
 // DefaultRetryConfig returns the default retry configuration
 func DefaultRetryConfig() *RetryConfig {
+	return RetryConfigFromConfig(internal.DefaultConfig())
+}
+
+// RetryConfigFromConfig returns the retry policy of the application configuration
+func RetryConfigFromConfig(config *internal.Config) *RetryConfig {
 	return &RetryConfig{
-		MaxAttempts:   3,
-		BaseDelay:     1 * time.Second,
-		MaxDelay:      30 * time.Second,
+		MaxAttempts:   config.MaxRetries + 1,
+		BaseDelay:     time.Duration(config.RetryDelay) * time.Second,
+		MaxDelay:      time.Duration(config.MaxRetryDelay) * time.Second,
 		Multiplier:    2.0,
 		JitterPercent: 0.1,
 	}
 }
 
+// Delay calculates the delay before the given retry attempt, counting from 1
+func (r *RetryConfig) Delay(attempt int) time.Duration {
+	// Exponential backoff: baseDelay * multiplier^(attempt-1)
+	delay := float64(r.BaseDelay) * math.Pow(r.Multiplier, float64(attempt-1))
+	
+	// Apply jitter (random variation)
+	jitter := delay * r.JitterPercent * (rand.Float64()*2 - 1) // -jitterPercent to +jitterPercent
+	delay += jitter
+	
+	// Ensure delay doesn't exceed maximum
+	if delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
+	}
+	
+	// Ensure delay is not negative
+	if delay < 0 {
+		delay = float64(r.BaseDelay)
+	}
+	
+	return time.Duration(delay)
+}
+
 // HTTPClientConfig contains configuration for the HTTP client
 type HTTPClientConfig struct {
 	Timeout     time.Duration
 	ProxyURL    string
 	RetryConfig *RetryConfig
+	UserAgents  []string // User agents to rotate through, the defaults when empty
 }
 
 // HTTPClient provides a custom HTTP client with retry logic and user-agent rotation
@@ -53,25 +83,25 @@ type HTTPClient struct {
 	userAgentIdx int
 	mutex        sync.RWMutex
 	retryConfig  *RetryConfig
+	cookies      map[*http.Cookie]bool // Cookies already added to the jar
 }
 
 // Predefined user agent strings for rotation
-var defaultUserAgents = []string{
-	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
-	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
-	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/120.0",
-	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:109.0) Gecko/20100101 Firefox/120.0",
-	"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/120.0",
-}
+var defaultUserAgents = internal.DefaultUserAgents
 
 // NewHTTPClient creates a new HTTP client with default configuration
 func NewHTTPClient() *HTTPClient {
+	return NewHTTPClientFromConfig(internal.DefaultConfig(), "")
+}
+
+// NewHTTPClientFromConfig creates a new HTTP client with the timeout, retry
+// policy and user agents of the application configuration
+func NewHTTPClientFromConfig(config *internal.Config, proxyURL string) *HTTPClient {
 	return NewHTTPClientWithConfig(&HTTPClientConfig{
-		Timeout:     30 * time.Second,
-		RetryConfig: DefaultRetryConfig(),
+		Timeout:     time.Duration(config.DefaultTimeout) * time.Second,
+		ProxyURL:    proxyURL,
+		RetryConfig: RetryConfigFromConfig(config),
+		UserAgents:  config.UserAgentList,
 	})
 }
 
@@ -81,13 +111,25 @@ func NewHTTPClientWithConfig(config *HTTPClientConfig) *HTTPClient {
 		config.RetryConfig = DefaultRetryConfig()
 	}
 
+	userAgents := config.UserAgents
+	if len(userAgents) == 0 {
+		userAgents = defaultUserAgents
+	}
+
+	// Connecting and waiting for headers get a third of the request timeout
+	// each, so a longer timeout for a slow link covers every stage
+	stageTimeout := 10 * time.Second
+	if config.Timeout > 0 {
+		stageTimeout = config.Timeout / 3
+	}
+
 	transport := &http.Transport{
 		DialContext: (&net.Dialer{
-			Timeout:   10 * time.Second,
+			Timeout:   stageTimeout,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: 10 * time.Second,
+		TLSHandshakeTimeout:   stageTimeout,
+		ResponseHeaderTimeout: stageTimeout,
 		ExpectContinueTimeout: 1 * time.Second,
 		MaxIdleConns:          100,
 		MaxIdleConnsPerHost:   10,
@@ -105,8 +147,13 @@ func NewHTTPClientWithConfig(config *HTTPClientConfig) *HTTPClient {
 		}
 	}
 
+	// The jar keeps the cookies of every response, so the session cookies Terabox
+	// updates with Set-Cookie carry over to later requests
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+
 	client := &http.Client{
 		Transport: transport,
+		Jar:       jar,
 		Timeout:   config.Timeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// Allow up to 10 redirects
@@ -119,9 +166,10 @@ func NewHTTPClientWithConfig(config *HTTPClientConfig) *HTTPClient {
 
 	return &HTTPClient{
 		client:      client,
-		userAgents:  make([]string, len(defaultUserAgents)),
-		userAgent:   defaultUserAgents[0],
+		userAgents:  append([]string(nil), userAgents...),
+		userAgent:   userAgents[0],
 		retryConfig: config.RetryConfig,
+		cookies:     make(map[*http.Cookie]bool),
 	}
 }
 
@@ -158,8 +206,13 @@ func (c *HTTPClient) Get(url string) (*http.Response, error) {
 
 // GetWithHeaders performs a GET request with custom headers and retry logic
 func (c *HTTPClient) GetWithHeaders(url string, headers map[string]string) (*http.Response, error) {
-	return c.executeWithRetry(func() (*http.Response, error) {
-		req, err := http.NewRequest("GET", url, nil)
+	return c.GetWithHeadersContext(context.Background(), url, headers)
+}
+
+// GetWithHeadersContext performs a GET request with custom headers, context and retry logic
+func (c *HTTPClient) GetWithHeadersContext(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
+	return c.executeWithRetryContext(ctx, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create request: %w", err)
 		}
@@ -187,6 +240,37 @@ func (c *HTTPClient) GetWithHeaders(url string, headers map[string]string) (*htt
 	})
 }
 
+// PostFormWithHeaders performs a form-encoded POST request with custom headers and retry logic
+func (c *HTTPClient) PostFormWithHeaders(rawURL string, form url.Values, headers map[string]string) (*http.Response, error) {
+	return c.PostFormWithContext(context.Background(), rawURL, form, headers)
+}
+
+// PostFormWithContext performs a form-encoded POST request with custom headers, context and retry logic
+func (c *HTTPClient) PostFormWithContext(ctx context.Context, rawURL string, form url.Values, headers map[string]string) (*http.Response, error) {
+	return c.executeWithRetryContext(ctx, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", rawURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		// Set User-Agent
+		c.mutex.RLock()
+		req.Header.Set("User-Agent", c.userAgent)
+		c.mutex.RUnlock()
+
+		// Set custom headers
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
+		req.Header.Set("Accept", "application/json, text/plain, */*")
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+		return c.client.Do(req)
+	})
+}
+
 // GetWithContext performs a GET request with context and retry logic
 func (c *HTTPClient) GetWithContext(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
 	return c.executeWithRetryContext(ctx, func() (*http.Response, error) {
@@ -215,13 +299,52 @@ func (c *HTTPClient) GetWithContext(ctx context.Context, url string, headers map
 	})
 }
 
+// AddCookies adds cookies to the client's jar, so that every request to a host
+// they cover carries them. Cookies are stored for their own domain and, when
+// they have none or it doesn't cover apiURL, for apiURL's host as well. Each
+// cookie is only added once, so the values a server has since sent with
+// Set-Cookie are not overwritten by the originals.
+func (c *HTTPClient) AddCookies(apiURL *url.URL, cookies []*http.Cookie) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, cookie := range cookies {
+		if cookie == nil || c.cookies[cookie] {
+			continue
+		}
+		c.cookies[cookie] = true
+
+		domain := strings.TrimPrefix(cookie.Domain, ".")
+		if domain != "" {
+			c.client.Jar.SetCookies(&url.URL{Scheme: "https", Host: domain, Path: "/"}, []*http.Cookie{cookie})
+		}
+
+		host := apiURL.Hostname()
+		if domain == "" || (host != domain && !strings.HasSuffix(host, "."+domain)) {
+			hostOnly := *cookie
+			hostOnly.Domain = ""
+			c.client.Jar.SetCookies(apiURL, []*http.Cookie{&hostOnly})
+		}
+	}
+}
+
+// Cookies returns the cookies the client would send to u
+func (c *HTTPClient) Cookies(u *url.URL) []*http.Cookie {
+	return c.client.Jar.Cookies(u)
+}
+
 // RotateUserAgent rotates to the next user agent string
 func (c *HTTPClient) RotateUserAgent() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.userAgentIdx = (c.userAgentIdx + 1) % len(defaultUserAgents)
-	c.userAgent = defaultUserAgents[c.userAgentIdx]
+	c.userAgentIdx = (c.userAgentIdx + 1) % len(c.userAgents)
+	c.userAgent = c.userAgents[c.userAgentIdx]
+}
+
+// RetryConfig returns the client's retry policy
+func (c *HTTPClient) RetryConfig() *RetryConfig {
+	return c.retryConfig
 }
 
 // GetCurrentUserAgent returns the current user agent string
@@ -322,24 +445,7 @@ func (c *HTTPClient) executeWithRetryContext(ctx context.Context, fn func() (*ht
 
 // calculateDelay calculates the delay for the next retry attempt
 func (c *HTTPClient) calculateDelay(attempt int) time.Duration {
-	// Exponential backoff: baseDelay * multiplier^(attempt-1)
-	delay := float64(c.retryConfig.BaseDelay) * math.Pow(c.retryConfig.Multiplier, float64(attempt-1))
-	
-	// Apply jitter (random variation)
-	jitter := delay * c.retryConfig.JitterPercent * (rand.Float64()*2 - 1) // -jitterPercent to +jitterPercent
-	delay += jitter
-	
-	// Ensure delay doesn't exceed maximum
-	if delay > float64(c.retryConfig.MaxDelay) {
-		delay = float64(c.retryConfig.MaxDelay)
-	}
-	
-	// Ensure delay is not negative
-	if delay < 0 {
-		delay = float64(c.retryConfig.BaseDelay)
-	}
-	
-	return time.Duration(delay)
+	return c.retryConfig.Delay(attempt)
 }
 
 // isRetryableError determines if an error should trigger a retry
@@ -4,9 +4,12 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
+
+	"terafetch/internal"
 )
 
 func TestNewHTTPClient(t *testing.T) {
@@ -50,6 +53,93 @@ func TestNewHTTPClientWithConfig(t *testing.T) {
 	}
 }
 
+func TestNewHTTPClientFromConfig(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("User-Agent"))
+		if len(seen) == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	
+	config := internal.DefaultConfig()
+	config.DefaultTimeout = 90
+	config.MaxRetries = 0
+	config.RetryDelay = 0
+	config.UserAgentList = []string{"AgentA/1.0", "AgentB/1.0"}
+	
+	client := NewHTTPClientFromConfig(config, "")
+	if client.client.Timeout != 90*time.Second {
+		t.Errorf("Expected timeout of 90s, got %v", client.client.Timeout)
+	}
+	if timeout := client.client.Transport.(*http.Transport).ResponseHeaderTimeout; timeout != 30*time.Second {
+		t.Errorf("Expected a response header timeout of 30s, got %v", timeout)
+	}
+	
+	// Without retries the first 403 is final
+	if _, err := client.Get(server.URL); err == nil {
+		t.Fatal("Expected the request to fail without retries")
+	}
+	
+	// One retry rotates to the next configured user agent
+	seen = nil
+	config.MaxRetries = 1
+	client = NewHTTPClientFromConfig(config, "")
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request should have succeeded after a retry: %v", err)
+	}
+	resp.Body.Close()
+	
+	if len(seen) != 2 || seen[0] != "AgentA/1.0" || seen[1] != "AgentB/1.0" {
+		t.Errorf("Expected the configured user agents in order, got %v", seen)
+	}
+	if client.RetryConfig().MaxAttempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", client.RetryConfig().MaxAttempts)
+	}
+}
+
+func TestHTTPClientAddCookies(t *testing.T) {
+	client := NewHTTPClient()
+	apiURL, _ := url.Parse("https://www.terabox.com")
+	dlinkURL, _ := url.Parse("https://d.terabox.com/file/abc")
+	otherURL, _ := url.Parse("https://www.1024tera.com")
+	
+	session := &http.Cookie{Name: "BDUSS", Value: "bduss", Domain: ".terabox.com", Path: "/"}
+	foreign := &http.Cookie{Name: "ndus", Value: "ndus", Domain: ".1024tera.com", Path: "/"}
+	client.AddCookies(apiURL, []*http.Cookie{session, foreign})
+	
+	names := func(u *url.URL) map[string]string {
+		values := make(map[string]string)
+		for _, cookie := range client.Cookies(u) {
+			values[cookie.Name] = cookie.Value
+		}
+		return values
+	}
+	
+	// Domain cookies follow their domain to download hosts, and the API host
+	// gets every cookie
+	if got := names(dlinkURL); len(got) != 1 || got["BDUSS"] != "bduss" {
+		t.Errorf("Expected only the terabox.com cookie on the download host, got %v", got)
+	}
+	if got := names(apiURL); len(got) != 2 {
+		t.Errorf("Expected both cookies on the API host, got %v", got)
+	}
+	if got := names(otherURL); got["ndus"] != "ndus" || got["BDUSS"] != "" {
+		t.Errorf("Expected only the 1024tera.com cookie on its own domain, got %v", got)
+	}
+	
+	// Adding the same cookie again doesn't undo a Set-Cookie update
+	client.client.Jar.SetCookies(apiURL, []*http.Cookie{{Name: "BDUSS", Value: "updated", Domain: ".terabox.com", Path: "/"}})
+	client.AddCookies(apiURL, []*http.Cookie{session})
+	if got := names(dlinkURL); got["BDUSS"] != "updated" {
+		t.Errorf("Expected the updated cookie to be kept, got %v", got)
+	}
+}
+
 func TestUserAgentRotation(t *testing.T) {
 	client := NewHTTPClient()
 	
@@ -150,6 +240,39 @@ func TestHTTPClientGetWithHeaders(t *testing.T) {
 	}
 }
 
+func TestHTTPClientPostFormWithHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		if r.Header.Get("Referer") != "https://terabox.com/" {
+			t.Errorf("Expected Referer header to be forwarded, got %q", r.Header.Get("Referer"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("Failed to parse form: %v", err)
+		}
+		if r.PostForm.Get("pwd") != "x7k2" {
+			t.Errorf("Expected pwd=x7k2 in form body, got %q", r.PostForm.Get("pwd"))
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient()
+	form := url.Values{}
+	form.Set("pwd", "x7k2")
+	resp, err := client.PostFormWithHeaders(server.URL, form, map[string]string{"Referer": "https://terabox.com/"})
+	if err != nil {
+		t.Fatalf("POST request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+}
+
 func TestHTTPClientRetryLogic(t *testing.T) {
 	attempts := 0
 	
@@ -12,6 +12,7 @@ import (
 type ProgressTracker struct {
 	bar       *pb.ProgressBar
 	quiet     bool
+	paused    bool
 	startTime time.Time
 	total     int64
 	current   int64
@@ -22,15 +23,21 @@ type ProgressTracker struct {
 	lastBytes      int64
 	speedSamples   []float64
 	maxSamples     int
+
+	// Machine-readable progress events, emitted at most once per progressEventInterval
+	events          *EventWriter
+	eventFile       string
+	lastEvent       time.Time
+	lastEventBytes  int64
 }
 
 // DownloadSummary contains final download statistics
 type DownloadSummary struct {
-	TotalBytes    int64
-	TotalTime     time.Duration
-	AverageSpeed  float64 // bytes per second
-	PeakSpeed     float64 // bytes per second
-	Filename      string
+	TotalBytes    int64         `json:"total_bytes"`
+	TotalTime     time.Duration `json:"total_time_ns"`
+	AverageSpeed  float64       `json:"average_speed"` // bytes per second
+	PeakSpeed     float64       `json:"peak_speed"`    // bytes per second
+	Filename      string        `json:"filename,omitempty"`
 }
 
 // NewProgressTracker creates a new progress tracker with enhanced statistics
@@ -66,41 +73,104 @@ func (p *ProgressTracker) Update(current int64) {
 	
 	now := time.Now()
 	p.current = current
+	p.emitProgress(now)
 	
-	// Calculate current speed
 	if p.bar != nil {
 		p.bar.SetCurrent(current)
-		
-		// Update speed calculation
-		timeDiff := now.Sub(p.lastUpdate).Seconds()
-		if timeDiff > 0.1 { // Update speed every 100ms to avoid too frequent updates
-			bytesDiff := current - p.lastBytes
-			currentSpeed := float64(bytesDiff) / timeDiff
-			
-			// Add to speed samples for smoothing
-			p.speedSamples = append(p.speedSamples, currentSpeed)
-			if len(p.speedSamples) > p.maxSamples {
-				p.speedSamples = p.speedSamples[1:]
-			}
-			
+	}
+
+	// Update speed calculation; speed is tracked in quiet mode too, for
+	// GetCurrentStats
+	timeDiff := now.Sub(p.lastUpdate).Seconds()
+	if timeDiff > 0.1 { // Update speed every 100ms to avoid too frequent updates
+		bytesDiff := current - p.lastBytes
+		currentSpeed := float64(bytesDiff) / timeDiff
+
+		// Add to speed samples for smoothing
+		p.speedSamples = append(p.speedSamples, currentSpeed)
+		if len(p.speedSamples) > p.maxSamples {
+			p.speedSamples = p.speedSamples[1:]
+		}
+
+		if p.bar != nil {
 			// Calculate smoothed speed
 			var avgSpeed float64
 			for _, speed := range p.speedSamples {
 				avgSpeed += speed
 			}
-			if len(p.speedSamples) > 0 {
-				avgSpeed /= float64(len(p.speedSamples))
-			}
-			
+			avgSpeed /= float64(len(p.speedSamples))
+
 			// Update progress bar with current speed
 			p.bar.Set(pb.Static, fmt.Sprintf("%.2f MB/s", avgSpeed/(1024*1024)))
-			
-			p.lastUpdate = now
-			p.lastBytes = current
 		}
+
+		p.lastUpdate = now
+		p.lastBytes = current
 	}
 }
 
+// SetPaused switches the progress bar's label between downloading and paused
+func (p *ProgressTracker) SetPaused(paused bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.bar == nil || p.paused == paused {
+		return
+	}
+	p.paused = paused
+	if paused {
+		p.bar.Set("prefix", "Paused: ")
+	} else {
+		p.bar.Set("prefix", "Downloading: ")
+	}
+}
+
+// SetEvents makes the tracker emit periodic progress events for file
+func (p *ProgressTracker) SetEvents(events *EventWriter, file string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.events = events
+	p.eventFile = file
+	p.lastEvent = time.Now()
+	p.lastEventBytes = p.current
+}
+
+// emitProgress emits a progress event if the event interval has passed; the
+// caller holds the mutex
+func (p *ProgressTracker) emitProgress(now time.Time) {
+	if p.events == nil {
+		return
+	}
+
+	// The final update is always reported, once
+	finished := p.current >= p.total && p.lastEventBytes < p.total
+	elapsed := now.Sub(p.lastEvent)
+	if elapsed < progressEventInterval && !finished {
+		return
+	}
+
+	var percent, speed float64
+	if p.total > 0 {
+		percent = float64(p.current) / float64(p.total) * 100
+	}
+	if elapsed > 0 {
+		speed = float64(p.current-p.lastEventBytes) / elapsed.Seconds()
+	}
+	p.events.Emit(Event{
+		Type:       EventProgress,
+		Time:       now,
+		File:       p.eventFile,
+		Downloaded: p.current,
+		Total:      p.total,
+		Percent:    percent,
+		Speed:      speed,
+	})
+
+	p.lastEvent = now
+	p.lastEventBytes = p.current
+}
+
 // Finish completes the progress bar and returns download summary
 func (p *ProgressTracker) Finish() *DownloadSummary {
 	p.mutex.Lock()
@@ -195,6 +265,13 @@ func (p *ProgressTracker) GetCurrentStats() (speed float64, eta time.Duration, p
 	return currentSpeed, etaTime, percent
 }
 
+// GetProgress returns the bytes downloaded so far and the total size
+func (p *ProgressTracker) GetProgress() (current, total int64) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.current, p.total
+}
+
 // IsQuiet returns whether the tracker is in quiet mode
 func (p *ProgressTracker) IsQuiet() bool {
 	return p.quiet
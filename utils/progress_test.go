@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"testing"
 	"time"
 )
@@ -116,4 +117,30 @@ func TestProgressTracker_NonQuietMode(t *testing.T) {
 	if summary == nil {
 		t.Error("Expected summary to be returned")
 	}
-}
\ No newline at end of file
+}
+
+func TestProgressTracker_Events(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewProgressTracker(1000, true)
+	tracker.SetEvents(NewEventWriter(&buf), "movie.mkv")
+
+	// Updates within the event interval are coalesced, but the final one is always reported
+	tracker.Update(250)
+	tracker.Update(500)
+	tracker.Update(1000)
+	tracker.Update(1000)
+	tracker.Finish()
+
+	events := decodeEvents(t, buf.String())
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 progress event, got %d: %s", len(events), buf.String())
+	}
+
+	event := events[0]
+	if event.Type != EventProgress || event.File != "movie.mkv" {
+		t.Errorf("Unexpected progress event: %+v", event)
+	}
+	if event.Downloaded != 1000 || event.Total != 1000 || event.Percent != 100 {
+		t.Errorf("Expected complete progress, got %+v", event)
+	}
+}
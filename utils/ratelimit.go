@@ -73,13 +73,14 @@ func NewDistributedRateLimiter(bytesPerSecond int64, threadCount int) internal.R
 
 // Wait blocks until the specified number of bytes can be consumed
 func (r *TokenBucketLimiter) Wait(ctx context.Context, n int) error {
-	if r.rate <= 0 {
-		return nil // No rate limiting
-	}
-
 	startTime := time.Now()
 	
+	// The rate is read under the lock since SetRate may change it mid-download
 	r.mutex.Lock()
+	if r.rate <= 0 {
+		r.mutex.Unlock()
+		return nil // No rate limiting
+	}
 	
 	// Refill tokens based on elapsed time
 	now := time.Now()
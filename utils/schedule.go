@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"terafetch/internal"
+)
+
+const minutesPerDay = 24 * 60
+
+// RateSchedule is a daily bandwidth schedule made of time-of-day windows, each
+// with its own rate. Windows may wrap past midnight; outside every window the
+// caller's fallback rate applies.
+type RateSchedule struct {
+	windows []rateWindow
+}
+
+// rateWindow limits the bandwidth from start until end, in minutes since midnight
+type rateWindow struct {
+	start, end int
+	rate       int64 // Bytes per second, 0 for unlimited
+}
+
+// contains reports whether the window covers the given minute of the day. A
+// window that starts and ends at the same time covers the whole day.
+func (w rateWindow) contains(minute int) bool {
+	switch {
+	case w.start < w.end:
+		return minute >= w.start && minute < w.end
+	case w.start > w.end:
+		return minute >= w.start || minute < w.end
+	default:
+		return true
+	}
+}
+
+// ParseRateSchedule parses a schedule such as "08:00-18:00=2M,18:00-08:00=0".
+// Rates use the ParseRateLimit format and 0 means unlimited. Windows must not
+// overlap.
+func ParseRateSchedule(spec string) (*RateSchedule, error) {
+	schedule := &RateSchedule{}
+	var covered [minutesPerDay]bool
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		span, rateStr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q, expected HH:MM-HH:MM=RATE", entry)
+		}
+		startStr, endStr, ok := strings.Cut(span, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid schedule entry %q, expected HH:MM-HH:MM=RATE", entry)
+		}
+
+		var window rateWindow
+		var err error
+		if window.start, err = parseTimeOfDay(startStr); err != nil {
+			return nil, fmt.Errorf("invalid start of %q: %w", entry, err)
+		}
+		if window.end, err = parseTimeOfDay(endStr); err != nil {
+			return nil, fmt.Errorf("invalid end of %q: %w", entry, err)
+		}
+		if strings.TrimSpace(rateStr) == "" {
+			return nil, fmt.Errorf("missing rate in %q, use 0 for unlimited", entry)
+		}
+		if window.rate, err = ParseRateLimit(rateStr); err != nil {
+			return nil, fmt.Errorf("invalid rate of %q: %w", entry, err)
+		}
+
+		for minute := 0; minute < minutesPerDay; minute++ {
+			if !window.contains(minute) {
+				continue
+			}
+			if covered[minute] {
+				return nil, fmt.Errorf("schedule entry %q overlaps an earlier one at %02d:%02d", entry, minute/60, minute%60)
+			}
+			covered[minute] = true
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+
+	if len(schedule.windows) == 0 {
+		return nil, fmt.Errorf("rate schedule is empty")
+	}
+	return schedule, nil
+}
+
+// parseTimeOfDay parses HH:MM into minutes since midnight. 24:00 is midnight.
+func parseTimeOfDay(value string) (int, error) {
+	hourStr, minuteStr, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	minute, err := strconv.Atoi(minuteStr)
+	if err != nil || len(minuteStr) != 2 {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	if hour == 24 && minute == 0 {
+		return 0, nil
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("time out of range: %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// RateAt returns the rate in effect at t, or fallback when no window covers t
+func (s *RateSchedule) RateAt(t time.Time, fallback int64) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range s.windows {
+		if window.contains(minute) {
+			return window.rate
+		}
+	}
+	return fallback
+}
+
+// NextChange returns the first window boundary after t
+func (s *RateSchedule) NextChange(t time.Time) time.Time {
+	var next time.Time
+	for _, window := range s.windows {
+		for _, minute := range []int{window.start, window.end} {
+			for days := 0; days <= 1; days++ {
+				boundary := time.Date(t.Year(), t.Month(), t.Day()+days, minute/60, minute%60, 0, 0, t.Location())
+				if boundary.After(t) && (next.IsZero() || boundary.Before(next)) {
+					next = boundary
+				}
+			}
+		}
+	}
+	return next
+}
+
+// Run applies the rate in effect now and again at every window boundary until
+// ctx is cancelled. fallback is the rate outside every window. The schedule is
+// also rechecked every minute, so a suspended machine or a clock change is
+// picked up promptly.
+func (s *RateSchedule) Run(ctx context.Context, fallback int64, apply func(bytesPerSecond int64)) {
+	current := s.RateAt(time.Now(), fallback)
+	apply(current)
+
+	for {
+		wait := time.Until(s.NextChange(time.Now()))
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if rate := s.RateAt(time.Now(), fallback); rate != current {
+			if rate > 0 {
+				internal.LogInfo("Rate schedule: bandwidth limit changed to %s/s", formatRate(rate))
+			} else {
+				internal.LogInfo("Rate schedule: bandwidth limit lifted")
+			}
+			apply(rate)
+			current = rate
+		}
+	}
+}
+
+// String formats the schedule the way ParseRateSchedule reads it
+func (s *RateSchedule) String() string {
+	entries := make([]string, len(s.windows))
+	for i, window := range s.windows {
+		entries[i] = fmt.Sprintf("%02d:%02d-%02d:%02d=%s", window.start/60, window.start%60,
+			window.end/60, window.end%60, formatRate(window.rate))
+	}
+	return strings.Join(entries, ",")
+}
+
+// formatRate formats a rate in bytes per second for schedules and logs
+func formatRate(bytesPerSecond int64) string {
+	if bytesPerSecond <= 0 {
+		return "0"
+	}
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"G", 1024 * 1024 * 1024}, {"M", 1024 * 1024}, {"K", 1024}} {
+		if bytesPerSecond%unit.size == 0 {
+			return fmt.Sprintf("%d%s", bytesPerSecond/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(bytesPerSecond, 10)
+}
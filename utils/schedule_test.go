@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestParseRateSchedule tests schedule parsing and validation
+func TestParseRateSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"08:00-18:00=2M,18:00-08:00=0", "08:00-18:00=2M,18:00-08:00=0", false},
+		{" 09:30-12:00 = 512K , 13:00-17:00=1.5M ", "09:30-12:00=512K,13:00-17:00=1536K", false},
+		{"22:00-24:00=100", "22:00-00:00=100", false},
+		{"00:00-00:00=1G", "00:00-00:00=1G", false},
+		{"", "", true},
+		{"08:00-18:00", "", true},
+		{"08:00=2M", "", true},
+		{"08:00-18:00=", "", true},
+		{"8-18:00=2M", "", true},
+		{"25:00-18:00=2M", "", true},
+		{"08:60-18:00=2M", "", true},
+		{"08:00-18:00=fast", "", true},
+		{"08:00-18:00=2M,17:00-20:00=1M", "", true},
+		{"20:00-06:00=2M,05:00-07:00=1M", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseRateSchedule(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q, got %s", tt.spec, schedule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := schedule.String(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestRateSchedule_RateAt tests which window applies at a given time
+func TestRateSchedule_RateAt(t *testing.T) {
+	schedule, err := ParseRateSchedule("08:00-18:00=2M,22:00-02:00=1K")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule: %v", err)
+	}
+
+	const fallback = 5000
+	tests := []struct {
+		clock string
+		want  int64
+	}{
+		{"07:59", fallback},
+		{"08:00", 2 * 1024 * 1024},
+		{"17:59", 2 * 1024 * 1024},
+		{"18:00", fallback},
+		{"23:30", 1024},
+		{"00:00", 1024},
+		{"01:59", 1024},
+		{"02:00", fallback},
+	}
+
+	for _, tt := range tests {
+		at, _ := time.Parse("15:04", tt.clock)
+		if got := schedule.RateAt(at, fallback); got != tt.want {
+			t.Errorf("At %s: expected %d, got %d", tt.clock, tt.want, got)
+		}
+	}
+}
+
+// TestRateSchedule_NextChange tests finding the next window boundary
+func TestRateSchedule_NextChange(t *testing.T) {
+	schedule, err := ParseRateSchedule("08:00-18:00=2M,18:00-08:00=0")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule: %v", err)
+	}
+
+	day := func(d, hour, minute int) time.Time {
+		return time.Date(2025, time.October, d, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		at, want time.Time
+	}{
+		{day(7, 7, 30), day(7, 8, 0)},
+		{day(7, 8, 0), day(7, 18, 0)},
+		{day(7, 12, 0), day(7, 18, 0)},
+		{day(7, 23, 0), day(8, 8, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := schedule.NextChange(tt.at); !got.Equal(tt.want) {
+			t.Errorf("After %s: expected %s, got %s", tt.at, tt.want, got)
+		}
+	}
+}
+
+// TestRateSchedule_Run tests that the current rate is applied on start
+func TestRateSchedule_Run(t *testing.T) {
+	schedule, err := ParseRateSchedule("00:00-00:00=3M")
+	if err != nil {
+		t.Fatalf("Failed to parse schedule: %v", err)
+	}
+
+	limiter := NewTokenBucketLimiter(0).(*TokenBucketLimiter)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		schedule.Run(ctx, 0, limiter.SetRate)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		limiter.mutex.Lock()
+		rate := limiter.rate
+		limiter.mutex.Unlock()
+		if rate == 3*1024*1024 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the scheduled rate to be applied, limiter has %d", rate)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop when its context was cancelled")
+	}
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"net/url"
 	"regexp"
 	"strings"
@@ -15,40 +17,34 @@ type URLInfo struct {
 	Domain      string
 	Surl        string
 	ShareID     string
+	Password    string // Share extraction code from the pwd query parameter
 	IsPrivate   bool
 }
 
+// sharePathPattern matches the /s/ share path on hosts the URL patterns
+// don't know, which are only accepted when they are in allowed_domains
+var sharePathPattern = regexp.MustCompile(`^https?://[^/?#]+/s/([a-zA-Z0-9_-]+)(?:\?.*)?$`)
+
 // URLValidator handles URL validation and parsing for Terabox links
 type URLValidator struct {
 	allowedDomains []string
 	urlPatterns    []*regexp.Regexp
 }
 
-// NewURLValidator creates a new URL validator with predefined patterns
+// NewURLValidator creates a new URL validator with predefined patterns,
+// accepting the default Terabox domains
 func NewURLValidator() *URLValidator {
-	allowedDomains := []string{
-		"terabox.com",
-		"www.terabox.com",
-		"terabox.app",
-		"www.terabox.app",
-		"1024terabox.com",
-		"www.1024terabox.com",
-		"dm.terabox.com",
-		"v1.terabox.com",
-		"d.terabox.com",
-		"data.terabox.com",
-		"v2.terabox.com",
-		"t.terabox.com",
-		"autodiscover.terabox.com",
-		"blog.terabox.com",
-		"us.terabox.com",
-		"jp.terabox.com",
-		"ca.terabox.com",
-		"email.terabox.com",
-		"s.terabox.com",
-		"op.terabox.com",
-		"pan.baidu.com",
-		"www.pan.baidu.com",
+	return NewURLValidatorWithDomains(internal.DefaultAllowedDomains)
+}
+
+// NewURLValidatorWithDomains creates a new URL validator accepting URLs from
+// the given hosts, such as the allowed_domains setting
+func NewURLValidatorWithDomains(domains []string) *URLValidator {
+	allowedDomains := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			allowedDomains = append(allowedDomains, domain)
+		}
 	}
 
 	// Regex patterns for different Terabox URL formats
@@ -109,7 +105,7 @@ func (v *URLValidator) ValidateURL(rawURL string) error {
 
 	return internal.NewTeraboxError(
 		0,
-		fmt.Sprintf("URL must be from terabox.com or pan.baidu.com domains, got: %s", host),
+		fmt.Sprintf("URL must be from an allowed domain such as terabox.com or pan.baidu.com, got: %s", host),
 		internal.ErrInvalidURL,
 	)
 }
@@ -154,6 +150,13 @@ func (v *URLValidator) ParseURL(rawURL string) (*URLInfo, error) {
 
 	// If no pattern matched, try to extract from query parameters
 	v.extractQueryParams(parsedURL, urlInfo)
+
+	// Other allowed domains may still use the standard share path
+	if urlInfo.Surl == "" && urlInfo.ShareID == "" {
+		if matches := sharePathPattern.FindStringSubmatch(rawURL); matches != nil {
+			urlInfo.Surl = matches[1]
+		}
+	}
 	
 	// Validate that we extracted at least one identifier
 	if urlInfo.Surl == "" && urlInfo.ShareID == "" {
@@ -188,6 +191,11 @@ func (v *URLValidator) extractQueryParams(parsedURL *url.URL, urlInfo *URLInfo)
 		urlInfo.ShareID = shareid
 	}
 	
+	// Extract share password (extraction code) parameter
+	if pwd := query.Get("pwd"); pwd != "" {
+		urlInfo.Password = pwd
+	}
+	
 	// Check for path parameter (indicates private file)
 	if path := query.Get("path"); path != "" && path != "/" {
 		urlInfo.IsPrivate = true
@@ -231,4 +239,30 @@ func (urlInfo *URLInfo) GetIdentifier() string {
 func (urlInfo *URLInfo) String() string {
 	return fmt.Sprintf("URLInfo{Domain: %s, Surl: %s, ShareID: %s, IsPrivate: %t}", 
 		urlInfo.Domain, urlInfo.Surl, urlInfo.ShareID, urlInfo.IsPrivate)
-}
\ No newline at end of file
+}
+
+// ReadURLList reads share URLs from r, one per line. Blank lines and lines
+// starting with # are skipped, and repeated URLs are only returned once.
+func ReadURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		urls = append(urls, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading URL list: %w", err)
+	}
+
+	return urls, nil
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 
 	"terafetch/internal"
@@ -628,6 +629,28 @@ func TestURLValidator_DomainValidation(t *testing.T) {
 	}
 }
 
+func TestURLValidator_AllowedDomains(t *testing.T) {
+	validator := NewURLValidatorWithDomains([]string{"TeraboxLink.com ", "terabox.com"})
+
+	info, err := validator.ParseURL("https://teraboxlink.com/s/1AbC123?pwd=ab12")
+	if err != nil {
+		t.Fatalf("expected a configured domain to be accepted, got %v", err)
+	}
+	if info.Surl != "1AbC123" || info.Password != "ab12" {
+		t.Errorf("expected surl 1AbC123 and password ab12, got %s", info)
+	}
+	if _, err := validator.ParseURL("https://terabox.com/s/1AbC123"); err != nil {
+		t.Errorf("expected terabox.com to be accepted, got %v", err)
+	}
+
+	// Domains left out of the list are rejected, even default ones
+	for _, rawURL := range []string{"https://pan.baidu.com/s/1AbC123", "https://teraboxlink.com.evil.com/s/1AbC123"} {
+		if _, err := validator.ParseURL(rawURL); err == nil {
+			t.Errorf("expected %s to be rejected", rawURL)
+		}
+	}
+}
+
 // TestURLValidator_ParameterExtraction tests parameter extraction edge cases
 func TestURLValidator_ParameterExtraction(t *testing.T) {
 	validator := NewURLValidator()
@@ -638,6 +661,7 @@ func TestURLValidator_ParameterExtraction(t *testing.T) {
 		expectedSurl    string
 		expectedShareID string
 		expectedPrivate bool
+		expectedPwd     string
 		description     string
 	}{
 		{
@@ -681,6 +705,21 @@ func TestURLValidator_ParameterExtraction(t *testing.T) {
 			expectedPrivate: true,
 			description:     "Nested path should mark as private",
 		},
+		{
+			name:         "pwd_parameter_extracted",
+			url:          "https://terabox.com/s/1AbC123?pwd=x7k2",
+			expectedSurl: "1AbC123",
+			expectedPwd:  "x7k2",
+			description:  "Share password is taken from the pwd parameter",
+		},
+		{
+			name:            "pwd_does_not_make_private",
+			url:             "https://terabox.com/sharing/link?surl=AbC123&pwd=abcd",
+			expectedSurl:    "AbC123",
+			expectedPrivate: false,
+			expectedPwd:     "abcd",
+			description:     "Password-protected shares are still public shares",
+		},
 	}
 
 	for _, tt := range tests {
@@ -702,6 +741,43 @@ func TestURLValidator_ParameterExtraction(t *testing.T) {
 			if urlInfo.IsPrivate != tt.expectedPrivate {
 				t.Errorf("expected IsPrivate %t, got %t for %s", tt.expectedPrivate, urlInfo.IsPrivate, tt.description)
 			}
+
+			if urlInfo.Password != tt.expectedPwd {
+				t.Errorf("expected password '%s', got '%s' for %s", tt.expectedPwd, urlInfo.Password, tt.description)
+			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestReadURLList(t *testing.T) {
+	input := `# episodes to fetch
+https://terabox.com/s/1AbC123
+
+  https://terabox.com/s/2DeF456  
+# https://terabox.com/s/commented
+https://terabox.com/s/1AbC123
+`
+
+	urls, err := ReadURLList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadURLList failed: %v", err)
+	}
+
+	expected := []string{"https://terabox.com/s/1AbC123", "https://terabox.com/s/2DeF456"}
+	if len(urls) != len(expected) {
+		t.Fatalf("expected %d URLs, got %d: %v", len(expected), len(urls), urls)
+	}
+	for i := range expected {
+		if urls[i] != expected[i] {
+			t.Errorf("URL %d: expected %q, got %q", i, expected[i], urls[i])
+		}
+	}
+
+	urls, err = ReadURLList(strings.NewReader("\n# nothing here\n"))
+	if err != nil {
+		t.Fatalf("ReadURLList failed on empty list: %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("expected no URLs, got %v", urls)
+	}
+}